@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tiny-pastebin/internal/apikey"
+	"tiny-pastebin/internal/storage"
+)
+
+const apikeyUsage = `usage: tinypaste apikey <command> [flags]
+
+commands:
+  create -name NAME -scope SCOPE[,SCOPE] [-rate N] [-burst N]
+  list
+  revoke ID`
+
+// runAPIKey implements the "apikey" subcommand for managing API keys
+// without going through the admin dashboard.
+func runAPIKey(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(apikeyUsage)
+	}
+	cmd, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("apikey "+cmd, flag.ContinueOnError)
+	dataPath := fs.String("data", "./tiny-paste.db", "data file path or backend DSN: bolt:///path.db, sqlite:///path.db, redis://host, s3://bucket/prefix or memory://")
+	name := fs.String("name", "", "key name (create)")
+	var scopes []string
+	fs.Func("scope", "comma-separated scopes: "+strings.Join(apikey.Scopes, ", ")+" (create, repeatable)", func(v string) error {
+		scopes = append(scopes, splitList(v)...)
+		return nil
+	})
+	rateLimit := fs.Float64("rate", 0, "requests per second, 0 for the server default (create)")
+	burst := fs.Int("burst", 0, "burst size, 0 for the server default (create)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openStore(*dataPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	keys, ok := storage.As[storage.APIKeyStore](store)
+	if !ok {
+		return errors.New("data store does not support api keys")
+	}
+	ctx := context.Background()
+
+	switch cmd {
+	case "create":
+		if *name == "" {
+			return errors.New("-name is required")
+		}
+		key, token, err := apikey.New(*name, scopes, *rateLimit, *burst, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := keys.SaveAPIKey(ctx, key); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "created key %s\n%s\n", key.ID, token)
+	case "list":
+		list, err := keys.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tRATE\tBURST\tCREATED")
+		for _, key := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%g\t%d\t%s\n", key.ID, key.Name, strings.Join(key.Scopes, ","), key.RateLimit, key.Burst, key.CreatedAt.Format(time.RFC3339))
+		}
+		return tw.Flush()
+	case "revoke":
+		if fs.NArg() != 1 {
+			return errors.New("usage: tinypaste apikey revoke ID")
+		}
+		if err := keys.DeleteAPIKey(ctx, fs.Arg(0)); err != nil {
+			return fmt.Errorf("revoke %s: %w", fs.Arg(0), err)
+		}
+		fmt.Fprintf(stdout, "revoked key %s\n", fs.Arg(0))
+	default:
+		return errors.New(apikeyUsage)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// runExport implements "tinypaste export", writing a backup of the data
+// store while the server is stopped. See storage.Export for the format.
+func runExport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	dataPath := fs.String("data", "./tiny-paste.db", "data file path or backend DSN to back up")
+	out := fs.String("o", "", "backup file to write, gzipped if it ends in .gz, or - for stdout")
+	sinceFlag := fs.String("since", "", "cursor of an earlier export; only pastes saved after it are written")
+	cursorFile := fs.String("cursor-file", "", "file holding the cursor to continue from, rewritten after a successful export")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return errors.New("usage: tinypaste export [-data DSN] [-since CURSOR | -cursor-file FILE] -o backup.jsonl.gz")
+	}
+	cursor := *sinceFlag
+	if cursor == "" && *cursorFile != "" {
+		data, err := os.ReadFile(*cursorFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("read cursor: %w", err)
+		}
+		cursor = strings.TrimSpace(string(data))
+	}
+	var since time.Time
+	if cursor != "" {
+		t, err := storage.ParseCursor(cursor)
+		if err != nil {
+			return err
+		}
+		since = t
+	}
+	now := time.Now()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(*dataPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *out == "-" {
+		if _, err := storage.ExportSince(ctx, stdout, store, since, now); err != nil {
+			return err
+		}
+		return saveCursor(*cursorFile, now)
+	}
+
+	// Write next to the target and rename, so a failed export never leaves
+	// a truncated file under the final name.
+	tmp, err := os.CreateTemp(filepath.Dir(*out), filepath.Base(*out)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create backup: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	var w io.Writer = tmp
+	var zw *gzip.Writer
+	if strings.HasSuffix(*out, ".gz") {
+		zw = gzip.NewWriter(tmp)
+		w = zw
+	}
+	stats, err := storage.ExportSince(ctx, w, store, since, now)
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), *out); err != nil {
+		return fmt.Errorf("write backup: %w", err)
+	}
+	if err := saveCursor(*cursorFile, now); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "exported %d pastes (%d bytes) and %d api keys to %s\n", stats.Pastes, stats.Bytes, stats.APIKeys, *out)
+	fmt.Fprintf(stdout, "next incremental export: -since %s\n", storage.FormatCursor(now))
+	return nil
+}
+
+// saveCursor records where the next incremental export continues from. It
+// runs only after the backup is complete, so a failed export is retried from
+// the old cursor.
+func saveCursor(path string, now time.Time) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(storage.FormatCursor(now)+"\n"), 0o600); err != nil {
+		return fmt.Errorf("write cursor: %w", err)
+	}
+	return nil
+}
+
+// runImport implements "tinypaste import", restoring a backup into the data
+// store while the server is stopped.
+func runImport(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	dataPath := fs.String("data", "./tiny-paste.db", "data file path or backend DSN to restore into")
+	dryRun := fs.Bool("dry-run", false, "validate the backup without writing anything")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: tinypaste import [-data DSN] [-dry-run] backup.jsonl.gz")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var in io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	store, err := openStore(*dataPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	stats, err := storage.Import(ctx, store, in, storage.CopyOptions{
+		DryRun:        *dryRun,
+		ProgressEvery: 1000,
+		Progress: func(s storage.CopyStats) {
+			fmt.Fprintf(stdout, "read %d pastes\n", s.Pastes)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("import after %d pastes: %w", stats.Pastes, err)
+	}
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(stdout, "%s %d pastes (%d bytes) and %d api keys", verb, stats.Pastes, stats.Bytes, stats.APIKeys)
+	if stats.Skipped > 0 {
+		fmt.Fprintf(stdout, ", skipped %d records", stats.Skipped)
+	}
+	fmt.Fprintln(stdout)
+	return nil
+}
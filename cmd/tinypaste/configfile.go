@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the environment variables overriding flags: -log-format
+// is TINYPASTE_LOG_FORMAT.
+const envPrefix = "TINYPASTE_"
+
+// Settings are taken from, in order of precedence: the command line, the
+// TINYPASTE_* environment, the -config file, the -profile, and the flag
+// defaults. Each source only fills in the flags the ones before it left
+// unset, so a repeatable flag is never merged across sources.
+
+// envName is the variable overriding the flag name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv sets the flags with a TINYPASTE_* variable that were not given
+// on the command line.
+func applyEnv(set *flag.FlagSet) error {
+	explicit := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	var err error
+	set.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if !ok || explicit[f.Name] || err != nil {
+			return
+		}
+		if e := set.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", v, envName(f.Name), e)
+		}
+	})
+	return err
+}
+
+// configFile is a parsed -config file.
+type configFile struct {
+	settings []setting
+	tenants  []tenantSection
+}
+
+// loadConfigFile reads a YAML -config file. Keys are flag names, and nested
+// mappings join their keys with dashes, so
+//
+//	log:
+//	  format: json
+//
+// sets -log-format. A list sets a repeatable flag once per item. A tenants
+// mapping of host names to settings configures more sites, like a -tenants
+// file.
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cf := &configFile{}
+	if len(doc.Content) == 0 {
+		return cf, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping of settings", path)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value == "tenants" && value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				host, body := value.Content[j], value.Content[j+1]
+				sec := tenantSection{host: host.Value, where: where(path, host)}
+				if body.Kind != yaml.MappingNode {
+					return nil, fmt.Errorf("%s: expected the settings of %s", sec.where, host.Value)
+				}
+				if sec.settings, err = flatten(path, "", body); err != nil {
+					return nil, err
+				}
+				cf.tenants = append(cf.tenants, sec)
+			}
+			continue
+		}
+		settings, err := flatten(path, key.Value, value)
+		if err != nil {
+			return nil, err
+		}
+		cf.settings = append(cf.settings, settings...)
+	}
+	return cf, nil
+}
+
+// flatten turns node, found under key, into flag settings.
+func flatten(path, key string, node *yaml.Node) ([]setting, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		var out []setting
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			name := node.Content[i].Value
+			if key != "" {
+				name = key + "-" + name
+			}
+			settings, err := flatten(path, name, node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, settings...)
+		}
+		return out, nil
+	case yaml.SequenceNode:
+		out := make([]setting, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("%s: %s: expected a list of values", where(path, item), key)
+			}
+			out = append(out, setting{key: key, value: item.Value, where: where(path, item)})
+		}
+		return out, nil
+	case yaml.ScalarNode:
+		value := node.Value
+		if node.Tag == "!!null" {
+			value = ""
+		}
+		return []setting{{key: key, value: value, where: where(path, node)}}, nil
+	case yaml.AliasNode:
+		return flatten(path, key, node.Alias)
+	}
+	return nil, fmt.Errorf("%s: %s: unsupported value", where(path, node), key)
+}
+
+func where(path string, node *yaml.Node) string {
+	return fmt.Sprintf("%s line %d", path, node.Line)
+}
+
+// apply sets the settings of cf on set, skipping the flags already given on
+// the command line or in the environment.
+func (cf *configFile) apply(set *flag.FlagSet) error {
+	given := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var errs []error
+	for _, st := range cf.settings {
+		switch {
+		case set.Lookup(st.key) == nil:
+			errs = append(errs, fmt.Errorf("%s: unknown flag %q", st.where, st.key))
+		case st.key == "config":
+			errs = append(errs, fmt.Errorf("%s: a config file cannot name another", st.where))
+		case !given[st.key]:
+			if err := set.Set(st.key, st.value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value %q for %s: %w", st.where, st.value, st.key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
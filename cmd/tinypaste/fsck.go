@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/signal"
+	"syscall"
+
+	"tiny-pastebin/internal/storage/boltstore"
+)
+
+// runFsck implements "tinypaste fsck", checking a bolt data file for broken
+// records, indexes and counters while the server is stopped.
+func runFsck(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	dataPath := fs.String("data", "./tiny-paste.db", "bolt data file path or DSN to check")
+	repair := fs.Bool("repair", false, "fix what can be fixed; undecodable records are moved to the lost+found bucket")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: tinypaste fsck [-data DSN] [-repair]")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	store, err := openStore(*dataPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	bs, ok := store.(*boltstore.Store)
+	if !ok {
+		return fmt.Errorf("fsck only checks bolt stores, not %s", *dataPath)
+	}
+
+	report, err := bs.Check(ctx, *repair)
+	if err != nil {
+		return fmt.Errorf("fsck: %w", err)
+	}
+	for _, p := range report.Problems {
+		fmt.Fprintln(stdout, p)
+	}
+	fmt.Fprintf(stdout, "checked %d pastes and %d blobs: %d problems, %d repaired\n",
+		report.Pastes, report.Blobs, len(report.Problems), len(report.Problems)-report.Unrepaired())
+	if n := report.Unrepaired(); n > 0 {
+		if !*repair {
+			return fmt.Errorf("%d problems found; run with -repair to fix them", n)
+		}
+		return fmt.Errorf("%d problems could not be repaired", n)
+	}
+	return nil
+}
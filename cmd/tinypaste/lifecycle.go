@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// component is a running part of the server that must be stopped on
+// shutdown.
+type component struct {
+	name string
+	// timeout bounds stop; a component still stopping after it is logged
+	// and abandoned so the rest can shut down.
+	timeout time.Duration
+	stop    func(ctx context.Context) error
+}
+
+// lifecycle tracks the components of a running server. Components register
+// as they start and are stopped in reverse order, so each one stops before
+// whatever it was started on top of: listeners before the background work
+// their requests queued, and that before the store it writes to.
+type lifecycle struct {
+	logger     *slog.Logger
+	components []component
+	failed     chan error
+}
+
+func newLifecycle(logger *slog.Logger) *lifecycle {
+	return &lifecycle{logger: logger, failed: make(chan error, 1)}
+}
+
+// register adds a component to stop on shutdown, with at most timeout to do
+// so.
+func (l *lifecycle) register(name string, timeout time.Duration, stop func(ctx context.Context) error) {
+	l.components = append(l.components, component{name: name, timeout: timeout, stop: stop})
+}
+
+// fail reports that the named component stopped unexpectedly, which shuts
+// the server down. Only the first failure is kept.
+func (l *lifecycle) fail(name string, err error) {
+	select {
+	case l.failed <- fmt.Errorf("%s: %w", name, err):
+	default:
+	}
+}
+
+// run blocks until ctx is done or a component fails, then stops every
+// component. It returns the failure, if any.
+func (l *lifecycle) run(ctx context.Context) error {
+	var err error
+	select {
+	case <-ctx.Done():
+		l.logger.Info("shutting down")
+	case err = <-l.failed:
+		l.logger.Error("shutting down after failure", "error", err)
+	}
+	l.shutdown()
+	return err
+}
+
+// shutdown stops the components in reverse order of registration.
+func (l *lifecycle) shutdown() {
+	for i := len(l.components) - 1; i >= 0; i-- {
+		c := l.components[i]
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		done := make(chan error, 1)
+		go func() { done <- c.stop(ctx) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				l.logger.Error("failed stopping component", "component", c.name, "duration", time.Since(start), "error", err)
+			} else {
+				l.logger.Info("stopped component", "component", c.name, "duration", time.Since(start))
+			}
+		case <-ctx.Done():
+			l.logger.Error("timed out stopping component", "component", c.name, "timeout", c.timeout)
+		}
+		cancel()
+	}
+	l.components = nil
+}
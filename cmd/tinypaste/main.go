@@ -2,105 +2,1255 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"golang.org/x/time/rate"
 
+	"tiny-pastebin/internal/acmecert"
+	"tiny-pastebin/internal/apikey"
+	"tiny-pastebin/internal/challenge"
+	"tiny-pastebin/internal/diagram"
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/filter"
+	"tiny-pastebin/internal/geoip"
 	"tiny-pastebin/internal/httpserver"
 	"tiny-pastebin/internal/id"
+	"tiny-pastebin/internal/ipacl"
+	"tiny-pastebin/internal/jwt"
+	"tiny-pastebin/internal/logging"
+	"tiny-pastebin/internal/oidc"
+	"tiny-pastebin/internal/scan"
+	"tiny-pastebin/internal/security"
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/memstore"
+	"tiny-pastebin/internal/tracing"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		var run func([]string, io.Writer) error
+		switch os.Args[1] {
+		case "apikey":
+			run = runAPIKey
+		case "migrate":
+			run = runMigrate
+		case "export":
+			run = runExport
+		case "import":
+			run = runImport
+		case "fsck":
+			run = runFsck
+		}
+		if run != nil {
+			if err := run(os.Args[2:], os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			return
+		}
+	}
+
 	cfg := parseFlags()
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	logger, logCloser, err := logging.New(cfg.log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configure logging: %v\n", err)
+		os.Exit(2)
+	}
+	defer logCloser.Close()
 
-	store, err := openStore(cfg.dataPath)
+	var tenants []*tenant
+	switch {
+	case cfg.tenantsFile != "":
+		tenants, err = loadTenants(cfg.tenantsFile, cfg)
+	case len(cfg.configTenants) > 0:
+		tenants, err = buildTenants(cfg.configFile, cfg.configTenants, cfg)
+	}
 	if err != nil {
-		logger.Error("failed opening data store", "error", err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	defer store.Close()
 
-	limiter := httpserver.NewRateLimiter(rate.Limit(5), 10, 15*time.Minute)
+	life := newLifecycle(logger)
+	// fatal stops what has started so far, so the stores are closed cleanly.
+	fatal := func(msg string, err error) {
+		logger.Error(msg, "error", err)
+		life.shutdown()
+		os.Exit(1)
+	}
 
-	srv, err := httpserver.New(httpserver.Config{
-		Store:       store,
-		IDGenerator: id.New(12),
-		MaxBytes:    cfg.maxBytes,
-		RateLimiter: limiter,
-		TrustProxy:  cfg.behindProxy,
-		BaseURL:     cfg.baseURL,
-		Logger:      logger,
-	})
+	upgrades, err := newUpgrader(logger, cfg.pidFile)
 	if err != nil {
-		logger.Error("failed to construct server", "error", err)
-		os.Exit(1)
+		fatal("failed taking over from the previous process", err)
+	}
+	svc, err := newServices(cfg, logger)
+	if err != nil {
+		fatal("failed configuring server", err)
+	}
+	if svc.tracer != nil {
+		// Registered first, so the spans of everything else stopping are
+		// still exported.
+		life.register("tracing", 10*time.Second, svc.tracer.Shutdown)
+	}
+	if cfg.ipListFile != "" {
+		go reloadIPList(logger, svc.ipAccess)
+	}
+	// Listen before opening the stores, so an upgrade that cannot bind
+	// fails while the previous process still serves.
+	lnHTTP, err := upgrades.listen("http", cfg.addr)
+	if err != nil {
+		fatal("failed listening", err)
+	}
+	var lnRedirect, lnAdmin net.Listener
+	if addr := cfg.redirectAddr(); addr != "" {
+		if lnRedirect, err = upgrades.listen("redirect", addr); err != nil {
+			fatal("failed listening for redirects", err)
+		}
+	}
+	if svc.adminTLS != nil {
+		if lnAdmin, err = upgrades.listen("admin", cfg.adminAddr); err != nil {
+			fatal("failed listening for admin", err)
+		}
+	}
+	if err := upgrades.takeOver(); err != nil {
+		fatal("failed taking over from the previous process", err)
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	srv, err := startSite(life, "", cfg, svc)
+	if err != nil {
+		fatal("failed starting server", err)
+	}
+	handler, adminHandler := srv.Handler(), srv.AdminHandler()
+	if len(tenants) > 0 {
+		sites := make(map[string]http.Handler)
+		adminSites := make(map[string]http.Handler)
+		for _, t := range tenants {
+			tsrv, err := startSite(life, t.host, t.cfg, svc)
+			if err != nil {
+				fatal("failed starting site "+t.host, err)
+			}
+			for _, host := range t.hosts() {
+				sites[host] = tsrv.Handler()
+				adminSites[host] = tsrv.AdminHandler()
+			}
+			logger.Info("serving site", "host", t.host)
+		}
+		handler = httpserver.HostRouter(handler, sites)
+		adminHandler = httpserver.HostRouter(adminHandler, adminSites)
+	}
 
-	httpserver.StartJanitor(ctx, store, time.Minute, logger)
+	if cfg.captureAddr != "" {
+		conn, err := net.ListenPacket("udp", cfg.captureAddr)
+		if err != nil {
+			fatal("failed opening log capture listener", err)
+		}
+		captureCtx, stopCapture := context.WithCancel(context.Background())
+		if err := srv.StartLogCapture(captureCtx, conn, cfg.capture); err != nil {
+			stopCapture()
+			fatal("failed starting log capture", err)
+		}
+		life.register("log capture", 5*time.Second, func(context.Context) error {
+			stopCapture()
+			return nil
+		})
+		logger.Info("capturing logs", "addr", conn.LocalAddr().String())
+	}
 
 	srvHTTP := &http.Server{
 		Addr:              cfg.addr,
-		Handler:           srv.Handler(),
+		Handler:           handler,
+		TLSConfig:         svc.tls,
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       15 * time.Second,
 		WriteTimeout:      15 * time.Second,
 		IdleTimeout:       120 * time.Second,
 	}
+	upgrades.track(srvHTTP)
 
-	errCh := make(chan error, 1)
 	go func() {
-		logger.Info("listening", "addr", cfg.addr)
-		if err := srvHTTP.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			errCh <- err
+		logger.Info("listening", "addr", lnHTTP.Addr().String(), "tls", svc.tls != nil)
+		var err error
+		if svc.tls != nil {
+			// With ACME the files are empty and svc.tls supplies the
+			// certificates.
+			err = srvHTTP.ServeTLS(lnHTTP, cfg.tlsCert, cfg.tlsKey)
+		} else {
+			err = srvHTTP.Serve(lnHTTP)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			life.fail("http server", err)
 		}
 	}()
+	life.register("http server", 10*time.Second, srvHTTP.Shutdown)
 
-	select {
-	case <-ctx.Done():
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		if err := srvHTTP.Shutdown(shutdownCtx); err != nil {
-			logger.Error("shutdown error", "error", err)
+	if lnRedirect != nil {
+		_, httpsPort, _ := net.SplitHostPort(cfg.addr)
+		redirect := acmecert.RedirectHTTPS(httpsPort)
+		if svc.acme != nil {
+			redirect = svc.acme.HTTPHandler(redirect)
 		}
-	case err := <-errCh:
-		logger.Error("http server error", "error", err)
-		os.Exit(1)
+		srvRedirect := &http.Server{
+			Handler:           redirect,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		}
+		upgrades.track(srvRedirect)
+		go func() {
+			logger.Info("redirecting to https", "addr", lnRedirect.Addr().String())
+			if err := srvRedirect.Serve(lnRedirect); err != nil && err != http.ErrServerClosed {
+				life.fail("redirect server", err)
+			}
+		}()
+		life.register("redirect server", 5*time.Second, srvRedirect.Shutdown)
+	}
+
+	if lnAdmin != nil {
+		srvAdmin := &http.Server{
+			Handler:           adminHandler,
+			TLSConfig:         svc.adminTLS,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       120 * time.Second,
+		}
+		upgrades.track(srvAdmin)
+		go func() {
+			logger.Info("admin listening", "addr", lnAdmin.Addr().String())
+			if err := srvAdmin.ServeTLS(lnAdmin, cfg.adminTLSCert, cfg.adminTLSKey); err != nil && err != http.ErrServerClosed {
+				life.fail("admin server", err)
+			}
+		}()
+		life.register("admin server", 10*time.Second, srvAdmin.Shutdown)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		// A second signal kills the process rather than waiting for teardown.
+		<-ctx.Done()
+		stop()
+	}()
+	err = life.run(upgrades.watch(ctx))
+	// A process taking over opens the stores once they are closed here.
+	upgrades.release()
+	if err != nil {
+		os.Exit(1)
+	}
 	logger.Info("shutdown complete")
 }
 
+// services are built once and shared by every site the process serves.
+type services struct {
+	logger    *slog.Logger
+	login     httpserver.LoginProvider
+	challenge httpserver.Challenge
+	scanner   scan.Scanner
+	filter    *filter.Filter
+	ipAccess  *ipacl.List
+	diagrams  diagram.Renderer
+	events    events.Sink
+	// cookieSecrets is the cookie key ring, current secret first; empty
+	// lets each server generate its own.
+	cookieSecrets [][]byte
+	// site makes every site private; nil leaves them public.
+	site *httpserver.SiteAccess
+	jwt  *httpserver.JWTAuth
+	// geoip is the shared country database, when configured.
+	geoip *geoip.DB
+	// tls, when set, makes the main listener serve HTTPS; acme supplies its
+	// certificates in ACME mode.
+	tls  *tls.Config
+	acme *acmecert.Manager
+	// adminTLS requires client certificates on the admin listener; nil
+	// keeps admin routes on the main listener.
+	adminTLS *tls.Config
+	// tracer exports trace spans; nil disables tracing.
+	tracer *tracing.Tracer
+}
+
+func newServices(cfg config, logger *slog.Logger) (services, error) {
+	svc := services{logger: logger}
+	if cfg.oidc.ClientID != "" {
+		discoverCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		provider, err := oidc.New(discoverCtx, cfg.oidc)
+		cancel()
+		if err != nil {
+			return svc, fmt.Errorf("configure login provider: %w", err)
+		}
+		svc.login = provider
+	}
+
+	if cfg.challengeKind != "" {
+		provider, err := challenge.New(cfg.challengeKind, cfg.challengeSiteKey, cfg.challengeSecret)
+		if err != nil {
+			return svc, err
+		}
+		svc.challenge = provider
+	}
+
+	var scanners scan.Chain
+	if len(cfg.scanPatterns) > 0 {
+		re, err := scan.NewRegex(cfg.scanPatterns)
+		if err != nil {
+			return svc, fmt.Errorf("invalid scan pattern: %w", err)
+		}
+		scanners = append(scanners, re)
+	}
+	var external scan.Chain
+	if cfg.clamdAddr != "" {
+		external = append(external, scan.NewClamd(cfg.clamdAddr))
+	}
+	if cfg.scanURL != "" {
+		external = append(external, &scan.HTTP{URL: cfg.scanURL, Token: cfg.scanToken})
+	}
+	switch {
+	case len(external) > 0 && cfg.scanMinBytes > 0:
+		scanners = append(scanners, scan.Selective{Scanner: external, MinBytes: cfg.scanMinBytes})
+	case len(external) > 0:
+		scanners = append(scanners, external)
+	}
+	if len(scanners) > 0 {
+		svc.scanner = scanners
+	}
+
+	if len(cfg.filterRules) > 0 || cfg.filterBans != "" {
+		f, err := filter.New(cfg.filterRules, cfg.filterBans)
+		if err != nil {
+			return svc, err
+		}
+		svc.filter = f
+	}
+
+	if len(cfg.allowIPs) > 0 || len(cfg.denyIPs) > 0 || cfg.ipListFile != "" {
+		acl, err := ipacl.New(cfg.allowIPs, cfg.denyIPs, cfg.ipListFile)
+		if err != nil {
+			return svc, err
+		}
+		svc.ipAccess = acl
+	}
+
+	secrets, err := loadCookieSecrets(cfg)
+	if err != nil {
+		return svc, err
+	}
+	svc.cookieSecrets = secrets
+
+	if cfg.jwtSecret != "" || cfg.jwtJWKSURL != "" {
+		auth, err := jwtAuth(cfg)
+		if err != nil {
+			return svc, err
+		}
+		svc.jwt = auth
+	}
+
+	if cfg.siteToken != "" || cfg.siteUsersFile != "" {
+		site, err := loadSiteAccess(cfg)
+		if err != nil {
+			return svc, err
+		}
+		svc.site = site
+	}
+
+	if cfg.otlpEndpoint != "" {
+		tracer, err := tracing.New(tracing.Config{
+			Endpoint:    cfg.otlpEndpoint,
+			Headers:     cfg.otlpHeaders,
+			ServiceName: cfg.traceService,
+			SampleRatio: cfg.traceSampleRatio,
+			Logger:      logger,
+		})
+		if err != nil {
+			return svc, err
+		}
+		svc.tracer = tracer
+	}
+
+	if cfg.geoipDB != "" {
+		db, err := geoip.Open(cfg.geoipDB)
+		if err != nil {
+			return svc, fmt.Errorf("open geoip database: %w", err)
+		}
+		svc.geoip = db
+	}
+
+	switch {
+	case len(cfg.acmeDomains) > 0:
+		svc.acme = &acmecert.Manager{
+			Domains:      cfg.acmeDomains,
+			CacheDir:     cfg.acmeCache,
+			Email:        cfg.acmeEmail,
+			DirectoryURL: cfg.acmeDirectory,
+			Logger:       logger,
+		}
+		svc.tls = svc.acme.TLSConfig()
+	case cfg.tlsCert != "":
+		if _, err := tls.LoadX509KeyPair(cfg.tlsCert, cfg.tlsKey); err != nil {
+			return svc, fmt.Errorf("load certificate: %w", err)
+		}
+		svc.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.adminAddr != "" {
+		tlsConfig, err := adminTLSConfig(cfg)
+		if err != nil {
+			return svc, err
+		}
+		svc.adminTLS = tlsConfig
+	}
+
+	if cfg.mermaidCommand != "" || cfg.plantumlCommand != "" {
+		svc.diagrams = diagram.NewCommand(map[string]string{"mermaid": cfg.mermaidCommand, "plantuml": cfg.plantumlCommand})
+	}
+
+	sinks := events.Multi{events.Log{Logger: logger}}
+	if cfg.eventWebhook != "" {
+		sinks = append(sinks, events.Webhook{URL: cfg.eventWebhook, Client: &http.Client{Timeout: 10 * time.Second}})
+	}
+	svc.events = sinks
+	return svc, nil
+}
+
+// reloadIPList rereads the -ip-list file on every SIGHUP.
+func reloadIPList(logger *slog.Logger, acl *ipacl.List) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if err := acl.Reload(); err != nil {
+			logger.Error("failed reloading ip list", "error", err)
+			continue
+		}
+		logger.Info("reloaded ip list")
+	}
+}
+
+// startSite opens the stores of the site served for host ("" for the main
+// site), builds its server and starts its janitor, registering each to stop
+// on shutdown.
+func startSite(life *lifecycle, host string, cfg config, svc services) (*httpserver.Server, error) {
+	logger, suffix := svc.logger, ""
+	if host != "" {
+		logger, suffix = logger.With("site", host), " "+host
+	}
+	cfg.memory.OnSnapshotError = func(err error) {
+		logger.Error("failed writing memory snapshot", "error", err)
+	}
+	cfg.mirror.OnError = func(err error) {
+		logger.Error("failed mirroring data store", "error", err)
+	}
+	store, archive, err := openServerStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("open data store: %w", err)
+	}
+	// The mirror may need up to 30s to drain its queue.
+	life.register("store"+suffix, 45*time.Second, func(context.Context) error {
+		return store.Close()
+	})
+
+	var robotsTxt string
+	if cfg.robotsFile != "" {
+		data, err := os.ReadFile(cfg.robotsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read robots file: %w", err)
+		}
+		robotsTxt = string(data)
+	}
+
+	terms := cfg.terms
+	if cfg.termsFile != "" {
+		data, err := os.ReadFile(cfg.termsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read terms file: %w", err)
+		}
+		terms = string(data)
+	}
+
+	var geo *httpserver.GeoPolicy
+	if svc.geoip != nil {
+		geo = &httpserver.GeoPolicy{Lookup: svc.geoip, Block: cfg.geoBlock, Strict: cfg.geoStrict}
+		if len(cfg.geoStrict) > 0 {
+			geo.StrictLimiter = httpserver.NewRateLimiter(rate.Every(time.Hour/time.Duration(cfg.geoStrictPerHour)), cfg.geoStrictPerHour, 2*time.Hour)
+		}
+	}
+
+	var botCheck *httpserver.BotCheck
+	if cfg.botCheck {
+		botCheck = &cfg.bots
+	}
+
+	branding := httpserver.Branding{Name: cfg.brandName, FooterLinks: cfg.footerLinks}
+	if cfg.faviconFile != "" {
+		if branding.Favicon, err = os.ReadFile(cfg.faviconFile); err != nil {
+			return nil, fmt.Errorf("read favicon file: %w", err)
+		}
+	}
+	if cfg.logoFile != "" {
+		if branding.Logo, err = os.ReadFile(cfg.logoFile); err != nil {
+			return nil, fmt.Errorf("read logo file: %w", err)
+		}
+	}
+
+	limiter := httpserver.NewRateLimiter(rate.Limit(cfg.rateLimit), cfg.rateBurst, 15*time.Minute)
+	var publicLimiter *httpserver.RateLimiter
+	if cfg.publicAPI {
+		publicLimiter = httpserver.NewRateLimiter(rate.Limit(cfg.publicRateLimit), cfg.publicRateBurst, 15*time.Minute)
+	}
+
+	var login httpserver.LoginProvider
+	if cfg.oidc.ClientID != "" {
+		login = svc.login
+	}
+	var cookieSecret []byte
+	var previousSecrets [][]byte
+	if len(svc.cookieSecrets) > 0 {
+		cookieSecret, previousSecrets = svc.cookieSecrets[0], svc.cookieSecrets[1:]
+	}
+	// The server takes a negative cap to turn the paste-wide lockout off.
+	passwordPasteMax := cfg.passwordPasteMax
+	if passwordPasteMax == 0 {
+		passwordPasteMax = -1
+	}
+	srv, err := httpserver.New(httpserver.Config{
+		Store:                 store,
+		IDGenerator:           id.New(12),
+		MaxBytes:              cfg.maxBytes,
+		RateLimiter:           limiter,
+		PublicAPI:             cfg.publicAPI,
+		PublicRateLimiter:     publicLimiter,
+		TrustedProxies:        cfg.trustedProxies,
+		Privacy:               cfg.privacy,
+		Site:                  svc.site,
+		JWT:                   svc.jwt,
+		AdminListener:         cfg.adminAddr != "",
+		Tracer:                svc.tracer,
+		BaseURL:               cfg.baseURL,
+		ShortURL:              cfg.shortURL,
+		Logger:                logger,
+		AdminToken:            cfg.adminToken,
+		CookieSecret:          cookieSecret,
+		PreviousCookieSecrets: previousSecrets,
+		AdminUsers:            cfg.adminUsers,
+		MetadataLinks:         cfg.metadataLinks,
+		DefaultSyntax:         cfg.defaultSyntax,
+		HiddenSyntaxes:        cfg.hiddenSyntaxes,
+		PlainAgents:           cfg.plainAgents,
+		RawOnlyBytes:          cfg.rawOnlyBytes,
+		DisablePreviews:       cfg.disablePreviews,
+		IndexPastes:           cfg.indexPastes,
+		RobotsTxt:             robotsTxt,
+		Login:                 login,
+		Challenge:             svc.challenge,
+		BotCheck:              botCheck,
+		GeoIP:                 geo,
+		Scanner:               svc.scanner,
+		ScanReject:            cfg.scanReject,
+		Filter:                svc.filter,
+		Secrets:               cfg.secrets,
+		SealProtected:         cfg.sealProtected,
+		Argon:                 cfg.argon,
+		IPAccess:              svc.ipAccess,
+		Diagrams:              svc.diagrams,
+		Events:                svc.events,
+		Ingest:                cfg.ingest,
+		Metrics:               cfg.metrics,
+		Announcement:          cfg.announcement,
+		TermsOfService:        terms,
+		Branding:              branding,
+		Quota:                 cfg.quota,
+		CreatorQuota:          cfg.creatorQuota,
+		SuggestIDs:            cfg.suggestIDs,
+		DeleteGrace:           cfg.deleteGrace,
+		AuditRetention:        cfg.auditRetention,
+		Archive:               archive,
+		TombstoneWindow:       cfg.tombstoneWindow,
+		PasswordAttempts:      cfg.passwordAttempts,
+		PasswordBackoff:       cfg.passwordBackoff,
+		PasswordBackoffMax:    cfg.passwordBackoffMax,
+		PasswordPasteMax:      passwordPasteMax,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("construct server: %w", err)
+	}
+
+	janitorCtx, stopJanitor := context.WithCancel(tracing.WithTracer(context.Background(), svc.tracer))
+	janitorDone := httpserver.StartJanitor(janitorCtx, store, time.Minute, logger, srv.JanitorTasks()...)
+	life.register("janitor"+suffix, 10*time.Second, func(ctx context.Context) error {
+		stopJanitor()
+		select {
+		case <-janitorDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	// Scans, event deliveries and log capture flushes started by the
+	// components registered after this finish here.
+	life.register("background work"+suffix, 30*time.Second, func(context.Context) error {
+		srv.Wait()
+		return nil
+	})
+	return srv, nil
+}
+
 type config struct {
-	addr        string
-	dataPath    string
-	baseURL     string
-	maxBytes    int
-	behindProxy bool
+	addr               string
+	dataPath           string
+	storeKind          string
+	memory             memstore.Options
+	blobDir            string
+	blobThreshold      int
+	cachePastes        int
+	cacheBytes         int64
+	archiveData        string
+	archiveRetention   time.Duration
+	mirrorData         string
+	mirror             storage.MirrorOptions
+	baseURL            string
+	shortURL           string
+	profile            string
+	tenantsFile        string
+	configFile         string
+	pidFile            string
+	configTenants      []tenantSection
+	maxBytes           int
+	rateLimit          float64
+	rateBurst          int
+	publicAPI          bool
+	publicRateLimit    float64
+	publicRateBurst    int
+	trustedProxies     httpserver.TrustedProxies
+	privacy            bool
+	siteToken          string
+	siteUsersFile      string
+	adminToken         string
+	adminAddr          string
+	tlsCert            string
+	tlsKey             string
+	acmeDomains        []string
+	acmeEmail          string
+	acmeCache          string
+	acmeDirectory      string
+	httpRedirectAddr   string
+	adminTLSCert       string
+	adminTLSKey        string
+	adminClientCA      string
+	cookieSecret       string
+	cookieSecretsPrev  []string
+	cookieSecretFile   string
+	metadataLinks      map[string]string
+	defaultSyntax      string
+	hiddenSyntaxes     []string
+	plainAgents        map[string]string
+	rawOnlyBytes       int
+	disablePreviews    bool
+	indexPastes        bool
+	robotsFile         string
+	brandName          string
+	faviconFile        string
+	logoFile           string
+	footerLinks        []httpserver.FooterLink
+	quota              httpserver.Quota
+	creatorQuota       httpserver.CreatorQuota
+	suggestIDs         bool
+	deleteGrace        time.Duration
+	auditRetention     time.Duration
+	tombstoneWindow    time.Duration
+	terms              string
+	termsFile          string
+	oidc               oidc.Config
+	jwtSecret          string
+	jwtJWKSURL         string
+	jwtIssuer          string
+	jwtAudience        string
+	jwtOwnerClaim      string
+	jwtScopeClaim      string
+	jwtDefaultScopes   []string
+	adminUsers         []string
+	challengeKind      string
+	challengeSiteKey   string
+	challengeSecret    string
+	botCheck           bool
+	geoipDB            string
+	geoBlock           []string
+	geoStrict          []string
+	geoStrictPerHour   int
+	bots               httpserver.BotCheck
+	scanPatterns       []string
+	clamdAddr          string
+	scanURL            string
+	scanToken          string
+	scanMinBytes       int
+	scanReject         bool
+	filterRules        []filter.Rule
+	filterBans         string
+	secrets            httpserver.SecretPolicy
+	sealProtected      bool
+	argon              security.Params
+	allowIPs           []netip.Prefix
+	denyIPs            []netip.Prefix
+	ipListFile         string
+	mermaidCommand     string
+	plantumlCommand    string
+	eventWebhook       string
+	ingest             []httpserver.IngestEndpoint
+	captureAddr        string
+	capture            httpserver.LogCapture
+	metrics            bool
+	otlpEndpoint       string
+	otlpHeaders        map[string]string
+	traceSampleRatio   float64
+	traceService       string
+	announcement       string
+	log                logging.Config
+	passwordAttempts   int
+	passwordBackoff    time.Duration
+	passwordBackoffMax time.Duration
+	passwordPasteMax   time.Duration
 }
 
 func parseFlags() config {
 	var cfg config
-	flag.StringVar(&cfg.addr, "addr", ":8080", "listen address")
-	flag.StringVar(&cfg.dataPath, "data", "./tiny-paste.db", "path to data file")
-	flag.StringVar(&cfg.baseURL, "base-url", "", "canonical base URL (optional)")
-	flag.IntVar(&cfg.maxBytes, "max-bytes", 1_048_576, "maximum paste size in bytes")
-	flag.BoolVar(&cfg.behindProxy, "behind-proxy", false, "trust proxy headers for rate limiting and scheme")
+	bindFlags(flag.CommandLine, &cfg)
 	flag.Parse()
+	if err := applyEnv(flag.CommandLine); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.configFile != "" {
+		cf, err := loadConfigFile(cfg.configFile)
+		if err == nil {
+			err = cf.apply(flag.CommandLine)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		cfg.configTenants = cf.tenants
+	}
+	if cfg.profile != "" {
+		if err := applyProfile(flag.CommandLine, cfg.profile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
 
-	if cfg.maxBytes <= 0 {
-		fmt.Fprintf(os.Stderr, "max-bytes must be positive\n")
+	if err := cfg.check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
 	return cfg
 }
+
+// bindFlags registers the server flags on set, storing their values in cfg.
+func bindFlags(set *flag.FlagSet, cfg *config) {
+	set.StringVar(&cfg.addr, "addr", ":8080", "listen address")
+	set.StringVar(&cfg.tlsCert, "tls-cert", "", "serve HTTPS with this certificate file (PEM, chain included)")
+	set.StringVar(&cfg.tlsKey, "tls-key", "", "with -tls-cert, the private key file")
+	set.Func("acme-domain", "comma-separated domains to serve HTTPS for with certificates obtained from Let's Encrypt over HTTP-01; needs port 80 (repeatable)", func(v string) error {
+		for _, d := range splitList(v) {
+			cfg.acmeDomains = append(cfg.acmeDomains, strings.ToLower(d))
+		}
+		return nil
+	})
+	set.StringVar(&cfg.acmeEmail, "acme-email", "", "with -acme-domain, contact address for expiry notices from the CA")
+	set.StringVar(&cfg.acmeCache, "acme-cache", "acme-certs", "with -acme-domain, directory caching the account key and certificates")
+	set.StringVar(&cfg.acmeDirectory, "acme-directory", "", "with -acme-domain, ACME directory URL of another CA or a staging endpoint (default Let's Encrypt)")
+	set.StringVar(&cfg.httpRedirectAddr, "http-redirect-addr", "", "with TLS, plain HTTP address redirecting to HTTPS and answering ACME challenges (default :80 with -acme-domain, off otherwise)")
+	set.StringVar(&cfg.dataPath, "data", "./tiny-paste.db", "data file path or backend DSN: bolt:///path.db, sqlite:///path.db, redis://host, s3://bucket/prefix or memory://")
+	set.StringVar(&cfg.storeKind, "store", "data", "storage backend: data (use -data) or memory")
+	set.IntVar(&cfg.memory.MaxPastes, "memory-max-pastes", 10_000, "with -store=memory, evict the oldest pastes beyond this many (0 disables)")
+	set.Int64Var(&cfg.memory.MaxBytes, "memory-max-bytes", 256<<20, "with -store=memory, evict the oldest pastes beyond this much content (0 disables)")
+	set.StringVar(&cfg.memory.SnapshotPath, "memory-snapshot", "", "with -store=memory, restore from and save to this file across restarts")
+	set.DurationVar(&cfg.memory.SnapshotInterval, "memory-snapshot-interval", time.Minute, "with -memory-snapshot, also write the snapshot this often to survive crashes (0 only writes on shutdown)")
+	set.StringVar(&cfg.blobDir, "blob-dir", "", "keep the content of large pastes in files under this directory rather than in the data store")
+	set.IntVar(&cfg.blobThreshold, "blob-threshold", 256<<10, "with -blob-dir, size in bytes from which paste content is kept in a file")
+	set.IntVar(&cfg.cachePastes, "cache-pastes", 0, "cache up to this many hot pastes in memory (0 disables); only for a store no other process writes to")
+	set.Int64Var(&cfg.cacheBytes, "cache-bytes", 64<<20, "with -cache-pastes, cap the cached content at this many bytes")
+	set.StringVar(&cfg.archiveData, "archive", "", "backend DSN to move expired pastes to instead of deleting them, e.g. sqlite:///var/lib/tinypaste-archive.sqlite")
+	set.DurationVar(&cfg.archiveRetention, "archive-retention", 30*24*time.Hour, "with -archive, keep archived pastes this long after they expired (0 keeps them forever)")
+	set.StringVar(&cfg.mirrorData, "mirror", "", "backend DSN to mirror every write to in the background, e.g. s3://bucket/prefix")
+	set.DurationVar(&cfg.mirror.ReconcileEvery, "mirror-reconcile", time.Hour, "with -mirror, compare both stores and repair the mirror this often (0 disables)")
+	set.StringVar(&cfg.baseURL, "base-url", "", "canonical base URL (optional)")
+	set.StringVar(&cfg.announcement, "announcement", "", "banner shown above every page until dismissed; the admin API can change it at runtime")
+	set.StringVar(&cfg.shortURL, "short-url", "", "short domain for paste links and QR codes, e.g. https://pst.example; its requests redirect to -base-url (optional)")
+	set.StringVar(&cfg.configFile, "config", "", "YAML file of settings named like the flags, nesting allowed (log: {format: json} sets -log-format), lists for repeatable flags and a tenants mapping of host to settings; flags win over TINYPASTE_<FLAG> variables, which win over this file, which wins over -profile (default $TINYPASTE_CONFIG)")
+	set.StringVar(&cfg.pidFile, "pid-file", "", "write the process ID to this file, rewritten when SIGUSR2 upgrades the server in place by re-executing it with the open listeners")
+	set.StringVar(&cfg.tenantsFile, "tenants", "", "file of [host] sections serving more sites from this process, each with \"flag = value\" lines setting its own -data, -base-url, branding and limits")
+	set.StringVar(&cfg.profile, "profile", os.Getenv("TINYPASTE_PROFILE"), "apply a bundle of defaults for "+strings.Join(profileNames(), " or ")+"; explicit flags still override (default $TINYPASTE_PROFILE)")
+	set.IntVar(&cfg.maxBytes, "max-bytes", 1_048_576, "maximum paste size in bytes")
+	set.Float64Var(&cfg.rateLimit, "rate-limit", 5, "sustained requests per second allowed per client")
+	set.IntVar(&cfg.rateBurst, "rate-burst", 10, "requests a client may make in a burst above -rate-limit")
+	set.Func("allow-ip", "comma-separated CIDR ranges or addresses allowed to reach the server, refusing all others (repeatable)", func(v string) error {
+		return appendPrefixes(&cfg.allowIPs, v)
+	})
+	set.Func("deny-ip", "comma-separated CIDR ranges or addresses refused before rate limiting (repeatable)", func(v string) error {
+		return appendPrefixes(&cfg.denyIPs, v)
+	})
+	set.StringVar(&cfg.ipListFile, "ip-list", "", "file of \"allow <cidr>\" and \"deny <cidr>\" lines added to -allow-ip and -deny-ip, reread on SIGHUP")
+	set.BoolVar(&cfg.publicAPI, "public-api", false, "serve the anonymous read-only API for public pastes under /api/v1/public")
+	set.Float64Var(&cfg.publicRateLimit, "public-api-rate", 0.5, "with -public-api, sustained requests per second allowed per client on it")
+	set.IntVar(&cfg.publicRateBurst, "public-api-burst", 10, "with -public-api, requests a client may make in a burst above -public-api-rate")
+	set.Int64Var(&cfg.quota.MaxBytes, "quota-bytes", 0, "cap on the total content stored, in bytes (0 disables)")
+	set.Int64Var(&cfg.quota.MaxPastes, "quota-pastes", 0, "cap on the number of stored pastes (0 disables)")
+	set.Func("quota-policy", "at the quota: evict (delete the oldest expiring pastes) or reject (refuse new pastes) (default evict)", func(v string) error {
+		cfg.quota.Policy = httpserver.QuotaPolicy(v)
+		return nil
+	})
+	set.IntVar(&cfg.creatorQuota.MaxPastes, "ip-quota-pastes", 0, "unexpired pastes one client address may create per -ip-quota-window (0 disables)")
+	set.Int64Var(&cfg.creatorQuota.MaxBytes, "ip-quota-bytes", 0, "content bytes one client address may create per -ip-quota-window (0 disables)")
+	set.DurationVar(&cfg.creatorQuota.Window, "ip-quota-window", 24*time.Hour, "window for -ip-quota-pastes and -ip-quota-bytes")
+	set.IntVar(&cfg.passwordAttempts, "password-attempts", 5, "wrong passwords one client may try per paste before being locked out; four times as many per paste across clients and per client across pastes")
+	set.DurationVar(&cfg.passwordBackoff, "password-backoff", time.Second, "first password lockout, doubling with each further failure")
+	set.DurationVar(&cfg.passwordBackoffMax, "password-backoff-max", 15*time.Minute, "longest password lockout")
+	set.DurationVar(&cfg.passwordPasteMax, "password-paste-backoff-max", 30*time.Second, "longest lockout of a paste after wrong passwords from any clients; it shuts out readers who know the password too, so anyone can hold it, while 0 disables it and leaves guessing from many addresses to the per-client limits")
+	cfg.argon = security.DefaultParams
+	set.Func("argon-time", fmt.Sprintf("Argon2id passes for paste passwords; weaker hashes are upgraded on the next correct password (default %d)", cfg.argon.Time), func(v string) error {
+		n, err := strconv.ParseUint(v, 10, 32)
+		cfg.argon.Time = uint32(n)
+		return err
+	})
+	set.Func("argon-memory", fmt.Sprintf("Argon2id memory in KiB for paste passwords (default %d)", cfg.argon.Memory), func(v string) error {
+		n, err := strconv.ParseUint(v, 10, 32)
+		cfg.argon.Memory = uint32(n)
+		return err
+	})
+	set.Func("argon-threads", fmt.Sprintf("Argon2id parallelism for paste passwords (default %d)", cfg.argon.Threads), func(v string) error {
+		n, err := strconv.ParseUint(v, 10, 8)
+		cfg.argon.Threads = uint8(n)
+		return err
+	})
+	set.BoolVar(&cfg.metrics, "metrics", false, "serve Prometheus capacity gauges at /metrics (unauthenticated)")
+	set.StringVar(&cfg.otlpEndpoint, "otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "export OpenTelemetry traces of requests, store calls and janitor sweeps to this OTLP/HTTP collector, e.g. http://localhost:4318 (default $OTEL_EXPORTER_OTLP_ENDPOINT)")
+	set.Func("otlp-header", "header sent with trace exports as name=value, e.g. for collector authentication (repeatable)", func(v string) error {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("want name=value, got %q", v)
+		}
+		if cfg.otlpHeaders == nil {
+			cfg.otlpHeaders = make(map[string]string)
+		}
+		cfg.otlpHeaders[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		return nil
+	})
+	set.Float64Var(&cfg.traceSampleRatio, "trace-sample-ratio", 1, "with -otlp-endpoint, the fraction of new traces recorded; traces continued from a trusted proxy follow its decision")
+	set.StringVar(&cfg.traceService, "trace-service", "tinypaste", "with -otlp-endpoint, the service name traces are reported under")
+	set.Func("trusted-proxies", "comma separated CIDRs of the reverse proxies whose X-Forwarded-For, X-Real-IP, X-Forwarded-Proto and X-Request-ID headers are believed (repeatable)", func(v string) error {
+		proxies, err := httpserver.ParseTrustedProxies(splitList(v))
+		cfg.trustedProxies = append(cfg.trustedProxies, proxies...)
+		return err
+	})
+	set.BoolFunc("behind-proxy", "removed; use -trusted-proxies", func(string) error {
+		return errors.New("-behind-proxy was replaced by -trusted-proxies, which lists the proxies to trust")
+	})
+	set.BoolVar(&cfg.privacy, "privacy", false, "only store and log client IPs as hashes under a salt rotated daily")
+	set.StringVar(&cfg.adminToken, "admin-token", os.Getenv("TINYPASTE_ADMIN_TOKEN"), "bearer token enabling the admin API (default $TINYPASTE_ADMIN_TOKEN)")
+	set.StringVar(&cfg.adminAddr, "admin-addr", "", "serve /admin and the admin API only on this second address, over TLS with client certificates required")
+	set.StringVar(&cfg.adminTLSCert, "admin-tls-cert", "", "certificate file of the admin listener")
+	set.StringVar(&cfg.adminTLSKey, "admin-tls-key", "", "private key file of the admin listener")
+	set.StringVar(&cfg.adminClientCA, "admin-client-ca", "", "PEM file of the CAs whose client certificates the admin listener accepts")
+	set.StringVar(&cfg.cookieSecret, "cookie-secret", os.Getenv("TINYPASTE_COOKIE_SECRET"), "secret of at least 32 bytes signing cookies and CSRF tokens, so sessions survive restarts; without it one is generated on each start (default $TINYPASTE_COOKIE_SECRET)")
+	set.Func("cookie-secret-previous", "retired cookie secret still accepted while sessions signed with it expire (repeatable)", func(v string) error {
+		cfg.cookieSecretsPrev = append(cfg.cookieSecretsPrev, v)
+		return nil
+	})
+	set.StringVar(&cfg.siteToken, "site-token", os.Getenv("TINYPASTE_SITE_TOKEN"), "make the instance private, admitting requests with this token in X-Access-Token or as the basic auth password (default $TINYPASTE_SITE_TOKEN)")
+	set.StringVar(&cfg.siteUsersFile, "site-users-file", "", "make the instance private, admitting the basic auth users in this file, one user:password per line")
+	set.StringVar(&cfg.cookieSecretFile, "cookie-secret-file", "", "file holding the cookie key ring, one secret per line: the current secret first, then retired ones still accepted")
+	set.Func("admin-user", "comma-separated signed-in identities (oidc:<subject>) allowed into /admin", func(v string) error {
+		cfg.adminUsers = append(cfg.adminUsers, splitList(v)...)
+		return nil
+	})
+	cfg.metadataLinks = make(map[string]string)
+	set.Func("metadata-link", "render a metadata key as a link, key=https://host/path/{value} (repeatable)", func(v string) error {
+		key, tmpl, ok := strings.Cut(v, "=")
+		if !ok || key == "" || tmpl == "" {
+			return fmt.Errorf("expected key=template, got %q", v)
+		}
+		cfg.metadataLinks[key] = tmpl
+		return nil
+	})
+	set.StringVar(&cfg.defaultSyntax, "default-syntax", "", "syntax preselected on the create form (default plaintext, or the first syntax -hide-syntax leaves)")
+	set.Func("hide-syntax", "comma-separated syntaxes to hide from the form and reject on create", func(v string) error {
+		cfg.hiddenSyntaxes = append(cfg.hiddenSyntaxes, splitList(v)...)
+		return nil
+	})
+	set.Func("plain-agents", "comma-separated agent=raw|text pairs replacing the user agents (curl, wget, lynx, ...) served plain paste pages, or none to always serve HTML", func(v string) error {
+		if cfg.plainAgents == nil {
+			cfg.plainAgents = make(map[string]string)
+		}
+		if v == "none" {
+			return nil
+		}
+		for _, item := range splitList(v) {
+			agent, mode, ok := strings.Cut(item, "=")
+			if !ok || agent == "" {
+				return fmt.Errorf("expected agent=raw or agent=text, got %q", item)
+			}
+			cfg.plainAgents[agent] = mode
+		}
+		return nil
+	})
+	set.IntVar(&cfg.rawOnlyBytes, "raw-only-bytes", 262_144, "pastes larger than this are shown as a summary with raw/download links (0 disables)")
+	set.BoolVar(&cfg.disablePreviews, "disable-previews", false, "omit OpenGraph/Twitter link preview tags from paste pages")
+	set.BoolVar(&cfg.indexPastes, "index-pastes", false, "allow search engines to index pastes (individual pastes may still opt out)")
+	set.StringVar(&cfg.brandName, "site-name", "", "instance name shown in the header, page titles, feed and embeds (default Tiny Pastebin)")
+	set.StringVar(&cfg.faviconFile, "favicon-file", "", "image served as /favicon.ico instead of the built-in icon")
+	set.StringVar(&cfg.logoFile, "logo-file", "", "image shown in the header instead of the site name")
+	set.Func("footer-link", "footer link as Label=URL, replacing the default tagline (repeatable)", func(v string) error {
+		label, link, ok := strings.Cut(v, "=")
+		if !ok || label == "" || link == "" {
+			return fmt.Errorf("expected Label=URL, got %q", v)
+		}
+		cfg.footerLinks = append(cfg.footerLinks, httpserver.FooterLink{Label: label, URL: link})
+		return nil
+	})
+	set.DurationVar(&cfg.deleteGrace, "delete-grace", 0, "keep deleted pastes this long so admins can undelete them (0 deletes immediately)")
+	set.DurationVar(&cfg.auditRetention, "audit-retention", 90*24*time.Hour, "keep audit log entries this long, for stores that keep one (0 keeps them forever)")
+	set.DurationVar(&cfg.tombstoneWindow, "tombstone-window", 0, "answer 410 Gone with the date for this long after a paste expires or is deleted (0 answers 404)")
+	set.BoolVar(&cfg.suggestIDs, "suggest-ids", false, "on missing paste pages, link recent public pastes whose ID differs by case or one character")
+	set.StringVar(&cfg.robotsFile, "robots-file", "", "file served as /robots.txt instead of the generated default")
+	set.StringVar(&cfg.terms, "tos", "", "terms of service creators must accept before their first paste (API clients send X-Accept-Tos)")
+	set.StringVar(&cfg.termsFile, "tos-file", "", "read the terms of service from this file instead of -tos")
+	set.StringVar(&cfg.oidc.Issuer, "oidc-issuer", "", "OpenID Connect issuer URL used for discovery (e.g. https://accounts.google.com)")
+	set.StringVar(&cfg.oidc.ClientID, "oidc-client-id", "", "OAuth2 client ID; enables sign-in when set")
+	set.StringVar(&cfg.oidc.ClientSecret, "oidc-client-secret", os.Getenv("TINYPASTE_OIDC_CLIENT_SECRET"), "OAuth2 client secret (default $TINYPASTE_OIDC_CLIENT_SECRET)")
+	set.StringVar(&cfg.oidc.RedirectURL, "oidc-redirect-url", "", "callback URL registered with the provider, ending in /auth/callback")
+	set.StringVar(&cfg.oidc.SubjectClaim, "oidc-subject-claim", "sub", "claim mapped to the paste owner identity (GitHub: id)")
+	set.StringVar(&cfg.oidc.AuthURL, "oidc-auth-url", "", "authorization endpoint for providers without discovery (GitHub)")
+	set.StringVar(&cfg.oidc.TokenURL, "oidc-token-url", "", "token endpoint for providers without discovery")
+	set.StringVar(&cfg.oidc.UserInfoURL, "oidc-userinfo-url", "", "userinfo endpoint for providers without discovery")
+	set.Func("oidc-scopes", "comma-separated scopes to request", func(v string) error {
+		cfg.oidc.Scopes = splitList(v)
+		return nil
+	})
+	set.StringVar(&cfg.jwtSecret, "jwt-secret", os.Getenv("TINYPASTE_JWT_SECRET"), "accept API bearer JWTs signed with this HMAC secret (HS256/384/512) (default $TINYPASTE_JWT_SECRET)")
+	set.StringVar(&cfg.jwtJWKSURL, "jwt-jwks-url", "", "accept API bearer JWTs signed with RSA or ECDSA keys published at this JWKS URL")
+	set.StringVar(&cfg.jwtIssuer, "jwt-issuer", "", "with JWT auth, required iss claim")
+	set.StringVar(&cfg.jwtAudience, "jwt-audience", "", "with JWT auth, required aud claim")
+	set.StringVar(&cfg.jwtOwnerClaim, "jwt-owner-claim", "sub", "with JWT auth, claim mapped to the paste owner identity (jwt:<value>)")
+	set.StringVar(&cfg.jwtScopeClaim, "jwt-scope-claim", "scope", "with JWT auth, claim listing granted scopes ("+strings.Join(apikey.Scopes, ", ")+")")
+	set.Func("jwt-default-scopes", "with JWT auth, comma-separated scopes granted to tokens without the scope claim", func(v string) error {
+		cfg.jwtDefaultScopes = splitList(v)
+		return nil
+	})
+	set.StringVar(&cfg.challengeKind, "challenge", "", "require anonymous creators to solve a challenge from "+strings.Join(challenge.Kinds(), ", ")+"; signed-in users and API keys skip it")
+	set.StringVar(&cfg.challengeSiteKey, "challenge-site-key", "", "with -challenge, the site key shown in the widget")
+	set.StringVar(&cfg.challengeSecret, "challenge-secret", os.Getenv("TINYPASTE_CHALLENGE_SECRET"), "with -challenge, the secret used to verify responses (default $TINYPASTE_CHALLENGE_SECRET)")
+	set.StringVar(&cfg.geoipDB, "geoip-db", "", "MaxMind DB file (e.g. GeoLite2-Country.mmdb) locating clients for -geoip-block, -geoip-strict and the audit log")
+	set.Func("geoip-block", "comma-separated ISO country codes whose clients may not create pastes; viewing stays open", func(v string) error {
+		cfg.geoBlock = append(cfg.geoBlock, countryCodes(v)...)
+		return nil
+	})
+	set.Func("geoip-strict", "comma-separated ISO country codes whose clients may only create -geoip-strict-per-hour pastes an hour", func(v string) error {
+		cfg.geoStrict = append(cfg.geoStrict, countryCodes(v)...)
+		return nil
+	})
+	set.IntVar(&cfg.geoStrictPerHour, "geoip-strict-per-hour", 10, "with -geoip-strict, pastes a client from those countries may create per hour")
+	set.BoolVar(&cfg.botCheck, "bot-check", false, "add a hidden honeypot field to the create form and silently drop submissions that fill it in or come too fast")
+	set.DurationVar(&cfg.bots.MinSubmitTime, "bot-min-submit", 2*time.Second, "with -bot-check, drop form submissions made sooner than this after loading the form (0 disables)")
+	set.DurationVar(&cfg.bots.Tarpit, "bot-tarpit", 5*time.Second, "with -bot-check, delay the answer to dropped submissions this long")
+	set.Func("scan-pattern", "quarantine new pastes whose content or metadata matches this regular expression (repeatable)", func(v string) error {
+		cfg.scanPatterns = append(cfg.scanPatterns, v)
+		return nil
+	})
+	set.StringVar(&cfg.clamdAddr, "clamd-addr", "", "scan new pastes with clamd at host:port or a unix socket path")
+	set.StringVar(&cfg.scanURL, "scan-url", "", "scan new pastes with an HTTP service answering POSTed content with {\"flagged\": bool, \"reason\": string}")
+	set.StringVar(&cfg.scanToken, "scan-token", os.Getenv("TINYPASTE_SCAN_TOKEN"), "bearer token sent to -scan-url (default $TINYPASTE_SCAN_TOKEN)")
+	set.IntVar(&cfg.scanMinBytes, "scan-min-bytes", 0, "only send pastes of at least this many bytes, or binary ones, to clamd and -scan-url (0 sends all)")
+	set.BoolVar(&cfg.scanReject, "scan-reject", false, "scan new pastes before saving them and reject flagged ones instead of quarantining them")
+	set.Func("filter", "screen new pastes with a rule, action:kind:value where action is reject, quarantine or flag and kind is regex, keyword or urls (most links allowed), e.g. flag:urls:10 (repeatable)", func(v string) error {
+		rule, err := filter.ParseRule(v)
+		cfg.filterRules = append(cfg.filterRules, rule)
+		return err
+	})
+	set.StringVar(&cfg.filterBans, "filter-bans", "", "JSON file keeping the content hashes banned from /admin/filter; without it bans last until restart")
+	set.Func("secrets", "on new pastes that look like they hold credentials (AWS keys, private keys, bearer tokens): warn (ask to confirm), expire (cap the expiry at 1 hour) or reject (default off)", func(v string) error {
+		cfg.secrets = httpserver.SecretPolicy(v)
+		return nil
+	})
+	set.BoolVar(&cfg.sealProtected, "seal-protected", false, "store the content of password-protected pastes encrypted under a key derived from the password (Argon2id + HKDF)")
+	set.StringVar(&cfg.mermaidCommand, "mermaid-command", "", "render mermaid blocks in markdown pastes with this command, reading the source on stdin and writing SVG, e.g. \"mmdc -i - -o - -e svg\"")
+	set.StringVar(&cfg.plantumlCommand, "plantuml-command", "", "render plantuml blocks in markdown pastes with this command, e.g. \"plantuml -tsvg -pipe\"")
+	set.StringVar(&cfg.eventWebhook, "event-webhook", "", "URL receiving paste events (quarantine, release, delete) as JSON POSTs")
+	set.Func("ingest", "accept webhooks at /ingest/<key> as pastes, name:key[;title=<template>][;syntax=<syntax>][;expire=<expire>][;format=alertmanager] (repeatable)", func(v string) error {
+		endpoint, err := parseIngest(v)
+		cfg.ingest = append(cfg.ingest, endpoint)
+		return err
+	})
+	set.StringVar(&cfg.captureAddr, "capture-addr", "", "UDP address collecting syslog messages or raw lines into rolling pastes, e.g. :5514")
+	set.StringVar(&cfg.capture.Name, "capture-name", "syslog", "label recorded on captured log pastes")
+	set.IntVar(&cfg.capture.MaxBytes, "capture-max-bytes", 0, "start a new capture paste once this size is reached (default max-bytes)")
+	set.DurationVar(&cfg.capture.Window, "capture-window", time.Hour, "start a new capture paste after this long")
+	set.StringVar(&cfg.capture.Expire, "capture-expire", "", "expiry for capture pastes (default the form default)")
+	set.StringVar(&cfg.log.Output, "log-output", "stdout", "log destination: stdout, stderr, syslog, syslog://host:port, syslog+tcp://host:port, journald or a file path")
+	set.StringVar(&cfg.log.Format, "log-format", "text", "format of the log, request access lines included, for stdout, stderr and files: text or json")
+	cfg.log.Level = slog.LevelInfo
+	set.Func("log-level", "minimum log level: debug, info, warn or error (default info)", func(v string) error {
+		level, err := logging.ParseLevel(v)
+		cfg.log.Level = level
+		return err
+	})
+	set.Int64Var(&cfg.log.MaxSize, "log-max-size", 100<<20, "rotate the log file once it exceeds this many bytes (0 disables)")
+	set.DurationVar(&cfg.log.MaxAge, "log-max-age", 0, "rotate the log file once it is this old, e.g. 24h (0 disables)")
+	set.IntVar(&cfg.log.MaxBackups, "log-max-backups", 5, "rotated log files to keep (0 keeps all)")
+}
+
+// check validates cfg once its flags are set, filling in settings derived
+// from others.
+func (cfg *config) check() error {
+	if cfg.maxBytes <= 0 {
+		return errors.New("max-bytes must be positive")
+	}
+	if cfg.oidc.ClientID != "" && cfg.oidc.RedirectURL == "" && cfg.baseURL != "" {
+		cfg.oidc.RedirectURL = strings.TrimSuffix(cfg.baseURL, "/") + "/auth/callback"
+	}
+	if cfg.rateLimit <= 0 || cfg.rateBurst <= 0 {
+		return errors.New("rate-limit and rate-burst must be positive")
+	}
+	if cfg.publicAPI && (cfg.publicRateLimit <= 0 || cfg.publicRateBurst <= 0) {
+		return errors.New("public-api-rate and public-api-burst must be positive")
+	}
+	if cfg.rawOnlyBytes < 0 {
+		return errors.New("raw-only-bytes must not be negative")
+	}
+	if cfg.passwordPasteMax < 0 {
+		return errors.New("password-paste-backoff-max must not be negative")
+	}
+	if (len(cfg.geoBlock) > 0 || len(cfg.geoStrict) > 0) && cfg.geoipDB == "" {
+		return errors.New("geoip-block and geoip-strict need geoip-db")
+	}
+	if cfg.tenantsFile != "" && len(cfg.configTenants) > 0 {
+		return errors.New("tenants cannot be set both as a file and in the config file")
+	}
+	if (cfg.tlsCert == "") != (cfg.tlsKey == "") {
+		return errors.New("tls-cert and tls-key must be set together")
+	}
+	if cfg.tlsCert != "" && len(cfg.acmeDomains) > 0 {
+		return errors.New("tls-cert and acme-domain cannot be combined")
+	}
+	if cfg.traceSampleRatio < 0 || cfg.traceSampleRatio > 1 {
+		return errors.New("trace-sample-ratio must be between 0 and 1")
+	}
+	if cfg.geoStrictPerHour <= 0 {
+		return errors.New("geoip-strict-per-hour must be positive")
+	}
+	// The tarpit must answer before the server's write timeout does.
+	if cfg.bots.Tarpit < 0 || cfg.bots.Tarpit > 10*time.Second {
+		return errors.New("bot-tarpit must be between 0 and 10s")
+	}
+	return nil
+}
+
+// parseIngest reads an -ingest value. Options follow the key separated by
+// semicolons, so title templates may contain commas and colons.
+func parseIngest(v string) (httpserver.IngestEndpoint, error) {
+	parts := strings.Split(v, ";")
+	name, key, ok := strings.Cut(parts[0], ":")
+	if !ok || name == "" || key == "" {
+		return httpserver.IngestEndpoint{}, fmt.Errorf("expected name:key, got %q", parts[0])
+	}
+	endpoint := httpserver.IngestEndpoint{Name: name, Key: key}
+	for _, opt := range parts[1:] {
+		k, val, _ := strings.Cut(opt, "=")
+		switch strings.TrimSpace(k) {
+		case "title":
+			endpoint.Title = val
+		case "syntax":
+			endpoint.Syntax = val
+		case "expire":
+			endpoint.Expire = val
+		case "format":
+			endpoint.Format = val
+		default:
+			return endpoint, fmt.Errorf("unknown ingest option %q", k)
+		}
+	}
+	return endpoint, nil
+}
+
+// minCookieSecretLen is the shortest cookie secret accepted, in bytes.
+const minCookieSecretLen = 32
+
+// loadCookieSecrets returns the cookie key ring, current secret first: from
+// -cookie-secret-file, which holds one secret per line with the current one
+// first, or from -cookie-secret and -cookie-secret-previous.
+func loadCookieSecrets(cfg config) ([][]byte, error) {
+	values := append([]string{cfg.cookieSecret}, cfg.cookieSecretsPrev...)
+	if cfg.cookieSecretFile != "" {
+		if cfg.cookieSecret != "" || len(cfg.cookieSecretsPrev) > 0 {
+			return nil, errors.New("cookie-secret-file cannot be combined with cookie-secret or cookie-secret-previous")
+		}
+		data, err := os.ReadFile(cfg.cookieSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("read cookie secret file: %w", err)
+		}
+		values = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				values = append(values, line)
+			}
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("cookie secret file %s holds no secrets", cfg.cookieSecretFile)
+		}
+	}
+	if values[0] == "" {
+		if len(values) > 1 {
+			return nil, errors.New("cookie-secret-previous needs cookie-secret")
+		}
+		return nil, nil
+	}
+	secrets := make([][]byte, 0, len(values))
+	for _, v := range values {
+		if len(v) < minCookieSecretLen {
+			return nil, fmt.Errorf("cookie secrets must be at least %d bytes", minCookieSecretLen)
+		}
+		secrets = append(secrets, []byte(v))
+	}
+	return secrets, nil
+}
+
+// jwtAuth configures JWT bearer auth for the API. HMAC secrets and key sets
+// are exclusive, so a token cannot pass off an HMAC signature made with a
+// public key as valid.
+func jwtAuth(cfg config) (*httpserver.JWTAuth, error) {
+	if cfg.jwtSecret != "" && cfg.jwtJWKSURL != "" {
+		return nil, errors.New("jwt-secret and jwt-jwks-url cannot be combined")
+	}
+	for _, scope := range cfg.jwtDefaultScopes {
+		if !slices.Contains(apikey.Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q in jwt-default-scopes", scope)
+		}
+	}
+	verifier := &jwt.Verifier{Issuer: cfg.jwtIssuer, Audience: cfg.jwtAudience, Leeway: time.Minute}
+	if cfg.jwtSecret != "" {
+		if len(cfg.jwtSecret) < 32 {
+			return nil, errors.New("jwt-secret must be at least 32 bytes")
+		}
+		verifier.Keys = jwt.StaticKey{K: []byte(cfg.jwtSecret)}
+		verifier.Algorithms = []string{"HS256", "HS384", "HS512"}
+	} else {
+		verifier.Keys = jwt.NewJWKS(cfg.jwtJWKSURL, nil)
+		verifier.Algorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+	}
+	return &httpserver.JWTAuth{
+		Verifier:      verifier,
+		OwnerClaim:    cfg.jwtOwnerClaim,
+		ScopeClaim:    cfg.jwtScopeClaim,
+		DefaultScopes: cfg.jwtDefaultScopes,
+	}, nil
+}
+
+// redirectAddr returns the address of the HTTP to HTTPS redirect listener,
+// or "" for none.
+func (cfg *config) redirectAddr() string {
+	switch {
+	case cfg.tlsCert == "" && len(cfg.acmeDomains) == 0:
+		return ""
+	case cfg.httpRedirectAddr != "":
+		return cfg.httpRedirectAddr
+	case len(cfg.acmeDomains) > 0:
+		return ":80"
+	}
+	return ""
+}
+
+// countryCodes splits a list of ISO country codes, upper-casing them as the
+// GeoIP database writes them.
+func countryCodes(v string) []string {
+	codes := splitList(v)
+	for i, c := range codes {
+		codes[i] = strings.ToUpper(c)
+	}
+	return codes
+}
+
+// adminTLSConfig builds the TLS settings of the admin listener, which only
+// completes handshakes with clients presenting a certificate issued by one
+// of the -admin-client-ca authorities.
+func adminTLSConfig(cfg config) (*tls.Config, error) {
+	if cfg.adminTLSCert == "" || cfg.adminTLSKey == "" || cfg.adminClientCA == "" {
+		return nil, errors.New("admin-addr needs admin-tls-cert, admin-tls-key and admin-client-ca")
+	}
+	if _, err := tls.LoadX509KeyPair(cfg.adminTLSCert, cfg.adminTLSKey); err != nil {
+		return nil, fmt.Errorf("load admin certificate: %w", err)
+	}
+	data, err := os.ReadFile(cfg.adminClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("read admin client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("admin client CA %s holds no PEM certificates", cfg.adminClientCA)
+	}
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// loadSiteAccess reads the credentials of a private instance.
+func loadSiteAccess(cfg config) (*httpserver.SiteAccess, error) {
+	site := &httpserver.SiteAccess{Token: cfg.siteToken}
+	if cfg.siteUsersFile == "" {
+		return site, nil
+	}
+	data, err := os.ReadFile(cfg.siteUsersFile)
+	if err != nil {
+		return nil, fmt.Errorf("read site users file: %w", err)
+	}
+	site.Users = make(map[string]string)
+	for n, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, password, ok := strings.Cut(line, ":")
+		if !ok || user == "" || password == "" {
+			return nil, fmt.Errorf("site users file %s line %d: want user:password", cfg.siteUsersFile, n+1)
+		}
+		site.Users[user] = password
+	}
+	if len(site.Users) == 0 && site.Token == "" {
+		return nil, fmt.Errorf("site users file %s holds no users", cfg.siteUsersFile)
+	}
+	return site, nil
+}
+
+// appendPrefixes parses a comma-separated list of ranges onto dst.
+func appendPrefixes(dst *[]netip.Prefix, v string) error {
+	for _, item := range splitList(v) {
+		p, err := ipacl.ParsePrefix(item)
+		if err != nil {
+			return err
+		}
+		*dst = append(*dst, p)
+	}
+	return nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// runMigrate implements the "migrate" subcommand, which copies every paste
+// and API key from one backend into another.
+func runMigrate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	from := fs.String("from", "", "source backend DSN, e.g. bolt:./old.db")
+	to := fs.String("to", "", "destination backend DSN, e.g. sqlite:./new.db")
+	dryRun := fs.Bool("dry-run", false, "read the source and report what would be copied without writing")
+	every := fs.Int("progress", 1000, "report progress every this many pastes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return errors.New("usage: tinypaste migrate -from DSN -to DSN [-dry-run]")
+	}
+	if *from == *to {
+		return errors.New("-from and -to name the same store")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	src, err := openStore(*from)
+	if err != nil {
+		return fmt.Errorf("open source: %w", err)
+	}
+	defer src.Close()
+	dst := src
+	if !*dryRun {
+		if dst, err = openStore(*to); err != nil {
+			return fmt.Errorf("open destination: %w", err)
+		}
+		defer dst.Close()
+	}
+
+	total, err := src.Count(ctx, storage.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("count source pastes: %w", err)
+	}
+	verb := "copied"
+	if *dryRun {
+		verb = "would copy"
+	}
+	start := time.Now()
+	stats, err := storage.Copy(ctx, dst, src, storage.CopyOptions{
+		DryRun:        *dryRun,
+		ProgressEvery: *every,
+		Progress: func(s storage.CopyStats) {
+			fmt.Fprintf(stdout, "%s %d/%d pastes (%d bytes)\n", verb, s.Pastes, total, s.Bytes)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("migrate after %d pastes: %w", stats.Pastes, err)
+	}
+	fmt.Fprintf(stdout, "%s %d pastes and %d api keys from %s to %s in %s\n",
+		verb, stats.Pastes, stats.APIKeys, *from, *to, time.Since(start).Round(time.Millisecond))
+	return nil
+}
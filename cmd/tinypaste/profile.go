@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// profileFiles are curated bundles of flag defaults for common deployments,
+// one "flag = value" per line, so a binary on its own starts with a sensible
+// configuration.
+//
+//go:embed profiles/*.conf
+var profileFiles embed.FS
+
+// profileNames lists the embedded profiles in sorted order.
+func profileNames() []string {
+	entries, _ := fs.ReadDir(profileFiles, "profiles")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the flags of the named profile on set, skipping those
+// given on the command line so explicit flags always win.
+func applyProfile(set *flag.FlagSet, name string) error {
+	data, err := profileFiles.ReadFile("profiles/" + name + ".conf")
+	if err != nil {
+		return fmt.Errorf("unknown profile %q (have %s)", name, strings.Join(profileNames(), ", "))
+	}
+	explicit := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || set.Lookup(key) == nil {
+			return fmt.Errorf("profile %s line %d: unknown flag %q", name, n, key)
+		}
+		if explicit[key] {
+			continue
+		}
+		if err := set.Set(key, value); err != nil {
+			return fmt.Errorf("profile %s line %d: %w", name, n, err)
+		}
+	}
+	return sc.Err()
+}
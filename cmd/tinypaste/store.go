@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"tiny-pastebin/internal/storage"
+	_ "tiny-pastebin/internal/storage/boltstore"
+	"tiny-pastebin/internal/storage/memstore"
+	_ "tiny-pastebin/internal/storage/redisstore"
+	_ "tiny-pastebin/internal/storage/s3store"
+	_ "tiny-pastebin/internal/storage/sqlitestore"
+)
+
+// defaultScheme is assumed for -data values that are plain file paths.
+const defaultScheme = "bolt"
+
+// openStore opens the backend named by the -data DSN, e.g.
+// bolt:///var/lib/tinypaste.db, sqlite:///var/lib/tinypaste.sqlite,
+// redis://host:6379/0, s3://bucket/prefix or memory://. The short form
+// bolt:./old.db is accepted for file backends, and a plain path opens a bolt
+// file, as before DSNs were supported.
+func openStore(data string) (storage.Store, error) {
+	return storage.Open(dataDSN(data))
+}
+
+// dataDSN expands the short forms -data accepts into a full DSN.
+func dataDSN(data string) string {
+	if strings.Contains(data, "://") {
+		return data
+	}
+	if scheme, path, ok := strings.Cut(data, ":"); ok && slices.Contains(storage.Schemes(), scheme) {
+		return scheme + "://" + path
+	}
+	return defaultScheme + "://" + data
+}
+
+// openServerStore honours -store: "memory" keeps everything in process and
+// ignores -data, the default opens whatever -data names. With -blob-dir the
+// content of large pastes is kept in files beside the backend, with -archive
+// expired pastes are moved to a second backend, which is also returned, with
+// -mirror every write is copied to a second backend, and with -cache-pastes
+// hot pastes are served from memory. With -otlp-endpoint calls to the backend
+// are traced.
+func openServerStore(cfg config) (store, archive storage.Store, err error) {
+	store, err = openBackend(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped := store
+	if cfg.otlpEndpoint != "" {
+		// Right over the backend, so spans time the database calls alone.
+		wrapped = storage.WithTracing(wrapped, backendSystem(cfg))
+	}
+	if cfg.blobDir != "" {
+		if wrapped, err = storage.WithBlobs(wrapped, cfg.blobDir, cfg.blobThreshold); err != nil {
+			store.Close()
+			return nil, nil, err
+		}
+	}
+	if cfg.archiveData != "" {
+		if archive, err = openStore(cfg.archiveData); err != nil {
+			store.Close()
+			return nil, nil, fmt.Errorf("open archive: %w", err)
+		}
+		// Archive above the blob files, so the archive gets whole pastes.
+		wrapped = storage.WithArchive(wrapped, archive, cfg.archiveRetention)
+	}
+	if cfg.mirrorData != "" {
+		secondary, err := openStore(cfg.mirrorData)
+		if err != nil {
+			wrapped.Close()
+			return nil, nil, fmt.Errorf("open mirror: %w", err)
+		}
+		// Mirror above the blob files, so the secondary gets whole pastes.
+		wrapped = storage.WithMirror(wrapped, secondary, cfg.mirror)
+	}
+	if cfg.cachePastes > 0 && cfg.storeKind != "memory" {
+		cached, err := storage.WithCache(wrapped, cfg.cachePastes, cfg.cacheBytes)
+		if err != nil {
+			wrapped.Close()
+			return nil, nil, err
+		}
+		wrapped = cached
+	}
+	return wrapped, archive, nil
+}
+
+// backendSystem names the backend openBackend opens, for traces.
+func backendSystem(cfg config) string {
+	if cfg.storeKind == "memory" {
+		return "memory"
+	}
+	scheme, _, _ := strings.Cut(dataDSN(cfg.dataPath), "://")
+	return scheme
+}
+
+func openBackend(cfg config) (storage.Store, error) {
+	switch cfg.storeKind {
+	case "", "data":
+		return openStore(cfg.dataPath)
+	case "memory":
+		return memstore.New(cfg.memory)
+	default:
+		return nil, fmt.Errorf("unknown store %q (want data or memory)", cfg.storeKind)
+	}
+}
@@ -1,10 +0,0 @@
-package main
-
-import (
-	"tiny-pastebin/internal/storage"
-	"tiny-pastebin/internal/storage/boltstore"
-)
-
-func openStore(path string) (storage.Store, error) {
-	return boltstore.Open(path)
-}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// tenantFlags are the flags a -tenants section may set: where the site keeps
+// its pastes, how it presents itself, and its limits. Everything else, such
+// as logging, sign-in, scanning and events, is shared by every site.
+var tenantFlags = map[string]bool{
+	"data":              true,
+	"blob-dir":          true,
+	"blob-threshold":    true,
+	"archive":           true,
+	"archive-retention": true,
+	"mirror":            true,
+	"base-url":          true,
+	"short-url":         true,
+	"site-name":         true,
+	"favicon-file":      true,
+	"logo-file":         true,
+	"footer-link":       true,
+	"announcement":      true,
+	"tos":               true,
+	"tos-file":          true,
+	"robots-file":       true,
+	"index-pastes":      true,
+	"default-syntax":    true,
+	"hide-syntax":       true,
+	"admin-token":       true,
+	"max-bytes":         true,
+	"raw-only-bytes":    true,
+	"rate-limit":        true,
+	"rate-burst":        true,
+	"public-api":        true,
+	"quota-bytes":       true,
+	"quota-pastes":      true,
+	"quota-policy":      true,
+	"ip-quota-pastes":   true,
+	"ip-quota-bytes":    true,
+	"ip-quota-window":   true,
+}
+
+// tenant is another site served from this process, chosen by Host.
+type tenant struct {
+	host string
+	cfg  config
+}
+
+// hosts lists the Host names the tenant answers: its own and that of its
+// short URL, if any.
+func (t *tenant) hosts() []string {
+	hosts := []string{t.host}
+	if u, err := url.Parse(t.cfg.shortURL); err == nil && u.Host != "" {
+		hosts = append(hosts, u.Host)
+	}
+	return hosts
+}
+
+// setting is one "flag = value" from a file; where locates it for errors.
+type setting struct {
+	key, value string
+	where      string
+}
+
+// tenantSection is the settings of one site, from a -tenants file or the
+// tenants mapping of a -config file.
+type tenantSection struct {
+	host     string
+	where    string
+	settings []setting
+}
+
+// loadTenants reads a -tenants file: a "[host]" line starts each site,
+// followed by "flag = value" lines as in profiles.
+func loadTenants(path string, base config) ([]*tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sections []tenantSection
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		where := fmt.Sprintf("%s line %d", path, n)
+		if host, ok := strings.CutPrefix(line, "["); ok {
+			host, ok = strings.CutSuffix(host, "]")
+			if !ok {
+				return nil, fmt.Errorf("%s: expected [host], got %q", where, line)
+			}
+			sections = append(sections, tenantSection{host: host, where: where})
+			continue
+		}
+		if len(sections) == 0 {
+			return nil, fmt.Errorf("%s: setting before the first [host] section", where)
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown flag %q", where, key)
+		}
+		cur := &sections[len(sections)-1]
+		cur.settings = append(cur.settings, setting{key: key, value: value, where: where})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return buildTenants(path, sections, base)
+}
+
+// buildTenants configures a tenant per section. A tenant starts from the
+// main site's settings without its stores, so each section must set data;
+// repeatable flags given in a section replace the inherited values.
+func buildTenants(source string, sections []tenantSection, base config) ([]*tenant, error) {
+	tenants := make([]*tenant, 0, len(sections))
+	for _, sec := range sections {
+		host := strings.ToLower(strings.TrimSpace(sec.host))
+		if host == "" || strings.ContainsAny(host, "/ ") {
+			return nil, fmt.Errorf("%s: %q is not a host name", sec.where, sec.host)
+		}
+		cur, set := newTenant(host, base)
+		seen := make(map[string]bool)
+		for _, st := range sec.settings {
+			switch {
+			case set.Lookup(st.key) == nil:
+				return nil, fmt.Errorf("%s: unknown flag %q", st.where, st.key)
+			case !tenantFlags[st.key]:
+				return nil, fmt.Errorf("%s: %s cannot be set per site (allowed: %s)", st.where, st.key, strings.Join(tenantFlagNames(), ", "))
+			}
+			if !seen[st.key] {
+				seen[st.key] = true
+				switch st.key {
+				case "footer-link":
+					cur.cfg.footerLinks = nil
+				case "hide-syntax":
+					cur.cfg.hiddenSyntaxes = nil
+				}
+			}
+			if err := set.Set(st.key, st.value); err != nil {
+				return nil, fmt.Errorf("%s: %w", st.where, err)
+			}
+		}
+		tenants = append(tenants, cur)
+	}
+
+	hosts := make(map[string]bool)
+	for _, t := range tenants {
+		if t.cfg.dataPath == "" {
+			return nil, fmt.Errorf("%s: [%s] must set data", source, t.host)
+		}
+		if err := t.cfg.check(); err != nil {
+			return nil, fmt.Errorf("%s: [%s]: %w", source, t.host, err)
+		}
+		for _, h := range t.hosts() {
+			if hosts[h] {
+				return nil, fmt.Errorf("%s: host %s is served by more than one section", source, h)
+			}
+			hosts[h] = true
+		}
+	}
+	return tenants, nil
+}
+
+// newTenant returns a tenant configured like base, and the flag set its
+// section is applied with.
+func newTenant(host string, base config) (*tenant, *flag.FlagSet) {
+	t := &tenant{host: host}
+	set := flag.NewFlagSet(host, flag.ContinueOnError)
+	// Binding stores the flag defaults, so copy base over them afterwards.
+	bindFlags(set, &t.cfg)
+	t.cfg = base
+	t.cfg.footerLinks = slices.Clip(base.footerLinks)
+	t.cfg.hiddenSyntaxes = slices.Clip(base.hiddenSyntaxes)
+
+	// Stores, and the features that feed pastes in from outside the site,
+	// belong to the main site alone.
+	t.cfg.storeKind = "data"
+	t.cfg.dataPath = ""
+	t.cfg.memory.SnapshotPath = ""
+	t.cfg.blobDir = ""
+	t.cfg.archiveData = ""
+	t.cfg.mirrorData = ""
+	t.cfg.shortURL = ""
+	t.cfg.ingest = nil
+	t.cfg.captureAddr = ""
+	// Sign-in redirects back to the main site, so tenants go without it.
+	t.cfg.oidc.ClientID = ""
+	t.cfg.adminUsers = nil
+	return t, set
+}
+
+func tenantFlagNames() []string {
+	names := make([]string, 0, len(tenantFlags))
+	for name := range tenantFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A running server upgrades in place on SIGUSR2: it starts its executable
+// again, handing over its listening sockets, and shuts down once the new
+// process has read its configuration. Connections arriving meanwhile wait in
+// the sockets' backlog rather than being refused. Stores are handed over
+// too: bolt holds an exclusive lock and memory snapshots are written on
+// close, so the new process only opens them once the old one has closed
+// them. If the new process fails before then, the old one keeps serving.
+//
+// Before shutting down, the old process stops accepting and waits for the
+// connections it accepted to send their request: net/http hangs up on a
+// connection whose first request arrives once Shutdown has begun.
+
+// upgradeEnv tells a process started for an upgrade which of its files are
+// inherited listeners, as name=addr pairs in the order of the files from fd
+// 3. The two files after them are the pipes of the handoff.
+const upgradeEnv = "TINYPASTE_UPGRADE_LISTENERS"
+
+// drainTimeout bounds how long an old process waits for the connections it
+// accepted to send their request, as ReadHeaderTimeout does.
+const drainTimeout = 5 * time.Second
+
+// upgrader keeps the listeners a server may hand to its successor, and
+// takes over from its predecessor.
+type upgrader struct {
+	logger  *slog.Logger
+	pidFile string
+
+	mu        sync.Mutex
+	listeners []namedListener
+	// fresh holds the connections that have not sent a request yet.
+	fresh map[net.Conn]bool
+	// handoffW is closed to let a successor open the stores.
+	handoffW *os.File
+
+	// Set in a process started for an upgrade.
+	inherited map[string]*os.File
+	// ready is written once the process is configured; handoff reaches EOF
+	// once the predecessor has closed its stores.
+	ready, handoff *os.File
+}
+
+type namedListener struct {
+	name, addr string
+	ln         *handoffListener
+}
+
+// handoffListener stops accepting once its socket is handed over, without
+// failing the server's Serve before Shutdown closes it.
+type handoffListener struct {
+	net.Listener
+	handedOver chan struct{}
+	// parked is closed once Serve is back in Accept after the handoff, so
+	// the connections it accepted before are tracked.
+	parked    chan struct{}
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (l *handoffListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		select {
+		case <-l.handedOver:
+			select {
+			case <-l.parked:
+			default:
+				close(l.parked)
+			}
+			<-l.closed
+			return nil, net.ErrClosed
+		default:
+		}
+	}
+	return c, err
+}
+
+// Close closes the listener, or only stops Accept if the socket is already
+// handed over.
+func (l *handoffListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	select {
+	case <-l.handedOver:
+		return nil
+	default:
+		return l.Listener.Close()
+	}
+}
+
+// newUpgrader picks up what a predecessor handed over, if this process was
+// started for an upgrade.
+func newUpgrader(logger *slog.Logger, pidFile string) (*upgrader, error) {
+	u := &upgrader{logger: logger, pidFile: pidFile, fresh: make(map[net.Conn]bool)}
+	spec, ok := os.LookupEnv(upgradeEnv)
+	if !ok {
+		return u, nil
+	}
+	os.Unsetenv(upgradeEnv)
+	u.inherited = make(map[string]*os.File)
+	fd := 3
+	for _, pair := range strings.Split(spec, ",") {
+		if pair == "" {
+			continue
+		}
+		if _, _, ok := strings.Cut(pair, "="); !ok {
+			return nil, fmt.Errorf("%s: malformed listener %q", upgradeEnv, pair)
+		}
+		u.inherited[pair] = os.NewFile(uintptr(fd), pair)
+		fd++
+	}
+	u.ready = os.NewFile(uintptr(fd), "upgrade ready")
+	u.handoff = os.NewFile(uintptr(fd+1), "upgrade handoff")
+	if u.ready == nil || u.handoff == nil {
+		return nil, errors.New("upgrade: handoff pipes missing")
+	}
+	return u, nil
+}
+
+// listen returns the listener named name on addr: the one inherited from
+// the predecessor, if it listened there, or a new one.
+func (u *upgrader) listen(name, addr string) (net.Listener, error) {
+	var ln net.Listener
+	if f, ok := u.inherited[name+"="+addr]; ok {
+		delete(u.inherited, name+"="+addr)
+		var err error
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherit %s listener: %w", name, err)
+		}
+	} else {
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+	hl := &handoffListener{
+		Listener:   ln,
+		handedOver: make(chan struct{}),
+		parked:     make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+	u.mu.Lock()
+	u.listeners = append(u.listeners, namedListener{name: name, addr: addr, ln: hl})
+	u.mu.Unlock()
+	return hl, nil
+}
+
+// track follows the connections of srv, so a handoff can wait for them to
+// send their request.
+func (u *upgrader) track(srv *http.Server) {
+	srv.ConnState = func(c net.Conn, state http.ConnState) {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if state == http.StateNew {
+			u.fresh[c] = true
+		} else {
+			delete(u.fresh, c)
+		}
+	}
+}
+
+// drain stops accepting, leaving the sockets to the successor, and waits
+// for the accepted connections to send their request.
+func (u *upgrader) drain() {
+	u.mu.Lock()
+	listeners := u.listeners
+	u.mu.Unlock()
+	for _, l := range listeners {
+		close(l.ln.handedOver)
+		l.ln.Listener.Close()
+	}
+	deadline := time.After(drainTimeout)
+	for _, l := range listeners {
+		select {
+		case <-l.ln.parked:
+		case <-deadline:
+			return
+		}
+	}
+	for {
+		select {
+		case <-deadline:
+			return
+		default:
+		}
+		u.mu.Lock()
+		n := len(u.fresh)
+		u.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// takeOver tells the predecessor, if any, that this process is configured
+// and listening, and waits until it has closed its stores. A predecessor
+// that dies meanwhile releases them too.
+func (u *upgrader) takeOver() error {
+	if err := u.writePID(); err != nil {
+		return err
+	}
+	if u.ready == nil {
+		return nil
+	}
+	// Listeners the new configuration no longer uses.
+	for _, f := range u.inherited {
+		f.Close()
+	}
+	u.inherited = nil
+	_, err := u.ready.Write([]byte("ready\n"))
+	u.ready.Close()
+	if err != nil {
+		return fmt.Errorf("signal predecessor: %w", err)
+	}
+	u.logger.Info("waiting for the previous process to release the stores")
+	_, err = io.Copy(io.Discard, u.handoff)
+	u.handoff.Close()
+	return err
+}
+
+// writePID records this process in the -pid-file, so supervisors follow
+// the server across upgrades.
+func (u *upgrader) writePID() error {
+	if u.pidFile == "" {
+		return nil
+	}
+	return os.WriteFile(u.pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644)
+}
+
+// release lets the successor, if one is taking over, open the stores. Call
+// it once they are closed.
+func (u *upgrader) release() {
+	if u.handoffW != nil {
+		u.handoffW.Close()
+	}
+}
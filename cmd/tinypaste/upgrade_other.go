@@ -0,0 +1,11 @@
+//go:build windows || plan9
+
+package main
+
+import "context"
+
+// watch does nothing: upgrading in place needs the listeners passed to a
+// child process, which this platform cannot do.
+func (u *upgrader) watch(ctx context.Context) context.Context {
+	return ctx
+}
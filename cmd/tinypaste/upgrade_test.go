@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testUpgrader(t *testing.T) *upgrader {
+	t.Helper()
+	u, err := newUpgrader(slog.New(slog.DiscardHandler), "")
+	if err != nil {
+		t.Fatalf("new upgrader: %v", err)
+	}
+	return u
+}
+
+// serve runs srv on a new listener from u and returns Serve's result.
+func serve(t *testing.T, u *upgrader, srv *http.Server) (net.Listener, <-chan error) {
+	t.Helper()
+	ln, err := u.listen("http", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	return ln, done
+}
+
+func TestHandedOverListenerServesUntilClosed(t *testing.T) {
+	u := testUpgrader(t)
+	srv := &http.Server{Handler: http.NotFoundHandler()}
+	_, done := serve(t, u, srv)
+
+	u.drain()
+	select {
+	case err := <-done:
+		t.Fatalf("expected Serve to wait for Close after the handoff, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := srv.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != http.ErrServerClosed {
+			t.Fatalf("expected ErrServerClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Serve to return once closed")
+	}
+}
+
+func TestDrainWaitsForAcceptedConnections(t *testing.T) {
+	u := testUpgrader(t)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	u.track(srv)
+	ln, done := serve(t, u, srv)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	for deadline := time.Now().Add(time.Second); ; {
+		u.mu.Lock()
+		n := len(u.fresh)
+		u.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the connection accepted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		u.drain()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		t.Fatal("expected drain to wait for the connection's request")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example\r\n\r\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("expected drain to return once the request arrived")
+	}
+
+	// Shutting down now still answers the request.
+	go srv.Shutdown(t.Context())
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the request answered, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+	if err := <-done; err != http.ErrServerClosed {
+		t.Fatalf("expected ErrServerClosed, got %v", err)
+	}
+}
+
+func TestTakeOverWaitsForHandoff(t *testing.T) {
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	defer readyR.Close()
+	handoffR, handoffW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("pipe: %v", err)
+	}
+	pidFile := filepath.Join(t.TempDir(), "tinypaste.pid")
+	u, err := newUpgrader(slog.New(slog.DiscardHandler), pidFile)
+	if err != nil {
+		t.Fatalf("new upgrader: %v", err)
+	}
+	u.inherited = map[string]*os.File{}
+	u.ready, u.handoff = readyW, handoffR
+
+	took := make(chan error, 1)
+	go func() { took <- u.takeOver() }()
+	line, err := bufio.NewReader(readyR).ReadString('\n')
+	if err != nil || line != "ready\n" {
+		t.Fatalf("expected ready signalled, got %q %v", line, err)
+	}
+	if data, err := os.ReadFile(pidFile); err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Fatalf("expected the pid file written, got %q %v", data, err)
+	}
+	select {
+	case err := <-took:
+		t.Fatalf("expected takeOver to wait for the handoff, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	handoffW.Close()
+	select {
+	case err := <-took:
+		if err != nil {
+			t.Fatalf("take over: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected takeOver to return once the handoff pipe closed")
+	}
+}
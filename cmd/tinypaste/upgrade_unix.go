@@ -0,0 +1,129 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// upgradeTimeout bounds how long a new process may take to read its
+// configuration before the upgrade is abandoned.
+const upgradeTimeout = time.Minute
+
+// watch upgrades the server on SIGUSR2. The returned context is done once
+// a new process is ready to take over, so the server shuts down, after
+// which release lets the new process open the stores.
+func (u *upgrader) watch(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(sig)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sig:
+				if err := u.upgrade(); err != nil {
+					u.logger.Error("upgrade failed, still serving", "error", err)
+					if err := u.writePID(); err != nil {
+						u.logger.Error("failed writing pid file", "error", err)
+					}
+					continue
+				}
+				u.logger.Info("new process ready, handing over")
+				u.drain()
+				cancel()
+				return
+			}
+		}
+	}()
+	return ctx
+}
+
+// upgrade starts the executable again with the listeners and waits until
+// it is configured.
+func (u *upgrader) upgrade() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	u.mu.Lock()
+	listeners := slices.Clone(u.listeners)
+	u.mu.Unlock()
+
+	var (
+		files []*os.File
+		names []string
+	)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, l := range listeners {
+		fl, ok := l.ln.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("%s listener cannot be handed over", l.name)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return fmt.Errorf("%s listener: %w", l.name, err)
+		}
+		files = append(files, f)
+		names = append(names, l.name+"="+l.addr)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	handoffR, handoffW, err := os.Pipe()
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), upgradeEnv+"="+strings.Join(names, ","))
+	cmd.ExtraFiles = append(slices.Clip(files), readyW, handoffR)
+	err = cmd.Start()
+	readyW.Close()
+	handoffR.Close()
+	if err != nil {
+		handoffW.Close()
+		return err
+	}
+	u.logger.Info("started new process", "pid", cmd.Process.Pid, "executable", exe)
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(readyR, make([]byte, len("ready\n")))
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(upgradeTimeout):
+		err = errors.New("timed out")
+	}
+	if err != nil {
+		handoffW.Close()
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("new process did not take over: %v (exit: %v)", err, <-exited)
+	}
+	u.handoffW = handoffW
+	return nil
+}
@@ -0,0 +1,390 @@
+// Package acmecert obtains and renews TLS certificates from an ACME
+// certificate authority such as Let's Encrypt, answering the CA's http-01
+// challenges on port 80.
+//
+// Certificates and the account key are cached in a directory, so restarts
+// do not request new ones. A certificate is renewed in the background once
+// it has less than RenewBefore left, while the old one keeps being served.
+package acmecert
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+const (
+	defaultRenewBefore = 30 * 24 * time.Hour
+	// obtainTimeout bounds one certificate order, challenges included.
+	obtainTimeout  = 5 * time.Minute
+	accountKeyFile = "acme_account.key"
+)
+
+// Manager obtains certificates for Domains. Its zero value is not usable;
+// set at least Domains and CacheDir.
+type Manager struct {
+	// Domains are the host names certificates are obtained for. Handshakes
+	// for other names fail.
+	Domains []string
+	// CacheDir holds the account key and the certificates.
+	CacheDir string
+	// Email is given to the CA for expiry notices. Optional.
+	Email string
+	// DirectoryURL is the CA's ACME directory. Defaults to Let's Encrypt.
+	DirectoryURL string
+	// RenewBefore is how long before expiry certificates are renewed.
+	// Defaults to 30 days.
+	RenewBefore time.Duration
+	Logger      *slog.Logger
+
+	clientMu sync.Mutex
+	client   *acme.Client
+
+	mu       sync.Mutex
+	certs    map[string]*tls.Certificate
+	pending  map[string]chan struct{}
+	renewing map[string]bool
+	tokens   map[string]string
+}
+
+// TLSConfig returns a TLS configuration serving the managed certificates.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: m.GetCertificate, MinVersion: tls.VersionTLS12}
+}
+
+// GetCertificate implements tls.Config.GetCertificate. The first handshake
+// for a domain without a cached certificate waits while one is obtained.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")
+	if name == "" && len(m.Domains) > 0 {
+		name = m.Domains[0]
+	}
+	if !slices.Contains(m.Domains, name) {
+		return nil, fmt.Errorf("acmecert: host %q not configured", hello.ServerName)
+	}
+	ctx := hello.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return m.cert(ctx, name)
+}
+
+func (m *Manager) cert(ctx context.Context, name string) (*tls.Certificate, error) {
+	for {
+		m.mu.Lock()
+		if m.certs == nil {
+			m.certs = make(map[string]*tls.Certificate)
+			m.pending = make(map[string]chan struct{})
+			m.renewing = make(map[string]bool)
+		}
+		if c, ok := m.certs[name]; ok {
+			if m.dueForRenewal(c) && !m.renewing[name] {
+				m.renewing[name] = true
+				go m.renew(name)
+			}
+			m.mu.Unlock()
+			return c, nil
+		}
+		if wait, ok := m.pending[name]; ok {
+			m.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		m.pending[name] = done
+		m.mu.Unlock()
+
+		c, err := m.load(name)
+		if err != nil {
+			c, err = m.obtain(name)
+		}
+		m.mu.Lock()
+		delete(m.pending, name)
+		if err == nil {
+			m.certs[name] = c
+		}
+		m.mu.Unlock()
+		close(done)
+		return c, err
+	}
+}
+
+func (m *Manager) dueForRenewal(c *tls.Certificate) bool {
+	before := m.RenewBefore
+	if before <= 0 {
+		before = defaultRenewBefore
+	}
+	return time.Until(c.Leaf.NotAfter) < before
+}
+
+// renew replaces the certificate of name in the background.
+func (m *Manager) renew(name string) {
+	c, err := m.obtain(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renewing[name] = false
+	if err != nil {
+		m.warn("renew certificate", "domain", name, "error", err)
+		return
+	}
+	m.certs[name] = c
+}
+
+// load reads the cached certificate of name, if it is still usable.
+func (m *Manager) load(name string) (*tls.Certificate, error) {
+	data, err := os.ReadFile(m.certPath(name))
+	if err != nil {
+		return nil, err
+	}
+	c, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(c.Leaf.NotAfter) || c.Leaf.VerifyHostname(name) != nil {
+		return nil, errors.New("cached certificate is expired or for another host")
+	}
+	return &c, nil
+}
+
+func (m *Manager) certPath(name string) string {
+	return filepath.Join(m.CacheDir, name+".pem")
+}
+
+// obtain orders a new certificate for name and caches it.
+func (m *Manager) obtain(name string) (*tls.Certificate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), obtainTimeout)
+	defer cancel()
+	client, err := m.acmeClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(name))
+	if err != nil {
+		return nil, fmt.Errorf("order certificate: %w", err)
+	}
+	for _, u := range order.AuthzURLs {
+		if err := m.authorize(ctx, client, u); err != nil {
+			return nil, err
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, fmt.Errorf("wait for order: %w", err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: name},
+		DNSNames: []string{name},
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("finalize order: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	for _, b := range der {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: b})...)
+	}
+	c, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(m.certPath(name), data, 0o600); err != nil {
+		m.warn("cache certificate", "domain", name, "error", err)
+	}
+	m.log("obtained certificate", "domain", name, "expires", c.Leaf.NotAfter)
+	return &c, nil
+}
+
+// authorize proves control of the domain of the authorization at url with
+// an http-01 challenge.
+func (m *Manager) authorize(ctx context.Context, client *acme.Client, url string) error {
+	z, err := client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("get authorization: %w", err)
+	}
+	if z.Status == acme.StatusValid {
+		return nil
+	}
+	var chal *acme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("no http-01 challenge offered for %s", z.Identifier.Value)
+	}
+	resp, err := client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return err
+	}
+	m.setToken(chal.Token, resp)
+	defer m.setToken(chal.Token, "")
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("accept challenge: %w", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, z.URI); err != nil {
+		return fmt.Errorf("authorize %s: %w", z.Identifier.Value, err)
+	}
+	return nil
+}
+
+// acmeClient returns the registered ACME client, creating the account key
+// and registering it on first use. Failures are retried on the next call.
+func (m *Manager) acmeClient(ctx context.Context) (*acme.Client, error) {
+	m.clientMu.Lock()
+	defer m.clientMu.Unlock()
+	if m.client != nil {
+		return m.client, nil
+	}
+	key, err := m.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	dir := m.DirectoryURL
+	if dir == "" {
+		dir = acme.LetsEncryptURL
+	}
+	client := &acme.Client{Key: key, DirectoryURL: dir}
+	account := &acme.Account{}
+	if m.Email != "" {
+		account.Contact = []string{"mailto:" + m.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("register acme account: %w", err)
+	}
+	m.client = client
+	return client, nil
+}
+
+// accountKey reads the cached account key, or creates and caches one.
+func (m *Manager) accountKey() (crypto.Signer, error) {
+	path := filepath.Join(m.CacheDir, accountKeyFile)
+	if data, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s holds no PEM key", path)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if err := os.MkdirAll(m.CacheDir, 0o700); err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (m *Manager) setToken(token, resp string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tokens == nil {
+		m.tokens = make(map[string]string)
+	}
+	if resp == "" {
+		delete(m.tokens, token)
+		return
+	}
+	m.tokens[token] = resp
+}
+
+// challengePrefix is where the CA fetches http-01 challenge responses.
+const challengePrefix = "/.well-known/acme-challenge/"
+
+// HTTPHandler answers the CA's http-01 challenges and passes every other
+// request to fallback; nil redirects them to HTTPS.
+func (m *Manager) HTTPHandler(fallback http.Handler) http.Handler {
+	if fallback == nil {
+		fallback = RedirectHTTPS("")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.URL.Path, challengePrefix)
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		m.mu.Lock()
+		resp, found := m.tokens[token]
+		m.mu.Unlock()
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte(resp))
+	})
+}
+
+// RedirectHTTPS redirects requests to the same URL over HTTPS, on port
+// httpsPort unless it is empty or "443".
+func RedirectHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		// Only idempotent requests are redirected; anything else would be
+		// resent in the clear on the way.
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Use HTTPS", http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+func (m *Manager) log(msg string, args ...any) {
+	if m.Logger != nil {
+		m.Logger.Info("acme: "+msg, args...)
+	}
+}
+
+func (m *Manager) warn(msg string, args ...any) {
+	if m.Logger != nil {
+		m.Logger.Warn("acme: "+msg, args...)
+	}
+}
@@ -0,0 +1,97 @@
+package acmecert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCachedCert stores a self-signed certificate for name in dir, as
+// obtain would have.
+func writeCachedCert(t *testing.T, dir, name string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServesCachedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	writeCachedCert(t, dir, "paste.example", time.Now().Add(90*24*time.Hour))
+	m := &Manager{Domains: []string{"paste.example"}, CacheDir: dir}
+
+	c, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "Paste.Example"})
+	if err != nil || c.Leaf.Subject.CommonName != "paste.example" {
+		t.Fatalf("expected the cached certificate, got %v", err)
+	}
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{}); err != nil {
+		t.Fatalf("expected handshakes without SNI served the first domain: %v", err)
+	}
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example"}); err == nil {
+		t.Fatalf("expected unknown hosts refused")
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	m := &Manager{Domains: []string{"paste.example"}}
+	m.setToken("tok", "tok.thumbprint")
+	h := m.HTTPHandler(nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://paste.example/.well-known/acme-challenge/tok", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "tok.thumbprint" {
+		t.Fatalf("expected the challenge response, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://paste.example/.well-known/acme-challenge/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown tokens not found, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://paste.example:80/p/abc?x=1", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://paste.example/p/abc?x=1" {
+		t.Fatalf("expected a redirect to https, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://paste.example/pastes", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected posts over http refused, got %d", rec.Code)
+	}
+}
+
+func TestRedirectHTTPSKeepsCustomPort(t *testing.T) {
+	rec := httptest.NewRecorder()
+	RedirectHTTPS("8443").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://paste.example:8080/recent", nil))
+	if got := rec.Header().Get("Location"); got != "https://paste.example:8443/recent" {
+		t.Fatalf("unexpected redirect %q", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Package apikey mints and verifies API keys of the form tp_<id>_<secret>.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// Scopes granted to keys.
+const (
+	ScopeCreate = "paste:create"
+	ScopeRead   = "paste:read"
+	ScopeDelete = "paste:delete"
+)
+
+// Scopes lists every known scope.
+var Scopes = []string{ScopeCreate, ScopeRead, ScopeDelete}
+
+const prefix = "tp_"
+
+// New mints a key with the given scopes and returns it along with the token
+// to hand to the client. The token cannot be recovered later.
+func New(name string, scopes []string, rateLimit float64, burst int, now time.Time) (*storage.APIKey, string, error) {
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if !known(s) {
+			return nil, "", fmt.Errorf("unknown scope %q", s)
+		}
+	}
+	if rateLimit < 0 || burst < 0 {
+		return nil, "", errors.New("rate limit and burst must not be negative")
+	}
+	idBytes, err := random(4)
+	if err != nil {
+		return nil, "", err
+	}
+	secretBytes, err := random(24)
+	if err != nil {
+		return nil, "", err
+	}
+	id := hex.EncodeToString(idBytes)
+	secret := base64.RawURLEncoding.EncodeToString(secretBytes)
+	key := &storage.APIKey{
+		ID:         id,
+		Name:       name,
+		SecretHash: hash(secret),
+		Scopes:     append([]string(nil), scopes...),
+		CreatedAt:  now.UTC(),
+		RateLimit:  rateLimit,
+		Burst:      burst,
+	}
+	return key, prefix + id + "_" + secret, nil
+}
+
+// Parse splits a token into its key ID and secret.
+func Parse(token string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(token, prefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// IsToken reports whether v looks like an API key rather than another kind
+// of bearer token.
+func IsToken(v string) bool {
+	return strings.HasPrefix(v, prefix)
+}
+
+// Verify reports whether secret matches key.
+func Verify(key *storage.APIKey, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash(secret)), []byte(key.SecretHash)) == 1
+}
+
+func known(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	return b, nil
+}
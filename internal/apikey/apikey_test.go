@@ -0,0 +1,39 @@
+package apikey
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewParseVerify(t *testing.T) {
+	key, token, err := New("ci", []string{ScopeCreate, ScopeRead}, 2, 4, time.Now())
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if !IsToken(token) {
+		t.Fatalf("expected %q to look like a key", token)
+	}
+	id, secret, ok := Parse(token)
+	if !ok || id != key.ID {
+		t.Fatalf("parse %q: id=%q ok=%v", token, id, ok)
+	}
+	if !Verify(key, secret) {
+		t.Fatalf("expected secret to verify")
+	}
+	if Verify(key, secret+"x") {
+		t.Fatalf("expected altered secret to fail")
+	}
+	if !key.HasScope(ScopeRead) || key.HasScope(ScopeDelete) {
+		t.Fatalf("unexpected scopes %v", key.Scopes)
+	}
+
+	if _, _, ok := Parse("tp_onlyid"); ok {
+		t.Fatalf("expected malformed token rejected")
+	}
+	if _, _, err := New("bad", []string{"paste:everything"}, 0, 0, time.Now()); err == nil {
+		t.Fatalf("expected unknown scope rejected")
+	}
+	if _, _, err := New("none", nil, 0, 0, time.Now()); err == nil {
+		t.Fatalf("expected empty scopes rejected")
+	}
+}
@@ -0,0 +1,135 @@
+// Package challenge verifies human-verification challenges solved in the
+// browser, such as hCaptcha, reCAPTCHA and Cloudflare Turnstile, which all
+// share the same siteverify protocol.
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrFailed reports a response the provider did not accept.
+var ErrFailed = errors.New("challenge not passed")
+
+// Widget describes how a page embeds the challenge.
+type Widget struct {
+	// Script is the provider's JavaScript, loaded by the page.
+	Script string
+	// Class marks the element the script renders the widget into.
+	Class   string
+	SiteKey string
+	// Field is the form field the solved response is posted in.
+	Field string
+}
+
+// kind is what differs between the supported providers.
+type kind struct {
+	verifyURL string
+	widget    Widget
+}
+
+var kinds = map[string]kind{
+	"hcaptcha": {
+		verifyURL: "https://api.hcaptcha.com/siteverify",
+		widget:    Widget{Script: "https://js.hcaptcha.com/1/api.js", Class: "h-captcha", Field: "h-captcha-response"},
+	},
+	"recaptcha": {
+		verifyURL: "https://www.google.com/recaptcha/api/siteverify",
+		widget:    Widget{Script: "https://www.google.com/recaptcha/api.js", Class: "g-recaptcha", Field: "g-recaptcha-response"},
+	},
+	"turnstile": {
+		verifyURL: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+		widget:    Widget{Script: "https://challenges.cloudflare.com/turnstile/v0/api.js", Class: "cf-turnstile", Field: "cf-turnstile-response"},
+	},
+}
+
+// Kinds lists the supported provider names in sorted order.
+func Kinds() []string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Provider verifies responses with one challenge provider.
+type Provider struct {
+	widget Widget
+	secret string
+	// VerifyURL is the siteverify endpoint; New sets the provider's own.
+	VerifyURL string
+	// Client defaults to one with a 10 second timeout.
+	Client *http.Client
+}
+
+// New returns a provider of the named kind (hcaptcha, recaptcha or
+// turnstile) for the given site key and secret.
+func New(name, siteKey, secret string) (*Provider, error) {
+	k, ok := kinds[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown challenge provider %q (want %s)", name, strings.Join(Kinds(), ", "))
+	}
+	if siteKey == "" || secret == "" {
+		return nil, errors.New("challenge provider needs a site key and a secret")
+	}
+	w := k.widget
+	w.SiteKey = siteKey
+	return &Provider{widget: w, secret: secret, VerifyURL: k.verifyURL}, nil
+}
+
+// Widget returns how pages embed the challenge.
+func (p *Provider) Widget() Widget {
+	return p.widget
+}
+
+// Verify checks a solved response with the provider, passing the client's
+// address when known. It returns ErrFailed for a response that was not
+// accepted, and other errors when the provider could not be asked.
+func (p *Provider) Verify(ctx context.Context, response, remoteIP string) error {
+	if response == "" {
+		return ErrFailed
+	}
+	form := url.Values{"secret": {p.secret}, "response": {response}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.VerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("verify challenge: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("verify challenge: status %d", resp.StatusCode)
+	}
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode challenge verification: %w", err)
+	}
+	if !result.Success {
+		if len(result.ErrorCodes) > 0 {
+			return fmt.Errorf("%w: %s", ErrFailed, strings.Join(result.ErrorCodes, ", "))
+		}
+		return ErrFailed
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package challenge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyPostsToSiteverify(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.FormValue("secret") != "s3cret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("response") == "good" && r.FormValue("remoteip") == "192.0.2.1" {
+			_, _ = w.Write([]byte(`{"success":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer ts.Close()
+
+	p, err := New("turnstile", "site-key", "s3cret")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	p.VerifyURL = ts.URL
+	if w := p.Widget(); w.SiteKey != "site-key" || w.Field != "cf-turnstile-response" {
+		t.Fatalf("unexpected widget %+v", w)
+	}
+
+	ctx := context.Background()
+	if err := p.Verify(ctx, "good", "192.0.2.1"); err != nil {
+		t.Fatalf("expected good response accepted, got %v", err)
+	}
+	for _, response := range []string{"bad", ""} {
+		if err := p.Verify(ctx, response, "192.0.2.1"); !errors.Is(err, ErrFailed) {
+			t.Fatalf("%q: expected ErrFailed, got %v", response, err)
+		}
+	}
+
+	ts.Close()
+	if err := p.Verify(ctx, "good", ""); err == nil || errors.Is(err, ErrFailed) {
+		t.Fatalf("expected an unreachable provider reported as an error, got %v", err)
+	}
+
+	if _, err := New("captchaco", "k", "s"); err == nil {
+		t.Fatalf("expected unknown provider rejected")
+	}
+	if _, err := New("hcaptcha", "k", ""); err == nil {
+		t.Fatalf("expected missing secret rejected")
+	}
+}
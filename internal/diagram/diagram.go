@@ -0,0 +1,166 @@
+// Package diagram renders diagram languages such as Mermaid and PlantUML to
+// SVG for markdown pastes.
+package diagram
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSVGBytes caps the output accepted from a renderer.
+const maxSVGBytes = 4 << 20
+
+// Renderer turns diagram source in a language such as "mermaid" into SVG.
+type Renderer interface {
+	Render(ctx context.Context, lang, source string) ([]byte, error)
+	// Supports reports whether lang can be rendered.
+	Supports(lang string) bool
+}
+
+// ErrUnsupported is returned for languages without a renderer.
+var ErrUnsupported = errors.New("unsupported diagram language")
+
+// Command renders each language by piping the source to an external program
+// that writes SVG to stdout, e.g. "mmdc -i - -o - -e svg" for Mermaid or
+// "plantuml -tsvg -pipe" for PlantUML. Untrusted diagrams are parsed outside
+// the server process; wrap the program in a sandbox such as bwrap to confine
+// it further.
+type Command struct {
+	// Commands maps a language to its program and arguments.
+	Commands map[string][]string
+	// Timeout bounds a single render; zero means 10 seconds.
+	Timeout time.Duration
+
+	once sync.Once
+	slot chan struct{}
+}
+
+// NewCommand returns a renderer for the languages with a non-empty command
+// line, split on spaces.
+func NewCommand(commands map[string]string) *Command {
+	c := &Command{Commands: make(map[string][]string)}
+	for lang, line := range commands {
+		if args := strings.Fields(line); len(args) > 0 {
+			c.Commands[lang] = args
+		}
+	}
+	return c
+}
+
+// Supports implements Renderer.
+func (c *Command) Supports(lang string) bool {
+	_, ok := c.Commands[lang]
+	return ok
+}
+
+// Render implements Renderer. At most two renders run at once, since the
+// usual renderers start a browser or a JVM.
+func (c *Command) Render(ctx context.Context, lang, source string) ([]byte, error) {
+	args, ok := c.Commands[lang]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	c.once.Do(func() { c.slot = make(chan struct{}, 2) })
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	select {
+	case c.slot <- struct{}{}:
+		defer func() { <-c.slot }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(source)
+	cmd.Stdout = &limitedBuffer{buf: &stdout, max: maxSVGBytes}
+	cmd.Stderr = &limitedBuffer{buf: &stderr, max: 4 << 10}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("render %s: %w: %s", lang, err, msg)
+		}
+		return nil, fmt.Errorf("render %s: %w", lang, err)
+	}
+	if !bytes.Contains(stdout.Bytes(), []byte("<svg")) {
+		return nil, fmt.Errorf("render %s: output is not SVG", lang)
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer fails writes past max, which stops a runaway renderer.
+type limitedBuffer struct {
+	buf *bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		return 0, errors.New("renderer output too large")
+	}
+	return b.buf.Write(p)
+}
+
+// Cache remembers the SVGs of recently rendered diagrams, keyed by a hash of
+// their language and source, so popular pastes are rendered once.
+type Cache struct {
+	renderer Renderer
+	max      int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type cacheEntry struct {
+	key [sha256.Size]byte
+	svg []byte
+}
+
+// NewCache wraps r with a cache of up to max diagrams.
+func NewCache(r Renderer, max int) *Cache {
+	return &Cache{renderer: r, max: max, order: list.New(), entries: make(map[[sha256.Size]byte]*list.Element)}
+}
+
+// Supports implements Renderer.
+func (c *Cache) Supports(lang string) bool { return c.renderer.Supports(lang) }
+
+// Render implements Renderer. Failures are not cached.
+func (c *Cache) Render(ctx context.Context, lang, source string) ([]byte, error) {
+	key := sha256.Sum256([]byte(lang + "\x00" + source))
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		svg := el.Value.(*cacheEntry).svg
+		c.mu.Unlock()
+		return svg, nil
+	}
+	c.mu.Unlock()
+
+	svg, err := c.renderer.Render(ctx, lang, source)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.order.PushFront(&cacheEntry{key: key, svg: svg})
+		for c.order.Len() > c.max {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return svg, nil
+}
@@ -0,0 +1,50 @@
+package diagram
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type countingRenderer struct{ calls int }
+
+func (r *countingRenderer) Supports(lang string) bool { return lang == "mermaid" }
+
+func (r *countingRenderer) Render(_ context.Context, lang, source string) ([]byte, error) {
+	r.calls++
+	return []byte("<svg>" + source + "</svg>"), nil
+}
+
+func TestCacheRendersEachDiagramOnce(t *testing.T) {
+	inner := &countingRenderer{}
+	c := NewCache(inner, 2)
+	ctx := context.Background()
+
+	for _, src := range []string{"a", "a", "b", "a", "c", "b"} {
+		svg, err := c.Render(ctx, "mermaid", src)
+		if err != nil || string(svg) != "<svg>"+src+"</svg>" {
+			t.Fatalf("render %q: %s %v", src, svg, err)
+		}
+	}
+	// a, b, c miss; the second a hits; c evicts b, so the last b misses.
+	if inner.calls != 4 {
+		t.Fatalf("expected 4 renders, got %d", inner.calls)
+	}
+}
+
+func TestCommandPipesSourceThroughProgram(t *testing.T) {
+	c := NewCommand(map[string]string{"mermaid": "cat", "plantuml": " "})
+	if !c.Supports("mermaid") || c.Supports("plantuml") {
+		t.Fatalf("expected only mermaid to be configured")
+	}
+	svg, err := c.Render(context.Background(), "mermaid", `<svg><text>graph TD</text></svg>`)
+	if err != nil || !strings.Contains(string(svg), "graph TD") {
+		t.Fatalf("render: %s %v", svg, err)
+	}
+	if _, err := c.Render(context.Background(), "mermaid", "graph TD; A-->B"); err == nil {
+		t.Fatalf("expected output without an svg element to be rejected")
+	}
+	if _, err := c.Render(context.Background(), "plantuml", "@startuml"); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
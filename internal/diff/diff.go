@@ -0,0 +1,209 @@
+// Package diff compares texts line by line and renders unified diffs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells bounds the table used to align the differing middle of two texts.
+// Beyond it the middle is reported as replaced wholesale, which is still a
+// correct diff, just not a minimal one.
+const maxCells = 4 << 20
+
+// Op says what happened to a line.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Line is one line of a diff, without its newline.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Hunk is a run of changes with the unchanged lines around them. Starts are
+// 1-based line numbers in the old and new texts.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header is the hunk's "@@ -a,b +c,d @@" line.
+func (h Hunk) Header() string {
+	oldStart, newStart := h.OldStart, h.NewStart
+	// An empty side names the line before the hunk, as diff(1) does.
+	if h.OldLines == 0 {
+		oldStart--
+	}
+	if h.NewLines == 0 {
+		newStart--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldStart, h.OldLines, newStart, h.NewLines)
+}
+
+// Lines diffs a against b. CRLF line endings are treated as LF.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	out := make([]Line, 0, len(x)+len(y)-pre-suf)
+	for _, l := range x[:pre] {
+		out = append(out, Line{Equal, l})
+	}
+	out = append(out, middle(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, l := range x[len(x)-suf:] {
+		out = append(out, Line{Equal, l})
+	}
+	return out
+}
+
+// middle aligns x and y through their longest common subsequence.
+func middle(x, y []string) []Line {
+	n, m := len(x), len(y)
+	if n*m > maxCells {
+		out := make([]Line, 0, n+m)
+		for _, l := range x {
+			out = append(out, Line{Delete, l})
+		}
+		for _, l := range y {
+			out = append(out, Line{Insert, l})
+		}
+		return out
+	}
+	// lcs[i*(m+1)+j] is the LCS length of x[i:] and y[j:].
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case x[i] == y[j]:
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j]
+			default:
+				lcs[i*(m+1)+j] = lcs[i*(m+1)+j+1]
+			}
+		}
+	}
+	out := make([]Line, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			out = append(out, Line{Delete, x[i]})
+			i++
+		default:
+			out = append(out, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, Line{Delete, x[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, Line{Insert, y[j]})
+	}
+	return out
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Changed reports whether lines contain any insertion or deletion.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunks groups lines into hunks with up to context unchanged lines on each
+// side. Changes closer than twice the context share a hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	oldPos, newPos := make([]int, len(lines)), make([]int, len(lines))
+	o, n := 1, 1
+	for i, l := range lines {
+		oldPos[i], newPos[i] = o, n
+		if l.Op != Insert {
+			o++
+		}
+		if l.Op != Delete {
+			n++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Op != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		stop := min(end+context+1, len(lines))
+		h := Hunk{OldStart: oldPos[start], NewStart: newPos[start], Lines: lines[start:stop]}
+		for _, l := range h.Lines {
+			if l.Op != Insert {
+				h.OldLines++
+			}
+			if l.Op != Delete {
+				h.NewLines++
+			}
+		}
+		hunks = append(hunks, h)
+		i = stop
+	}
+	return hunks
+}
+
+// Unified renders the diff from a to b in unified format with three lines of
+// context, labelling the sides oldName and newName. It is empty when the
+// texts have the same lines.
+func Unified(oldName, newName, a, b string) string {
+	hunks := Hunks(Lines(a, b), 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		sb.WriteString(h.Header())
+		sb.WriteByte('\n')
+		for _, l := range h.Lines {
+			sb.WriteByte(byte(l.Op))
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\ntwo\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := strings.Join([]string{
+		"--- upstream",
+		"+++ paste",
+		"@@ -1,5 +1,5 @@",
+		" 1",
+		"-2",
+		"+two",
+		" 3",
+		" 4",
+		" 5",
+		"@@ -10,3 +10,4 @@",
+		" 10",
+		" 11",
+		" 12",
+		"+13",
+		"",
+	}, "\n")
+	if got := Unified("upstream", "paste", a, b); got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLinesIgnoresLineEndings(t *testing.T) {
+	if lines := Lines("a\r\nb\r\n", "a\nb"); Changed(lines) {
+		t.Fatalf("expected no changes, got %+v", lines)
+	}
+	if got := Unified("a", "b", "same\n", "same\n"); got != "" {
+		t.Fatalf("expected empty diff, got %q", got)
+	}
+}
+
+func TestNearbyChangesShareAHunk(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	b := "x\n2\n3\n4\n5\n6\n7\ny\n"
+	if hunks := Hunks(Lines(a, b), 3); len(hunks) != 1 || hunks[0].Header() != "@@ -1,8 +1,8 @@" {
+		t.Fatalf("unexpected hunks: %+v", hunks)
+	}
+}
+
+func TestHunkHeaderForEmptySide(t *testing.T) {
+	hunks := Hunks(Lines("", "new\n"), 3)
+	if len(hunks) != 1 || hunks[0].Header() != "@@ -0,0 +1,1 @@" {
+		t.Fatalf("unexpected hunks: %+v", hunks)
+	}
+}
+
+func TestLargeMiddleFallsBackToReplacement(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		a.WriteString("a\n")
+		b.WriteString("b\n")
+	}
+	lines := Lines(a.String(), b.String())
+	if len(lines) != 6000 || lines[0].Op != Delete || lines[5999].Op != Insert {
+		t.Fatalf("unexpected fallback diff: %d lines", len(lines))
+	}
+}
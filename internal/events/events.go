@@ -0,0 +1,101 @@
+// Package events delivers notifications about paste state transitions to
+// logs and external receivers.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Type names a transition.
+type Type string
+
+const (
+	PasteQuarantined Type = "paste.quarantined"
+	PasteReleased    Type = "paste.released"
+	PasteDeleted     Type = "paste.deleted"
+	PasteRestored    Type = "paste.restored"
+	PasteFlagged     Type = "paste.flagged"
+	PasteReported    Type = "paste.reported"
+	PasteEdited      Type = "paste.edited"
+	PasteTakenDown   Type = "paste.taken_down"
+	// PasteRejected reports content refused before it was saved; its
+	// PasteID is empty.
+	PasteRejected Type = "paste.rejected"
+)
+
+// Event describes a single transition.
+type Event struct {
+	Type    Type      `json:"type"`
+	PasteID string    `json:"paste_id"`
+	Reason  string    `json:"reason,omitempty"`
+	Actor   string    `json:"actor"`
+	Time    time.Time `json:"time"`
+}
+
+// Sink receives events. Implementations must be safe for concurrent use.
+type Sink interface {
+	Emit(ctx context.Context, e Event) error
+}
+
+// Multi fans an event out to every sink, returning the first error.
+type Multi []Sink
+
+// Emit implements Sink.
+func (m Multi) Emit(ctx context.Context, e Event) error {
+	var first error
+	for _, s := range m {
+		if err := s.Emit(ctx, e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Log writes events to a structured logger.
+type Log struct {
+	Logger *slog.Logger
+}
+
+// Emit implements Sink.
+func (l Log) Emit(ctx context.Context, e Event) error {
+	l.Logger.InfoContext(ctx, "paste event", "type", string(e.Type), "id", e.PasteID, "actor", e.Actor, "reason", e.Reason)
+	return nil
+}
+
+// Webhook posts events as JSON to a URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Emit implements Sink.
+func (w Webhook) Emit(ctx context.Context, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post event: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("post event: unexpected status %s", resp.Status)
+	}
+	return nil
+}
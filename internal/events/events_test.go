@@ -0,0 +1,39 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookPostsJSON(t *testing.T) {
+	var got Event
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	e := Event{Type: PasteQuarantined, PasteID: "abc", Reason: "spam", Actor: "scanner", Time: time.Now().UTC()}
+	if err := (Webhook{URL: ts.URL}).Emit(context.Background(), e); err != nil {
+		t.Fatalf("emit: %v", err)
+	}
+	if got.Type != PasteQuarantined || got.PasteID != "abc" || got.Reason != "spam" {
+		t.Fatalf("unexpected event received: %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := (Multi{Webhook{URL: failing.URL}}).Emit(context.Background(), e); err == nil {
+		t.Fatalf("expected error for failing receiver")
+	}
+}
@@ -0,0 +1,314 @@
+// Package filter screens new pastes before they are saved: against regular
+// expression and keyword blocklists, a cap on the links a paste may carry,
+// and a list of banned content hashes that admins manage at runtime.
+package filter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action is what happens to a paste a rule matches.
+type Action string
+
+const (
+	// Flag saves the paste but marks it for an admin to review.
+	Flag Action = "flag"
+	// Quarantine saves the paste hidden from readers until released.
+	Quarantine Action = "quarantine"
+	// Reject refuses the paste.
+	Reject Action = "reject"
+)
+
+// rank orders actions by severity; the most severe match wins.
+func (a Action) rank() int {
+	switch a {
+	case Flag:
+		return 1
+	case Quarantine:
+		return 2
+	case Reject:
+		return 3
+	}
+	return 0
+}
+
+// ParseAction reads an action name.
+func ParseAction(v string) (Action, error) {
+	a := Action(strings.ToLower(strings.TrimSpace(v)))
+	if a.rank() == 0 {
+		return "", fmt.Errorf("unknown filter action %q (want reject, quarantine or flag)", v)
+	}
+	return a, nil
+}
+
+// Kinds of rule.
+const (
+	KindRegex   = "regex"
+	KindKeyword = "keyword"
+	KindURLs    = "urls"
+)
+
+// urlPattern counts links for KindURLs rules.
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?|ftp)://`)
+
+// Rule is one configured check.
+type Rule struct {
+	Action Action
+	Kind   string
+	// Value is the expression, the keyword, or the most links allowed.
+	Value string
+
+	re      *regexp.Regexp
+	keyword string
+	maxURLs int
+}
+
+// ParseRule reads a rule written as action:kind:value, for example
+// "reject:regex:(?i)cheap pills", "quarantine:keyword:casino" or
+// "flag:urls:10". Keywords match case-insensitively anywhere in the text;
+// a urls rule matches pastes with more links than the value.
+func ParseRule(spec string) (Rule, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return Rule{}, fmt.Errorf("filter rule %q: expected action:kind:value", spec)
+	}
+	action, err := ParseAction(parts[0])
+	if err != nil {
+		return Rule{}, fmt.Errorf("filter rule %q: %w", spec, err)
+	}
+	r := Rule{Action: action, Kind: parts[1], Value: parts[2]}
+	switch r.Kind {
+	case KindRegex:
+		if r.re, err = regexp.Compile(r.Value); err != nil {
+			return Rule{}, fmt.Errorf("filter rule %q: %w", spec, err)
+		}
+	case KindKeyword:
+		r.keyword = strings.ToLower(r.Value)
+	case KindURLs:
+		if r.maxURLs, err = strconv.Atoi(r.Value); err != nil || r.maxURLs < 0 {
+			return Rule{}, fmt.Errorf("filter rule %q: urls needs a count of links allowed", spec)
+		}
+	default:
+		return Rule{}, fmt.Errorf("filter rule %q: unknown kind %q (want regex, keyword or urls)", spec, r.Kind)
+	}
+	return r, nil
+}
+
+func (r Rule) String() string {
+	return string(r.Action) + ":" + r.Kind + ":" + r.Value
+}
+
+// match reports whether r matches text, given it lowercased and the number
+// of links in it.
+func (r Rule) match(text, lowered string, links int) bool {
+	switch r.Kind {
+	case KindRegex:
+		return r.re.MatchString(text)
+	case KindKeyword:
+		return strings.Contains(lowered, r.keyword)
+	case KindURLs:
+		return links > r.maxURLs
+	}
+	return false
+}
+
+// reason describes a match of r for admins.
+func (r Rule) reason() string {
+	switch r.Kind {
+	case KindURLs:
+		return "more than " + r.Value + " links"
+	default:
+		return r.Kind + " " + strconv.Quote(r.Value)
+	}
+}
+
+// Verdict is the outcome of checking a paste. The zero Verdict lets it
+// through.
+type Verdict struct {
+	Action Action
+	Reason string
+}
+
+// Ban is a banned content hash.
+type Ban struct {
+	Hash      string    `json:"hash"`
+	Action    Action    `json:"action"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Hash returns the hash content is banned by: hex SHA-256.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Filter checks pastes against its rules and banned hashes. It is safe for
+// concurrent use.
+type Filter struct {
+	rules []Rule
+	path  string
+
+	mu   sync.RWMutex
+	bans map[string]Ban
+}
+
+// New returns a filter with rules. Banned hashes are kept in the JSON file
+// at path, loaded now and rewritten on every change; with no path they only
+// last until restart.
+func New(rules []Rule, path string) (*Filter, error) {
+	f := &Filter{rules: rules, path: path, bans: make(map[string]Ban)}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read banned hashes: %w", err)
+	}
+	var bans []Ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, fmt.Errorf("decode banned hashes %s: %w", path, err)
+	}
+	for _, b := range bans {
+		f.bans[b.Hash] = b
+	}
+	return f, nil
+}
+
+// Rules returns the configured rules.
+func (f *Filter) Rules() []Rule {
+	return f.rules
+}
+
+// Check screens a paste's content and any further text that goes with it,
+// such as the title and metadata values. Banned hashes are matched against
+// the content alone. When several rules match, the most severe action wins.
+func (f *Filter) Check(content string, extra ...string) Verdict {
+	var v Verdict
+	f.mu.RLock()
+	if b, ok := f.bans[Hash(content)]; ok {
+		v = Verdict{Action: b.Action, Reason: "banned content"}
+	}
+	f.mu.RUnlock()
+	if v.Action == Reject || len(f.rules) == 0 {
+		return v
+	}
+
+	text := content
+	if len(extra) > 0 {
+		text = strings.Join(append([]string{content}, extra...), "\n")
+	}
+	lowered := strings.ToLower(text)
+	links := len(urlPattern.FindAllStringIndex(text, -1))
+	for _, r := range f.rules {
+		if r.Action.rank() > v.Action.rank() && r.match(text, lowered, links) {
+			v = Verdict{Action: r.Action, Reason: "matched " + r.reason()}
+		}
+	}
+	return v
+}
+
+// Ban bans content by its hash.
+func (f *Filter) Ban(hash string, action Action, note string, now time.Time) error {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("invalid content hash %q: want hex SHA-256", hash)
+	}
+	if action.rank() == 0 {
+		return fmt.Errorf("unknown filter action %q", action)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	prev, had := f.bans[hash]
+	f.bans[hash] = Ban{Hash: hash, Action: action, Note: note, CreatedAt: now.UTC()}
+	if err := f.saveLocked(); err != nil {
+		if had {
+			f.bans[hash] = prev
+		} else {
+			delete(f.bans, hash)
+		}
+		return err
+	}
+	return nil
+}
+
+// Unban lifts the ban on hash, reporting whether there was one.
+func (f *Filter) Unban(hash string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	prev, ok := f.bans[hash]
+	if !ok {
+		return false, nil
+	}
+	delete(f.bans, hash)
+	if err := f.saveLocked(); err != nil {
+		f.bans[hash] = prev
+		return false, err
+	}
+	return true, nil
+}
+
+// Bans lists the banned hashes, newest first.
+func (f *Filter) Bans() []Ban {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	out := make([]Ban, 0, len(f.bans))
+	for _, b := range f.bans {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].Hash < out[j].Hash
+	})
+	return out
+}
+
+// saveLocked rewrites the banned hashes file through a temporary file, so a
+// crash never leaves it half written.
+func (f *Filter) saveLocked() error {
+	if f.path == "" {
+		return nil
+	}
+	bans := make([]Ban, 0, len(f.bans))
+	for _, b := range f.bans {
+		bans = append(bans, b)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Hash < bans[j].Hash })
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save banned hashes: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save banned hashes: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save banned hashes: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("save banned hashes: %w", err)
+	}
+	return nil
+}
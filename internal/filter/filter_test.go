@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func mustRules(t *testing.T, specs ...string) []Rule {
+	t.Helper()
+	var rules []Rule
+	for _, spec := range specs {
+		r, err := ParseRule(spec)
+		if err != nil {
+			t.Fatalf("parse %q: %v", spec, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func TestCheckPicksTheMostSevereMatch(t *testing.T) {
+	f, err := New(mustRules(t, "flag:urls:2", "quarantine:keyword:Casino", "reject:regex:(?i)cheap\\s+pills"), "")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	for _, tc := range []struct {
+		content string
+		extra   []string
+		want    Action
+	}{
+		{"hello world", nil, ""},
+		{"see https://a.example http://b.example", nil, ""},
+		{"see https://a.example http://b.example ftp://c.example", nil, Flag},
+		{"best CASINO https://a.example http://b.example ftp://c.example", nil, Quarantine},
+		{"innocent", []string{"Cheap  Pills"}, Reject},
+		{"casino with cheap pills", nil, Reject},
+	} {
+		if v := f.Check(tc.content, tc.extra...); v.Action != tc.want {
+			t.Fatalf("%q: expected %q, got %+v", tc.content, tc.want, v)
+		}
+	}
+
+	for _, spec := range []string{"reject:regex", "drop:keyword:x", "flag:urls:many", "flag:glob:*", "reject:regex:("} {
+		if _, err := ParseRule(spec); err == nil {
+			t.Fatalf("expected %q rejected", spec)
+		}
+	}
+}
+
+func TestBannedHashesPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.json")
+	f, err := New(nil, path)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := f.Ban("nothex", Reject, "", time.Now()); err == nil {
+		t.Fatalf("expected an invalid hash refused")
+	}
+	if err := f.Ban(Hash("spam"), Quarantine, "seen on the feed", time.Now()); err != nil {
+		t.Fatalf("ban: %v", err)
+	}
+	if v := f.Check("spam"); v.Action != Quarantine || v.Reason != "banned content" {
+		t.Fatalf("expected banned content quarantined, got %+v", v)
+	}
+	if v := f.Check("spam!"); v.Action != "" {
+		t.Fatalf("expected other content let through, got %+v", v)
+	}
+
+	reopened, err := New(nil, path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if bans := reopened.Bans(); len(bans) != 1 || bans[0].Note != "seen on the feed" {
+		t.Fatalf("expected the ban kept across restarts, got %+v", bans)
+	}
+	if ok, err := reopened.Unban(Hash("spam")); !ok || err != nil {
+		t.Fatalf("unban: %v %v", ok, err)
+	}
+	if again, _ := New(nil, path); len(again.Bans()) != 0 {
+		t.Fatalf("expected the unban saved")
+	}
+}
@@ -0,0 +1,355 @@
+// Package geoip looks up the country of IP addresses in MaxMind DB files,
+// such as GeoLite2-Country or the free DB-IP country databases.
+//
+// Only what country lookups need is implemented: the search tree with 24,
+// 28 and 32 bit records, and decoding of the data section.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of the file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSeparator is the size of the zero bytes between the search tree and
+// the data section.
+const dataSeparator = 16
+
+// ErrInvalidDatabase is returned for files that are not MaxMind DBs, or
+// are damaged.
+var ErrInvalidDatabase = errors.New("geoip: invalid database")
+
+// DB is an open MaxMind DB. It is safe for concurrent use.
+type DB struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node IPv4 lookups start from in an IPv6 tree, found
+	// by following the 96 zero bits of an IPv4-mapped address.
+	ipv4Start uint
+	// Type is the database type from the metadata, such as
+	// "GeoLite2-Country".
+	Type string
+}
+
+// Open reads the database at path into memory.
+func Open(path string) (*DB, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(b)
+}
+
+// New parses a database held in b, which it keeps.
+func New(b []byte) (*DB, error) {
+	at := bytes.LastIndex(b, metadataMarker)
+	if at < 0 {
+		return nil, fmt.Errorf("%w: no metadata", ErrInvalidDatabase)
+	}
+	meta := b[at+len(metadataMarker):]
+	v, _, err := decoder{buf: meta}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidDatabase, err)
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+	db := &DB{
+		nodeCount:  uint(asUint(m["node_count"])),
+		recordSize: uint(asUint(m["record_size"])),
+		ipVersion:  uint(asUint(m["ip_version"])),
+	}
+	db.Type, _ = m["database_type"].(string)
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, db.recordSize)
+	}
+	if db.ipVersion != 4 && db.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported ip version %d", ErrInvalidDatabase, db.ipVersion)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSeparator > uint(at) {
+		return nil, fmt.Errorf("%w: search tree exceeds file", ErrInvalidDatabase)
+	}
+	db.tree = b[:treeSize]
+	db.data = b[treeSize+dataSeparator : at]
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (db *DB) record(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := db.tree[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7 : node*7+7]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(db.tree[off : off+4]))
+	}
+}
+
+// Lookup returns the record stored for addr, or nil when the database has
+// none.
+func (db *DB) Lookup(addr netip.Addr) (map[string]any, error) {
+	addr = addr.Unmap()
+	var ip []byte
+	node := uint(0)
+	switch {
+	case addr.Is4():
+		a := addr.As4()
+		ip = a[:]
+		node = db.ipv4Start
+	case addr.Is6() && db.ipVersion == 6:
+		a := addr.As16()
+		ip = a[:]
+	default:
+		return nil, nil
+	}
+	for i := 0; i < len(ip)*8 && node < db.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-i%8)) & 1
+		node = db.record(node, bit)
+	}
+	if node == db.nodeCount {
+		return nil, nil
+	}
+	if node < db.nodeCount {
+		return nil, fmt.Errorf("%w: search tree too deep", ErrInvalidDatabase)
+	}
+	offset := node - db.nodeCount - dataSeparator
+	v, _, err := decoder{buf: db.data}.decode(offset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	m, _ := v.(map[string]any)
+	return m, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country addr is
+// located in, falling back to the country its network is registered in.
+// It returns "" for addresses the database does not know.
+func (db *DB) Country(addr netip.Addr) (string, error) {
+	m, err := db.Lookup(addr)
+	if err != nil || m == nil {
+		return "", err
+	}
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := m[key].(map[string]any); ok {
+			if code, ok := c["iso_code"].(string); ok && code != "" {
+				return code, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Data section types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds nesting, so damaged files cannot recurse forever.
+const maxDepth = 32
+
+type decoder struct {
+	buf   []byte
+	depth int
+}
+
+var errTruncated = errors.New("data section truncated")
+
+// decode decodes the value at offset, returning it and the offset after it.
+func (d decoder) decode(offset uint) (any, uint, error) {
+	if d.depth > maxDepth {
+		return nil, 0, errors.New("data nested too deeply")
+	}
+	d.depth++
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(ptr)
+		return v, next, err
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errTruncated
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errTruncated
+		}
+		extra := uint(0)
+		for _, b := range d.buf[offset : offset+n] {
+			extra = extra<<8 | uint(b)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for range size {
+			k, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			v, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 1024))
+		for range size {
+			v, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes, typeUint128:
+		return bytes.Clone(b), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("bad double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("bad float size")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("bad integer size")
+		}
+		var u uint64
+		for _, c := range b {
+			u = u<<8 | uint64(c)
+		}
+		return u, offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("bad integer size")
+		}
+		var u uint32
+		for _, c := range b {
+			u = u<<8 | uint32(c)
+		}
+		return int32(u), offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// pointer decodes the pointer whose control byte is ctrl, returning the
+// offset it points to and the offset after it.
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errTruncated
+	}
+	b := d.buf[offset : offset+n]
+	v := uint(ctrl & 0x7)
+	if n == 4 {
+		v = 0
+	}
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+func asUint(v any) uint64 {
+	u, _ := v.(uint64)
+	return u
+}
@@ -0,0 +1,102 @@
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+// Minimal encoders for building test databases.
+
+func encString(s string) []byte {
+	return append([]byte{byte(typeString<<5 | len(s))}, s...)
+}
+
+func encUint(typ int, v uint32, n int) []byte {
+	out := []byte{byte(typ<<5 | n)}
+	for i := n - 1; i >= 0; i-- {
+		out = append(out, byte(v>>(8*i)))
+	}
+	return out
+}
+
+func encMap(pairs ...[]byte) []byte {
+	out := []byte{byte(typeMap<<5 | len(pairs)/2)}
+	for _, p := range pairs {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func countryRecord(code string) []byte {
+	return encMap(encString("country"), encMap(encString("iso_code"), encString(code)))
+}
+
+// buildIPv4DB returns a database with 24 bit records where addresses whose
+// first bit is 0 are in "US", and those starting 10 are registered in "DE";
+// addresses starting 11 are unknown.
+func buildIPv4DB(t *testing.T) []byte {
+	t.Helper()
+	us := countryRecord("US")
+	de := encMap(encString("registered_country"), encMap(encString("iso_code"), encString("DE")))
+	// A pointer to the DE record exercises pointer decoding.
+	data := append(append([]byte{}, us...), de...)
+	ptrOff := len(data)
+	data = append(data, byte(typePointer<<5), byte(len(us)))
+
+	const nodes = 2
+	rec := func(v int) []byte { return []byte{byte(v >> 16), byte(v >> 8), byte(v)} }
+	dataRec := func(off int) int { return nodes + dataSeparator + off }
+	var tree []byte
+	tree = append(tree, rec(dataRec(0))...)      // node 0, bit 0: US
+	tree = append(tree, rec(1)...)               // node 0, bit 1: node 1
+	tree = append(tree, rec(dataRec(ptrOff))...) // node 1, bit 0: DE via pointer
+	tree = append(tree, rec(nodes)...)           // node 1, bit 1: no data
+
+	var b bytes.Buffer
+	b.Write(tree)
+	b.Write(make([]byte, dataSeparator))
+	b.Write(data)
+	b.Write(metadataMarker)
+	b.Write(encMap(
+		encString("node_count"), encUint(typeUint32, nodes, 1),
+		encString("record_size"), encUint(typeUint16, 24, 1),
+		encString("ip_version"), encUint(typeUint16, 4, 1),
+		encString("database_type"), encString("Test-Country"),
+	))
+	return b.Bytes()
+}
+
+func TestCountry(t *testing.T) {
+	db, err := New(buildIPv4DB(t))
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if db.Type != "Test-Country" {
+		t.Fatalf("unexpected type %q", db.Type)
+	}
+	for addr, want := range map[string]string{
+		"1.2.3.4":          "US",
+		"127.0.0.1":        "US",
+		"::ffff:127.0.0.1": "US",
+		"130.1.1.1":        "DE",
+		"200.1.1.1":        "",
+		"2001:db8::1":      "",
+	} {
+		got, err := db.Country(netip.MustParseAddr(addr))
+		if err != nil || got != want {
+			t.Fatalf("%s: got %q %v, want %q", addr, got, err, want)
+		}
+	}
+}
+
+func TestRejectsInvalidDatabases(t *testing.T) {
+	if _, err := New([]byte("not a database")); !errors.Is(err, ErrInvalidDatabase) {
+		t.Fatalf("expected invalid database, got %v", err)
+	}
+	b := buildIPv4DB(t)
+	if _, err := New(b[len(b)-20:]); !errors.Is(err, ErrInvalidDatabase) {
+		t.Fatalf("expected a truncated database refused, got %v", err)
+	}
+}
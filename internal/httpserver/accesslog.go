@@ -0,0 +1,61 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// accessLog logs every request through the server's logger, so access logs
+// share its output, format and level with everything else. Request and
+// trace IDs come from the context. Paths are scrubbed of secrets, query
+// strings are left out and client addresses are anonymized.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	if s.logger == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		s.logger.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", scrubPath(r.URL.Path)),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", s.anonymizedIP(r)),
+		)
+	})
+}
+
+// anonymizedIP is the client address as access logs show it: its network,
+// a /24 for IPv4 and a /48 for IPv6, or in privacy mode the daily hash.
+func (s *Server) anonymizedIP(r *http.Request) string {
+	ip := ClientIP(r, s.proxies)
+	if s.privacy != nil {
+		return s.ipHash(ip)
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	bits := 24
+	if addr.Is6() {
+		bits = 48
+	}
+	p, _ := addr.WithZone("").Prefix(bits)
+	return p.Addr().String()
+}
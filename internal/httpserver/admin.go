@@ -0,0 +1,526 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	adminCookie     = "tp_admin"
+	adminSessionTTL = 12 * time.Hour
+	adminPageSize   = 50
+)
+
+type adminSession struct {
+	Nonce   string `json:"n"`
+	Expires int64  `json:"e"`
+}
+
+type adminLoginPageData struct {
+	Error        string
+	TokenEnabled bool
+	LoginEnabled bool
+}
+
+func (d adminLoginPageData) PageTitle() string { return "Admin · Tiny Pastebin" }
+func (d adminLoginPageData) NoIndex() bool     { return true }
+
+type adminPageData struct {
+	Stats         adminStats
+	Pastes        []adminPasteRow
+	Total         int
+	Filter        adminFilter
+	Query         string
+	PrevURL       string
+	NextURL       string
+	CSRF          string
+	Notice        string
+	Error         string
+	SyntaxOptions []option
+	ExpireOptions []option
+	KeysEnabled   bool
+	SoftDelete    bool
+	FilterEnabled bool
+	ReportQueue   bool
+	OpenReports   int
+	AuditLog      bool
+}
+
+func (d adminPageData) PageTitle() string { return "Admin · Tiny Pastebin" }
+func (d adminPageData) NoIndex() bool     { return true }
+
+// adminFilter holds the dashboard's filter form as entered.
+type adminFilter struct {
+	Syntax      string
+	MinSize     string
+	MaxSize     string
+	Newer       string
+	Older       string
+	IPHash      string
+	Owner       string
+	Quarantined bool
+	Flagged     bool
+	Deleted     bool
+	Page        int
+}
+
+type adminPasteRow struct {
+	ID          string
+	Title       string
+	SyntaxLabel string
+	Size        int
+	CreatedAt   time.Time
+	ExpiresIn   string
+	Protected   bool
+	Public      bool
+	Quarantined bool
+	Reason      string
+	FlagReason  string
+	Owner       string
+	IPHash      string
+	DeletedAt   time.Time
+	DeletedBy   string
+	TakenDownAt time.Time
+	Notice      string
+}
+
+type adminStats struct {
+	Total       int
+	Active      int
+	Expired     int
+	Protected   int
+	Public      int
+	Quarantined int
+	Owned       int
+	TotalBytes  int
+	Syntaxes    []syntaxStat
+}
+
+type syntaxStat struct {
+	Label string
+	Count int
+	Bytes int
+}
+
+func (s *Server) adminEnabled() bool {
+	return s.adminToken != "" || len(s.adminUsers) > 0
+}
+
+// adminIdentity identifies the administrator behind a request: a bearer token,
+// a dashboard session opened with the admin token, or a signed-in user listed
+// as an admin. The identity keys the CSRF token of dashboard forms. Admin
+// credentials count for nothing on the public listener when admin routes
+// live on the admin listener.
+func (s *Server) adminIdentity(r *http.Request) (string, bool) {
+	if !s.adminReachable(r) {
+		return "", false
+	}
+	if s.adminToken != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+				return "bearer", true
+			}
+			return "", false
+		}
+		var as adminSession
+		if s.readSealedCookie(r, adminCookie, &as) && as.Nonce != "" && s.nowTime().Unix() <= as.Expires {
+			return "token:" + as.Nonce, true
+		}
+	}
+	if owner := s.ownerOf(r); owner != "" && s.adminUsers[owner] {
+		return "user:" + owner, true
+	}
+	return "", false
+}
+
+func (s *Server) adminCSRF(identity string) string {
+	return s.sealMAC("admin-csrf", identity)
+}
+
+// requireAdminPage guards the dashboard. Browsers without admin rights are
+// sent to the login page; form posts must carry the dashboard CSRF token
+// unless authenticated with a bearer token.
+func (s *Server) requireAdminPage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := s.adminIdentity(r)
+		if !ok {
+			if r.Method == http.MethodGet {
+				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				return
+			}
+			s.render(w, r, http.StatusForbidden, "error", errorPageData{Message: "Forbidden"})
+			return
+		}
+		if r.Method == http.MethodPost && identity != "bearer" {
+			if !s.macMatches("admin-csrf", identity, r.PostFormValue("csrf")) {
+				s.render(w, r, http.StatusForbidden, "error", errorPageData{Message: "Forbidden"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleAdminLoginForm(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.adminIdentity(r); ok {
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return
+	}
+	s.render(w, r, http.StatusOK, "admin-login", adminLoginPageData{
+		TokenEnabled: s.adminToken != "",
+		LoginEnabled: s.login != nil && len(s.adminUsers) > 0,
+	})
+}
+
+func (s *Server) handleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	data := adminLoginPageData{
+		TokenEnabled: s.adminToken != "",
+		LoginEnabled: s.login != nil && len(s.adminUsers) > 0,
+	}
+	token := r.PostFormValue("token")
+	if s.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		data.Error = "Invalid admin token"
+		s.recordRequest(r, storage.AuditEntry{Action: auditAdminLoginFailed})
+		s.render(w, r, http.StatusUnauthorized, "admin-login", data)
+		return
+	}
+	as := adminSession{Nonce: randomToken(), Expires: s.nowTime().Add(adminSessionTTL).Unix()}
+	if err := s.setSealedCookie(w, r, adminCookie, "/admin", as, adminSessionTTL); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	s.recordRequest(r, storage.AuditEntry{Action: auditAdminLogin, Actor: "token:" + as.Nonce})
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	s.clearCookie(w, adminCookie, "/admin")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminDashboard(w http.ResponseWriter, r *http.Request) {
+	identity, _ := s.adminIdentity(r)
+	query := r.URL.Query()
+	filter := adminFilter{
+		Syntax:      query.Get("syntax"),
+		MinSize:     query.Get("min_size"),
+		MaxSize:     query.Get("max_size"),
+		Newer:       query.Get("newer"),
+		Older:       query.Get("older"),
+		IPHash:      strings.TrimSpace(query.Get("ip")),
+		Owner:       strings.TrimSpace(query.Get("owner")),
+		Quarantined: query.Get("quarantined") == "1",
+		Flagged:     query.Get("flagged") == "1",
+		Deleted:     query.Get("deleted") == "1",
+	}
+	filter.Page, _ = strconv.Atoi(query.Get("page"))
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	data := adminPageData{
+		Filter: filter,
+		CSRF:   s.adminCSRF(identity),
+		Notice: query.Get("notice"),
+
+		KeysEnabled:   s.keys != nil,
+		SoftDelete:    s.deleteGrace > 0,
+		FilterEnabled: s.filter != nil,
+		ReportQueue:   s.reports != nil,
+		AuditLog:      s.audit != nil,
+	}
+	if s.reports != nil {
+		reports, err := s.reports.ListReports(r.Context())
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		data.OpenReports = len(reports)
+	}
+
+	opts, err := s.adminListOptions(filter)
+	if err != nil {
+		data.Error = err.Error()
+	}
+
+	stats, err := s.adminStats(r)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	data.Stats = stats
+
+	if data.Error == "" {
+		total, err := s.store.Count(r.Context(), opts)
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		pastes, err := s.store.List(r.Context(), opts)
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		data.Total = total
+		now := s.nowTime()
+		for _, p := range pastes {
+			data.Pastes = append(data.Pastes, adminPasteRow{
+				ID:          p.ID,
+				Title:       displayTitle(p),
+				SyntaxLabel: syntaxLabel(p.Syntax),
+				Size:        p.Size,
+				CreatedAt:   p.CreatedAt,
+				ExpiresIn:   remaining(p.ExpiresAt, now),
+				Protected:   p.PasswordHash != "",
+				Public:      p.Public,
+				Quarantined: p.Quarantined,
+				Reason:      p.QuarantineReason,
+				FlagReason:  flagReason(p),
+				Owner:       p.Owner,
+				IPHash:      p.IPHash,
+				DeletedAt:   p.DeletedAt,
+				DeletedBy:   p.DeletedBy,
+				TakenDownAt: p.TakenDownAt,
+				Notice:      p.TakedownNotice,
+			})
+		}
+		if filter.Page > 1 {
+			data.PrevURL = adminPageURL(query, filter.Page-1)
+		}
+		if filter.Page*adminPageSize < total {
+			data.NextURL = adminPageURL(query, filter.Page+1)
+		}
+	}
+
+	query.Del("notice")
+	data.Query = query.Encode()
+	data.SyntaxOptions = []option{{Value: "", Label: "Any", Selected: filter.Syntax == ""}}
+	for _, v := range syntaxWhitelist {
+		data.SyntaxOptions = append(data.SyntaxOptions, option{Value: v, Label: syntaxLabel(v), Selected: v == filter.Syntax})
+	}
+	for _, c := range expireChoices {
+		data.ExpireOptions = append(data.ExpireOptions, option{Value: c.Value, Label: c.Label, Selected: c.Value == defaultExpire})
+	}
+	s.render(w, r, http.StatusOK, "admin", data)
+}
+
+// adminListOptions converts the dashboard filter into store list options.
+func (s *Server) adminListOptions(f adminFilter) (storage.ListOptions, error) {
+	opts := storage.ListOptions{
+		Syntax:      f.Syntax,
+		IPHash:      f.IPHash,
+		Owner:       f.Owner,
+		Quarantined: f.Quarantined,
+		Deleted:     f.Deleted,
+		Offset:      (f.Page - 1) * adminPageSize,
+		Limit:       adminPageSize,
+	}
+	if f.Flagged {
+		opts.Metadata = map[string]string{filterReviewKey: filterReviewPending}
+	}
+	var err error
+	if opts.MinSize, err = parseSizeFilter(f.MinSize); err != nil {
+		return opts, fmt.Errorf("invalid minimum size: %w", err)
+	}
+	if opts.MaxSize, err = parseSizeFilter(f.MaxSize); err != nil {
+		return opts, fmt.Errorf("invalid maximum size: %w", err)
+	}
+	now := s.nowTime()
+	if f.Newer != "" {
+		d, err := parseAge(f.Newer)
+		if err != nil {
+			return opts, fmt.Errorf("invalid age %q", f.Newer)
+		}
+		opts.CreatedAfter = now.Add(-d)
+	}
+	if f.Older != "" {
+		d, err := parseAge(f.Older)
+		if err != nil {
+			return opts, fmt.Errorf("invalid age %q", f.Older)
+		}
+		opts.CreatedBefore = now.Add(-d)
+	}
+	return opts, nil
+}
+
+// parseSizeFilter accepts a byte count with an optional K or M suffix.
+func parseSizeFilter(v string) (int, error) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	if v == "" {
+		return 0, nil
+	}
+	mult := 1
+	switch {
+	case strings.HasSuffix(v, "K"):
+		mult, v = 1024, strings.TrimSuffix(v, "K")
+	case strings.HasSuffix(v, "M"):
+		mult, v = 1024*1024, strings.TrimSuffix(v, "M")
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errors.New("expected a size such as 512, 4K or 2M")
+	}
+	return n * mult, nil
+}
+
+// parseAge accepts Go durations plus a day suffix, e.g. "90m", "12h", "7d".
+func parseAge(v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.New("invalid days")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid duration")
+	}
+	return d, nil
+}
+
+func adminPageURL(query url.Values, page int) string {
+	q := url.Values{}
+	for k, v := range query {
+		if k != "notice" {
+			q[k] = v
+		}
+	}
+	q.Set("page", strconv.Itoa(page))
+	return "/admin?" + q.Encode()
+}
+
+// adminReturnURL sends the admin back to the dashboard view they acted from.
+func adminReturnURL(r *http.Request, notice string) string {
+	q, _ := url.ParseQuery(r.PostFormValue("return"))
+	if q == nil {
+		q = url.Values{}
+	}
+	q.Set("notice", notice)
+	return "/admin?" + q.Encode()
+}
+
+func (s *Server) adminStats(r *http.Request) (adminStats, error) {
+	pastes, err := s.store.List(r.Context(), storage.ListOptions{})
+	if err != nil {
+		return adminStats{}, err
+	}
+	now := s.nowTime()
+	var st adminStats
+	bySyntax := make(map[string]*syntaxStat)
+	for _, p := range pastes {
+		st.Total++
+		st.TotalBytes += p.Size
+		if p.HasExpiration() && !p.ExpiresAt.After(now) {
+			st.Expired++
+		} else {
+			st.Active++
+		}
+		if p.PasswordHash != "" {
+			st.Protected++
+		}
+		if p.Public {
+			st.Public++
+		}
+		if p.Quarantined {
+			st.Quarantined++
+		}
+		if p.Owner != "" {
+			st.Owned++
+		}
+		entry, ok := bySyntax[p.Syntax]
+		if !ok {
+			entry = &syntaxStat{Label: syntaxLabel(p.Syntax)}
+			bySyntax[p.Syntax] = entry
+		}
+		entry.Count++
+		entry.Bytes += p.Size
+	}
+	for _, entry := range bySyntax {
+		st.Syntaxes = append(st.Syntaxes, *entry)
+	}
+	sort.Slice(st.Syntaxes, func(i, j int) bool {
+		if st.Syntaxes[i].Count == st.Syntaxes[j].Count {
+			return st.Syntaxes[i].Label < st.Syntaxes[j].Label
+		}
+		return st.Syntaxes[i].Count > st.Syntaxes[j].Count
+	})
+	return st, nil
+}
+
+// handleAdminBulkDelete removes every paste selected on the dashboard.
+func (s *Server) handleAdminBulkDelete(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.render(w, r, http.StatusBadRequest, "error", errorPageData{Message: "Unable to parse form"})
+		return
+	}
+	ids := r.PostForm["id"]
+	deleted, held := 0, 0
+	for _, id := range ids {
+		err := s.deletePaste(r.Context(), id, actorAdmin)
+		switch {
+		case err == nil:
+			deleted++
+		case errors.Is(err, storage.ErrNotFound):
+		case errors.Is(err, errLegalHold):
+			held++
+		default:
+			s.serverError(w, r, err)
+			return
+		}
+	}
+	if s.logger != nil && deleted > 0 {
+		s.logger.InfoContext(r.Context(), "admin bulk delete", "count", deleted)
+	}
+	notice := fmt.Sprintf("Deleted %s.", plural(deleted, "paste"))
+	if held > 0 {
+		notice += fmt.Sprintf(" Kept %s under legal hold; purge them instead.", plural(held, "paste"))
+	}
+	http.Redirect(w, r, adminReturnURL(r, notice), http.StatusSeeOther)
+}
+
+// handleAdminExpiry overrides a paste's expiry relative to now.
+func (s *Server) handleAdminExpiry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	duration, ok := expireMap[r.PostFormValue("expire")]
+	if !ok {
+		http.Redirect(w, r, adminReturnURL(r, "Invalid expiration."), http.StatusSeeOther)
+		return
+	}
+	paste, err := s.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" no longer exists."), http.StatusSeeOther)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if paste.TakenDown() {
+		http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" is under legal hold and cannot expire."), http.StatusSeeOther)
+		return
+	}
+	previous := *paste
+	paste.ExpiresAt = time.Time{}
+	if duration > 0 {
+		paste.ExpiresAt = s.nowTime().UTC().Add(duration)
+	}
+	if err := s.store.Save(r.Context(), paste); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	s.syntaxStats.remove(&previous)
+	s.syntaxStats.add(paste)
+	http.Redirect(w, r, adminReturnURL(r, "Updated expiry of "+id+"."), http.StatusSeeOther)
+}
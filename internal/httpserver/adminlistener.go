@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// With Config.AdminListener the dashboard and the admin API move to a
+// second listener, which the binary serves with client certificates
+// required. The public listener answers 404 for them as if admin were
+// disabled, and no longer recognises admin credentials anywhere, so purges,
+// erasures, backups and the like cannot be reached from it even with a
+// leaked admin token.
+
+type adminListenerContextKey struct{}
+
+// AdminHandler returns the router for the admin listener. It serves
+// everything Handler does, plus the admin routes Handler refuses when
+// Config.AdminListener is set.
+func (s *Server) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.router.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminListenerContextKey{}, true)))
+	})
+}
+
+// adminReachable reports whether admin routes and credentials are honoured
+// for r: always, unless they were moved to the admin listener and r did not
+// arrive through it.
+func (s *Server) adminReachable(r *http.Request) bool {
+	if !s.adminListener {
+		return true
+	}
+	on, _ := r.Context().Value(adminListenerContextKey{}).(bool)
+	return on
+}
+
+// requireAdminListener hides admin routes from the public listener.
+func (s *Server) requireAdminListener(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminReachable(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			http.NotFound(w, r)
+			return
+		}
+		s.notFound(w, r)
+	})
+}
@@ -0,0 +1,112 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IngestFormatAlertmanager renders Alertmanager webhook notifications as a
+// Markdown report instead of storing the raw JSON.
+const IngestFormatAlertmanager = "alertmanager"
+
+// alertmanagerPayload is the webhook body Alertmanager sends (version 4).
+type alertmanagerPayload struct {
+	Version           string              `json:"version"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []alertmanagerAlert `json:"alerts"`
+}
+
+type alertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// renderAlertmanager turns a notification into a Markdown report and a
+// title in Alertmanager's own "[FIRING:2] name" style.
+func renderAlertmanager(body []byte) (title, content string, err error) {
+	var p alertmanagerPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return "", "", fmt.Errorf("invalid alertmanager payload: %w", err)
+	}
+	if len(p.Alerts) == 0 {
+		return "", "", fmt.Errorf("invalid alertmanager payload: no alerts")
+	}
+
+	firing := 0
+	for _, a := range p.Alerts {
+		if a.Status == "firing" {
+			firing++
+		}
+	}
+	title = fmt.Sprintf("[%s:%d] %s", strings.ToUpper(p.Status), firing, joinLabels(p.GroupLabels))
+	if firing == 0 {
+		title = fmt.Sprintf("[%s] %s", strings.ToUpper(p.Status), joinLabels(p.GroupLabels))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	if summary := p.CommonAnnotations["summary"]; summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", summary)
+	}
+	if p.Receiver != "" {
+		fmt.Fprintf(&b, "- **Receiver:** %s\n", p.Receiver)
+	}
+	if p.ExternalURL != "" {
+		fmt.Fprintf(&b, "- **Alertmanager:** %s\n", p.ExternalURL)
+	}
+	if len(p.CommonLabels) > 0 {
+		fmt.Fprintf(&b, "- **Common labels:** %s\n", joinLabels(p.CommonLabels))
+	}
+	for i, a := range p.Alerts {
+		fmt.Fprintf(&b, "\n## %d. %s — %s\n\n", i+1, a.Labels["alertname"], strings.ToUpper(a.Status))
+		fmt.Fprintf(&b, "- **Started:** %s\n", a.StartsAt.UTC().Format(time.RFC3339))
+		if !a.EndsAt.IsZero() && a.EndsAt.After(a.StartsAt) && a.Status != "firing" {
+			fmt.Fprintf(&b, "- **Ended:** %s\n", a.EndsAt.UTC().Format(time.RFC3339))
+		}
+		if a.GeneratorURL != "" {
+			fmt.Fprintf(&b, "- **Source:** %s\n", a.GeneratorURL)
+		}
+		writeAlertTable(&b, "Labels", a.Labels)
+		writeAlertTable(&b, "Annotations", a.Annotations)
+	}
+	return title, b.String(), nil
+}
+
+func writeAlertTable(b *strings.Builder, heading string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n**%s**\n\n| Name | Value |\n| --- | --- |\n", heading)
+	for _, k := range sortedKeys(m) {
+		v := strings.ReplaceAll(strings.ReplaceAll(m[k], "|", `\|`), "\n", " ")
+		fmt.Fprintf(b, "| %s | %s |\n", k, v)
+	}
+}
+
+// joinLabels formats labels as alertname first, then the rest sorted.
+func joinLabels(m map[string]string) string {
+	keys := sortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i] == "alertname" && keys[j] != "alertname" })
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k == "alertname" {
+			parts = append(parts, m[k])
+			continue
+		}
+		parts = append(parts, k+"="+m[k])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,171 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	announcementCookie     = "announcement_dismissed"
+	maxAnnouncementLength  = 1000
+	announcementDismissTTL = 365 * 24 * time.Hour
+)
+
+// announcement is the instance-wide banner shown above every page. It starts
+// from Config.Announcement and is kept in memory, so changes made through the
+// admin API last until the next restart.
+type announcement struct {
+	mu        sync.RWMutex
+	message   string
+	expiresAt time.Time
+	updatedAt time.Time
+}
+
+// banner is the layout's view of the current announcement.
+type banner struct {
+	Message string
+	ID      string
+}
+
+type announcementRequest struct {
+	Message   string     `json:"message"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type announcementResponse struct {
+	Message   string     `json:"message"`
+	ID        string     `json:"id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func (a *announcement) set(message string, expiresAt, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.message = message
+	a.expiresAt = expiresAt
+	a.updatedAt = now
+}
+
+// current returns the announcement while it is set and not yet expired.
+func (a *announcement) current(now time.Time) (banner, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.currentLocked(now)
+}
+
+func (a *announcement) currentLocked(now time.Time) (banner, bool) {
+	if a.message == "" || (!a.expiresAt.IsZero() && !now.Before(a.expiresAt)) {
+		return banner{}, false
+	}
+	return banner{Message: a.message, ID: announcementID(a.message)}, true
+}
+
+func (a *announcement) response(now time.Time) announcementResponse {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	b, ok := a.currentLocked(now)
+	if !ok {
+		return announcementResponse{}
+	}
+	out := announcementResponse{Message: b.Message, ID: b.ID}
+	if !a.expiresAt.IsZero() {
+		exp := a.expiresAt.UTC()
+		out.ExpiresAt = &exp
+	}
+	if !a.updatedAt.IsZero() {
+		upd := a.updatedAt.UTC()
+		out.UpdatedAt = &upd
+	}
+	return out
+}
+
+// announcementID identifies a message so a dismissal covers only the text
+// that was dismissed; a new message shows again.
+func announcementID(message string) string {
+	sum := sha256.Sum256([]byte(message))
+	return hex.EncodeToString(sum[:6])
+}
+
+// banner returns the announcement to render for r, unless the client has
+// dismissed it.
+func (s *Server) banner(r *http.Request) *banner {
+	b, ok := s.announcement.current(s.nowTime())
+	if !ok {
+		return nil
+	}
+	if c, err := r.Cookie(announcementCookie); err == nil && c.Value == b.ID {
+		return nil
+	}
+	return &b
+}
+
+// handleDismissAnnouncement remembers the dismissed message in a cookie and
+// sends the reader back to the page they were on.
+func (s *Server) handleDismissAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if id := r.PostFormValue("id"); id != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     announcementCookie,
+			Value:    id,
+			Path:     "/",
+			MaxAge:   int(announcementDismissTTL.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			Secure:   s.isSecureRequest(r),
+		})
+	}
+	back := "/"
+	if ref, err := url.Parse(r.Referer()); err == nil && strings.HasPrefix(ref.Path, "/") && (ref.Host == "" || ref.Host == r.Host) {
+		back = ref.Path
+		if ref.RawQuery != "" {
+			back += "?" + ref.RawQuery
+		}
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+func (s *Server) handleGetAnnouncement(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.announcement.response(s.nowTime()))
+}
+
+// handleSetAnnouncement replaces the announcement; an empty message clears it.
+func (s *Server) handleSetAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var req announcementRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid JSON body"})
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if len(req.Message) > maxAnnouncementLength {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "message is too long"})
+		return
+	}
+	now := s.nowTime()
+	var expiresAt time.Time
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "expires_at must be in the future"})
+			return
+		}
+		expiresAt = *req.ExpiresAt
+	}
+	s.announcement.set(req.Message, expiresAt, now)
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "announcement updated", "cleared", req.Message == "")
+	}
+	writeJSON(w, http.StatusOK, s.announcement.response(now))
+}
+
+func (s *Server) handleClearAnnouncement(w http.ResponseWriter, r *http.Request) {
+	s.announcement.set("", time.Time{}, s.nowTime())
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "announcement updated", "cleared", true)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
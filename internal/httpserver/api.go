@@ -0,0 +1,258 @@
+package httpserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/web"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+type pasteSummary struct {
+	ID          string            `json:"id"`
+	Title       string            `json:"title,omitempty"`
+	URL         string            `json:"url"`
+	Syntax      string            `json:"syntax"`
+	Size        int               `json:"size"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Protected   bool              `json:"protected"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	License     string            `json:"license,omitempty"`
+	Attribution string            `json:"attribution,omitempty"`
+	SourceURL   string            `json:"source_url,omitempty"`
+	Encrypted   bool              `json:"encrypted,omitempty"`
+	// Quarantine fields only appear in admin responses; readers never see
+	// quarantined pastes.
+	Quarantined      bool   `json:"quarantined,omitempty"`
+	QuarantineReason string `json:"quarantine_reason,omitempty"`
+	// Deletion fields only appear on soft-deleted pastes listed to admins.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty"`
+	// Takedown fields only appear to admins, the only ones who can still
+	// load a taken-down paste.
+	TakenDownAt    *time.Time `json:"taken_down_at,omitempty"`
+	TakedownNotice string     `json:"takedown_notice,omitempty"`
+	TakedownLegal  bool       `json:"takedown_legal,omitempty"`
+}
+
+type limitsResponse struct {
+	MaxBytes      int             `json:"max_bytes"`
+	MaxTitleBytes int             `json:"max_title_bytes"`
+	RawOnlyBytes  int             `json:"raw_only_bytes,omitempty"`
+	DefaultSyntax string          `json:"default_syntax"`
+	Syntaxes      []limitsOption  `json:"syntaxes"`
+	DefaultExpire string          `json:"default_expire"`
+	Expiries      []limitsOption  `json:"expiries"`
+	TermsURL      string          `json:"terms_url,omitempty"`
+	TermsVersion  string          `json:"terms_version,omitempty"`
+	CreatorQuota  *creatorLimits  `json:"creator_quota,omitempty"`
+	Metadata      metadataLimits  `json:"metadata"`
+	Features      map[string]bool `json:"features"`
+}
+
+// creatorLimits describes the per-address creation quota.
+type creatorLimits struct {
+	MaxPastes     int   `json:"max_pastes,omitempty"`
+	MaxBytes      int64 `json:"max_bytes,omitempty"`
+	WindowSeconds int64 `json:"window_seconds"`
+}
+
+type limitsOption struct {
+	Value   string `json:"value"`
+	Label   string `json:"label"`
+	Seconds *int64 `json:"seconds,omitempty"`
+}
+
+type metadataLimits struct {
+	MaxEntries    int `json:"max_entries"`
+	MaxValueBytes int `json:"max_value_bytes"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// handleSearch lists pastes whose metadata matches every meta.<key>=<value> query parameter.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := storage.ListOptions{Limit: defaultSearchLimit, ActiveAt: s.nowTime()}
+	for param, values := range query {
+		key, ok := strings.CutPrefix(param, "meta.")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		key = strings.ToLower(key)
+		if err := validateMetadata(key, values[0]); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		if opts.Metadata == nil {
+			opts.Metadata = make(map[string]string)
+		}
+		opts.Metadata[key] = values[0]
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid limit"})
+			return
+		}
+		opts.Limit = limit
+	}
+
+	pastes, err := s.store.List(r.Context(), opts)
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	out := make([]pasteSummary, 0, len(pastes))
+	for _, p := range pastes {
+		out = append(out, s.summarize(r, p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleLimits describes what the instance accepts so clients can validate
+// submissions up front.
+func (s *Server) handleLimits(w http.ResponseWriter, r *http.Request) {
+	out := limitsResponse{
+		MaxBytes:      s.maxBytes,
+		MaxTitleBytes: maxTitleLen,
+		RawOnlyBytes:  s.rawOnlyBytes,
+		DefaultSyntax: s.defaultSyntax,
+		Syntaxes:      make([]limitsOption, 0, len(s.syntaxes)),
+		DefaultExpire: defaultExpire,
+		Expiries:      make([]limitsOption, 0, len(expireChoices)),
+		Metadata:      metadataLimits{MaxEntries: maxMetadataEntries, MaxValueBytes: maxMetadataValueLen},
+		Features:      s.features(),
+	}
+	for _, v := range s.syntaxes {
+		out.Syntaxes = append(out.Syntaxes, limitsOption{Value: v, Label: syntaxLabel(v)})
+	}
+	for _, c := range expireChoices {
+		opt := limitsOption{Value: c.Value, Label: c.Label}
+		if c.Duration > 0 {
+			secs := int64(c.Duration.Seconds())
+			opt.Seconds = &secs
+		}
+		out.Expiries = append(out.Expiries, opt)
+	}
+	if s.terms != nil {
+		out.TermsURL = s.absoluteURL(r, "/terms")
+		out.TermsVersion = s.terms.version
+	}
+	if q := s.creatorQuota; q.enabled() {
+		out.CreatorQuota = &creatorLimits{MaxPastes: q.MaxPastes, MaxBytes: q.MaxBytes, WindowSeconds: int64(q.Window.Seconds())}
+	}
+	s.setLimitHeaders(w)
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleOpenAPI serves the OpenAPI document describing this API.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = w.Write(web.OpenAPI)
+}
+
+// features reports optional capabilities and whether they are enabled.
+func (s *Server) features() map[string]bool {
+	return map[string]bool{
+		"admin_api":       s.adminToken != "",
+		"admin_dashboard": s.adminEnabled(),
+		"api_keys":        s.keys != nil,
+		"claim_tokens":    true,
+		"drafts":          true,
+		"embed":           true,
+		"index_pastes":    !s.noIndexPastes,
+		"ingest":          len(s.ingest) > 0,
+		"link_previews":   !s.disablePreviews,
+		"metadata":        true,
+		"metrics":         s.metrics,
+		"my_pastes":       true,
+		"password":        true,
+		"public_listing":  true,
+		"terms":           s.terms != nil,
+		"upstream_diff":   true,
+	}
+}
+
+// setLimitHeaders advertises the size limit on responses that accept or
+// describe submissions.
+func (s *Server) setLimitHeaders(w http.ResponseWriter) {
+	w.Header().Set("X-Paste-Max-Bytes", strconv.Itoa(s.maxBytes))
+}
+
+func (s *Server) summarize(r *http.Request, p *storage.Paste) pasteSummary {
+	sum := pasteSummary{
+		ID:               p.ID,
+		Title:            p.Title,
+		URL:              s.canonicalURL(r, p.ID),
+		Syntax:           p.Syntax,
+		Size:             p.Size,
+		CreatedAt:        p.CreatedAt,
+		Protected:        p.PasswordHash != "",
+		Metadata:         visibleMetadata(p.Metadata),
+		License:          p.License,
+		Attribution:      p.Attribution,
+		SourceURL:        p.SourceURL,
+		Encrypted:        p.Encrypted,
+		Quarantined:      p.Quarantined,
+		QuarantineReason: p.QuarantineReason,
+	}
+	if p.HasExpiration() {
+		exp := p.ExpiresAt
+		sum.ExpiresAt = &exp
+	}
+	if p.TakenDown() {
+		at := p.TakenDownAt
+		sum.TakenDownAt, sum.TakedownNotice, sum.TakedownLegal = &at, p.TakedownNotice, p.TakedownLegal
+	}
+	if p.Deleted() {
+		at := p.DeletedAt
+		sum.DeletedAt, sum.DeletedBy = &at, p.DeletedBy
+	}
+	return sum
+}
+
+// requireAdmin guards admin routes with the configured bearer token. Without a
+// token the routes are reported as missing.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tinypaste-admin"`)
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) apiServerError(w http.ResponseWriter, r *http.Request, err error) {
+	if s.logger != nil {
+		s.logger.ErrorContext(r.Context(), "internal error", "error", err)
+	}
+	writeJSON(w, http.StatusInternalServerError, apiError{Error: "internal server error"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,170 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/time/rate"
+
+	"tiny-pastebin/internal/apikey"
+	"tiny-pastebin/internal/storage"
+)
+
+type apiKeyContextKey struct{}
+
+// apiKeyOwnerScope prefixes the owner identity of pastes created with an API key.
+const apiKeyOwnerScope = "key:"
+
+// authenticateAPIKey resolves "Authorization: Bearer tp_..." headers to an
+// API key before rate limiting, so limits apply per key instead of per
+// address. Invalid keys are rejected outright.
+func (s *Server) authenticateAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || s.keys == nil || !apikey.IsToken(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, secret, ok := apikey.Parse(token)
+		if !ok {
+			s.rejectAPIKey(w)
+			return
+		}
+		key, err := s.keys.GetAPIKey(r.Context(), id)
+		if errors.Is(err, storage.ErrNotFound) || (err == nil && !apikey.Verify(key, secret)) {
+			s.rejectAPIKey(w)
+			return
+		}
+		if err != nil {
+			s.apiServerError(w, r, err)
+			return
+		}
+		s.limiter.SetLimit(apiKeyOwnerScope+key.ID, rate.Limit(key.RateLimit), key.Burst)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	})
+}
+
+func (s *Server) rejectAPIKey(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="tinypaste", error="invalid_token"`)
+	writeJSON(w, http.StatusUnauthorized, apiError{Error: "invalid api key"})
+}
+
+// requestAPIKey returns the API key the request authenticated with, if any.
+func requestAPIKey(r *http.Request) *storage.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey{}).(*storage.APIKey)
+	return key
+}
+
+// rateLimitKey buckets requests by API key or token owner when present,
+// else by client address.
+func (s *Server) rateLimitKey(r *http.Request) string {
+	if key := requestAPIKey(r); key != nil {
+		return apiKeyOwnerScope + key.ID
+	}
+	if c := requestToken(r); c != nil {
+		return c.Owner
+	}
+	return s.clientKey(r)
+}
+
+// requireScope rejects API-key and JWT requests lacking scope. Anonymous
+// requests pass through and are subject to the handler's own checks.
+func requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := requestAPIKey(r); key != nil && !key.HasScope(scope) {
+				writeJSON(w, http.StatusForbidden, apiError{Error: fmt.Sprintf("api key lacks scope %s", scope)})
+				return
+			}
+			if c := requestToken(r); c != nil && !slices.Contains(c.Scopes, scope) {
+				writeJSON(w, http.StatusForbidden, apiError{Error: fmt.Sprintf("token lacks scope %s", scope)})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// apiOwner is the owner identity recorded for pastes created through the API.
+func (s *Server) apiOwner(r *http.Request) string {
+	if key := requestAPIKey(r); key != nil {
+		return apiKeyOwnerScope + key.ID
+	}
+	if c := requestToken(r); c != nil {
+		return c.Owner
+	}
+	return s.ownerOf(r)
+}
+
+type adminKeysPageData struct {
+	Keys     []*storage.APIKey
+	Scopes   []string
+	NewToken string
+	CSRF     string
+	Notice   string
+	Error    string
+}
+
+func (d adminKeysPageData) PageTitle() string { return "API keys · Tiny Pastebin" }
+func (d adminKeysPageData) NoIndex() bool     { return true }
+
+func (s *Server) handleAdminKeys(w http.ResponseWriter, r *http.Request) {
+	s.renderAdminKeys(w, r, http.StatusOK, adminKeysPageData{Notice: r.URL.Query().Get("notice")})
+}
+
+// handleAdminCreateKey mints a key and shows its token once.
+func (s *Server) handleAdminCreateKey(w http.ResponseWriter, r *http.Request) {
+	rateLimit, _ := strconv.ParseFloat(strings.TrimSpace(r.PostFormValue("rate")), 64)
+	burst, _ := strconv.Atoi(strings.TrimSpace(r.PostFormValue("burst")))
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if name == "" {
+		s.renderAdminKeys(w, r, http.StatusBadRequest, adminKeysPageData{Error: "Name is required"})
+		return
+	}
+	key, token, err := apikey.New(name, r.PostForm["scope"], rateLimit, burst, s.nowTime())
+	if err != nil {
+		s.renderAdminKeys(w, r, http.StatusBadRequest, adminKeysPageData{Error: err.Error()})
+		return
+	}
+	if err := s.keys.SaveAPIKey(r.Context(), key); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "api key created", "key", key.ID, "name", key.Name, "scopes", strings.Join(key.Scopes, ","))
+	}
+	s.renderAdminKeys(w, r, http.StatusCreated, adminKeysPageData{NewToken: token})
+}
+
+func (s *Server) handleAdminRevokeKey(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := s.keys.DeleteAPIKey(r.Context(), id); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.serverError(w, r, err)
+		return
+	}
+	s.limiter.SetLimit(apiKeyOwnerScope+id, 0, 0)
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "api key revoked", "key", id)
+	}
+	http.Redirect(w, r, "/admin/keys?notice="+url.QueryEscape("Revoked key "+id+"."), http.StatusSeeOther)
+}
+
+func (s *Server) renderAdminKeys(w http.ResponseWriter, r *http.Request, status int, data adminKeysPageData) {
+	keys, err := s.keys.ListAPIKeys(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	identity, _ := s.adminIdentity(r)
+	data.Keys = keys
+	data.Scopes = apikey.Scopes
+	data.CSRF = s.adminCSRF(identity)
+	s.render(w, r, status, "admin-keys", data)
+}
@@ -0,0 +1,78 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// handleArchiveList returns the expired pastes kept in the archive, with
+// expires_at set to when each one expired.
+func (s *Server) handleArchiveList(w http.ResponseWriter, r *http.Request) {
+	pastes, err := s.archive.List(r.Context(), storage.ListOptions{ActiveAt: s.nowTime()})
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	out := make([]pasteSummary, 0, len(pastes))
+	for _, p := range pastes {
+		paste, expiredAt := storage.Unarchive(p)
+		sum := s.summarize(r, paste)
+		sum.ExpiresAt = &expiredAt
+		out = append(out, sum)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleArchiveRestore moves an archived paste back into the store under its
+// old id. It stays until the expiry chosen with ?expire=, which defaults to
+// never, and a paste since created under that id is not overwritten.
+func (s *Server) handleArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	expire := r.URL.Query().Get("expire")
+	if expire == "" {
+		expire = "never"
+	}
+	duration, ok := expireMap[expire]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "unsupported expire value"})
+		return
+	}
+	archived, err := s.archive.Get(ctx, chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found in the archive"})
+		return
+	}
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	switch _, err := s.store.Get(ctx, archived.ID); {
+	case err == nil:
+		writeJSON(w, http.StatusConflict, apiError{Error: "a paste with this id exists"})
+		return
+	case !errors.Is(err, storage.ErrNotFound):
+		s.apiServerError(w, r, err)
+		return
+	}
+
+	paste, _ := storage.Unarchive(archived)
+	if duration > 0 {
+		paste.ExpiresAt = s.nowTime().UTC().Add(duration)
+	}
+	if err := s.store.Save(ctx, paste); err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if err := s.archive.Delete(ctx, paste.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.apiServerError(w, r, err)
+		return
+	}
+	s.syntaxStats.add(paste)
+	s.emit(ctx, events.Event{Type: events.PasteRestored, PasteID: paste.ID, Actor: actorAdmin})
+	writeJSON(w, http.StatusOK, s.summarize(r, paste))
+}
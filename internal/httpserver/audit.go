@@ -0,0 +1,226 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// When the store keeps an audit log, the server appends an entry for every
+// paste created, unlocked or changed state, every wrong password, and every
+// admin login and admin write. Paste events are recorded under their event
+// type, so the log and the event sink agree on names.
+
+// Audit actions besides the event types.
+const (
+	auditPasteCreated     = "paste.created"
+	auditPasteUnlocked    = "paste.unlocked"
+	auditPasswordFailed   = "password.failed"
+	auditAdminLogin       = "admin.login"
+	auditAdminLoginFailed = "admin.login_failed"
+	auditAdminAction      = "admin.action"
+)
+
+// auditTimeout bounds writing a single audit entry.
+const auditTimeout = 5 * time.Second
+
+// Audit log page sizes.
+const (
+	auditPageSize = 200
+	auditMaxLimit = 1000
+)
+
+// record appends e to the audit log, if the store keeps one. Failures are
+// logged; they never fail the request being audited.
+func (s *Server) record(ctx context.Context, e storage.AuditEntry) {
+	if s.audit == nil {
+		return
+	}
+	e.Time = s.nowTime().UTC()
+	e.ID = auditID(e.Time)
+	if e.Country == "" {
+		e.Country = requestCountry(ctx)
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditTimeout)
+	defer cancel()
+	if err := s.audit.AppendAudit(ctx, &e); err != nil && s.logger != nil {
+		s.logger.ErrorContext(ctx, "append audit entry", "action", e.Action, "id", e.PasteID, "error", err)
+	}
+}
+
+// recordRequest is record filling in the client's IP hash from r.
+func (s *Server) recordRequest(r *http.Request, e storage.AuditEntry) {
+	e.IPHash = s.ipHash(ClientIP(r, s.proxies))
+	s.record(r.Context(), e)
+}
+
+// recordEvent audits a paste event.
+func (s *Server) recordEvent(ctx context.Context, e events.Event) {
+	s.record(ctx, storage.AuditEntry{Action: string(e.Type), PasteID: e.PasteID, Actor: e.Actor, Detail: e.Reason})
+}
+
+// auditID returns a unique ID that sorts by time.
+func auditID(t time.Time) string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%016x", t.UnixNano()) + hex.EncodeToString(b)
+}
+
+// auditAdmin records every admin request that may change something once it
+// has been authorized, with the path and response status.
+func (s *Server) auditAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.audit == nil || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		identity, _ := s.adminIdentity(r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.recordRequest(r, storage.AuditEntry{
+			Action: auditAdminAction,
+			Actor:  identity,
+			Detail: r.Method + " " + r.URL.Path + " " + strconv.Itoa(status),
+		})
+	})
+}
+
+type auditPageData struct {
+	Entries []*storage.AuditEntry
+	Filter  auditFilter
+	// OlderURL pages to the entries before the last one shown.
+	OlderURL  string
+	Retention string
+	Error     string
+	// Countries shows the country column when GeoIP is on.
+	Countries bool
+}
+
+func (d auditPageData) PageTitle() string { return "Audit log · Tiny Pastebin" }
+func (d auditPageData) NoIndex() bool     { return true }
+
+// auditFilter holds the audit page's filter form as entered.
+type auditFilter struct {
+	Action  string
+	PasteID string
+	Actor   string
+	IPHash  string
+	Newer   string
+	Until   string
+}
+
+func (f auditFilter) query(now time.Time) (storage.AuditQuery, error) {
+	q := storage.AuditQuery{Action: f.Action, PasteID: f.PasteID, Actor: f.Actor, IPHash: f.IPHash, Limit: auditPageSize}
+	if f.Newer != "" {
+		d, err := parseAge(f.Newer)
+		if err != nil {
+			return q, fmt.Errorf("invalid age %q", f.Newer)
+		}
+		q.Since = now.Add(-d)
+	}
+	if f.Until != "" {
+		t, err := time.Parse(time.RFC3339Nano, f.Until)
+		if err != nil {
+			return q, fmt.Errorf("invalid time %q", f.Until)
+		}
+		q.Until = t
+	}
+	return q, nil
+}
+
+// handleAdminAudit shows the newest audit entries matching the filter.
+func (s *Server) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	data := auditPageData{Filter: auditFilter{
+		Action:  strings.TrimSpace(query.Get("action")),
+		PasteID: strings.TrimSpace(query.Get("paste")),
+		Actor:   strings.TrimSpace(query.Get("actor")),
+		IPHash:  strings.TrimSpace(query.Get("ip")),
+		Newer:   query.Get("newer"),
+		Until:   query.Get("until"),
+	}, Countries: s.geo != nil}
+	if s.auditRetention > 0 {
+		data.Retention = remaining(s.nowTime().Add(s.auditRetention), s.nowTime())
+	}
+	q, err := data.Filter.query(s.nowTime())
+	if err != nil {
+		data.Error = err.Error()
+		s.render(w, r, http.StatusBadRequest, "admin-audit", data)
+		return
+	}
+	entries, err := s.audit.ListAudit(r.Context(), q)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	data.Entries = entries
+	if len(entries) == auditPageSize {
+		older := url.Values{}
+		for k, v := range query {
+			older[k] = v
+		}
+		older.Set("until", entries[len(entries)-1].Time.Format(time.RFC3339Nano))
+		data.OlderURL = "/admin/audit?" + older.Encode()
+	}
+	s.render(w, r, http.StatusOK, "admin-audit", data)
+}
+
+// handleAuditList returns audit entries as JSON, newest first, filtered by
+// the action, paste_id, actor, ip_hash, since and until parameters.
+func (s *Server) handleAuditList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := storage.AuditQuery{
+		Action:  query.Get("action"),
+		PasteID: query.Get("paste_id"),
+		Actor:   query.Get("actor"),
+		IPHash:  query.Get("ip_hash"),
+		Limit:   auditPageSize,
+	}
+	for _, bound := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &q.Since}, {"until", &q.Until}} {
+		if v := query.Get(bound.name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: bound.name + " must be an RFC 3339 time"})
+				return
+			}
+			*bound.dst = t
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > auditMaxLimit {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "limit must be between 1 and " + strconv.Itoa(auditMaxLimit)})
+			return
+		}
+		q.Limit = n
+	}
+	entries, err := s.audit.ListAudit(r.Context(), q)
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if entries == nil {
+		entries = []*storage.AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+)
+
+// honeypotField names the create form input hidden from people. Its name
+// is one password managers and autofill leave alone.
+const honeypotField = "nickname_confirm"
+
+// BotCheck screens create form submissions for bots before any challenge
+// is asked for: the form carries a field people cannot see and leave
+// empty, and a person takes a moment to fill the form in. Submissions that
+// trip either check are answered as if they had succeeded, after Tarpit,
+// and nothing is saved. The JSON API is not affected.
+type BotCheck struct {
+	// MinSubmitTime is the least time between loading the form and
+	// submitting it. Zero only checks the hidden field. Submissions
+	// without a form token, such as scripted ones, skip the time check.
+	MinSubmitTime time.Duration
+	// Tarpit delays the answer to trapped submissions, tying up the bot.
+	Tarpit time.Duration
+}
+
+// trapsBot reports whether a parsed create form submission looks automated,
+// and why.
+func (s *Server) trapsBot(r *http.Request) (string, bool) {
+	if s.botCheck == nil {
+		return "", false
+	}
+	if r.PostFormValue(honeypotField) != "" {
+		return "honeypot filled", true
+	}
+	if s.botCheck.MinSubmitTime <= 0 {
+		return "", false
+	}
+	token := r.PostFormValue("form_token")
+	if token == "" {
+		return "", false
+	}
+	issued, ok := s.formTokens.issuedAt(token)
+	if ok && s.nowTime().Sub(issued) < s.botCheck.MinSubmitTime {
+		return "submitted too fast", true
+	}
+	return "", false
+}
+
+// dropBot answers a trapped submission after the tarpit delay with the
+// redirect a successful one would get, minus the paste.
+func (s *Server) dropBot(w http.ResponseWriter, r *http.Request, reason string) {
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "dropped bot submission", "reason", reason, "client", s.clientKey(r))
+	}
+	if d := s.botCheck.Tarpit; d > 0 {
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			return
+		}
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,173 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"tiny-pastebin/web"
+)
+
+const (
+	defaultSiteName  = "Tiny Pastebin"
+	maxSiteNameLen   = 64
+	maxBrandAsset    = 512 << 10
+	maxFooterLinks   = 8
+	brandAssetMaxAge = 24 * time.Hour
+)
+
+// Branding replaces the built-in name and artwork of the instance.
+type Branding struct {
+	// Name replaces "Tiny Pastebin" in the header, page titles, the feed and
+	// embeds.
+	Name string
+	// Favicon is served at /favicon.ico instead of the embedded icon.
+	Favicon []byte
+	// Logo is an image shown in the header in place of the name.
+	Logo []byte
+	// FooterLinks replace the footer tagline.
+	FooterLinks []FooterLink
+}
+
+// FooterLink is a link in the page footer. URL is absolute (http or https)
+// or a path on this site.
+type FooterLink struct {
+	Label string
+	URL   string
+}
+
+// brandAsset is an image validated and fingerprinted once at startup.
+type brandAsset struct {
+	data        []byte
+	contentType string
+	etag        string
+}
+
+type brand struct {
+	name        string
+	favicon     *brandAsset
+	logo        *brandAsset
+	footerLinks []FooterLink
+}
+
+// layoutBrand is the layout's view of the branding.
+type layoutBrand struct {
+	Name        string
+	LogoURL     string
+	FooterLinks []FooterLink
+}
+
+func newBrand(b Branding) (*brand, error) {
+	out := &brand{name: strings.TrimSpace(b.Name)}
+	if out.name == "" {
+		out.name = defaultSiteName
+	}
+	if len(out.name) > maxSiteNameLen || strings.IndexFunc(out.name, unicode.IsControl) >= 0 {
+		return nil, fmt.Errorf("site name must be a single line of at most %d bytes", maxSiteNameLen)
+	}
+
+	favicon := b.Favicon
+	if favicon == nil {
+		data, err := web.Static.ReadFile("static/favicon.ico")
+		if err != nil {
+			return nil, fmt.Errorf("read embedded favicon: %w", err)
+		}
+		favicon = data
+	}
+	var err error
+	if out.favicon, err = newBrandAsset(favicon); err != nil {
+		return nil, fmt.Errorf("favicon: %w", err)
+	}
+	if b.Logo != nil {
+		if out.logo, err = newBrandAsset(b.Logo); err != nil {
+			return nil, fmt.Errorf("logo: %w", err)
+		}
+	}
+
+	if len(b.FooterLinks) > maxFooterLinks {
+		return nil, fmt.Errorf("at most %d footer links are allowed", maxFooterLinks)
+	}
+	for _, l := range b.FooterLinks {
+		l.Label = strings.TrimSpace(l.Label)
+		if l.Label == "" || !validFooterURL(l.URL) {
+			return nil, fmt.Errorf("invalid footer link %q: want a label and an http(s) URL or /path", l.Label+"="+l.URL)
+		}
+		out.footerLinks = append(out.footerLinks, l)
+	}
+	return out, nil
+}
+
+func newBrandAsset(data []byte) (*brandAsset, error) {
+	if len(data) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	if len(data) > maxBrandAsset {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxBrandAsset)
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		// The sniffer reports SVG as XML or text.
+		if !bytes.Contains(data[:min(len(data), 1024)], []byte("<svg")) {
+			return nil, fmt.Errorf("not an image (%s)", contentType)
+		}
+		contentType = "image/svg+xml"
+	}
+	sum := sha256.Sum256(data)
+	return &brandAsset{data: data, contentType: contentType, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}, nil
+}
+
+func validFooterURL(raw string) bool {
+	if strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//") {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// serve writes the asset with long-lived caching; the ETag lets browsers
+// revalidate cheaply after an operator swaps the file.
+func (a *brandAsset) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("ETag", a.etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(brandAssetMaxAge.Seconds())))
+	// SVG may carry script; never let it run in our origin.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(a.data))
+}
+
+func (s *Server) handleFavicon(w http.ResponseWriter, r *http.Request) {
+	s.brand.favicon.serve(w, r)
+}
+
+func (s *Server) handleLogo(w http.ResponseWriter, r *http.Request) {
+	s.brand.logo.serve(w, r)
+}
+
+// layoutBrand returns the branding shown by the layout. The logo URL carries
+// the ETag so a new logo is fetched despite the cache lifetime.
+func (s *Server) layoutBrand() layoutBrand {
+	out := layoutBrand{Name: s.brand.name, FooterLinks: s.brand.footerLinks}
+	if s.brand.logo != nil {
+		out.LogoURL = "/brand/logo?v=" + strings.Trim(s.brand.logo.etag, `"`)
+	}
+	return out
+}
+
+// brandTitle swaps the built-in site name at the end of a page title for the
+// configured one.
+func (s *Server) brandTitle(title string) string {
+	if s.brand.name == defaultSiteName {
+		return title
+	}
+	if prefix, ok := strings.CutSuffix(title, defaultSiteName); ok {
+		return prefix + s.brand.name
+	}
+	return title
+}
@@ -0,0 +1,262 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// Capabilities granted by a paste's signed links. Each link carries its own
+// token, so sharing the view link never lets the recipient change the paste.
+const (
+	capView   = "view"
+	capEdit   = "edit"
+	capDelete = "delete"
+)
+
+// actorLink is recorded on events raised through a capability link.
+const actorLink = "link"
+
+// pasteLinks are the capability links handed to a paste's creator.
+type pasteLinks struct {
+	View string `json:"view"`
+	// Edit is empty for encrypted and sealed pastes, whose content the
+	// server cannot show.
+	Edit   string `json:"edit,omitempty"`
+	Delete string `json:"delete"`
+}
+
+// capabilityToken signs capability over p. The token is bound to the paste's
+// ID and creation time, so it never carries over to a later paste reusing
+// the ID, and it stops working once the cookie secret that signed it is
+// retired.
+func (s *Server) capabilityToken(p *storage.Paste, capability string) string {
+	return s.sealMAC("paste-"+capability, capabilityPayload(p))
+}
+
+func (s *Server) hasCapability(p *storage.Paste, capability, token string) bool {
+	return token != "" && s.macMatches("paste-"+capability, capabilityPayload(p), token)
+}
+
+func capabilityPayload(p *storage.Paste) string {
+	return p.ID + "\x00" + strconv.FormatInt(p.CreatedAt.Unix(), 10)
+}
+
+// editable reports whether the server can show p's content for editing.
+func editable(p *storage.Paste) bool {
+	return !p.Encrypted && !p.Sealed
+}
+
+// capabilityLinks returns p's links. Unprotected pastes are viewed at their
+// canonical URL; a protected paste's view link stands in for its password,
+// except when its content is sealed under that password.
+func (s *Server) capabilityLinks(r *http.Request, p *storage.Paste) *pasteLinks {
+	base := "/p/" + p.ID
+	links := &pasteLinks{
+		View:   s.canonicalURL(r, p.ID),
+		Delete: s.absoluteURL(r, base+"/delete/"+s.capabilityToken(p, capDelete)),
+	}
+	if p.PasswordHash != "" && !p.Sealed {
+		links.View = s.absoluteURL(r, base+"/view/"+s.capabilityToken(p, capView))
+	}
+	if editable(p) {
+		links.Edit = s.absoluteURL(r, base+"/edit/"+s.capabilityToken(p, capEdit))
+	}
+	return links
+}
+
+// capabilityPaste loads the paste a capability link points at, answering
+// with the not-found page when the link's token does not grant capability.
+func (s *Server) capabilityPaste(w http.ResponseWriter, r *http.Request, capability string) (*storage.Paste, bool) {
+	// The token is in the URL; keep it out of Referer headers.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if err != nil {
+		var gone *goneError
+		switch {
+		case errors.As(err, &gone):
+			s.gonePaste(w, r, gone)
+		case errors.Is(err, storage.ErrNotFound):
+			s.notFound(w, r)
+		default:
+			s.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	if !s.hasCapability(paste, capability, chi.URLParam(r, "token")) {
+		s.notFound(w, r)
+		return nil, false
+	}
+	return paste, true
+}
+
+// handleCapabilityView unlocks a protected paste for the holder of its view
+// link, as entering the password would.
+func (s *Server) handleCapabilityView(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.capabilityPaste(w, r, capView)
+	if !ok {
+		return
+	}
+	if paste.PasswordHash != "" && !paste.Sealed {
+		s.setAuthCookie(w, r, paste.ID, paste.ExpiresAt)
+		s.recordRequest(r, storage.AuditEntry{Action: auditPasteUnlocked, PasteID: paste.ID, Actor: actorLink, Detail: "view link"})
+	}
+	http.Redirect(w, r, "/p/"+paste.ID, http.StatusSeeOther)
+}
+
+type editPageData struct {
+	ID            string
+	Token         string
+	Title         string
+	Content       string
+	SyntaxOptions []option
+	MaxBytes      int
+	Error         string
+	// Secrets asks the editor to confirm publishing the credentials found.
+	Secrets        bool
+	ConfirmSecrets bool
+}
+
+func (d editPageData) PageTitle() string { return "Edit paste · Tiny Pastebin" }
+func (d editPageData) NoIndex() bool     { return true }
+
+func (s *Server) editData(paste *storage.Paste, title, content, syntax string) editPageData {
+	return editPageData{
+		ID:            paste.ID,
+		Token:         s.capabilityToken(paste, capEdit),
+		Title:         title,
+		Content:       content,
+		SyntaxOptions: s.indexData(syntax, "", "", "").SyntaxOptions,
+		MaxBytes:      s.maxBytes,
+	}
+}
+
+func (s *Server) handleEditForm(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.capabilityPaste(w, r, capEdit)
+	if !ok {
+		return
+	}
+	if !editable(paste) {
+		s.notFound(w, r)
+		return
+	}
+	s.render(w, r, http.StatusOK, "edit", s.editData(paste, paste.Title, paste.Content, paste.Syntax))
+}
+
+// handleEdit replaces the title, content and syntax of a paste for the
+// holder of its edit link. The new content is screened as a new paste's
+// would be; every other property of the paste is kept.
+func (s *Server) handleEdit(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.capabilityPaste(w, r, capEdit)
+	if !ok {
+		return
+	}
+	if !editable(paste) {
+		s.notFound(w, r)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(s.maxBytes)+4096)
+	if err := r.ParseForm(); err != nil {
+		s.render(w, r, http.StatusBadRequest, "edit", s.editData(paste, paste.Title, paste.Content, paste.Syntax))
+		return
+	}
+	confirmSecrets := r.FormValue("confirm_secrets") == "on"
+	in := pasteInput{
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Content:     r.FormValue("content"),
+		Syntax:      r.FormValue("syntax"),
+		Expire:      "never",
+		Metadata:    paste.Metadata,
+		Public:      paste.Public,
+		NoIndex:     paste.NoIndex,
+		License:     paste.License,
+		Attribution: paste.Attribution,
+		SourceURL:   paste.SourceURL,
+	}
+	fail := func(msg string) {
+		data := s.editData(paste, in.Title, in.Content, in.Syntax)
+		data.Error = msg
+		data.Secrets = s.secretPolicy == SecretsWarn && len(in.secrets) > 0
+		data.ConfirmSecrets = confirmSecrets
+		s.render(w, r, http.StatusBadRequest, "edit", data)
+	}
+	if err := s.validatePaste(r.Context(), &in); err != nil {
+		fail(err.Error())
+		return
+	}
+	if !s.secretsConfirmed(in, confirmSecrets) {
+		fail(secretsMessage(in.secrets) + ". Remove them, or confirm below that you mean to publish them")
+		return
+	}
+	if grown := len(in.Content) - len(paste.Content); grown > 0 {
+		if err := s.checkQuota(r.Context(), 0, grown); err != nil {
+			if errors.Is(err, errQuotaExceeded) {
+				fail("This instance is out of space, please try again later")
+				return
+			}
+			s.serverError(w, r, err)
+			return
+		}
+	}
+
+	previous := *paste
+	paste.Title = in.Title
+	paste.Content = in.Content
+	paste.Syntax = in.Syntax
+	paste.Size = len(in.Content)
+	// The secret policy may have capped the expiry; it is never extended.
+	if d := expireMap[in.Expire]; d > 0 {
+		if limit := s.nowTime().UTC().Add(d); !paste.HasExpiration() || paste.ExpiresAt.After(limit) {
+			paste.ExpiresAt = limit
+		}
+	}
+	applyVerdict(paste, in.verdict)
+	if err := s.store.Save(r.Context(), paste); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	s.syntaxStats.remove(&previous)
+	s.syntaxStats.add(paste)
+	s.emit(r.Context(), events.Event{Type: events.PasteEdited, PasteID: paste.ID, Actor: actorLink})
+	s.contentSaved(r.Context(), paste)
+	if paste.PasswordHash != "" {
+		s.setAuthCookie(w, r, paste.ID, paste.ExpiresAt)
+	}
+	http.Redirect(w, r, "/p/"+paste.ID, http.StatusSeeOther)
+}
+
+type deletePageData struct {
+	ID    string
+	Token string
+}
+
+func (d deletePageData) PageTitle() string { return "Delete paste · Tiny Pastebin" }
+func (d deletePageData) NoIndex() bool     { return true }
+
+// handleDeleteForm asks the holder of a delete link to confirm, so link
+// previews and prefetchers following it do not delete the paste.
+func (s *Server) handleDeleteForm(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.capabilityPaste(w, r, capDelete)
+	if !ok {
+		return
+	}
+	s.render(w, r, http.StatusOK, "delete", deletePageData{ID: paste.ID, Token: s.capabilityToken(paste, capDelete)})
+}
+
+func (s *Server) handleCapabilityDelete(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.capabilityPaste(w, r, capDelete)
+	if !ok {
+		return
+	}
+	if err := s.deletePaste(r.Context(), paste.ID, actorLink); err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, errLegalHold) {
+		s.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,83 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"tiny-pastebin/internal/challenge"
+)
+
+// challengeHeader carries a solved challenge response on API requests.
+const challengeHeader = "X-Challenge-Response"
+
+// Challenge is a human-verification check, such as a CAPTCHA, that
+// anonymous creators must pass.
+type Challenge interface {
+	Widget() challenge.Widget
+	// Verify returns challenge.ErrFailed for a response that was not
+	// accepted, and other errors when the provider could not be asked.
+	Verify(ctx context.Context, response, remoteIP string) error
+}
+
+// challengeRequired reports whether r must pass the challenge: signed-in
+// users and API clients are trusted already.
+func (s *Server) challengeRequired(r *http.Request) bool {
+	if s.challenge == nil || apiCaller(r) {
+		return false
+	}
+	_, signedIn := s.currentSession(r)
+	return !signedIn
+}
+
+// challengeWidget returns the widget the create form shows r, if any.
+func (s *Server) challengeWidget(r *http.Request) *challenge.Widget {
+	if !s.challengeRequired(r) {
+		return nil
+	}
+	w := s.challenge.Widget()
+	return &w
+}
+
+// passChallenge verifies the solved response sent with r, returning a
+// message for the creator when it is missing or was not accepted.
+func (s *Server) passChallenge(r *http.Request, response string) (string, bool) {
+	if !s.challengeRequired(r) {
+		return "", true
+	}
+	remoteIP := ClientIP(r, s.proxies)
+	if s.privacy != nil {
+		remoteIP = ""
+	}
+	err := s.challenge.Verify(r.Context(), response, remoteIP)
+	switch {
+	case err == nil:
+		return "", true
+	case errors.Is(err, challenge.ErrFailed):
+		return "Please complete the challenge to prove you are human", false
+	default:
+		if s.logger != nil {
+			s.logger.WarnContext(r.Context(), "verify challenge", "error", err)
+		}
+		return "The challenge could not be verified, please try again", false
+	}
+}
+
+// passChallengeForm checks a form submission, which carries the response
+// in the widget's field.
+func (s *Server) passChallengeForm(r *http.Request) (string, bool) {
+	if !s.challengeRequired(r) {
+		return "", true
+	}
+	return s.passChallenge(r, r.FormValue(s.challenge.Widget().Field))
+}
+
+// passChallengeAPI checks an API create request, answering 403 when it
+// fails.
+func (s *Server) passChallengeAPI(w http.ResponseWriter, r *http.Request) bool {
+	msg, ok := s.passChallenge(r, r.Header.Get(challengeHeader))
+	if !ok {
+		writeJSON(w, http.StatusForbidden, apiError{Error: msg + ": send an API key, or a solved response in " + challengeHeader})
+	}
+	return ok
+}
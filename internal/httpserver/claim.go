@@ -0,0 +1,137 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// errClaimDenied reports a claim token that does not match the paste's
+// current owner, including one that has already been used.
+var errClaimDenied = errors.New("invalid claim token")
+
+type claimRequest struct {
+	ClaimToken string `json:"claim_token"`
+}
+
+type createPasteResponse struct {
+	pasteSummary
+	// ClaimToken lets an anonymous creator move the paste into an account
+	// later. Clients keep it alongside the paste URL, e.g. in localStorage.
+	ClaimToken string `json:"claim_token,omitempty"`
+	// Warnings tell the creator about problems worth a second look, such
+	// as credentials in the content.
+	Warnings []string `json:"warnings,omitempty"`
+	// Links are the paste's capability links. Only the view link is meant
+	// to be shared.
+	Links *pasteLinks `json:"links,omitempty"`
+}
+
+// isAnonymousOwner reports whether owner is not tied to an account.
+func isAnonymousOwner(owner string) bool {
+	return owner == "" || strings.HasPrefix(owner, anonOwnerScope)
+}
+
+// claimToken is bound to the paste and its current owner, so it stops
+// verifying as soon as the paste has been claimed.
+func (s *Server) claimToken(p *storage.Paste) string {
+	if !isAnonymousOwner(p.Owner) {
+		return ""
+	}
+	return s.sealMAC("claim", p.ID+"\x00"+p.Owner)
+}
+
+// claimPaste moves an anonymous paste to owner. Claims are serialized so a
+// token can only ever be redeemed once.
+func (s *Server) claimPaste(ctx context.Context, id, token, owner string) (*storage.Paste, error) {
+	s.claimMu.Lock()
+	defer s.claimMu.Unlock()
+
+	paste, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if paste.Deleted() {
+		return nil, storage.ErrNotFound
+	}
+	if !isAnonymousOwner(paste.Owner) || !s.macMatches("claim", paste.ID+"\x00"+paste.Owner, token) {
+		return nil, errClaimDenied
+	}
+	paste.Owner = owner
+	if err := s.store.Save(ctx, paste); err != nil {
+		return nil, err
+	}
+	if s.logger != nil {
+		s.logger.InfoContext(ctx, "paste claimed", "id", id, "owner", owner)
+	}
+	return paste, nil
+}
+
+// handleAPIClaim moves an anonymously created paste into the caller's account.
+func (s *Server) handleAPIClaim(w http.ResponseWriter, r *http.Request) {
+	owner := s.apiOwner(r)
+	if isAnonymousOwner(owner) {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "sign in to claim pastes"})
+		return
+	}
+	var req claimRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil || req.ClaimToken == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "claim_token required"})
+		return
+	}
+	paste, err := s.claimPaste(r.Context(), chi.URLParam(r, "id"), req.ClaimToken, owner)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return
+	case errors.Is(err, errClaimDenied):
+		writeJSON(w, http.StatusForbidden, apiError{Error: err.Error()})
+		return
+	case err != nil:
+		s.apiServerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.summarize(r, paste))
+}
+
+// handleMineClaim moves every paste created from this browser into the
+// signed-in account.
+func (s *Server) handleMineClaim(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.render(w, r, http.StatusBadRequest, "error", errorPageData{Message: "Unable to parse form"})
+		return
+	}
+	sess, ok := s.currentSession(r)
+	anon := s.creatorOwner(r)
+	if !ok || anon == "" || !s.macMatches("owner-csrf", sess.Owner, r.PostFormValue("csrf")) {
+		s.render(w, r, http.StatusForbidden, "error", errorPageData{Message: "Forbidden"})
+		return
+	}
+	pastes, err := s.ownedPastes(r, anon)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	claimed := 0
+	for _, p := range pastes {
+		_, err := s.claimPaste(r.Context(), p.ID, s.claimToken(p), sess.Owner)
+		switch {
+		case err == nil:
+			claimed++
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, errClaimDenied):
+		default:
+			s.serverError(w, r, err)
+			return
+		}
+	}
+	notice := fmt.Sprintf("Moved %s into your account.", plural(claimed, "paste"))
+	http.Redirect(w, r, "/mine?notice="+url.QueryEscape(notice), http.StatusSeeOther)
+}
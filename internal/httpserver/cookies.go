@@ -0,0 +1,102 @@
+package httpserver
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sealValue encodes v as JSON and signs it with the cookie secret. The
+// purpose string keeps values minted for one cookie from validating as another.
+func (s *Server) sealValue(purpose string, v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + s.sealMAC(purpose, payload), nil
+}
+
+// openValue verifies a value produced by sealValue and decodes it into v.
+func (s *Server) openValue(purpose, sealed string, v any) bool {
+	payload, sig, ok := strings.Cut(sealed, ".")
+	if !ok || !s.macMatches(purpose, payload, sig) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+func (s *Server) sealMAC(purpose, payload string) string {
+	return macWith(s.cookieSecret, purpose, payload)
+}
+
+// macMatches reports whether sig is the sealMAC of purpose and payload under
+// the current cookie secret or a previous one.
+func (s *Server) macMatches(purpose, payload, sig string) bool {
+	for _, secret := range s.cookieSecrets() {
+		if hmac.Equal([]byte(sig), []byte(macWith(secret, purpose, payload))) {
+			return true
+		}
+	}
+	return false
+}
+
+// cookieSecrets returns the secrets signed values are checked against,
+// current first.
+func (s *Server) cookieSecrets() [][]byte {
+	return append([][]byte{s.cookieSecret}, s.oldSecrets...)
+}
+
+func macWith(secret []byte, purpose, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) setSealedCookie(w http.ResponseWriter, r *http.Request, name, path string, v any, ttl time.Duration) error {
+	value, err := s.sealValue(name, v)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(ttl.Seconds()),
+		Expires:  s.nowTime().Add(ttl),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   s.isSecureRequest(r),
+	})
+	return nil
+}
+
+func (s *Server) readSealedCookie(r *http.Request, name string, v any) bool {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return false
+	}
+	return s.openValue(name, cookie.Value, v)
+}
+
+func (s *Server) clearCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     path,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
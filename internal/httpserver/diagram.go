@@ -0,0 +1,131 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	// maxDiagrams caps the diagrams rendered for one paste.
+	maxDiagrams = 20
+	// maxDiagramBytes skips fenced blocks too large to be worth rendering.
+	maxDiagramBytes = 64 << 10
+	// diagramCacheSize is how many rendered SVGs are kept.
+	diagramCacheSize = 256
+)
+
+// diagramLangs maps the info strings of fenced blocks to renderer languages.
+var diagramLangs = map[string]string{"mermaid": "mermaid", "plantuml": "plantuml", "puml": "plantuml"}
+
+type diagramBlock struct {
+	Lang   string
+	Source string
+}
+
+// diagramLink points the view page at the SVG of one diagram.
+type diagramLink struct {
+	Lang   string
+	Number int
+	URL    string
+}
+
+// extractDiagrams finds the fenced diagram blocks of a markdown paste, in
+// order. Fences follow CommonMark: a run of three or more backticks or
+// tildes, closed by a run of the same character at least as long.
+func extractDiagrams(content string) []diagramBlock {
+	var (
+		out   []diagramBlock
+		fence string
+		lang  string
+		body  strings.Builder
+	)
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" {
+			if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+				continue
+			}
+			info := strings.TrimLeft(trimmed, trimmed[:1])
+			fence = trimmed[:len(trimmed)-len(info)]
+			lang = ""
+			if fields := strings.Fields(info); len(fields) > 0 {
+				lang = diagramLangs[strings.ToLower(fields[0])]
+			}
+			body.Reset()
+			continue
+		}
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			if lang != "" && body.Len() <= maxDiagramBytes && len(out) < maxDiagrams {
+				out = append(out, diagramBlock{Lang: lang, Source: body.String()})
+			}
+			fence = ""
+			continue
+		}
+		if lang != "" && body.Len() <= maxDiagramBytes {
+			body.WriteString(strings.TrimSuffix(line, "\r"))
+			body.WriteByte('\n')
+		}
+	}
+	return out
+}
+
+// diagramLinks lists the renderable diagrams of a markdown paste.
+func (s *Server) diagramLinks(paste *storage.Paste) []diagramLink {
+	if s.diagrams == nil || paste.Syntax != "markdown" {
+		return nil
+	}
+	var links []diagramLink
+	for i, d := range extractDiagrams(paste.Content) {
+		if s.diagrams.Supports(d.Lang) {
+			links = append(links, diagramLink{Lang: d.Lang, Number: i + 1, URL: fmt.Sprintf("/p/%s/diagrams/%d", paste.ID, i+1)})
+		}
+	}
+	return links
+}
+
+// handleDiagram serves the SVG of the nth diagram in a markdown paste. The
+// page shows it through an img element and the response forbids scripts, so
+// markup smuggled into a diagram cannot run in the site's origin.
+func (s *Server) handleDiagram(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if !s.unlocked(r, paste) {
+		s.notFound(w, r)
+		return
+	}
+	n, _ := strconv.Atoi(chi.URLParam(r, "n"))
+	blocks := extractDiagrams(paste.Content)
+	if s.diagrams == nil || paste.Syntax != "markdown" || n < 1 || n > len(blocks) || !s.diagrams.Supports(blocks[n-1].Lang) {
+		s.notFound(w, r)
+		return
+	}
+	block := blocks[n-1]
+	svg, err := s.diagrams.Render(r.Context(), block.Lang, block.Source)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(r.Context(), "render diagram", "id", paste.ID, "diagram", n, "error", err)
+		}
+		http.Error(w, "diagram could not be rendered", http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:; font-src data:")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	s.setPasteRobots(w, paste)
+	_, _ = w.Write(svg)
+}
@@ -0,0 +1,126 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	draftTTL       = time.Hour
+	maxDraftsTotal = 10_000
+)
+
+// Client draft tokens are random strings generated in the browser.
+var draftTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22,64}$`)
+
+type draft struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Syntax  string `json:"syntax"`
+	Expire  string `json:"expire"`
+	Token   string `json:"token,omitempty"`
+
+	expires time.Time
+}
+
+// draftStore keeps unsubmitted form contents in memory for a short time so
+// the create page can recover them after accidental navigation.
+type draftStore struct {
+	mu    sync.Mutex
+	items map[string]draft
+}
+
+func newDraftStore() *draftStore {
+	return &draftStore{items: make(map[string]draft)}
+}
+
+func (d *draftStore) put(token string, v draft, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, exists := d.items[token]; !exists && len(d.items) >= maxDraftsTotal {
+		return false
+	}
+	v.Token = ""
+	v.expires = now.Add(draftTTL)
+	d.items[token] = v
+	return true
+}
+
+func (d *draftStore) get(token string, now time.Time) (draft, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	v, ok := d.items[token]
+	if !ok || now.After(v.expires) {
+		return draft{}, false
+	}
+	return v, true
+}
+
+func (d *draftStore) remove(token string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.items, token)
+}
+
+func (d *draftStore) prune(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	removed := 0
+	for token, v := range d.items {
+		if now.After(v.expires) {
+			delete(d.items, token)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *Server) handleSaveDraft(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(s.maxBytes)+4096)
+	var in draft
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid draft"})
+		return
+	}
+	if !draftTokenPattern.MatchString(in.Token) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid draft token"})
+		return
+	}
+	if len(in.Content) > s.maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: fmt.Sprintf("content exceeds %d byte limit", s.maxBytes)})
+		return
+	}
+	if len(in.Title) > maxTitleLen {
+		in.Title = in.Title[:maxTitleLen]
+	}
+	if in.Content == "" && in.Title == "" {
+		s.drafts.remove(in.Token)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !s.drafts.put(in.Token, in, s.nowTime()) {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "draft storage full"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetDraft(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if !draftTokenPattern.MatchString(token) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "draft not found"})
+		return
+	}
+	v, ok := s.drafts.get(token, s.nowTime())
+	if !ok {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "draft not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
@@ -0,0 +1,164 @@
+package httpserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	embedWidth  = 640
+	embedHeight = 400
+)
+
+type embedPageData struct {
+	Paste       *storage.Paste
+	Title       string
+	SyntaxLabel string
+	Canonical   string
+	RawOnly     bool
+	SiteName    string
+}
+
+type oembedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// handleEmbed renders a chrome-free view of a paste meant to be framed by
+// other sites. Protected and encrypted pastes are never embeddable.
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if paste.PasswordHash != "" || paste.Encrypted {
+		s.notFound(w, r)
+		return
+	}
+
+	data := embedPageData{
+		Paste:       paste,
+		Title:       displayTitle(paste),
+		SyntaxLabel: syntaxLabel(paste.Syntax),
+		Canonical:   s.canonicalURL(r, paste.ID),
+		RawOnly:     s.rawOnlyBytes > 0 && paste.Size > s.rawOnlyBytes,
+		SiteName:    s.brand.name,
+	}
+	buf := &bytes.Buffer{}
+	if err := s.templates.ExecuteTemplate(buf, "embed", data); err != nil {
+		s.handleTemplateError(w, r, http.StatusInternalServerError, "embed", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors *")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	s.setPasteRobots(w, paste)
+	_, _ = buf.WriteTo(w)
+}
+
+// handleOEmbed implements the oEmbed JSON endpoint for paste URLs.
+func (s *Server) handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		writeJSON(w, http.StatusNotImplemented, apiError{Error: "only json format is supported"})
+		return
+	}
+	id, ok := s.pasteIDFromURL(r, query.Get("url"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "url is not a paste on this instance"})
+		return
+	}
+	paste, err := s.fetchPaste(r, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+			return
+		}
+		s.apiServerError(w, r, err)
+		return
+	}
+	if paste.PasswordHash != "" || paste.Encrypted {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return
+	}
+
+	width := clampDimension(query.Get("maxwidth"), embedWidth)
+	height := clampDimension(query.Get("maxheight"), embedHeight)
+	writeJSON(w, http.StatusOK, oembedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: s.brand.name,
+		ProviderURL:  s.canonicalURL(r, ""),
+		Title:        displayTitle(paste),
+		HTML:         s.embedSnippet(r, paste.ID, width, height),
+		Width:        width,
+		Height:       height,
+	})
+}
+
+// pasteIDFromURL extracts the paste ID from a URL pointing at this instance.
+func (s *Server) pasteIDFromURL(r *http.Request, raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	home, err := url.Parse(s.canonicalURL(r, ""))
+	if err != nil {
+		return "", false
+	}
+	var prefix string
+	switch {
+	case strings.EqualFold(u.Host, home.Host):
+		prefix = strings.TrimSuffix(home.Path, "/") + "/p/"
+	case s.shortURL != nil && strings.EqualFold(u.Host, s.shortURL.Host):
+		prefix = s.shortURL.Path + "/"
+	default:
+		return "", false
+	}
+	id, ok := strings.CutPrefix(u.Path, prefix)
+	if !ok {
+		return "", false
+	}
+	id = strings.TrimSuffix(id, "/")
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *Server) embedSnippet(r *http.Request, id string, width, height int) string {
+	src := template.HTMLEscapeString(s.absoluteURL(r, "/p/"+id+"/embed"))
+	return fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" style="border:0" loading="lazy" sandbox="allow-scripts allow-popups"></iframe>`, src, width, height)
+}
+
+func clampDimension(raw string, def int) int {
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 || v > def {
+		return def
+	}
+	return v
+}
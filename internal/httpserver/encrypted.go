@@ -0,0 +1,31 @@
+package httpserver
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// Encrypted pastes are sealed in the browser by web/static/e2e.js with
+// AES-GCM; the key stays in the URL fragment, so the server only ever holds
+// base64(iv || ciphertext || tag) and cannot read, preview or screen them.
+const (
+	encryptedIVLen  = 12
+	encryptedTagLen = 16
+)
+
+// validateEncrypted checks the envelope of an encrypted paste and refuses the
+// options that need the server to see its content.
+func validateEncrypted(in *pasteInput) error {
+	in.Content = strings.TrimSpace(in.Content)
+	raw, err := base64.StdEncoding.DecodeString(in.Content)
+	if err != nil || len(raw) < encryptedIVLen+encryptedTagLen {
+		return inputError("Encrypted content must be base64 of an AES-GCM nonce and ciphertext")
+	}
+	if in.Public {
+		return inputError("Encrypted pastes cannot be listed publicly")
+	}
+	if in.SourceURL != "" {
+		return inputError("Encrypted pastes cannot track a source URL")
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// Pastes keep two creator identities: the signed-in owner and a keyed hash
+// of the creator's address. Admins can list and erase everything held under
+// either, and owners can download what is stored about each of their
+// pastes.
+
+// identity selects pastes by creator. Setting both fields selects the
+// pastes matching both.
+type identity struct {
+	Owner  string `json:"owner,omitempty"`
+	IPHash string `json:"ip_hash,omitempty"`
+}
+
+func (id identity) empty() bool {
+	return id.Owner == "" && id.IPHash == ""
+}
+
+// identityPastes returns every paste held under id, newest first, including
+// expired, soft-deleted and taken-down ones.
+func (s *Server) identityPastes(ctx context.Context, id identity) ([]*storage.Paste, error) {
+	var all []*storage.Paste
+	for _, deleted := range []bool{false, true} {
+		pastes, err := s.store.List(ctx, storage.ListOptions{Owner: id.Owner, IPHash: id.IPHash, Deleted: deleted})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, pastes...)
+	}
+	storage.SortNewestFirst(all)
+	return all, nil
+}
+
+// erasure reports what erasing an identity did.
+type erasure struct {
+	Purged int `json:"purged"`
+	// Held counts taken-down pastes kept under legal hold; purge them
+	// individually once the hold is released.
+	Held int `json:"held"`
+}
+
+// eraseIdentity removes every paste held under id for good, skipping the
+// grace period soft deletes get. Taken-down pastes are kept.
+func (s *Server) eraseIdentity(ctx context.Context, id identity, actor string) (erasure, error) {
+	var out erasure
+	pastes, err := s.identityPastes(ctx, id)
+	if err != nil {
+		return out, err
+	}
+	now := s.nowTime().UTC()
+	for _, p := range pastes {
+		if p.TakenDown() {
+			out.Held++
+			continue
+		}
+		if err := s.store.Delete(ctx, p.ID); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			return out, err
+		}
+		if !p.Deleted() {
+			s.syntaxStats.remove(p)
+		}
+		s.tombstones.bury(p.ID, now)
+		s.emit(ctx, events.Event{Type: events.PasteDeleted, PasteID: p.ID, Reason: "creator data erased", Actor: actor})
+		out.Purged++
+	}
+	return out, nil
+}
+
+// handleIdentityPastes lists the pastes held under the owner and ip_hash
+// parameters.
+func (s *Server) handleIdentityPastes(w http.ResponseWriter, r *http.Request) {
+	id := identity{Owner: r.URL.Query().Get("owner"), IPHash: r.URL.Query().Get("ip_hash")}
+	if id.empty() {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "owner or ip_hash is required"})
+		return
+	}
+	pastes, err := s.identityPastes(r.Context(), id)
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	out := make([]pasteSummary, 0, len(pastes))
+	for _, p := range pastes {
+		out = append(out, s.summarize(r, p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleEraseIdentity(w http.ResponseWriter, r *http.Request) {
+	var id identity
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if err := json.NewDecoder(r.Body).Decode(&id); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if id.empty() {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "owner or ip_hash is required"})
+		return
+	}
+	out, err := s.eraseIdentity(r.Context(), id, actorAdmin)
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleAdminEraseIdentity erases the identity the dashboard is filtered by.
+func (s *Server) handleAdminEraseIdentity(w http.ResponseWriter, r *http.Request) {
+	id := identity{Owner: strings.TrimSpace(r.PostFormValue("owner")), IPHash: strings.TrimSpace(r.PostFormValue("ip"))}
+	if id.empty() {
+		http.Redirect(w, r, adminReturnURL(r, "Filter by owner or IP hash first."), http.StatusSeeOther)
+		return
+	}
+	out, err := s.eraseIdentity(r.Context(), id, actorAdmin)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	if s.logger != nil && out.Purged > 0 {
+		s.logger.InfoContext(r.Context(), "admin erased creator data", "count", out.Purged)
+	}
+	notice := fmt.Sprintf("Erased %s.", plural(out.Purged, "paste"))
+	if out.Held > 0 {
+		notice += fmt.Sprintf(" Kept %s under legal hold.", plural(out.Held, "paste"))
+	}
+	http.Redirect(w, r, adminReturnURL(r, notice), http.StatusSeeOther)
+}
+
+// pasteExport is everything stored about a paste. The password hash is
+// left out; Protected says whether there is one.
+type pasteExport struct {
+	ExportedAt time.Time `json:"exported_at"`
+	storage.Paste
+	PasswordHash string `json:"password_hash,omitempty"`
+	Protected    bool   `json:"protected"`
+}
+
+// handleAPIExport returns a paste's stored record for its owner or an
+// admin, as a JSON download.
+func (s *Server) handleAPIExport(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.store.Get(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return
+	}
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if _, admin := s.adminIdentity(r); !admin {
+		if owner := s.apiOwner(r); owner == "" || paste.Owner != owner {
+			writeJSON(w, http.StatusForbidden, apiError{Error: "only the owner can export this paste"})
+			return
+		}
+		// The owner must not get back content an admin took down.
+		if paste.TakenDown() {
+			gone := &goneError{reason: goneTakenDown, legal: paste.TakedownLegal}
+			writeJSON(w, gone.status(), apiError{Error: "paste taken down"})
+			return
+		}
+	}
+	out := pasteExport{ExportedAt: s.nowTime().UTC(), Paste: *paste, Protected: paste.PasswordHash != ""}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "paste-"+paste.ID+"-export.json"))
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,136 @@
+package httpserver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const recentLimit = 50
+
+type recentPageData struct {
+	Pastes []recentItem
+}
+
+type recentItem struct {
+	ID          string
+	Title       string
+	SyntaxLabel string
+	Size        int
+	CreatedAt   time.Time
+}
+
+func (d recentPageData) PageTitle() string {
+	return "Recent Pastes · Tiny Pastebin"
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Link     atomLink     `xml:"link"`
+	Category atomCategory `xml:"category"`
+	Summary  string       `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+func (s *Server) recentPastes(r *http.Request) ([]*storage.Paste, error) {
+	return s.store.List(r.Context(), storage.ListOptions{
+		PublicOnly: true,
+		ActiveAt:   s.nowTime(),
+		Limit:      recentLimit,
+	})
+}
+
+func (s *Server) handleRecent(w http.ResponseWriter, r *http.Request) {
+	pastes, err := s.recentPastes(r)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	items := make([]recentItem, 0, len(pastes))
+	for _, p := range pastes {
+		items = append(items, recentItem{
+			ID:          p.ID,
+			Title:       displayTitle(p),
+			SyntaxLabel: syntaxLabel(p.Syntax),
+			Size:        p.Size,
+			CreatedAt:   p.CreatedAt,
+		})
+	}
+	s.render(w, r, http.StatusOK, "recent", recentPageData{Pastes: items})
+}
+
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	pastes, err := s.recentPastes(r)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+
+	home := s.canonicalURL(r, "")
+	updated := time.Unix(0, 0).UTC()
+	if len(pastes) > 0 {
+		updated = pastes[0].CreatedAt
+	}
+	feed := atomFeed{
+		ID:      home,
+		Title:   s.brand.name + " · Recent Pastes",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: home},
+			{Href: s.absoluteURL(r, "/feed.atom"), Rel: "self", Type: "application/atom+xml"},
+		},
+		Entries: make([]atomEntry, 0, len(pastes)),
+	}
+	for _, p := range pastes {
+		link := s.canonicalURL(r, p.ID)
+		label := syntaxLabel(p.Syntax)
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:       link,
+			Title:    displayTitle(p),
+			Updated:  p.CreatedAt.UTC().Format(time.RFC3339),
+			Link:     atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Category: atomCategory{Term: p.Syntax, Label: label},
+			Summary:  fmt.Sprintf("%s paste, %d bytes", label, p.Size),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	_, _ = w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil && s.logger != nil {
+		s.logger.ErrorContext(r.Context(), "encode feed", "error", err)
+	}
+}
+
+func displayTitle(p *storage.Paste) string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return "Paste " + p.ID
+}
@@ -0,0 +1,205 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/filter"
+	"tiny-pastebin/internal/storage"
+)
+
+// Metadata the content filter records on pastes it flags for review. Keys
+// under reservedMetadataPrefix cannot be set by creators and are not shown
+// to readers.
+const (
+	reservedMetadataPrefix = "filter."
+	filterReviewKey        = "filter.review"
+	filterReasonKey        = "filter.reason"
+	// filterReviewPending is the filterReviewKey value of pastes awaiting
+	// review, which the admin dashboard lists them by.
+	filterReviewPending = "pending"
+)
+
+// actorFilter is recorded on events raised by the content filter.
+const actorFilter = "filter"
+
+// screenPaste runs the content filter over validated input, refusing it when
+// a reject rule matches and otherwise noting the verdict for buildPaste.
+func (s *Server) screenPaste(in *pasteInput) error {
+	if s.filter == nil {
+		return nil
+	}
+	extra := []string{in.Title}
+	for _, v := range in.Metadata {
+		extra = append(extra, v)
+	}
+	in.verdict = s.filter.Check(in.Content, extra...)
+	if in.verdict.Action == filter.Reject {
+		return inputError("This paste was rejected by the content filter")
+	}
+	return nil
+}
+
+// applyVerdict quarantines or flags a new paste as its filter verdict asks.
+func applyVerdict(paste *storage.Paste, v filter.Verdict) {
+	switch v.Action {
+	case filter.Quarantine:
+		paste.Quarantined = true
+		paste.QuarantineReason = "content filter: " + v.Reason
+	case filter.Flag:
+		paste.Metadata = maps.Clone(paste.Metadata)
+		if paste.Metadata == nil {
+			paste.Metadata = make(map[string]string)
+		}
+		paste.Metadata[filterReviewKey] = filterReviewPending
+		paste.Metadata[filterReasonKey] = v.Reason
+	}
+}
+
+// pasteCreated does what every newly saved paste needs: it is counted,
+// audited, reported if the filter held it back, and scanned unless it is
+// encrypted.
+func (s *Server) pasteCreated(ctx context.Context, paste *storage.Paste) {
+	s.syntaxStats.add(paste)
+	s.record(ctx, storage.AuditEntry{Action: auditPasteCreated, PasteID: paste.ID, Actor: paste.Owner, IPHash: paste.IPHash})
+	s.contentSaved(ctx, paste)
+}
+
+// contentSaved reports a paste whose content was just saved if the filter
+// held it back, and scans it unless it is encrypted or was scanned before
+// it was saved.
+func (s *Server) contentSaved(ctx context.Context, paste *storage.Paste) {
+	switch {
+	case paste.Quarantined:
+		s.emit(ctx, events.Event{Type: events.PasteQuarantined, PasteID: paste.ID, Reason: paste.QuarantineReason, Actor: actorFilter})
+	case paste.Metadata[filterReviewKey] == filterReviewPending:
+		s.emit(ctx, events.Event{Type: events.PasteFlagged, PasteID: paste.ID, Reason: paste.Metadata[filterReasonKey], Actor: actorFilter})
+	}
+	if !paste.Encrypted && !paste.Sealed && !s.scanReject {
+		s.scanAsync(ctx, paste.ID)
+	}
+}
+
+// flagReason returns why the filter flagged p, or "" when it awaits no
+// review.
+func flagReason(p *storage.Paste) string {
+	if p.Metadata[filterReviewKey] != filterReviewPending {
+		return ""
+	}
+	if reason := p.Metadata[filterReasonKey]; reason != "" {
+		return reason
+	}
+	return "flagged"
+}
+
+// visibleMetadata drops the reserved keys from metadata shown to readers.
+func visibleMetadata(m map[string]string) map[string]string {
+	reserved := func(k, _ string) bool { return strings.HasPrefix(k, reservedMetadataPrefix) }
+	for k, v := range m {
+		if reserved(k, v) {
+			m = maps.Clone(m)
+			maps.DeleteFunc(m, reserved)
+			break
+		}
+	}
+	return m
+}
+
+// handleAdminReviewed clears the review flag from a paste.
+func (s *Server) handleAdminReviewed(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	paste, err := s.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" no longer exists."), http.StatusSeeOther)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if _, ok := paste.Metadata[filterReviewKey]; ok {
+		paste.Metadata = maps.Clone(paste.Metadata)
+		delete(paste.Metadata, filterReviewKey)
+		delete(paste.Metadata, filterReasonKey)
+		if err := s.store.Save(r.Context(), paste); err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+	}
+	http.Redirect(w, r, adminReturnURL(r, "Marked "+id+" as reviewed."), http.StatusSeeOther)
+}
+
+type adminFilterPageData struct {
+	Rules  []filter.Rule
+	Bans   []filter.Ban
+	CSRF   string
+	Notice string
+	Error  string
+}
+
+func (d adminFilterPageData) PageTitle() string { return "Content filter · Tiny Pastebin" }
+func (d adminFilterPageData) NoIndex() bool     { return true }
+
+func (s *Server) handleAdminFilter(w http.ResponseWriter, r *http.Request) {
+	s.renderAdminFilter(w, r, http.StatusOK, adminFilterPageData{Notice: r.URL.Query().Get("notice")})
+}
+
+// handleAdminBan bans content by hash, or by the ID of a paste holding it.
+func (s *Server) handleAdminBan(w http.ResponseWriter, r *http.Request) {
+	target := strings.TrimSpace(r.PostFormValue("target"))
+	if target == "" {
+		s.renderAdminFilter(w, r, http.StatusBadRequest, adminFilterPageData{Error: "Enter a paste ID or content hash"})
+		return
+	}
+	action, err := filter.ParseAction(r.PostFormValue("action"))
+	if err != nil {
+		s.renderAdminFilter(w, r, http.StatusBadRequest, adminFilterPageData{Error: err.Error()})
+		return
+	}
+	hash := target
+	if paste, err := s.store.Get(r.Context(), target); err == nil {
+		if paste.Encrypted || paste.Sealed {
+			s.renderAdminFilter(w, r, http.StatusBadRequest, adminFilterPageData{Error: "Paste " + target + " is encrypted; ban its content by hash instead"})
+			return
+		}
+		hash = filter.Hash(paste.Content)
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		s.serverError(w, r, err)
+		return
+	}
+	if err := s.filter.Ban(hash, action, strings.TrimSpace(r.PostFormValue("note")), s.nowTime()); err != nil {
+		s.renderAdminFilter(w, r, http.StatusBadRequest, adminFilterPageData{Error: err.Error()})
+		return
+	}
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "content banned", "hash", hash, "action", string(action))
+	}
+	http.Redirect(w, r, "/admin/filter?notice="+url.QueryEscape("Banned "+hash+"."), http.StatusSeeOther)
+}
+
+func (s *Server) handleAdminUnban(w http.ResponseWriter, r *http.Request) {
+	hash := chi.URLParam(r, "hash")
+	if _, err := s.filter.Unban(hash); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "content unbanned", "hash", hash)
+	}
+	http.Redirect(w, r, "/admin/filter?notice="+url.QueryEscape("Lifted the ban on "+hash+"."), http.StatusSeeOther)
+}
+
+func (s *Server) renderAdminFilter(w http.ResponseWriter, r *http.Request, status int, data adminFilterPageData) {
+	identity, _ := s.adminIdentity(r)
+	data.Rules = s.filter.Rules()
+	data.Bans = s.filter.Bans()
+	data.CSRF = s.adminCSRF(identity)
+	s.render(w, r, status, "admin-filter", data)
+}
@@ -0,0 +1,135 @@
+package httpserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const formTokenTTL = 24 * time.Hour
+
+var (
+	errFormTokenInvalid = errors.New("invalid form token")
+	errFormTokenPending = errors.New("form submission already in progress")
+)
+
+// formTokens issues signed one-time tokens for the create form and remembers
+// which tokens have been redeemed, so a resubmitted form maps back to the
+// paste it already created instead of producing a duplicate.
+type formTokens struct {
+	secret   []byte
+	previous [][]byte
+	mu       sync.Mutex
+	used     map[string]redeemedToken
+}
+
+type redeemedToken struct {
+	pasteID string
+	expires time.Time
+}
+
+func newFormTokens(secret []byte, previous [][]byte) *formTokens {
+	return &formTokens{secret: secret, previous: previous, used: make(map[string]redeemedToken)}
+}
+
+func (f *formTokens) issue(now time.Time) (string, error) {
+	payload := make([]byte, 24)
+	binary.BigEndian.PutUint64(payload, uint64(now.Unix()))
+	if _, err := rand.Read(payload[8:]); err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding.EncodeToString(payload)
+	return enc + "." + f.sign(enc), nil
+}
+
+// claim reserves token for a new submission. When the token was already
+// redeemed it returns the ID of the paste created by the first submission.
+func (f *formTokens) claim(token string, now time.Time) (string, error) {
+	issued, ok := f.issuedAt(token)
+	if !ok || now.Sub(issued) > formTokenTTL {
+		return "", errFormTokenInvalid
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if prev, ok := f.used[token]; ok {
+		if prev.pasteID == "" {
+			return "", errFormTokenPending
+		}
+		return prev.pasteID, nil
+	}
+	f.used[token] = redeemedToken{expires: issued.Add(formTokenTTL)}
+	return "", nil
+}
+
+// issuedAt returns when a validly signed token was issued.
+func (f *formTokens) issuedAt(token string) (time.Time, bool) {
+	enc, sig, ok := strings.Cut(token, ".")
+	if !ok || !f.verify(enc, sig) {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil || len(payload) != 24 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(payload)), 0), true
+}
+
+// complete records the paste created for a claimed token.
+func (f *formTokens) complete(token, pasteID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if entry, ok := f.used[token]; ok {
+		entry.pasteID = pasteID
+		f.used[token] = entry
+	}
+}
+
+// release forgets a claimed token after a failed submission so it can be retried.
+func (f *formTokens) release(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.used, token)
+}
+
+// prune drops redeemed tokens that can no longer be replayed.
+func (f *formTokens) prune(now time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	removed := 0
+	for token, entry := range f.used {
+		if now.After(entry.expires) {
+			delete(f.used, token)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (f *formTokens) sign(payload string) string {
+	return formTokenMAC(f.secret, payload)
+}
+
+// verify accepts tokens signed with the current or a previous secret, so a
+// rotation does not void forms that are already open.
+func (f *formTokens) verify(payload, sig string) bool {
+	for _, secret := range append([][]byte{f.secret}, f.previous...) {
+		if hmac.Equal([]byte(sig), []byte(formTokenMAC(secret, payload))) {
+			return true
+		}
+	}
+	return false
+}
+
+func formTokenMAC(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("form-token:"))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,95 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// CountryLookup finds the country an address is in, as an ISO 3166-1
+// alpha-2 code, or "" when it is unknown. *geoip.DB implements it.
+type CountryLookup interface {
+	Country(addr netip.Addr) (string, error)
+}
+
+// GeoPolicy applies per-country rules to creating pastes through the form
+// and the API. Reading is never restricted by country, and API keys and
+// JWTs, which an admin handed out, are exempt. The country of every request
+// is also recorded in the audit log.
+type GeoPolicy struct {
+	Lookup CountryLookup
+	// Block lists the countries whose clients may not create pastes.
+	Block []string
+	// Strict lists the countries whose clients create pastes under
+	// StrictLimiter as well as the instance rate limit.
+	Strict        []string
+	StrictLimiter *RateLimiter
+}
+
+type countryContextKey struct{}
+
+// requestCountry returns the country the request in ctx came from, or ""
+// when it is unknown or GeoIP is off.
+func requestCountry(ctx context.Context) string {
+	c, _ := ctx.Value(countryContextKey{}).(string)
+	return c
+}
+
+// locateCountry looks up the client's country once per request.
+func (s *Server) locateCountry(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, err := netip.ParseAddr(ClientIP(r, s.proxies))
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		country, err := s.geo.Lookup.Country(addr)
+		if err != nil {
+			if s.logger != nil {
+				s.logger.WarnContext(r.Context(), "geoip lookup", "error", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if country != "" {
+			r = r.WithContext(context.WithValue(r.Context(), countryContextKey{}, country))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// geoCreate guards the routes creating pastes with the GeoIP policy.
+func (s *Server) geoCreate(next http.Handler) http.Handler {
+	if s.geo == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		country := requestCountry(r.Context())
+		if country == "" || apiCaller(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		api := strings.HasPrefix(r.URL.Path, "/api/")
+		if slices.Contains(s.geo.Block, country) {
+			const msg = "Creating pastes is not available in your region"
+			if api {
+				writeJSON(w, http.StatusForbidden, apiError{Error: msg})
+			} else {
+				s.render(w, r, http.StatusForbidden, "error", errorPageData{Message: msg})
+			}
+			return
+		}
+		if slices.Contains(s.geo.Strict, country) && !s.geo.StrictLimiter.Allow(s.clientKey(r)) {
+			w.Header().Set("Retry-After", "60")
+			if api {
+				writeJSON(w, http.StatusTooManyRequests, apiError{Error: "rate limit exceeded"})
+			} else {
+				s.render(w, r, http.StatusTooManyRequests, "error", errorPageData{Message: "Too many pastes, please try again later"})
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -10,18 +10,24 @@ import (
 	"html/template"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/skip2/go-qrcode"
 
+	"tiny-pastebin/internal/challenge"
+	"tiny-pastebin/internal/filter"
 	"tiny-pastebin/internal/security"
 	"tiny-pastebin/internal/storage"
 )
 
 var (
-	syntaxWhitelist = []string{"plaintext", "go", "python", "js", "ts", "c", "cpp", "java", "bash", "sql", "html", "css", "json", "yaml", "markdown"}
+	syntaxWhitelist = []string{"plaintext", "go", "python", "js", "ts", "c", "cpp", "java", "bash", "sql", "html", "css", "json", "yaml", "markdown", "csv", "tsv", "ipynb"}
 	syntaxLabels    = map[string]string{
 		"plaintext": "Plain Text",
 		"go":        "Go",
@@ -38,6 +44,28 @@ var (
 		"json":      "JSON",
 		"yaml":      "YAML",
 		"markdown":  "Markdown",
+		"csv":       "CSV",
+		"tsv":       "TSV",
+		"ipynb":     "Jupyter Notebook",
+	}
+	syntaxExtensions = map[string]string{
+		"go":       "go",
+		"python":   "py",
+		"js":       "js",
+		"ts":       "ts",
+		"c":        "c",
+		"cpp":      "cpp",
+		"java":     "java",
+		"bash":     "sh",
+		"sql":      "sql",
+		"html":     "html",
+		"css":      "css",
+		"json":     "json",
+		"yaml":     "yml",
+		"markdown": "md",
+		"csv":      "csv",
+		"tsv":      "tsv",
+		"ipynb":    "ipynb",
 	}
 	expireChoices = []expireOption{
 		{Value: "10m", Label: "10 minutes", Duration: 10 * time.Minute},
@@ -55,7 +83,10 @@ var (
 	}()
 )
 
-const defaultExpire = "7d"
+const (
+	defaultExpire = "7d"
+	maxTitleLen   = 120
+)
 
 type expireOption struct {
 	Value    string
@@ -72,11 +103,29 @@ type option struct {
 type indexPageData struct {
 	SyntaxOptions []option
 	ExpireOptions []option
+	Title         string
 	Content       string
 	Syntax        string
 	Expire        string
+	Metadata      string
+	Public        bool
+	NoIndex       bool
+	ShowNoIndex   bool
+	License       string
+	Attribution   string
+	SourceURL     string
+	Licenses      []string
+	AcceptTerms   bool
+	FormToken     string
 	Error         string
 	MaxBytes      int
+	// Honeypot adds the hidden field of the bot check.
+	Honeypot bool
+	// Challenge is the widget anonymous creators must solve, if any.
+	Challenge *challenge.Widget
+	// Secrets asks the creator to confirm publishing the credentials found.
+	Secrets        bool
+	ConfirmSecrets bool
 }
 
 type viewPageData struct {
@@ -84,6 +133,33 @@ type viewPageData struct {
 	SyntaxLabel string
 	ExpiresIn   string
 	Canonical   string
+	Metadata    []metadataItem
+	LicenseURL  string
+	CanCompare  bool
+	SiteName    string
+	// RawOnly skips rendering the content for pastes above the raw-only threshold.
+	RawOnly bool
+	// Table is set for CSV and TSV pastes that parse, shown instead of the text.
+	Table *tableView
+	// Notebook is set for ipynb pastes that parse, shown instead of the text.
+	Notebook *notebookView
+	// Diagrams link the rendered diagrams of a markdown paste.
+	Diagrams     []diagramLink
+	EmbedSnippet string
+	OEmbedURL    string
+	// Description is the link preview text; empty when previews are disabled.
+	Description string
+	IsOwner     bool
+	// Links are the paste's capability links, shown to its owner.
+	Links *pasteLinks
+	// AdminCSRF authorizes the quarantine review and takedown forms shown
+	// to admins.
+	AdminCSRF string
+	// CanReport links the abuse report form.
+	CanReport bool
+	// ShareOptions offers share link lifetimes to readers who unlocked a
+	// protected paste with its password.
+	ShareOptions []option
 }
 
 type passwordPageData struct {
@@ -93,23 +169,76 @@ type passwordPageData struct {
 
 type errorPageData struct {
 	Message string
+	// Description replaces the generic explanation below the message.
+	Description string
+	RequestID   string
+	Suggestions []string
 }
 
 type titled interface {
 	PageTitle() string
 }
 
+// headLink is an extra <link> element emitted in the layout head.
+type headLink struct {
+	Rel   string
+	Type  string
+	Href  string
+	Title string
+}
+
+// headMeta is an extra <meta> element emitted in the layout head. OpenGraph
+// tags use Property, everything else uses Name.
+type headMeta struct {
+	Property string
+	Name     string
+	Content  string
+}
+
+// headed is implemented by page data that contributes head elements.
+type headed interface {
+	HeadLinks() []headLink
+	HeadMeta() []headMeta
+}
+
 func (d indexPageData) PageTitle() string {
 	return "New Paste · Tiny Pastebin"
 }
 
 func (d viewPageData) PageTitle() string {
+	if d.Paste != nil && d.Paste.Title != "" {
+		return fmt.Sprintf("%s · Tiny Pastebin", d.Paste.Title)
+	}
 	if d.Paste != nil && d.Paste.ID != "" {
 		return fmt.Sprintf("%s · Tiny Pastebin", d.Paste.ID)
 	}
 	return "View Paste · Tiny Pastebin"
 }
 
+func (d viewPageData) HeadLinks() []headLink {
+	if d.OEmbedURL == "" {
+		return nil
+	}
+	return []headLink{{Rel: "alternate", Type: "application/json+oembed", Href: d.OEmbedURL, Title: d.PageTitle()}}
+}
+
+func (d viewPageData) HeadMeta() []headMeta {
+	if d.Description == "" || d.Paste == nil {
+		return nil
+	}
+	title := displayTitle(d.Paste)
+	return []headMeta{
+		{Property: "og:type", Content: "article"},
+		{Property: "og:site_name", Content: d.SiteName},
+		{Property: "og:title", Content: title},
+		{Property: "og:description", Content: d.Description},
+		{Property: "og:url", Content: d.Canonical},
+		{Name: "twitter:card", Content: "summary"},
+		{Name: "twitter:title", Content: title},
+		{Name: "twitter:description", Content: d.Description},
+	}
+}
+
 func (d passwordPageData) PageTitle() string {
 	return "Protected Paste · Tiny Pastebin"
 }
@@ -123,6 +252,15 @@ func (d errorPageData) PageTitle() string {
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	data := s.indexData("", defaultExpire, "", "")
+	token, err := s.formTokens.issue(s.nowTime())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	data.FormToken = token
+	data.AcceptTerms = s.termsPending(r)
+	data.Challenge = s.challengeWidget(r)
+	s.setLimitHeaders(w)
 	s.render(w, r, http.StatusOK, "index", data)
 }
 
@@ -134,94 +272,367 @@ func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if reason, ok := s.trapsBot(r); ok {
+		s.dropBot(w, r, reason)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
 	content := r.FormValue("content")
 	syntax := r.FormValue("syntax")
 	expire := r.FormValue("expire")
 	password := r.FormValue("password")
+	metadataText := r.FormValue("metadata")
+	public := r.FormValue("public") == "on"
+	noIndex := r.FormValue("noindex") == "on"
+	license := r.FormValue("license")
+	attribution := r.FormValue("attribution")
+	sourceURL := r.FormValue("source_url")
+	formToken := r.FormValue("form_token")
+	confirmSecrets := r.FormValue("confirm_secrets") == "on"
+	encrypted := r.FormValue("encrypted") == "1"
+	var secretKinds []string
 
 	if expire == "" {
 		expire = defaultExpire
 	}
 
-	contentSize := len([]byte(content))
-	if contentSize == 0 {
-		s.render(w, r, http.StatusBadRequest, "index", s.indexData(syntax, expire, content, "Content cannot be empty"))
+	fail := func(msg string) {
+		data := s.indexData(syntax, expire, content, msg)
+		data.Title = title
+		data.Metadata = metadataText
+		data.Public = public
+		data.NoIndex = noIndex
+		data.License = license
+		data.Attribution = attribution
+		data.SourceURL = sourceURL
+		data.FormToken = formToken
+		data.AcceptTerms = s.termsPending(r)
+		data.Challenge = s.challengeWidget(r)
+		data.Secrets = s.secretPolicy == SecretsWarn && len(secretKinds) > 0
+		data.ConfirmSecrets = confirmSecrets
+		s.setLimitHeaders(w)
+		s.render(w, r, http.StatusBadRequest, "index", data)
+	}
+
+	metadata, err := parseMetadata(metadataText)
+	if err != nil {
+		fail(err.Error())
 		return
 	}
-	if contentSize > s.maxBytes {
-		s.render(w, r, http.StatusBadRequest, "index", s.indexData(syntax, expire, content, fmt.Sprintf("Content exceeds %d byte limit", s.maxBytes)))
+	in := pasteInput{
+		Title:       title,
+		Content:     content,
+		Syntax:      syntax,
+		Expire:      expire,
+		Password:    password,
+		Metadata:    metadata,
+		Public:      public,
+		NoIndex:     noIndex,
+		License:     license,
+		Attribution: attribution,
+		SourceURL:   sourceURL,
+		Encrypted:   encrypted,
+	}
+	if err := s.validatePaste(r.Context(), &in); err != nil {
+		fail(err.Error())
 		return
 	}
+	secretKinds = in.secrets
+	if !s.secretsConfirmed(in, confirmSecrets) {
+		fail(secretsMessage(in.secrets) + ". Remove them, or confirm below that you mean to publish them")
+		return
+	}
+	if !s.acceptTermsFromForm(w, r) {
+		fail("Please accept the terms of service")
+		return
+	}
+
+	// Browser submissions carry a one-time token; a replayed token redirects
+	// to the paste the first submission created.
+	if formToken != "" {
+		existing, err := s.formTokens.claim(formToken, s.nowTime())
+		switch {
+		case errors.Is(err, errFormTokenPending):
+			fail("This form is already being submitted")
+			return
+		case err != nil:
+			formToken, _ = s.formTokens.issue(s.nowTime())
+			fail("The form has expired, please submit again")
+			return
+		case existing != "":
+			http.Redirect(w, r, "/p/"+existing, http.StatusSeeOther)
+			return
+		}
+	}
+	saved := false
+	defer func() {
+		if formToken != "" && !saved {
+			s.formTokens.release(formToken)
+		}
+	}()
 
-	if !isAllowedSyntax(syntax) {
-		s.render(w, r, http.StatusBadRequest, "index", s.indexData(syntax, expire, content, "Unsupported syntax"))
+	// Checked after the form token, so a resubmitted form still finds the
+	// paste its first submission created.
+	if msg, ok := s.passChallengeForm(r); !ok {
+		fail(msg)
 		return
 	}
 
-	duration, ok := expireMap[expire]
-	if !ok {
-		s.render(w, r, http.StatusBadRequest, "index", s.indexData(syntax, expire, content, "Invalid expiration"))
+	if err := s.checkQuota(r.Context(), 1, len(in.Content)); err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			fail("This instance is out of space, please try again later")
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if err := s.checkCreatorQuota(r, 1, len(in.Content)); err != nil {
+		var cqe *creatorQuotaError
+		if errors.As(err, &cqe) {
+			fail(cqe.msg)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	owner, err := s.ensureOwner(w, r)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	paste, err := s.buildPaste(r, in, owner)
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	id := paste.ID
+
+	if err := s.store.Save(r.Context(), paste); err != nil {
+		s.serverError(w, r, err)
 		return
 	}
+	saved = true
+	s.pasteCreated(r.Context(), paste)
+	if formToken != "" {
+		s.formTokens.complete(formToken, id)
+	}
+	if draftToken := r.FormValue("draft_token"); draftToken != "" {
+		s.drafts.remove(draftToken)
+	}
+
+	http.Redirect(w, r, "/p/"+id, http.StatusSeeOther)
+}
+
+// pasteInput is a create request shared by the form and the JSON API.
+type pasteInput struct {
+	Title    string
+	Content  string
+	Syntax   string
+	Expire   string
+	Password string
+	Metadata map[string]string
+	Public   bool
+	NoIndex  bool
+	// License is an SPDX identifier or expression.
+	License     string
+	Attribution string
+	SourceURL   string
+	// Encrypted marks Content as ciphertext sealed in the browser.
+	Encrypted bool
+
+	// verdict is the content filter's, set by validatePaste.
+	verdict filter.Verdict
+	// secrets names the kinds of credential found, set by validatePaste.
+	secrets []string
+}
+
+// inputError is a validation failure whose message is safe to show the client.
+type inputError string
 
+func (e inputError) Error() string { return string(e) }
+
+// validatePaste checks in against the instance limits, filling in the
+// default syntax and expiry. Failures are inputErrors.
+func (s *Server) validatePaste(ctx context.Context, in *pasteInput) error {
+	contentSize := len(in.Content)
+	if contentSize == 0 {
+		return inputError("Content cannot be empty")
+	}
+	if len(in.Title) > maxTitleLen {
+		return inputError(fmt.Sprintf("Title exceeds %d byte limit", maxTitleLen))
+	}
+	if contentSize > s.maxBytes {
+		return inputError(fmt.Sprintf("Content exceeds %d byte limit", s.maxBytes))
+	}
+	if in.Syntax == "" {
+		in.Syntax = s.defaultSyntax
+		if detected := detectSyntax(in.Content); !in.Encrypted && detected != "" && s.syntaxEnabled(detected) {
+			in.Syntax = detected
+		}
+	}
+	if !s.syntaxEnabled(in.Syntax) {
+		return inputError("Unsupported syntax")
+	}
+	if in.Expire == "" {
+		in.Expire = defaultExpire
+	}
+	if _, ok := expireMap[in.Expire]; !ok {
+		return inputError("Invalid expiration")
+	}
+	if in.Public && strings.TrimSpace(in.Password) != "" {
+		return inputError("Password-protected pastes cannot be listed publicly")
+	}
+	if err := validateLicense(in); err != nil {
+		return err
+	}
+	if err := validateSourceURL(in); err != nil {
+		return err
+	}
+	if in.Encrypted {
+		return validateEncrypted(in)
+	}
+	if err := s.screenSecrets(in); err != nil {
+		return err
+	}
+	if err := s.screenPaste(in); err != nil {
+		return err
+	}
+	return s.scanInput(ctx, in)
+}
+
+// buildPaste turns validated input into a new paste owned by owner.
+func (s *Server) buildPaste(r *http.Request, in pasteInput, owner string) (*storage.Paste, error) {
 	hashed := ""
-	if strings.TrimSpace(password) != "" {
+	if strings.TrimSpace(in.Password) != "" {
 		var err error
-		hashed, err = security.HashPassword(password)
+		hashed, err = s.argon.Hash(in.Password)
 		if err != nil {
-			s.serverError(w, r, err)
-			return
+			return nil, err
 		}
 	}
 
 	id, err := s.idGen.Generate(r.Context())
 	if err != nil {
-		s.serverError(w, r, err)
-		return
+		return nil, err
 	}
 
 	now := s.nowTime().UTC()
 	paste := &storage.Paste{
 		ID:           id,
-		Content:      content,
-		Syntax:       syntax,
+		Title:        in.Title,
+		Content:      in.Content,
+		Syntax:       in.Syntax,
 		CreatedAt:    now,
 		PasswordHash: hashed,
-		Size:         contentSize,
+		Size:         len(in.Content),
+		Metadata:     in.Metadata,
+		Public:       in.Public,
+		NoIndex:      in.NoIndex,
+		Owner:        owner,
+		IPHash:       s.ipHash(ClientIP(r, s.proxies)),
+		License:      in.License,
+		Attribution:  in.Attribution,
+		SourceURL:    in.SourceURL,
+		Encrypted:    in.Encrypted,
 	}
-	if duration > 0 {
-		paste.ExpiresAt = now.Add(duration)
+	if d := expireMap[in.Expire]; d > 0 {
+		paste.ExpiresAt = now.Add(d)
 	}
-
-	if err := s.store.Save(r.Context(), paste); err != nil {
-		s.serverError(w, r, err)
-		return
+	applyVerdict(paste, in.verdict)
+	if s.sealProtected && hashed != "" {
+		if err := s.sealContent(paste, in.Password); err != nil {
+			return nil, err
+		}
 	}
-
-	http.Redirect(w, r, "/p/"+id, http.StatusSeeOther)
+	return paste, nil
 }
 
 func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
-	paste, err := s.fetchPaste(r.Context(), chi.URLParam(r, "id"))
+	mode := s.plainMode(r)
+	if len(s.plainAgents) > 0 {
+		w.Header().Add("Vary", "User-Agent")
+	}
+	if mode == plainRaw {
+		s.handleRaw(w, r)
+		return
+	}
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
 	if err != nil {
+		var gone *goneError
+		if errors.As(err, &gone) {
+			s.gonePaste(w, r, gone)
+			return
+		}
 		if errors.Is(err, storage.ErrNotFound) {
-			s.notFound(w, r)
+			s.notFoundPaste(w, r, chi.URLParam(r, "id"))
 			return
 		}
 		s.serverError(w, r, err)
 		return
 	}
 
-	if paste.PasswordHash != "" && !s.hasAuth(r, paste.ID) {
+	if r.URL.Query().Has("sig") && paste.PasswordHash != "" {
+		if err := s.acceptShareLink(w, r, paste); err != nil {
+			s.render(w, r, http.StatusForbidden, "password", passwordPageData{ID: paste.ID, Error: "This share link is invalid or has expired. Enter the password instead."})
+			return
+		}
+		// Drop the signature from the address bar and later Referer headers.
+		http.Redirect(w, r, "/p/"+paste.ID, http.StatusSeeOther)
+		return
+	}
+	if !s.unlocked(r, paste) {
 		s.render(w, r, http.StatusOK, "password", passwordPageData{ID: paste.ID})
 		return
 	}
+	if mode == plainText {
+		s.handlePlainView(w, r, paste)
+		return
+	}
 
 	data := viewPageData{
 		Paste:       paste,
 		SyntaxLabel: syntaxLabel(paste.Syntax),
 		ExpiresIn:   remaining(paste.ExpiresAt, s.nowTime()),
 		Canonical:   s.canonicalURL(r, paste.ID),
+		Metadata:    s.metadataItems(visibleMetadata(paste.Metadata)),
+		RawOnly:     s.rawOnlyBytes > 0 && paste.Size > s.rawOnlyBytes,
+		IsOwner:     paste.Owner != "" && paste.Owner == s.ownerOf(r),
+		LicenseURL:  licenseURL(paste.License),
+		SiteName:    s.brand.name,
+		CanReport:   s.reports != nil,
+	}
+	if paste.Encrypted {
+		data.RawOnly = false
+	} else if !data.RawOnly {
+		data.Table = parseTable(paste.Content, paste.Syntax, r.URL.Query())
+		data.Notebook = parseNotebook(paste.Content, paste.Syntax)
+		data.Diagrams = s.diagramLinks(paste)
+	}
+	if data.IsOwner {
+		data.Links = s.capabilityLinks(r, paste)
+	}
+	if shareable(paste) && s.hasPasswordAuth(r, paste.ID) {
+		for i, c := range shareLinkChoices {
+			data.ShareOptions = append(data.ShareOptions, option{Value: c.Value, Label: c.Label, Selected: i == 1})
+		}
+	}
+	if paste.SourceURL != "" {
+		_, admin := s.adminIdentity(r)
+		data.CanCompare = data.IsOwner || admin
+	}
+	if identity, ok := s.adminIdentity(r); ok && identity != "bearer" {
+		data.AdminCSRF = s.adminCSRF(identity)
+	}
+	// The page itself varies by viewer, so only a weak validator is offered.
+	s.setPasteHeaders(w, paste)
+	w.Header().Set("ETag", "W/"+etagFor(paste.Content))
+	if paste.PasswordHash == "" && !paste.Quarantined && !paste.Encrypted {
+		data.EmbedSnippet = s.embedSnippet(r, paste.ID, embedWidth, embedHeight)
+		data.OEmbedURL = s.absoluteURLQuery(r, "/oembed", url.Values{"url": {data.Canonical}})
+		if !s.disablePreviews {
+			data.Description = previewText(paste.Content)
+		}
 	}
 	s.render(w, r, http.StatusOK, "view", data)
 }
@@ -232,7 +643,7 @@ func (s *Server) handlePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	id := chi.URLParam(r, "id")
-	paste, err := s.fetchPaste(r.Context(), id)
+	paste, err := s.fetchPaste(r, id)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			s.notFound(w, r)
@@ -245,6 +656,12 @@ func (s *Server) handlePassword(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/p/"+id, http.StatusSeeOther)
 		return
 	}
+	ip := s.clientKey(r)
+	if wait := s.passwordGuard.wait(id, ip, s.nowTime()); wait > 0 {
+		setRetryAfter(w, wait)
+		s.render(w, r, http.StatusTooManyRequests, "password", passwordPageData{ID: id, Error: "Too many incorrect passwords. Try again in " + humanWait(wait) + "."})
+		return
+	}
 	password := r.FormValue("password")
 	ok, err := security.VerifyPassword(paste.PasswordHash, password)
 	if err != nil {
@@ -252,17 +669,105 @@ func (s *Server) handlePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !ok {
-		s.render(w, r, http.StatusUnauthorized, "password", passwordPageData{ID: id, Error: "Incorrect password"})
+		msg := "Incorrect password"
+		if wait := s.passwordFailed(r, id, ip); wait > 0 {
+			setRetryAfter(w, wait)
+			msg += ". Try again in " + humanWait(wait) + "."
+		}
+		s.render(w, r, http.StatusUnauthorized, "password", passwordPageData{ID: id, Error: msg})
 		return
 	}
+	s.passwordSucceeded(r, id, ip)
+	s.rehashPassword(r.Context(), paste, password)
 
+	if err := s.rememberSealKey(w, r, paste, password); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
 	s.setAuthCookie(w, r, id, paste.ExpiresAt)
 	http.Redirect(w, r, "/p/"+id, http.StatusSeeOther)
 }
 
+// rehashPassword upgrades a paste whose password hash, or sealed content,
+// was made with weaker Argon2 parameters than the server's, now that the
+// correct password is at hand. Failures are logged and leave the paste as
+// it was.
+func (s *Server) rehashPassword(ctx context.Context, paste *storage.Paste, password string) {
+	if !s.argon.NeedsRehash(paste.PasswordHash) && !(paste.Sealed && s.argon.NeedsRehash(paste.Content)) {
+		return
+	}
+	upgraded := *paste
+	hashed, err := s.argon.Hash(password)
+	if err == nil && paste.Sealed {
+		// Resealing derives a new key, so readers' remembered keys stop
+		// working and they are asked for the password again.
+		err = s.unseal(nil, &upgraded, password)
+		if err == nil {
+			err = s.sealContent(&upgraded, password)
+		}
+	}
+	if err == nil {
+		upgraded.PasswordHash = hashed
+		err = s.store.Save(ctx, &upgraded)
+	}
+	if err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(ctx, "rehash paste password", "id", paste.ID, "error", err)
+		}
+		return
+	}
+	*paste = upgraded
+}
+
+// handleDelete lets the signed-in owner of a paste remove it.
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	paste, err := s.fetchPaste(r, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if paste.Owner == "" || paste.Owner != s.ownerOf(r) {
+		s.render(w, r, http.StatusForbidden, "error", errorPageData{Message: "Forbidden"})
+		return
+	}
+	err = s.deletePaste(r.Context(), id, actorOwner)
+	if errors.Is(err, errLegalHold) {
+		s.render(w, r, http.StatusConflict, "error", errorPageData{Message: "This paste is under legal hold and cannot be deleted"})
+		return
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// streamMinBytes is the size from which raw views stream content from stores
+// that support it. Smaller pastes are read whole so their ETag can hash the
+// content.
+const streamMinBytes = 1 << 20
+
 func (s *Server) handleRaw(w http.ResponseWriter, r *http.Request) {
-	paste, err := s.fetchPaste(r.Context(), chi.URLParam(r, "id"))
+	id := chi.URLParam(r, "id")
+	get := s.store.Get
+	if s.content != nil {
+		get = s.content.GetMeta
+	}
+	paste, err := s.loadPaste(r, id, get)
+	if err == nil && s.content != nil && (paste.Size < streamMinBytes || paste.Sealed) {
+		paste.Content, err = s.readContent(r.Context(), id)
+	}
 	if err != nil {
+		var gone *goneError
+		if errors.As(err, &gone) {
+			s.gonePaste(w, r, gone)
+			return
+		}
 		if errors.Is(err, storage.ErrNotFound) {
 			s.notFound(w, r)
 			return
@@ -271,26 +776,67 @@ func (s *Server) handleRaw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if paste.PasswordHash != "" && !s.hasAuth(r, paste.ID) {
+	if !s.unlocked(r, paste) {
 		s.notFound(w, r)
 		return
 	}
 
-	etag := etagFor(paste.Content)
+	stream := s.content != nil && paste.Size >= streamMinBytes && !paste.Sealed
+	etag, size := etagFor(paste.Content), len(paste.Content)
+	if stream {
+		etag, size = metaETag(paste), paste.Size
+	}
+	s.setPasteHeaders(w, paste)
 	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
 		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
+	body := io.Reader(strings.NewReader(paste.Content))
+	if stream && r.Method != http.MethodHead {
+		rc, err := s.content.GetContent(r.Context(), id)
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		defer rc.Close()
+		body = rc
+	}
+
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(size))
 	w.Header().Set("Cache-Control", "private, max-age=60")
 	w.Header().Set("ETag", etag)
-	_, _ = io.WriteString(w, paste.Content)
+	s.setPasteRobots(w, paste)
+	if r.URL.Query().Get("download") == "1" {
+		filename := fmt.Sprintf("paste-%s.%s", paste.ID, fileExtension(paste.Syntax))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil && s.logger != nil {
+		s.logger.WarnContext(r.Context(), "stream paste", "id", id, "error", err)
+	}
+}
+
+// readContent reads the whole content of a paste from a streaming store.
+func (s *Server) readContent(ctx context.Context, id string) (string, error) {
+	rc, err := s.content.GetContent(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	var b strings.Builder
+	if _, err := io.Copy(&b, rc); err != nil {
+		return "", err
+	}
+	return b.String(), nil
 }
 
 func (s *Server) handleQR(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	paste, err := s.fetchPaste(r.Context(), id)
+	paste, err := s.fetchPaste(r, id)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			s.notFound(w, r)
@@ -311,66 +857,129 @@ func (s *Server) handleQR(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "image/png")
 	w.Header().Set("Cache-Control", "no-store")
+	s.setPasteRobots(w, paste)
 	_, _ = w.Write(png)
 }
 
-func (s *Server) fetchPaste(ctx context.Context, id string) (*storage.Paste, error) {
-	paste, err := s.store.Get(ctx, id)
+// fetchPaste loads a readable paste. Expired pastes are reported as missing,
+// as are quarantined ones unless an admin is asking.
+func (s *Server) fetchPaste(r *http.Request, id string) (*storage.Paste, error) {
+	return s.loadPaste(r, id, s.store.Get)
+}
+
+// loadPaste is fetchPaste reading through get, so callers can load the paste
+// without its content.
+func (s *Server) loadPaste(r *http.Request, id string, get func(context.Context, string) (*storage.Paste, error)) (*storage.Paste, error) {
+	paste, err := get(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		if gone, ok := s.tombstone(r.Context(), id); ok {
+			return nil, gone
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 	if paste == nil {
 		return nil, storage.ErrNotFound
 	}
+	if paste.Deleted() {
+		return nil, s.goneErr(goneDeleted, paste.DeletedAt)
+	}
+	if paste.TakenDown() {
+		if _, ok := s.adminIdentity(r); !ok {
+			return nil, &goneError{reason: goneTakenDown, at: paste.TakenDownAt, notice: paste.TakedownNotice, legal: paste.TakedownLegal}
+		}
+	}
+	if paste.Quarantined {
+		if _, ok := s.adminIdentity(r); !ok {
+			return nil, storage.ErrNotFound
+		}
+	}
 	if paste.ExpiresAt.IsZero() {
 		return paste, nil
 	}
 	if s.nowTime().After(paste.ExpiresAt) {
-		return nil, storage.ErrNotFound
+		return nil, s.goneErr(goneExpired, paste.ExpiresAt)
 	}
 	return paste, nil
 }
 
 func (s *Server) render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
-	title := "Tiny Pastebin"
+	title := s.brand.name
 	if t, ok := data.(titled); ok {
 		if pt := t.PageTitle(); pt != "" {
-			title = pt
+			title = s.brandTitle(pt)
 		}
 	}
+	if e, ok := data.(errorPageData); ok && e.RequestID == "" {
+		e.RequestID = middleware.GetReqID(r.Context())
+		data = e
+	}
 	body := &bytes.Buffer{}
 	bodyTemplate := name + "-body"
 	if err := s.templates.ExecuteTemplate(body, bodyTemplate, data); err != nil {
-		s.handleTemplateError(w, status, bodyTemplate, err)
+		s.handleTemplateError(w, r, status, bodyTemplate, err)
 		return
 	}
 	layoutBuf := &bytes.Buffer{}
+	var (
+		links []headLink
+		meta  []headMeta
+	)
+	if h, ok := data.(headed); ok {
+		links = h.HeadLinks()
+		meta = h.HeadMeta()
+		for i := range links {
+			links[i].Title = s.brandTitle(links[i].Title)
+		}
+	}
+	noIndex := s.pageNoIndex(data)
+	sess, _ := s.currentSession(r)
 	layoutData := struct {
-		Title string
-		Body  template.HTML
+		Title        string
+		Links        []headLink
+		Meta         []headMeta
+		NoIndex      bool
+		LoginEnabled bool
+		User         string
+		HasOwner     bool
+		Announcement *banner
+		Brand        layoutBrand
+		Body         template.HTML
 	}{
-		Title: title,
-		Body:  template.HTML(body.String()),
+		Title:        title,
+		Links:        links,
+		Meta:         meta,
+		NoIndex:      noIndex,
+		LoginEnabled: s.login != nil,
+		User:         sess.Name,
+		HasOwner:     s.ownerOf(r) != "",
+		Announcement: s.banner(r),
+		Brand:        s.layoutBrand(),
+		Body:         template.HTML(body.String()),
 	}
 	if err := s.templates.ExecuteTemplate(layoutBuf, "layout", layoutData); err != nil {
-		s.handleTemplateError(w, status, "layout", err)
+		s.handleTemplateError(w, r, status, "layout", err)
 		return
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if noIndex {
+		w.Header().Set("X-Robots-Tag", robotsNoIndex)
+	}
 	w.WriteHeader(status)
 	_, _ = layoutBuf.WriteTo(w)
 }
 
-func (s *Server) handleTemplateError(w http.ResponseWriter, status int, name string, err error) {
+func (s *Server) handleTemplateError(w http.ResponseWriter, r *http.Request, status int, name string, err error) {
 	if s.logger != nil {
-		s.logger.Error("render template", "error", err, "template", name)
+		s.logger.ErrorContext(r.Context(), "render template", "error", err, "template", name)
 	}
 	http.Error(w, "Template error", status)
 }
 
 func (s *Server) serverError(w http.ResponseWriter, r *http.Request, err error) {
 	if s.logger != nil {
-		s.logger.Error("internal error", "error", err)
+		s.logger.ErrorContext(r.Context(), "internal error", "error", err)
 	}
 	s.render(w, r, http.StatusInternalServerError, "error", errorPageData{Message: "Internal server error"})
 }
@@ -380,17 +989,14 @@ func (s *Server) notFound(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) indexData(selectedSyntax, selectedExpire, content, errMsg string) indexPageData {
-	if selectedSyntax == "" {
-		selectedSyntax = "plaintext"
-	}
-	if !isAllowedSyntax(selectedSyntax) {
-		selectedSyntax = "plaintext"
+	if selectedSyntax == "" || !s.syntaxEnabled(selectedSyntax) {
+		selectedSyntax = s.defaultSyntax
 	}
 	if selectedExpire == "" {
 		selectedExpire = defaultExpire
 	}
-	synOpts := make([]option, 0, len(syntaxWhitelist))
-	for _, v := range syntaxWhitelist {
+	synOpts := make([]option, 0, len(s.syntaxes))
+	for _, v := range s.syntaxes {
 		synOpts = append(synOpts, option{
 			Value:    v,
 			Label:    syntaxLabel(v),
@@ -413,6 +1019,9 @@ func (s *Server) indexData(selectedSyntax, selectedExpire, content, errMsg strin
 		Expire:        selectedExpire,
 		Error:         errMsg,
 		MaxBytes:      s.maxBytes,
+		ShowNoIndex:   !s.noIndexPastes,
+		Licenses:      commonLicenses,
+		Honeypot:      s.botCheck != nil,
 	}
 }
 
@@ -421,6 +1030,49 @@ func isAllowedSyntax(v string) bool {
 	return ok
 }
 
+// resolveSyntaxes applies the operator's default and hidden syntaxes to the
+// built-in whitelist, returning the enabled syntaxes in display order.
+func resolveSyntaxes(defaultSyntax string, hidden []string) ([]string, string, error) {
+	hiddenSet := make(map[string]bool, len(hidden))
+	for _, h := range hidden {
+		if !isAllowedSyntax(h) {
+			return nil, "", fmt.Errorf("unknown hidden syntax %q", h)
+		}
+		hiddenSet[h] = true
+	}
+	enabled := make([]string, 0, len(syntaxWhitelist))
+	for _, v := range syntaxWhitelist {
+		if !hiddenSet[v] {
+			enabled = append(enabled, v)
+		}
+	}
+	if len(enabled) == 0 {
+		return nil, "", errors.New("at least one syntax must remain enabled")
+	}
+	if defaultSyntax == "" {
+		defaultSyntax = "plaintext"
+		if hiddenSet[defaultSyntax] {
+			defaultSyntax = enabled[0]
+		}
+	}
+	if !isAllowedSyntax(defaultSyntax) {
+		return nil, "", fmt.Errorf("unknown default syntax %q", defaultSyntax)
+	}
+	if hiddenSet[defaultSyntax] {
+		return nil, "", fmt.Errorf("default syntax %q is hidden", defaultSyntax)
+	}
+	return enabled, defaultSyntax, nil
+}
+
+func (s *Server) syntaxEnabled(v string) bool {
+	for _, enabled := range s.syntaxes {
+		if enabled == v {
+			return true
+		}
+	}
+	return false
+}
+
 func syntaxLabel(v string) string {
 	if label, ok := syntaxLabels[v]; ok {
 		return label
@@ -431,6 +1083,37 @@ func syntaxLabel(v string) string {
 	return strings.ToUpper(v[:1]) + v[1:]
 }
 
+// previewText condenses the first lines of content into a single-line,
+// control-character-free snippet suitable for link previews.
+func previewText(content string) string {
+	const (
+		maxLines = 3
+		maxRunes = 200
+	)
+	lines := strings.SplitN(content, "\n", maxLines+1)
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+	}
+	text := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return ' '
+		}
+		return r
+	}, strings.Join(lines, " "))
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxRunes {
+		text = strings.TrimSpace(string(runes[:maxRunes-1])) + "…"
+	}
+	return text
+}
+
+func fileExtension(syntax string) string {
+	if ext, ok := syntaxExtensions[syntax]; ok {
+		return ext
+	}
+	return "txt"
+}
+
 func remaining(expires time.Time, now time.Time) string {
 	if expires.IsZero() {
 		return "Never"
@@ -475,7 +1158,24 @@ func plural(count int, singular string) string {
 	return fmt.Sprintf("%d %ss", count, singular)
 }
 
+// setPasteHeaders describes a paste in response headers so HEAD requests can
+// check it without fetching the body.
+func (s *Server) setPasteHeaders(w http.ResponseWriter, paste *storage.Paste) {
+	w.Header().Set("Last-Modified", paste.CreatedAt.UTC().Format(http.TimeFormat))
+	if paste.HasExpiration() {
+		w.Header().Set(pasteExpiresHeader, paste.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	setLicenseHeaders(w, paste)
+}
+
 func etagFor(content string) string {
 	sum := sha256.Sum256([]byte(content))
 	return `"` + hex.EncodeToString(sum[:]) + `"`
 }
+
+// metaETag is a weak validator for streamed pastes, whose content is not read
+// before the headers are sent. It changes whenever the paste is recreated or
+// its content grows or shrinks.
+func metaETag(p *storage.Paste) string {
+	return fmt.Sprintf(`W/"%x-%x"`, p.CreatedAt.UnixNano(), p.Size)
+}
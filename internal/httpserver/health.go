@@ -0,0 +1,45 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readyTimeout bounds the store check behind /readyz, so a hung backend
+// fails the probe instead of stalling it.
+const readyTimeout = 2 * time.Second
+
+type readyResponse struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// handleReady pings the store and reports how long it took. A failed or
+// timed-out ping answers 503 with status "degraded", so orchestrators stop
+// routing to an instance whose data file or backend is broken; /healthz
+// keeps answering, so the process is not restarted for it.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	start := time.Now()
+	err := s.store.Ping(ctx)
+	resp := readyResponse{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	status := http.StatusOK
+	if err != nil {
+		resp.Status = "degraded"
+		resp.Error = err.Error()
+		status = http.StatusServiceUnavailable
+		if s.logger != nil {
+			s.logger.WarnContext(r.Context(), "readiness check failed", "error", err, "latency_ms", resp.LatencyMS)
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, resp)
+}
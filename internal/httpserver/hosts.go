@@ -0,0 +1,32 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HostRouter serves several sites from one listener, picking the handler by
+// the request's Host with any port and trailing dot ignored. Hosts without a
+// site of their own, and requests without a Host, go to fallback.
+func HostRouter(fallback http.Handler, sites map[string]http.Handler) http.Handler {
+	byHost := make(map[string]http.Handler, len(sites))
+	for host, h := range sites {
+		byHost[hostKey(host)] = h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := byHost[hostKey(r.Host)]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+// hostKey normalizes a Host header or site name for lookup.
+func hostKey(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,173 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// idempotencyHeader names the client's key for a create request, as in
+	// the IETF Idempotency-Key draft.
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader marks responses replayed for a retry.
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// idempotencyTTL is how long a key is remembered after its request
+	// succeeded.
+	idempotencyTTL = 24 * time.Hour
+	// maxIdempotencyKeyLen bounds the keys clients may send.
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotencyKeys remembers the responses to successful create requests by
+// client and Idempotency-Key, so a client retrying after a dropped response
+// gets the original paste back instead of a duplicate. Keys are held in
+// memory: a restart forgets them, and instances behind a load balancer do
+// not share them.
+type idempotencyKeys struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentResponse
+}
+
+// idempotentResponse is the outcome of the request sent with a key. Until
+// the request completes, done is false and retries are turned away.
+type idempotentResponse struct {
+	request [sha256.Size]byte
+	done    bool
+	status  int
+	header  http.Header
+	body    []byte
+	at      time.Time
+}
+
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{entries: make(map[string]*idempotentResponse)}
+}
+
+// begin claims key for a request with the given fingerprint. It returns the
+// response to replay when the request already succeeded, or the status to
+// refuse it with when the key is busy or was used for another request; with
+// neither the caller holds the key until it calls finish.
+func (k *idempotencyKeys) begin(key string, request [sha256.Size]byte, now time.Time) (replay *idempotentResponse, refuse int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	e, found := k.entries[key]
+	switch {
+	case !found || (e.done && !now.Before(e.at.Add(idempotencyTTL))):
+		k.entries[key] = &idempotentResponse{request: request, at: now}
+		return nil, 0
+	case e.request != request:
+		return nil, http.StatusUnprocessableEntity
+	case !e.done:
+		return nil, http.StatusConflict
+	}
+	return e, 0
+}
+
+// finish records the response to the request holding key. Failed requests
+// release the key, so the client can retry them.
+func (k *idempotencyKeys) finish(key string, rec *responseCapture, now time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if rec.status < 200 || rec.status >= 300 {
+		delete(k.entries, key)
+		return
+	}
+	e := k.entries[key]
+	e.done, e.status, e.body, e.at = true, rec.status, rec.body.Bytes(), now
+	e.header = make(http.Header)
+	for _, name := range []string{"Content-Type", "Location"} {
+		if v := rec.Header().Get(name); v != "" {
+			e.header.Set(name, v)
+		}
+	}
+}
+
+// prune forgets keys older than idempotencyTTL and reports how many.
+func (k *idempotencyKeys) prune(now time.Time) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	n := 0
+	for key, e := range k.entries {
+		if e.done && !now.Before(e.at.Add(idempotencyTTL)) {
+			delete(k.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// responseCapture copies what a handler writes so it can be replayed.
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+// idempotent lets clients retry next safely by sending an Idempotency-Key
+// header. Keys are scoped to the API key or signed-in user, or else to the
+// client address, and a key reused with a different body is refused. Bodies
+// are read in full up to limit bytes to fingerprint them.
+func (s *Server) idempotent(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: idempotencyHeader + " must be at most 255 bytes"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: "request body too large"})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := s.apiOwner(r)
+		if scope == "" {
+			scope = "ip:" + s.clientKey(r)
+		}
+		key = scope + "\x00" + r.URL.Path + "\x00" + key
+		replay, refuse := s.idempotencyKeys.begin(key, sha256.Sum256(body), s.nowTime())
+		switch {
+		case refuse == http.StatusUnprocessableEntity:
+			writeJSON(w, refuse, apiError{Error: idempotencyHeader + " was already used for a different request"})
+			return
+		case refuse == http.StatusConflict:
+			writeJSON(w, refuse, apiError{Error: "a request with this " + idempotencyHeader + " is still in progress"})
+			return
+		case replay != nil:
+			for name, values := range replay.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set(idempotencyReplayedHeader, "true")
+			w.WriteHeader(replay.status)
+			_, _ = w.Write(replay.body)
+			return
+		}
+		rec := &responseCapture{ResponseWriter: w}
+		defer func() { s.idempotencyKeys.finish(key, rec, s.nowTime()) }()
+		next(rec, r)
+	}
+}
@@ -0,0 +1,213 @@
+package httpserver
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const ingestOwnerScope = "ingest:"
+
+// IngestEndpoint turns deliveries to POST /ingest/{Key} into pastes, so
+// alerting and CI systems can drop their output into the pastebin.
+type IngestEndpoint struct {
+	// Name labels the endpoint; pastes record it in the "ingest" metadata
+	// key and are owned by "ingest:<name>".
+	Name string
+	// Key is the secret path segment senders are given.
+	Key string
+	// Title is a text/template executed with the delivery: .Name, .Time,
+	// .Header, .Text (the raw body) and .JSON (the decoded body, if any),
+	// e.g. `{{.JSON.status}}: {{.JSON.commonLabels.alertname}}`.
+	Title string
+	// Syntax defaults to json for JSON bodies and the default syntax otherwise.
+	Syntax string
+	// Expire is one of the expiry options; empty uses the default.
+	Expire string
+	// Format selects how the body is rendered. Empty stores it as sent
+	// (JSON is pretty-printed); IngestFormatAlertmanager renders a report.
+	Format string
+}
+
+type ingestEndpoint struct {
+	IngestEndpoint
+	title *template.Template
+}
+
+// ingestDelivery is the data available to title templates.
+type ingestDelivery struct {
+	Name   string
+	Time   time.Time
+	Header http.Header
+	Text   string
+	JSON   any
+	// Rendered is the title produced by the endpoint's format, if any.
+	Rendered string
+}
+
+func parseIngestEndpoints(in []IngestEndpoint, syntaxes []string) ([]ingestEndpoint, error) {
+	out := make([]ingestEndpoint, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for _, e := range in {
+		if e.Name == "" || e.Key == "" {
+			return nil, errors.New("ingest endpoints need a name and a key")
+		}
+		if seen[e.Key] {
+			return nil, fmt.Errorf("ingest endpoint %q reuses another endpoint's key", e.Name)
+		}
+		seen[e.Key] = true
+		if len(e.Name) > maxMetadataValueLen {
+			return nil, fmt.Errorf("ingest endpoint name %q exceeds %d bytes", e.Name, maxMetadataValueLen)
+		}
+		if e.Syntax != "" && !slices.Contains(syntaxes, e.Syntax) {
+			return nil, fmt.Errorf("ingest endpoint %q: unsupported syntax %q", e.Name, e.Syntax)
+		}
+		if _, ok := expireMap[e.Expire]; e.Expire != "" && !ok {
+			return nil, fmt.Errorf("ingest endpoint %q: invalid expiration %q", e.Name, e.Expire)
+		}
+		if e.Format != "" && e.Format != IngestFormatAlertmanager {
+			return nil, fmt.Errorf("ingest endpoint %q: unknown format %q", e.Name, e.Format)
+		}
+		title := e.Title
+		if title == "" && e.Format == IngestFormatAlertmanager {
+			title = "{{.Rendered}}"
+		}
+		if title == "" {
+			title = "{{.Name}}"
+		}
+		tmpl, err := template.New(e.Name).Parse(title)
+		if err != nil {
+			return nil, fmt.Errorf("ingest endpoint %q title: %w", e.Name, err)
+		}
+		out = append(out, ingestEndpoint{IngestEndpoint: e, title: tmpl})
+	}
+	return out, nil
+}
+
+// ingestEndpointFor finds the endpoint for key, comparing every key in
+// constant time so lookups do not leak how much of a key matched.
+func (s *Server) ingestEndpointFor(key string) *ingestEndpoint {
+	var found *ingestEndpoint
+	for i := range s.ingest {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(s.ingest[i].Key)) == 1 {
+			found = &s.ingest[i]
+		}
+	}
+	return found
+}
+
+func (s *Server) handleIngest(w http.ResponseWriter, r *http.Request) {
+	endpoint := s.ingestEndpointFor(chi.URLParam(r, "key"))
+	if endpoint == nil {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "unknown ingest endpoint"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(s.maxBytes)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: fmt.Sprintf("Content exceeds %d byte limit", s.maxBytes)})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "unable to read body"})
+		return
+	}
+
+	delivery := ingestDelivery{Name: endpoint.Name, Time: s.nowTime(), Header: r.Header, Text: string(body)}
+	content := string(body)
+	syntax := endpoint.Syntax
+	if isJSONBody(r, body) {
+		_ = json.Unmarshal(body, &delivery.JSON)
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") == nil && pretty.Len() <= s.maxBytes {
+			content = pretty.String()
+		}
+		if syntax == "" && s.syntaxEnabled("json") {
+			syntax = "json"
+		}
+	}
+	if endpoint.Format == IngestFormatAlertmanager {
+		title, report, err := renderAlertmanager(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		delivery.Rendered, content = title, report
+		syntax = endpoint.Syntax
+		if syntax == "" && s.syntaxEnabled("markdown") {
+			syntax = "markdown"
+		}
+	}
+
+	in := pasteInput{
+		Title:    s.ingestTitle(r, endpoint, delivery),
+		Content:  content,
+		Syntax:   syntax,
+		Expire:   endpoint.Expire,
+		Metadata: map[string]string{"ingest": endpoint.Name},
+	}
+	if err := s.validatePaste(r.Context(), &in); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if !s.quotaAllowsAPI(w, r, 1, len(in.Content), false) {
+		return
+	}
+	paste, err := s.buildPaste(r, in, ingestOwnerScope+endpoint.Name)
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if err := s.store.Save(r.Context(), paste); err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	s.pasteCreated(r.Context(), paste)
+
+	sum := s.summarize(r, paste)
+	w.Header().Set("Location", sum.URL)
+	writeJSON(w, http.StatusCreated, sum)
+}
+
+// ingestTitle renders the endpoint's title template. Missing fields render
+// empty and a failing template falls back to the endpoint name, so a
+// surprising payload never loses the delivery.
+func (s *Server) ingestTitle(r *http.Request, endpoint *ingestEndpoint, d ingestDelivery) string {
+	var buf strings.Builder
+	if err := endpoint.title.Execute(&buf, d); err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(r.Context(), "ingest title template failed", "endpoint", endpoint.Name, "error", err)
+		}
+		return endpoint.Name
+	}
+	title := strings.Join(strings.Fields(strings.ReplaceAll(buf.String(), "<no value>", "")), " ")
+	for len(title) > maxTitleLen {
+		_, size := utf8.DecodeLastRuneInString(title)
+		title = title[:len(title)-size]
+	}
+	if title == "" {
+		return endpoint.Name
+	}
+	return title
+}
+
+func isJSONBody(r *http.Request, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return false
+	}
+	return json.Valid(body)
+}
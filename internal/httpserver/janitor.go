@@ -6,38 +6,176 @@ import (
 	"time"
 
 	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/tracing"
 )
 
-// StartJanitor launches a background janitor that deletes expired pastes.
-func StartJanitor(ctx context.Context, store storage.Store, interval time.Duration, logger *slog.Logger) {
+// JanitorTask purges one category of stale data during a janitor sweep.
+type JanitorTask struct {
+	Name string
+	Run  func(ctx context.Context, now time.Time) (int, error)
+}
+
+// JanitorReport records how many items each task removed during one sweep.
+type JanitorReport map[string]int
+
+// ExpiredPastesTask deletes pastes whose expiry has passed.
+func ExpiredPastesTask(store storage.Store) JanitorTask {
+	return JanitorTask{
+		Name: "expired_pastes",
+		Run: func(ctx context.Context, now time.Time) (int, error) {
+			return store.DeleteExpired(ctx, now)
+		},
+	}
+}
+
+// JanitorTasks returns the cleanup tasks for state derived by the server.
+func (s *Server) JanitorTasks() []JanitorTask {
+	tasks := []JanitorTask{{
+		Name: "form_tokens",
+		Run: func(_ context.Context, now time.Time) (int, error) {
+			return s.formTokens.prune(now), nil
+		},
+	}, {
+		Name: "drafts",
+		Run: func(_ context.Context, now time.Time) (int, error) {
+			return s.drafts.prune(now), nil
+		},
+	}, {
+		Name: "password_attempts",
+		Run: func(_ context.Context, now time.Time) (int, error) {
+			return s.passwordGuard.prune(now), nil
+		},
+	}, {
+		Name: "idempotency_keys",
+		Run: func(_ context.Context, now time.Time) (int, error) {
+			return s.idempotencyKeys.prune(now), nil
+		},
+	}, {
+		Name: "syntax_stats",
+		Run: func(_ context.Context, now time.Time) (int, error) {
+			return s.syntaxStats.prune(now), nil
+		},
+	}}
+	if s.quota.enabled() && s.quota.Policy == QuotaEvict {
+		tasks = append(tasks, JanitorTask{
+			Name: "quota_eviction",
+			Run: func(ctx context.Context, _ time.Time) (int, error) {
+				return s.evictForQuota(ctx)
+			},
+		})
+	}
+	if s.tombstones != nil {
+		tasks = append(tasks, JanitorTask{
+			Name: "tombstones",
+			Run: func(ctx context.Context, now time.Time) (int, error) {
+				if err := s.tombstones.load(ctx, s.store, now); err != nil {
+					return 0, err
+				}
+				return s.tombstones.prune(now), nil
+			},
+		})
+	}
+	if s.deleteGrace > 0 {
+		tasks = append(tasks, JanitorTask{Name: "deleted_pastes", Run: s.purgeDeleted})
+	}
+	if s.audit != nil && s.auditRetention > 0 {
+		tasks = append(tasks, JanitorTask{
+			Name: "audit_log",
+			Run: func(ctx context.Context, now time.Time) (int, error) {
+				return s.audit.PruneAudit(ctx, now.Add(-s.auditRetention))
+			},
+		})
+	}
+	if s.limiter != nil {
+		tasks = append(tasks, JanitorTask{
+			Name: "rate_limit_entries",
+			Run: func(_ context.Context, now time.Time) (int, error) {
+				return s.limiter.Prune(now), nil
+			},
+		})
+	}
+	if s.publicLimiter != nil {
+		tasks = append(tasks, JanitorTask{
+			Name: "public_rate_limit_entries",
+			Run: func(_ context.Context, now time.Time) (int, error) {
+				return s.publicLimiter.Prune(now), nil
+			},
+		})
+	}
+	if s.geo != nil && s.geo.StrictLimiter != nil {
+		tasks = append(tasks, JanitorTask{
+			Name: "geo_rate_limit_entries",
+			Run: func(_ context.Context, now time.Time) (int, error) {
+				return s.geo.StrictLimiter.Prune(now), nil
+			},
+		})
+	}
+	return tasks
+}
+
+// StartJanitor launches a background janitor that deletes expired pastes and
+// runs any additional cleanup tasks in the same sweep. It stops once ctx is
+// done, closing the returned channel when a sweep in progress has finished.
+// Each task is traced when ctx carries a tracer.
+func StartJanitor(ctx context.Context, store storage.Store, interval time.Duration, logger *slog.Logger, tasks ...JanitorTask) <-chan struct{} {
 	if interval <= 0 {
 		interval = time.Minute
 	}
+	tasks = append([]JanitorTask{ExpiredPastesTask(store)}, tasks...)
 	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				cleanOnce(ctx, store, logger)
+				cleanOnce(ctx, tasks, logger)
 			}
 		}
 	}()
+	return done
 }
 
-func cleanOnce(ctx context.Context, store storage.Store, logger *slog.Logger) {
-	c, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-	removed, err := store.DeleteExpired(c, time.Now())
-	if err != nil {
-		if logger != nil {
-			logger.Error("janitor error", "error", err)
+func cleanOnce(ctx context.Context, tasks []JanitorTask, logger *slog.Logger) JanitorReport {
+	report := make(JanitorReport, len(tasks))
+	now := time.Now()
+	ctx, sweep := tracing.Start(ctx, tracing.KindInternal, "janitor.sweep")
+	defer sweep.End()
+	for _, task := range tasks {
+		c, cancel := context.WithTimeout(ctx, 5*time.Second)
+		c, span := tracing.Start(c, tracing.KindInternal, "janitor."+task.Name)
+		removed, err := task.Run(c, now)
+		span.SetAttr("removed", removed)
+		span.Fail(err)
+		span.End()
+		cancel()
+		if err != nil {
+			if logger != nil {
+				logger.Error("janitor error", "task", task.Name, "removed", removed, "error", err)
+			}
+			continue
 		}
-		return
+		report[task.Name] = removed
 	}
-	if removed > 0 && logger != nil {
-		logger.Info("janitor removed expired pastes", "count", removed)
+	if logger != nil && report.total() > 0 {
+		attrs := make([]any, 0, 2*len(report))
+		for _, task := range tasks {
+			if n, ok := report[task.Name]; ok {
+				attrs = append(attrs, task.Name, n)
+			}
+		}
+		logger.Info("janitor sweep", attrs...)
+	}
+	return report
+}
+
+func (r JanitorReport) total() int {
+	total := 0
+	for _, n := range r {
+		total += n
 	}
+	return total
 }
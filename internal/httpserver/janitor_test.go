@@ -0,0 +1,82 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"tiny-pastebin/internal/storage"
+)
+
+func TestCleanOnceReportsPerTask(t *testing.T) {
+	store := newMemoryStore()
+	now := time.Now().UTC()
+	_ = store.Save(context.Background(), &storage.Paste{ID: "gone", Content: "x", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)})
+	_ = store.Save(context.Background(), &storage.Paste{ID: "kept", Content: "y", CreatedAt: now})
+
+	limiter := NewRateLimiter(rate.Limit(1), 1, time.Nanosecond)
+	limiter.Allow("1.2.3.4")
+	time.Sleep(time.Millisecond)
+
+	srv, err := New(Config{Store: store, RateLimiter: limiter})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	tasks := append([]JanitorTask{ExpiredPastesTask(store)}, srv.JanitorTasks()...)
+	tasks = append(tasks, JanitorTask{Name: "broken", Run: func(context.Context, time.Time) (int, error) {
+		return 0, errors.New("boom")
+	}})
+
+	report := cleanOnce(context.Background(), tasks, nil)
+	if report["expired_pastes"] != 1 {
+		t.Fatalf("expected 1 expired paste removed, got %d", report["expired_pastes"])
+	}
+	if report["rate_limit_entries"] != 1 {
+		t.Fatalf("expected 1 limiter entry pruned, got %d", report["rate_limit_entries"])
+	}
+	if _, ok := report["broken"]; ok {
+		t.Fatalf("failed task should not be reported")
+	}
+	if _, err := store.Get(context.Background(), "kept"); err != nil {
+		t.Fatalf("unexpired paste removed: %v", err)
+	}
+}
+
+func TestQuotaEvictionRemovesOldestExpiringPastes(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i, id := range []string{"oldest", "older", "newer", "newest"} {
+		_ = store.Save(ctx, &storage.Paste{ID: id, Content: "0123456789", CreatedAt: now.Add(time.Duration(i) * time.Minute), ExpiresAt: now.Add(time.Hour)})
+	}
+	_ = store.Save(ctx, &storage.Paste{ID: "permanent", Content: "0123456789", CreatedAt: now.Add(-time.Hour)})
+
+	srv, err := New(Config{Store: store, Quota: Quota{MaxBytes: 30}})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	report := cleanOnce(ctx, srv.JanitorTasks(), nil)
+	if report["quota_eviction"] != 2 {
+		t.Fatalf("expected 2 pastes evicted, got %d", report["quota_eviction"])
+	}
+	for _, id := range []string{"oldest", "older"} {
+		if _, err := store.Get(ctx, id); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %s evicted, got %v", id, err)
+		}
+	}
+	for _, id := range []string{"newer", "newest", "permanent"} {
+		if _, err := store.Get(ctx, id); err != nil {
+			t.Fatalf("expected %s kept, got %v", id, err)
+		}
+	}
+	if report := cleanOnce(ctx, srv.JanitorTasks(), nil); report["quota_eviction"] != 0 {
+		t.Fatalf("expected nothing evicted within quota, got %d", report["quota_eviction"])
+	}
+
+	if _, err := New(Config{Store: store, Quota: Quota{MaxPastes: 1, Policy: "drop"}}); err == nil {
+		t.Fatalf("expected unknown policy rejected")
+	}
+}
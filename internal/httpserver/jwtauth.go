@@ -0,0 +1,117 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"tiny-pastebin/internal/apikey"
+	"tiny-pastebin/internal/jwt"
+)
+
+// JWTAuth lets API clients authenticate with JSON Web Tokens issued by an
+// existing SSO provider or API gateway, as an alternative to API keys. A
+// token's owner claim becomes the owner of the pastes it creates, and its
+// scope claim grants the API key scopes it names.
+type JWTAuth struct {
+	// Verifier checks signatures, expiry, issuer and audience.
+	Verifier *jwt.Verifier
+	// OwnerClaim names the claim identifying the caller. Defaults to "sub".
+	OwnerClaim string
+	// OwnerPrefix is put before the claim to form the owner identity, so
+	// token owners never collide with other sign-ins. Defaults to "jwt:".
+	OwnerPrefix string
+	// ScopeClaim names the claim listing granted scopes, as a list or a
+	// space-separated string. Defaults to "scope".
+	ScopeClaim string
+	// DefaultScopes are granted to tokens without the scope claim.
+	DefaultScopes []string
+}
+
+// tokenCaller is an API client authenticated with a JWT.
+type tokenCaller struct {
+	Owner  string
+	Scopes []string
+}
+
+type tokenCallerContextKey struct{}
+
+// requestToken returns the JWT caller the request authenticated as, if any.
+func requestToken(r *http.Request) *tokenCaller {
+	c, _ := r.Context().Value(tokenCallerContextKey{}).(*tokenCaller)
+	return c
+}
+
+// apiCaller reports whether r authenticated with an API key or a JWT.
+func apiCaller(r *http.Request) bool {
+	return requestAPIKey(r) != nil || requestToken(r) != nil
+}
+
+// caller maps verified claims to the caller they identify.
+func (a *JWTAuth) caller(claims jwt.Claims) (*tokenCaller, error) {
+	ownerClaim := a.OwnerClaim
+	if ownerClaim == "" {
+		ownerClaim = "sub"
+	}
+	subject := claims.String(ownerClaim)
+	if subject == "" {
+		return nil, errors.New("token has no " + ownerClaim + " claim")
+	}
+	prefix := a.OwnerPrefix
+	if prefix == "" {
+		prefix = "jwt:"
+	}
+	scopeClaim := a.ScopeClaim
+	if scopeClaim == "" {
+		scopeClaim = "scope"
+	}
+	var granted []string
+	if _, ok := claims[scopeClaim]; ok {
+		for _, v := range claims.Strings(scopeClaim) {
+			granted = append(granted, strings.Fields(v)...)
+		}
+	} else {
+		granted = a.DefaultScopes
+	}
+	c := &tokenCaller{Owner: prefix + subject}
+	for _, scope := range granted {
+		if slices.Contains(apikey.Scopes, scope) && !slices.Contains(c.Scopes, scope) {
+			c.Scopes = append(c.Scopes, scope)
+		}
+	}
+	return c, nil
+}
+
+// authenticateJWT verifies JWT bearer tokens sent to the API. Bearer
+// values that are API keys or the admin token are left to their own
+// checks.
+func (s *Server) authenticateJWT(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(r.URL.Path, "/api/") || apikey.IsToken(token) || strings.Count(token, ".") != 2 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		claims, err := s.jwt.Verifier.Verify(r.Context(), token)
+		var c *tokenCaller
+		if err == nil {
+			c, err = s.jwt.caller(claims)
+		}
+		if err != nil {
+			if s.logger != nil && !errors.Is(err, jwt.ErrInvalidToken) && !errors.Is(err, jwt.ErrExpired) {
+				s.logger.WarnContext(r.Context(), "verify bearer token", "error", err)
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="tinypaste", error="invalid_token"`)
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "invalid bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenCallerContextKey{}, c)))
+	})
+}
@@ -0,0 +1,98 @@
+package httpserver
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	maxLicenseLen     = 64
+	maxAttributionLen = 200
+)
+
+// commonLicenses are suggested on the create form; any SPDX identifier or
+// expression is accepted.
+var commonLicenses = []string{
+	"MIT", "Apache-2.0", "BSD-2-Clause", "BSD-3-Clause", "0BSD", "ISC",
+	"MPL-2.0", "GPL-2.0-only", "GPL-3.0-or-later", "LGPL-3.0-or-later",
+	"AGPL-3.0-or-later", "Unlicense", "CC0-1.0", "CC-BY-4.0", "CC-BY-SA-4.0",
+}
+
+var spdxID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.\-]*\+?$`)
+
+// validLicense accepts an SPDX license identifier or a simple expression
+// joining identifiers with AND, OR, WITH and parentheses. Identifiers are
+// checked for shape only, so LicenseRef- ids and new licenses pass.
+func validLicense(expr string) bool {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	depth := 0
+	wantID := true
+	for _, tok := range strings.Fields(expr) {
+		switch {
+		case tok == "(":
+			if !wantID {
+				return false
+			}
+			depth++
+		case tok == ")":
+			if wantID || depth == 0 {
+				return false
+			}
+			depth--
+		case tok == "AND" || tok == "OR" || tok == "WITH":
+			if wantID {
+				return false
+			}
+			wantID = true
+		default:
+			if !wantID || !spdxID.MatchString(tok) {
+				return false
+			}
+			wantID = false
+		}
+	}
+	return !wantID && depth == 0
+}
+
+// validateLicense normalizes and checks the license fields of in.
+func validateLicense(in *pasteInput) error {
+	in.License = strings.Join(strings.Fields(in.License), " ")
+	in.Attribution = strings.TrimSpace(in.Attribution)
+	if in.License != "" && (len(in.License) > maxLicenseLen || !validLicense(in.License)) {
+		return inputError("License must be an SPDX identifier such as MIT or Apache-2.0")
+	}
+	if len(in.Attribution) > maxAttributionLen {
+		return inputError("Attribution is too long")
+	}
+	if strings.IndexFunc(in.Attribution, unicode.IsControl) >= 0 {
+		return inputError("Attribution must be a single line")
+	}
+	return nil
+}
+
+// licenseURL links a single SPDX identifier to its page on spdx.org; it is
+// empty for expressions and LicenseRef- ids, which have no such page.
+func licenseURL(license string) string {
+	if license == "" || strings.Contains(license, " ") || strings.HasPrefix(license, "LicenseRef-") {
+		return ""
+	}
+	return "https://spdx.org/licenses/" + strings.TrimSuffix(license, "+") + ".html"
+}
+
+// setLicenseHeaders carries a paste's license and attribution on raw
+// responses, so they travel with downloads.
+func setLicenseHeaders(w http.ResponseWriter, p *storage.Paste) {
+	if p.License != "" {
+		w.Header().Set("X-Paste-License", p.License)
+		if u := licenseURL(p.License); u != "" {
+			w.Header().Add("Link", "<"+u+`>; rel="license"`)
+		}
+	}
+	if p.Attribution != "" {
+		w.Header().Set("X-Paste-Attribution", p.Attribution)
+	}
+}
@@ -0,0 +1,240 @@
+package httpserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const captureOwnerScope = "capture:"
+
+// LogCapture configures a listener that collects syslog messages or raw
+// UDP lines into rolling pastes, for grabbing device logs without a shell.
+type LogCapture struct {
+	// Name labels the capture; pastes record it in the "capture" metadata
+	// key. Defaults to "syslog".
+	Name string
+	// MaxBytes caps each paste; once full a new paste is started. Defaults to
+	// and may not exceed the server's paste size limit.
+	MaxBytes int
+	// Window caps how long one paste collects lines. Defaults to an hour.
+	Window time.Duration
+	// FlushInterval is how often buffered lines are written. Defaults to 5s.
+	FlushInterval time.Duration
+	// Expire is one of the expiry options; empty uses the default.
+	Expire string
+}
+
+// syslogSeverities names the severity encoded in the low bits of a PRI.
+var syslogSeverities = [8]string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+type logCapture struct {
+	s       *Server
+	cfg     LogCapture
+	expire  time.Duration
+	pending []string
+	current *storage.Paste
+	// saved mirrors what the store holds for current, so the syntax stats
+	// can be corrected as the paste grows.
+	saved *storage.Paste
+}
+
+// StartLogCapture reads datagrams from conn until ctx is done, appending
+// each line to the current capture paste. Buffered lines are flushed before
+// Wait returns.
+func (s *Server) StartLogCapture(ctx context.Context, conn net.PacketConn, cfg LogCapture) error {
+	if cfg.Name == "" {
+		cfg.Name = "syslog"
+	}
+	if cfg.MaxBytes <= 0 || cfg.MaxBytes > s.maxBytes {
+		cfg.MaxBytes = s.maxBytes
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Hour
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.Expire == "" {
+		cfg.Expire = defaultExpire
+	}
+	expire, ok := expireMap[cfg.Expire]
+	if !ok {
+		return fmt.Errorf("log capture: invalid expiration %q", cfg.Expire)
+	}
+	c := &logCapture{s: s, cfg: cfg, expire: expire}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		c.run(ctx, conn)
+	}()
+	return nil
+}
+
+func (c *logCapture) run(ctx context.Context, conn net.PacketConn) {
+	lines := make(chan string, 1024)
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	go func() {
+		defer close(lines)
+		buf := make([]byte, 64*1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, raw := range bytes.Split(buf[:n], []byte("\n")) {
+				if len(bytes.TrimSpace(raw)) == 0 {
+					continue
+				}
+				select {
+				case lines <- c.formatLine(c.s.nowTime(), addr, string(raw)):
+				default:
+					// Never block the socket on a slow store; a burst that
+					// outruns the buffer loses lines rather than datagrams.
+				}
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(c.cfg.FlushInterval)
+	defer ticker.Stop()
+	buffered := 0
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				ctx := context.WithoutCancel(ctx)
+				c.flush(ctx)
+				c.finish(ctx)
+				return
+			}
+			c.pending = append(c.pending, line)
+			buffered += len(line)
+			if buffered >= c.cfg.MaxBytes {
+				c.flush(ctx)
+				buffered = 0
+			}
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				// Shutting down: the final flush happens once the reader stops.
+				continue
+			}
+			c.flush(ctx)
+			buffered = 0
+		}
+	}
+}
+
+// formatLine stamps a received line with its arrival time and sender, and
+// turns a syslog PRI prefix into a readable severity.
+func (c *logCapture) formatLine(now time.Time, addr net.Addr, raw string) string {
+	raw = strings.TrimRight(raw, "\r\x00")
+	host := addr.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	msg := raw
+	if rest, ok := strings.CutPrefix(raw, "<"); ok {
+		if end := strings.IndexByte(rest, '>'); end > 0 && end <= 3 {
+			if pri, err := strconv.Atoi(rest[:end]); err == nil && pri < 192 {
+				msg = syslogSeverities[pri%8] + ": " + strings.TrimSpace(rest[end+1:])
+			}
+		}
+	}
+	line := now.UTC().Format(time.RFC3339) + " " + host + " " + msg + "\n"
+	if len(line) > c.cfg.MaxBytes {
+		line = line[:c.cfg.MaxBytes-1] + "\n"
+	}
+	return line
+}
+
+// flush appends pending lines to the current paste, rolling over to a new
+// paste when the current one is full or older than the window.
+func (c *logCapture) flush(ctx context.Context) {
+	for len(c.pending) > 0 {
+		now := c.s.nowTime().UTC()
+		if c.current == nil || now.Sub(c.current.CreatedAt) >= c.cfg.Window || c.current.Size+len(c.pending[0]) > c.cfg.MaxBytes {
+			if err := c.roll(ctx, now); err != nil {
+				c.logError(ctx, "start capture paste", err)
+				return
+			}
+		}
+		var b strings.Builder
+		b.WriteString(c.current.Content)
+		taken := 0
+		for _, line := range c.pending {
+			if b.Len()+len(line) > c.cfg.MaxBytes {
+				break
+			}
+			b.WriteString(line)
+			taken++
+		}
+		c.current.Content = b.String()
+		c.current.Size = len(c.current.Content)
+		if err := c.s.store.Save(ctx, c.current); err != nil {
+			c.logError(ctx, "save capture paste", err)
+			// Keep the lines for the next flush instead of dropping them.
+			c.current.Content = ""
+			if c.saved != nil {
+				c.current.Content = c.saved.Content
+			}
+			c.current.Size = len(c.current.Content)
+			return
+		}
+		c.pending = c.pending[taken:]
+		if c.saved != nil {
+			c.s.syntaxStats.remove(c.saved)
+		}
+		c.s.syntaxStats.add(c.current)
+		snapshot := *c.current
+		c.saved = &snapshot
+	}
+	c.pending = nil
+}
+
+// roll starts a new capture paste. The finished one is handed to the scanner
+// now that nothing will write to it again.
+func (c *logCapture) roll(ctx context.Context, now time.Time) error {
+	id, err := c.s.idGen.Generate(ctx)
+	if err != nil {
+		return err
+	}
+	c.finish(ctx)
+	c.current = &storage.Paste{
+		ID:        id,
+		Title:     fmt.Sprintf("%s logs from %s", c.cfg.Name, now.Format("2006-01-02 15:04:05 UTC")),
+		Syntax:    "plaintext",
+		CreatedAt: now,
+		Metadata:  map[string]string{"capture": c.cfg.Name},
+		NoIndex:   true,
+		Owner:     captureOwnerScope + c.cfg.Name,
+	}
+	if c.expire > 0 {
+		c.current.ExpiresAt = now.Add(c.expire)
+	}
+	c.saved = nil
+	return nil
+}
+
+// finish scans the current paste once it will no longer be written.
+func (c *logCapture) finish(ctx context.Context) {
+	if c.saved != nil {
+		c.s.scanAsync(ctx, c.saved.ID)
+	}
+}
+
+func (c *logCapture) logError(ctx context.Context, msg string, err error) {
+	if c.s.logger != nil {
+		c.s.logger.ErrorContext(ctx, msg, "capture", c.cfg.Name, "error", err)
+	}
+}
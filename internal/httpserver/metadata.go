@@ -0,0 +1,139 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	maxMetadataEntries  = 10
+	maxMetadataValueLen = 256
+)
+
+var metadataKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,31}$`)
+
+type metadataItem struct {
+	Key   string
+	Value string
+	URL   string
+}
+
+// parseMetadata reads "key=value" lines as submitted by the create form.
+func parseMetadata(text string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("metadata line %q must be key=value", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if err := validateCreatorMetadata(key, value); err != nil {
+			return nil, err
+		}
+		out[key] = value
+	}
+	if len(out) > maxMetadataEntries {
+		return nil, fmt.Errorf("at most %d metadata entries allowed", maxMetadataEntries)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// normalizeMetadata validates metadata submitted as a JSON object, lowercasing keys.
+func normalizeMetadata(in map[string]string) (map[string]string, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	if len(in) > maxMetadataEntries {
+		return nil, fmt.Errorf("at most %d metadata entries allowed", maxMetadataEntries)
+	}
+	out := make(map[string]string, len(in))
+	for key, value := range in {
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if err := validateCreatorMetadata(key, value); err != nil {
+			return nil, err
+		}
+		out[key] = value
+	}
+	return out, nil
+}
+
+// validateCreatorMetadata also keeps creators off the keys the server
+// reserves for itself.
+func validateCreatorMetadata(key, value string) error {
+	if strings.HasPrefix(key, reservedMetadataPrefix) {
+		return fmt.Errorf("metadata key %q is reserved", key)
+	}
+	return validateMetadata(key, value)
+}
+
+func validateMetadata(key, value string) error {
+	if !metadataKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid metadata key %q", key)
+	}
+	if value == "" {
+		return fmt.Errorf("metadata %q has an empty value", key)
+	}
+	if len(value) > maxMetadataValueLen {
+		return fmt.Errorf("metadata %q exceeds %d bytes", key, maxMetadataValueLen)
+	}
+	return nil
+}
+
+// parseMetadataLinks validates the configured key -> URL template mapping.
+// Templates reference the value with {value}.
+func parseMetadataLinks(links map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(links))
+	for key, tmpl := range links {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !metadataKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid metadata link key %q", key)
+		}
+		if !strings.Contains(tmpl, "{value}") {
+			return nil, fmt.Errorf("metadata link for %q must contain {value}", key)
+		}
+		u, err := url.Parse(strings.ReplaceAll(tmpl, "{value}", "x"))
+		if err != nil {
+			return nil, fmt.Errorf("metadata link for %q: %w", key, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("metadata link templates must be absolute http(s) URLs")
+		}
+		out[key] = tmpl
+	}
+	return out, nil
+}
+
+func (s *Server) metadataItems(m map[string]string) []metadataItem {
+	keys := sortedKeys(m)
+	items := make([]metadataItem, 0, len(keys))
+	for _, k := range keys {
+		item := metadataItem{Key: k, Value: m[k]}
+		if tmpl, ok := s.metadataLinks[k]; ok {
+			item.URL = strings.ReplaceAll(tmpl, "{value}", url.PathEscape(m[k]))
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
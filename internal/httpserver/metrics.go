@@ -0,0 +1,55 @@
+package httpserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// handleMetrics serves capacity gauges in the Prometheus text format. Stored
+// figures come from the store's Stats, and active tallies from the
+// in-process syntax stats.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	stored, err := s.store.Stats(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	now := s.nowTime()
+	if err := s.syntaxStats.load(r.Context(), s.store, now); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	tallies := s.syntaxStats.snapshot(now)
+	syntaxes := make([]string, 0, len(tallies))
+	for syntax := range tallies {
+		syntaxes = append(syntaxes, syntax)
+	}
+	sort.Strings(syntaxes)
+
+	var b bytes.Buffer
+	gauge := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	gauge("tinypaste_stored_pastes", "Pastes held by the store, including expired ones not yet swept.")
+	fmt.Fprintf(&b, "tinypaste_stored_pastes %d\n", stored.Pastes)
+	gauge("tinypaste_stored_bytes", "Content bytes held by the store.")
+	fmt.Fprintf(&b, "tinypaste_stored_bytes %d\n", stored.Bytes)
+	gauge("tinypaste_expiring_pastes", "Unexpired pastes that expire within the given window.")
+	fmt.Fprintf(&b, "tinypaste_expiring_pastes{within=\"1h\"} %d\n", stored.ExpiringWithinHour)
+	fmt.Fprintf(&b, "tinypaste_expiring_pastes{within=\"24h\"} %d\n", stored.ExpiringWithinDay)
+	gauge("tinypaste_active_pastes", "Unexpired pastes by syntax.")
+	for _, syntax := range syntaxes {
+		fmt.Fprintf(&b, "tinypaste_active_pastes{syntax=%s} %d\n", strconv.Quote(syntax), tallies[syntax].Count)
+	}
+	gauge("tinypaste_active_bytes", "Content bytes of unexpired pastes by syntax.")
+	for _, syntax := range syntaxes {
+		fmt.Fprintf(&b, "tinypaste_active_bytes{syntax=%s} %d\n", strconv.Quote(syntax), tallies[syntax].Bytes)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(b.Bytes())
+}
@@ -1,22 +1,29 @@
 package httpserver
 
 import (
-	"net"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
+
+	"tiny-pastebin/internal/ipacl"
 )
 
 // RateLimiter implements a token bucket limiter per key.
 type RateLimiter struct {
-	rate    rate.Limit
-	burst   int
-	ttl     time.Duration
-	mu      sync.Mutex
-	clients map[string]*clientLimiter
+	rate      rate.Limit
+	burst     int
+	ttl       time.Duration
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	overrides map[string]rateOverride
+}
+
+// rateOverride replaces the default rate and burst for one key.
+type rateOverride struct {
+	rate  rate.Limit
+	burst int
 }
 
 type clientLimiter struct {
@@ -27,10 +34,11 @@ type clientLimiter struct {
 // NewRateLimiter constructs a RateLimiter.
 func NewRateLimiter(r rate.Limit, burst int, ttl time.Duration) *RateLimiter {
 	return &RateLimiter{
-		rate:    r,
-		burst:   burst,
-		ttl:     ttl,
-		clients: make(map[string]*clientLimiter),
+		rate:      r,
+		burst:     burst,
+		ttl:       ttl,
+		clients:   make(map[string]*clientLimiter),
+		overrides: make(map[string]rateOverride),
 	}
 }
 
@@ -47,10 +55,17 @@ func (rl *RateLimiter) Allow(key string) bool {
 		key = "unknown"
 	}
 
+	limit, burst := rl.rate, rl.burst
+	if o, ok := rl.overrides[key]; ok {
+		limit, burst = o.rate, o.burst
+	}
 	entry, ok := rl.clients[key]
 	if !ok {
-		entry = &clientLimiter{limiter: rate.NewLimiter(rl.rate, rl.burst)}
+		entry = &clientLimiter{limiter: rate.NewLimiter(limit, burst)}
 		rl.clients[key] = entry
+	} else if entry.limiter.Limit() != limit || entry.limiter.Burst() != burst {
+		entry.limiter.SetLimitAt(now, limit)
+		entry.limiter.SetBurstAt(now, burst)
 	}
 	entry.lastSeen = now
 	allowed := entry.limiter.Allow()
@@ -66,6 +81,45 @@ func (rl *RateLimiter) Allow(key string) bool {
 	return allowed
 }
 
+// SetLimit overrides the rate and burst applied to key, e.g. for an API key
+// with its own quota. A zero rate or burst keeps the corresponding default.
+func (rl *RateLimiter) SetLimit(key string, r rate.Limit, burst int) {
+	if rl == nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if r <= 0 {
+		r = rl.rate
+	}
+	if burst <= 0 {
+		burst = rl.burst
+	}
+	if r == rl.rate && burst == rl.burst {
+		delete(rl.overrides, key)
+		return
+	}
+	rl.overrides[key] = rateOverride{rate: r, burst: burst}
+}
+
+// Prune drops limiter state for clients idle longer than the TTL and
+// reports how many entries were removed.
+func (rl *RateLimiter) Prune(now time.Time) int {
+	if rl == nil || rl.ttl <= 0 {
+		return 0
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	removed := 0
+	for k, v := range rl.clients {
+		if now.Sub(v.lastSeen) > rl.ttl {
+			delete(rl.clients, k)
+			removed++
+		}
+	}
+	return removed
+}
+
 // RateLimitMiddleware enforces the limiter per-client.
 func RateLimitMiddleware(rl *RateLimiter, keyFunc func(*http.Request) string) func(http.Handler) http.Handler {
 	if rl == nil {
@@ -90,25 +144,26 @@ func RateLimitMiddleware(rl *RateLimiter, keyFunc func(*http.Request) string) fu
 	}
 }
 
-// ClientIP returns the client IP respecting proxy headers when trustProxy is true.
-func ClientIP(r *http.Request, trustProxy bool) string {
-	if trustProxy {
-		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-			parts := strings.Split(xff, ",")
-			if len(parts) > 0 {
-				ip := strings.TrimSpace(parts[0])
-				if ip != "" {
-					return ip
-				}
-			}
-		}
-		if xrip := r.Header.Get("X-Real-IP"); xrip != "" {
-			return strings.TrimSpace(xrip)
+// IPAccessMiddleware refuses clients whose address acl does not allow.
+func IPAccessMiddleware(acl *ipacl.List, proxies TrustedProxies) func(http.Handler) http.Handler {
+	if acl == nil {
+		return func(next http.Handler) http.Handler {
+			return next
 		}
 	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acl.Allowed(ClientIP(r, proxies)) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
-	return host
+}
+
+// ClientIP returns the client IP of r, following the proxy headers set by
+// trusted proxies.
+func ClientIP(r *http.Request, proxies TrustedProxies) string {
+	return proxies.clientIP(r)
 }
@@ -0,0 +1,155 @@
+package httpserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	creatorCookie  = "tp_creator"
+	creatorTTL     = 365 * 24 * time.Hour
+	anonOwnerScope = "anon:"
+)
+
+// creator identifies an anonymous browser that has created pastes, so it can
+// find and delete them later without an account.
+type creator struct {
+	ID string `json:"i"`
+}
+
+// creatorOwner returns the owner identity from the creator cookie, or "".
+func (s *Server) creatorOwner(r *http.Request) string {
+	var c creator
+	if !s.readSealedCookie(r, creatorCookie, &c) || c.ID == "" {
+		return ""
+	}
+	return anonOwnerScope + c.ID
+}
+
+// ensureOwner returns the request's owner identity, issuing a creator cookie
+// to anonymous browsers that do not have one yet.
+func (s *Server) ensureOwner(w http.ResponseWriter, r *http.Request) (string, error) {
+	if owner := s.ownerOf(r); owner != "" {
+		return owner, nil
+	}
+	c := creator{ID: randomToken()}
+	if err := s.setSealedCookie(w, r, creatorCookie, "/", c, creatorTTL); err != nil {
+		return "", err
+	}
+	return anonOwnerScope + c.ID, nil
+}
+
+// ownerCSRF binds the my-pastes forms to the owner they were rendered for.
+func (s *Server) ownerCSRF(owner string) string {
+	return s.sealMAC("owner-csrf", owner)
+}
+
+type minePageData struct {
+	Pastes []mineItem
+	CSRF   string
+	Notice string
+	// Claimable counts pastes created anonymously from this browser that
+	// the signed-in user can move into their account.
+	Claimable int
+}
+
+type mineItem struct {
+	ID          string
+	Title       string
+	SyntaxLabel string
+	Size        int
+	CreatedAt   time.Time
+	ExpiresIn   string
+}
+
+func (d minePageData) PageTitle() string { return "My Pastes · Tiny Pastebin" }
+func (d minePageData) NoIndex() bool     { return true }
+
+func (s *Server) handleMine(w http.ResponseWriter, r *http.Request) {
+	data := minePageData{Notice: r.URL.Query().Get("notice")}
+	if owner := s.ownerOf(r); owner != "" {
+		pastes, err := s.ownedPastes(r, owner)
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		now := s.nowTime()
+		for _, p := range pastes {
+			data.Pastes = append(data.Pastes, mineItem{
+				ID:          p.ID,
+				Title:       displayTitle(p),
+				SyntaxLabel: syntaxLabel(p.Syntax),
+				Size:        p.Size,
+				CreatedAt:   p.CreatedAt,
+				ExpiresIn:   remaining(p.ExpiresAt, now),
+			})
+		}
+		data.CSRF = s.ownerCSRF(owner)
+	}
+	if anon := s.creatorOwner(r); anon != "" && !isAnonymousOwner(s.ownerOf(r)) {
+		n, err := s.store.Count(r.Context(), storage.ListOptions{Owner: anon, ActiveAt: s.nowTime()})
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		data.Claimable = n
+	}
+	s.render(w, r, http.StatusOK, "mine", data)
+}
+
+// handleMineDelete deletes the selected pastes, or all of them, as long as
+// they belong to the requester.
+func (s *Server) handleMineDelete(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.render(w, r, http.StatusBadRequest, "error", errorPageData{Message: "Unable to parse form"})
+		return
+	}
+	owner := s.ownerOf(r)
+	if owner == "" || !s.macMatches("owner-csrf", owner, r.PostFormValue("csrf")) {
+		s.render(w, r, http.StatusForbidden, "error", errorPageData{Message: "Forbidden"})
+		return
+	}
+
+	ids := r.PostForm["id"]
+	if r.PostFormValue("all") == "1" {
+		pastes, err := s.ownedPastes(r, owner)
+		if err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+		ids = ids[:0]
+		for _, p := range pastes {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		paste, err := s.store.Get(r.Context(), id)
+		if errors.Is(err, storage.ErrNotFound) || (err == nil && (paste.Owner != owner || paste.Deleted())) {
+			continue
+		}
+		if err == nil {
+			err = s.deletePaste(r.Context(), id, actorOwner)
+		}
+		switch {
+		case err == nil:
+			deleted++
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, errLegalHold):
+		default:
+			s.serverError(w, r, err)
+			return
+		}
+	}
+	notice := fmt.Sprintf("Deleted %s.", plural(deleted, "paste"))
+	http.Redirect(w, r, "/mine?notice="+url.QueryEscape(notice), http.StatusSeeOther)
+}
+
+func (s *Server) ownedPastes(r *http.Request, owner string) ([]*storage.Paste, error) {
+	return s.store.List(r.Context(), storage.ListOptions{Owner: owner, ActiveAt: s.nowTime()})
+}
@@ -0,0 +1,177 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Notebooks above this size are shown as JSON instead; embedded images
+	// make them large quickly.
+	maxNotebookBytes = 8 << 20
+	// maxOutputBytes caps each cell output rendered.
+	maxOutputBytes = 16 << 10
+)
+
+// notebookView is a Jupyter notebook parsed for a read-only display. Nothing
+// in it is executed or trusted: markdown cells are shown as their source and
+// only text outputs are kept, all escaped by the template like any paste.
+type notebookView struct {
+	Language string
+	Cells    []notebookCell
+}
+
+type notebookCell struct {
+	// Kind is the nbformat cell type: "code", "markdown" or "raw".
+	Kind string
+	// Prompt is the execution counter of code cells, e.g. "[3]".
+	Prompt  string
+	Source  string
+	Outputs []notebookOutput
+}
+
+type notebookOutput struct {
+	Text string
+	// Error marks stderr streams and exceptions.
+	Error bool
+	// Omitted marks rich outputs such as images that are not shown.
+	Omitted bool
+}
+
+// nbText is a multiline string as nbformat stores it: a string or a list of
+// lines.
+type nbText string
+
+func (t *nbText) UnmarshalJSON(data []byte) error {
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err == nil {
+		*t = nbText(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = nbText(s)
+	return nil
+}
+
+type nbDocument struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType       string `json:"cell_type"`
+		Source         nbText `json:"source"`
+		ExecutionCount *int   `json:"execution_count"`
+		Outputs        []struct {
+			OutputType string            `json:"output_type"`
+			Name       string            `json:"name"`
+			Text       nbText            `json:"text"`
+			Data       map[string]nbText `json:"data"`
+			EName      string            `json:"ename"`
+			EValue     string            `json:"evalue"`
+			Traceback  []string          `json:"traceback"`
+		} `json:"outputs"`
+	} `json:"cells"`
+}
+
+// ansiEscape matches the terminal colour codes found in tracebacks.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// parseNotebook parses an ipynb paste. It returns nil for other syntaxes and
+// for content that is not an nbformat 4 notebook, which then shows as text.
+func parseNotebook(content, syntax string) *notebookView {
+	if syntax != "ipynb" || len(content) > maxNotebookBytes {
+		return nil
+	}
+	var doc nbDocument
+	if err := json.Unmarshal([]byte(content), &doc); err != nil || doc.NBFormat != 4 {
+		return nil
+	}
+	nb := &notebookView{Language: doc.Metadata.LanguageInfo.Name}
+	if nb.Language == "" {
+		nb.Language = doc.Metadata.KernelSpec.Language
+	}
+	for _, c := range doc.Cells {
+		cell := notebookCell{Kind: c.CellType, Source: string(c.Source)}
+		if c.CellType == "code" {
+			cell.Prompt = "[ ]"
+			if c.ExecutionCount != nil {
+				cell.Prompt = fmt.Sprintf("[%d]", *c.ExecutionCount)
+			}
+		}
+		for _, o := range c.Outputs {
+			var out notebookOutput
+			switch o.OutputType {
+			case "stream":
+				out = notebookOutput{Text: string(o.Text), Error: o.Name == "stderr"}
+			case "execute_result", "display_data":
+				if text, ok := o.Data["text/plain"]; ok {
+					out.Text = string(text)
+				} else {
+					out = notebookOutput{Text: "[" + richOutputType(o.Data) + " output not shown]", Omitted: true}
+				}
+			case "error":
+				text := strings.Join(o.Traceback, "\n")
+				if text == "" {
+					text = o.EName + ": " + o.EValue
+				}
+				out = notebookOutput{Text: ansiEscape.ReplaceAllString(text, ""), Error: true}
+			default:
+				continue
+			}
+			if len(out.Text) > maxOutputBytes {
+				out.Text = strings.ToValidUTF8(out.Text[:maxOutputBytes], "") + "\n… output truncated"
+			}
+			cell.Outputs = append(cell.Outputs, out)
+		}
+		nb.Cells = append(nb.Cells, cell)
+	}
+	return nb
+}
+
+// richOutputType names the first MIME type of an output without a text form.
+func richOutputType(data map[string]nbText) string {
+	first := ""
+	for mime := range data {
+		if first == "" || mime < first {
+			first = mime
+		}
+	}
+	if first == "" {
+		return "empty"
+	}
+	return first
+}
+
+// looksLikeNotebook reports whether content is a JSON document with the
+// top-level nbformat and cells keys of a Jupyter notebook.
+func looksLikeNotebook(content string) bool {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "{") || !strings.Contains(trimmed, `"nbformat"`) {
+		return false
+	}
+	var doc struct {
+		NBFormat int               `json:"nbformat"`
+		Cells    []json.RawMessage `json:"cells"`
+	}
+	return json.Unmarshal([]byte(trimmed), &doc) == nil && doc.NBFormat > 0 && doc.Cells != nil
+}
+
+// detectSyntax recognizes content that has a dedicated view when no syntax
+// was chosen: notebooks and tables.
+func detectSyntax(content string) string {
+	if looksLikeNotebook(content) {
+		return "ipynb"
+	}
+	return detectTableSyntax(content)
+}
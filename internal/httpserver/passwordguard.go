@@ -0,0 +1,206 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// Password guard defaults, used when the Config fields are zero.
+const (
+	defaultPasswordAttempts   = 5
+	defaultPasswordBackoff    = time.Second
+	defaultPasswordBackoffMax = 15 * time.Minute
+	// The paste-wide lockout also keeps out readers who know the password,
+	// so anyone could hold it for as long as it lasts.
+	defaultPasswordPasteMax = 30 * time.Second
+)
+
+// passwordScopeFactor multiplies the free guesses of the paste-wide and
+// client-wide counters over those of a single (paste, client) pair.
+const passwordScopeFactor = 4
+
+// Password guard scopes: one client guessing at one paste, any clients
+// guessing at one paste, and one client guessing at any pastes.
+const (
+	scopePair   = "pair"
+	scopePaste  = "paste"
+	scopeClient = "client"
+)
+
+// passwordGuard throttles password guesses per (paste, client) pair, and
+// more loosely per paste and per client, so neither many clients guessing at
+// one paste nor one client guessing across many pastes goes unchecked. A few
+// wrong answers are free; after that each failure locks the scope out for
+// twice as long as the last, up to a cap. The paste-wide scope has its own,
+// lower cap, or none when pasteMax is negative. It is separate from the
+// global limiter so readers browsing normally are never slowed down.
+type passwordGuard struct {
+	free     int
+	backoff  time.Duration
+	max      time.Duration
+	pasteMax time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*passwordAttempts
+}
+
+type passwordAttempts struct {
+	failures int
+	until    time.Time
+	last     time.Time
+}
+
+func newPasswordGuard(free int, backoff, max, pasteMax time.Duration) *passwordGuard {
+	if free <= 0 {
+		free = defaultPasswordAttempts
+	}
+	if backoff <= 0 {
+		backoff = defaultPasswordBackoff
+	}
+	if max <= 0 {
+		max = defaultPasswordBackoffMax
+	}
+	if pasteMax == 0 {
+		pasteMax = defaultPasswordPasteMax
+	}
+	return &passwordGuard{free: free, backoff: backoff, max: max, pasteMax: min(pasteMax, max), entries: make(map[string]*passwordAttempts)}
+}
+
+func passwordGuardKey(scope, id, ip string) string {
+	switch scope {
+	case scopePaste:
+		ip = ""
+	case scopeClient:
+		id = ""
+	}
+	return scope + "\x00" + id + "\x00" + ip
+}
+
+// passwordLockout is what a wrong guess earned: the longest lockout of its
+// scopes, and which scope imposed it after how many failures.
+type passwordLockout struct {
+	wait     time.Duration
+	scope    string
+	failures int
+}
+
+// wait reports how long the pair must wait before its next guess; zero means
+// it may try now.
+func (g *passwordGuard) wait(id, ip string, now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var d time.Duration
+	for _, scope := range []string{scopePair, scopePaste, scopeClient} {
+		if e, ok := g.entries[passwordGuardKey(scope, id, ip)]; ok && now.Before(e.until) {
+			d = max(d, e.until.Sub(now))
+		}
+	}
+	return d
+}
+
+// fail records a wrong guess in every scope and returns the lockout it
+// earned, if any.
+func (g *passwordGuard) fail(id, ip string, now time.Time) passwordLockout {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var out passwordLockout
+	for _, scope := range []string{scopePair, scopePaste, scopeClient} {
+		free, limit := g.free, g.max
+		if scope != scopePair {
+			free *= passwordScopeFactor
+		}
+		if scope == scopePaste {
+			if g.pasteMax < 0 {
+				continue
+			}
+			limit = g.pasteMax
+		}
+		key := passwordGuardKey(scope, id, ip)
+		e, ok := g.entries[key]
+		if !ok {
+			e = &passwordAttempts{}
+			g.entries[key] = e
+		}
+		e.failures++
+		e.last = now
+		over := e.failures - free
+		if over <= 0 {
+			continue
+		}
+		d := g.backoff
+		for i := 1; i < over && d < limit; i++ {
+			d *= 2
+		}
+		d = min(d, limit)
+		e.until = now.Add(d)
+		if d > out.wait {
+			out = passwordLockout{wait: d, scope: scope, failures: e.failures}
+		}
+	}
+	return out
+}
+
+// succeed forgets the pair's failures after a correct password. The
+// paste-wide and client-wide counts are left to age out, so an attacker who
+// knows one password cannot use it to reset them.
+func (g *passwordGuard) succeed(id, ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.entries, passwordGuardKey(scopePair, id, ip))
+}
+
+// passwordFailed records a wrong password for paste id from the client ip,
+// as returned by clientKey, logging the lockouts it earns so admins can see
+// guessing in progress, and returns the lockout.
+func (s *Server) passwordFailed(r *http.Request, id, ip string) time.Duration {
+	lockout := s.passwordGuard.fail(id, ip, s.nowTime())
+	if lockout.wait > 0 && s.logger != nil {
+		s.logger.WarnContext(r.Context(), "password guessing locked out", "id", id, "ip_hash", s.ipHash(ClientIP(r, s.proxies)), "scope", lockout.scope, "failures", lockout.failures, "lockout", lockout.wait)
+	}
+	entry := storage.AuditEntry{Action: auditPasswordFailed, PasteID: id, IPHash: s.ipHash(ClientIP(r, s.proxies))}
+	if lockout.wait > 0 {
+		entry.Detail = fmt.Sprintf("locked out for %s by %s failures", lockout.wait, lockout.scope)
+	}
+	s.record(r.Context(), entry)
+	return lockout.wait
+}
+
+// passwordSucceeded clears the lockout of the reader who entered the right
+// password for paste id and audits the unlock.
+func (s *Server) passwordSucceeded(r *http.Request, id, ip string) {
+	s.passwordGuard.succeed(id, ip)
+	s.record(r.Context(), storage.AuditEntry{Action: auditPasteUnlocked, PasteID: id, IPHash: s.ipHash(ClientIP(r, s.proxies)), Detail: "password"})
+}
+
+// prune drops pairs that are no longer locked out and have been quiet for
+// longer than the maximum backoff, and reports how many.
+func (g *passwordGuard) prune(now time.Time) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for key, e := range g.entries {
+		if !now.Before(e.until) && now.Sub(e.last) > g.max {
+			delete(g.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// setRetryAfter advertises a lockout in whole seconds, rounded up.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((d+time.Second-1)/time.Second)))
+}
+
+// humanWait phrases a lockout for the password page.
+func humanWait(d time.Duration) string {
+	if d < time.Minute {
+		return plural(int((d+time.Second-1)/time.Second), "second")
+	}
+	return plural(int(d.Round(time.Minute)/time.Minute), "minute")
+}
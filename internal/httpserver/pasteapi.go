@@ -0,0 +1,299 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/security"
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	// pastePasswordHeader carries the password of a protected paste on API reads.
+	pastePasswordHeader = "X-Paste-Password"
+	// pasteExpiresHeader advertises when a paste expires, in RFC 3339.
+	pasteExpiresHeader = "X-Paste-Expires-At"
+	// maxBatchPastes caps the pastes created by one batch request.
+	maxBatchPastes = 50
+)
+
+type createPasteRequest struct {
+	Title       string            `json:"title"`
+	Content     string            `json:"content"`
+	Syntax      string            `json:"syntax"`
+	Expire      string            `json:"expire"`
+	Password    string            `json:"password"`
+	Metadata    map[string]string `json:"metadata"`
+	Public      bool              `json:"public"`
+	NoIndex     bool              `json:"noindex"`
+	License     string            `json:"license"`
+	Attribution string            `json:"attribution"`
+	SourceURL   string            `json:"source_url"`
+	Encrypted   bool              `json:"encrypted"`
+}
+
+type batchCreateRequest struct {
+	Pastes []createPasteRequest `json:"pastes"`
+}
+
+type batchCreateResponse struct {
+	Pastes []createPasteResponse `json:"pastes"`
+}
+
+type pasteResponse struct {
+	pasteSummary
+	Content string `json:"content"`
+}
+
+func (s *Server) handleAPICreate(w http.ResponseWriter, r *http.Request) {
+	// JSON escaping can double the size of the content on the wire.
+	r.Body = http.MaxBytesReader(w, r.Body, int64(s.maxBytes)*2+8192)
+	if !s.termsAcceptedByHeader(r) {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "accept the terms of service at " + s.absoluteURL(r, "/terms") + " and send " + termsHeader + ": 1"})
+		return
+	}
+	if !s.passChallengeAPI(w, r) {
+		return
+	}
+	var req createPasteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	in, err := s.createInput(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if !s.quotaAllowsAPI(w, r, 1, len(in.Content), true) {
+		return
+	}
+	paste, err := s.buildPaste(r, in, s.apiOwner(r))
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if err := s.store.Save(r.Context(), paste); err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	s.pasteCreated(r.Context(), paste)
+
+	sum := s.summarize(r, paste)
+	w.Header().Set("Location", sum.URL)
+	writeJSON(w, http.StatusCreated, createPasteResponse{pasteSummary: sum, ClaimToken: s.claimToken(paste), Warnings: s.secretWarnings(in), Links: s.capabilityLinks(r, paste)})
+}
+
+// handleAPIBatchCreate creates up to maxBatchPastes pastes in one request.
+// Every paste is validated and checked against the quotas before any is
+// saved, and stores that support it save them in one transaction, so a
+// failed batch creates nothing.
+func (s *Server) handleAPIBatchCreate(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, (int64(s.maxBytes)*2+8192)*maxBatchPastes)
+	if !s.termsAcceptedByHeader(r) {
+		writeJSON(w, http.StatusForbidden, apiError{Error: "accept the terms of service at " + s.absoluteURL(r, "/terms") + " and send " + termsHeader + ": 1"})
+		return
+	}
+	if !s.passChallengeAPI(w, r) {
+		return
+	}
+	var req batchCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if len(req.Pastes) == 0 || len(req.Pastes) > maxBatchPastes {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "a batch holds between 1 and " + strconv.Itoa(maxBatchPastes) + " pastes"})
+		return
+	}
+	inputs := make([]pasteInput, len(req.Pastes))
+	size := 0
+	for i, p := range req.Pastes {
+		in, err := s.createInput(r.Context(), p)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "paste " + strconv.Itoa(i) + ": " + err.Error()})
+			return
+		}
+		inputs[i] = in
+		size += len(in.Content)
+	}
+	if !s.quotaAllowsAPI(w, r, len(inputs), size, true) {
+		return
+	}
+	owner := s.apiOwner(r)
+	pastes := make([]*storage.Paste, len(inputs))
+	for i, in := range inputs {
+		paste, err := s.buildPaste(r, in, owner)
+		if err != nil {
+			s.apiServerError(w, r, err)
+			return
+		}
+		pastes[i] = paste
+	}
+	if err := storage.SaveBatch(r.Context(), s.store, pastes); err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+
+	resp := batchCreateResponse{Pastes: make([]createPasteResponse, len(pastes))}
+	for i, paste := range pastes {
+		s.pasteCreated(r.Context(), paste)
+		resp.Pastes[i] = createPasteResponse{pasteSummary: s.summarize(r, paste), ClaimToken: s.claimToken(paste), Warnings: s.secretWarnings(inputs[i]), Links: s.capabilityLinks(r, paste)}
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// createInput validates a create request.
+func (s *Server) createInput(ctx context.Context, req createPasteRequest) (pasteInput, error) {
+	metadata, err := normalizeMetadata(req.Metadata)
+	if err != nil {
+		return pasteInput{}, err
+	}
+	in := pasteInput{
+		Title:       req.Title,
+		Content:     req.Content,
+		Syntax:      req.Syntax,
+		Expire:      req.Expire,
+		Password:    req.Password,
+		Metadata:    metadata,
+		Public:      req.Public,
+		NoIndex:     req.NoIndex,
+		License:     req.License,
+		Attribution: req.Attribution,
+		SourceURL:   req.SourceURL,
+		Encrypted:   req.Encrypted,
+	}
+	if err := s.validatePaste(ctx, &in); err != nil {
+		return pasteInput{}, err
+	}
+	return in, nil
+}
+
+// handleAPIGet returns a paste with its content. Protected pastes require the
+// password in the X-Paste-Password header or an unlocked browser session.
+func (s *Server) handleAPIGet(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if err != nil {
+		var gone *goneError
+		if errors.As(err, &gone) && gone.reason == goneTakenDown {
+			msg := "paste taken down"
+			if gone.notice != "" {
+				msg += ": " + gone.notice
+			}
+			writeJSON(w, gone.status(), apiError{Error: msg})
+			return
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+			return
+		}
+		s.apiServerError(w, r, err)
+		return
+	}
+	if paste.PasswordHash != "" && !s.hasAuth(r, paste.ID) {
+		password := r.Header.Get(pastePasswordHeader)
+		if password == "" {
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "password required"})
+			return
+		}
+		ip := s.clientKey(r)
+		if wait := s.passwordGuard.wait(paste.ID, ip, s.nowTime()); wait > 0 {
+			setRetryAfter(w, wait)
+			writeJSON(w, http.StatusTooManyRequests, apiError{Error: "too many incorrect passwords"})
+			return
+		}
+		ok, err := security.VerifyPassword(paste.PasswordHash, password)
+		if err != nil {
+			s.apiServerError(w, r, err)
+			return
+		}
+		if !ok {
+			if wait := s.passwordFailed(r, paste.ID, ip); wait > 0 {
+				setRetryAfter(w, wait)
+			}
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "incorrect password"})
+			return
+		}
+		s.passwordSucceeded(r, paste.ID, ip)
+		s.rehashPassword(r.Context(), paste, password)
+	}
+	if err := s.unseal(r, paste, r.Header.Get(pastePasswordHeader)); err != nil {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "password required"})
+		return
+	}
+	s.setPasteRobots(w, paste)
+	writeJSON(w, http.StatusOK, pasteResponse{pasteSummary: s.summarize(r, paste), Content: paste.Content})
+}
+
+type existsResponse struct {
+	ID        string     `json:"id"`
+	Exists    bool       `json:"exists"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// handleAPIExists reports whether a paste can be read, without its content, so
+// link checkers can poll cheaply. Missing pastes answer 404 so HEAD works too.
+func (s *Server) handleAPIExists(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	paste, err := s.fetchPaste(r, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, existsResponse{ID: id})
+		return
+	}
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	resp := existsResponse{ID: paste.ID, Exists: true}
+	if paste.HasExpiration() {
+		exp := paste.ExpiresAt
+		resp.ExpiresAt = &exp
+	}
+	s.setPasteHeaders(w, paste)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAPIDelete lets admins delete any paste and owners, including API
+// keys with the delete scope, delete their own.
+func (s *Server) handleAPIDelete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	actor := actorAdmin
+	if identity, ok := s.adminIdentity(r); !ok || identity != "bearer" {
+		paste, err := s.store.Get(r.Context(), id)
+		if errors.Is(err, storage.ErrNotFound) || (err == nil && paste.Deleted()) {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+			return
+		}
+		if err != nil {
+			s.apiServerError(w, r, err)
+			return
+		}
+		owner := s.apiOwner(r)
+		if owner == "" || paste.Owner != owner {
+			writeJSON(w, http.StatusForbidden, apiError{Error: "only the owner can delete this paste"})
+			return
+		}
+		actor = actorOwner
+	}
+
+	err := s.deletePaste(r.Context(), id, actor)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return
+	}
+	if errors.Is(err, errLegalHold) {
+		writeJSON(w, http.StatusConflict, apiError{Error: "paste is under legal hold"})
+		return
+	}
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// Answers negotiated for paste pages by User-Agent.
+const (
+	// plainRaw serves the content as /raw does.
+	plainRaw = "raw"
+	// plainText serves a plain-text page with the paste's details above the
+	// content.
+	plainText = "text"
+)
+
+// defaultPlainAgents covers the common command-line clients and text-mode
+// browsers.
+var defaultPlainAgents = map[string]string{
+	"curl":   plainRaw,
+	"wget":   plainRaw,
+	"httpie": plainRaw,
+	"xh":     plainRaw,
+	"fetch":  plainRaw,
+	"lynx":   plainText,
+	"links":  plainText,
+	"elinks": plainText,
+	"w3m":    plainText,
+}
+
+// normalizePlainAgents validates cfg.PlainAgents, lowercasing the names.
+func normalizePlainAgents(agents map[string]string) (map[string]string, error) {
+	if agents == nil {
+		return defaultPlainAgents, nil
+	}
+	out := make(map[string]string, len(agents))
+	for name, mode := range agents {
+		if mode != plainRaw && mode != plainText {
+			return nil, fmt.Errorf("plain agent %s: mode must be %s or %s, got %q", name, plainRaw, plainText, mode)
+		}
+		out[strings.ToLower(name)] = mode
+	}
+	return out, nil
+}
+
+// plainMode reports how the paste page should answer r, or "" for HTML. It
+// matches the product name leading the User-Agent, e.g. "curl" in
+// "curl/8.5.0" or "Links" in "Links (2.29; Linux)".
+func (s *Server) plainMode(r *http.Request) string {
+	if len(s.plainAgents) == 0 {
+		return ""
+	}
+	ua := r.UserAgent()
+	if i := strings.IndexAny(ua, "/ ("); i >= 0 {
+		ua = ua[:i]
+	}
+	return s.plainAgents[strings.ToLower(ua)]
+}
+
+// handlePlainView answers a paste page for text-mode browsers, which still
+// get the HTML password form for protected pastes.
+func (s *Server) handlePlainView(w http.ResponseWriter, r *http.Request, paste *storage.Paste) {
+	s.setPasteHeaders(w, paste)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	s.setPasteRobots(w, paste)
+	if r.Method == http.MethodHead {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", displayTitle(paste))
+	fmt.Fprintf(&b, "%s, %s, created %s, expires: %s\n", syntaxLabel(paste.Syntax), formatSize(paste.Size),
+		paste.CreatedAt.UTC().Format(time.RFC1123), remaining(paste.ExpiresAt, s.nowTime()))
+	fmt.Fprintf(&b, "Raw: %s\n", s.absoluteURL(r, "/p/"+paste.ID+"/raw"))
+	b.WriteString(strings.Repeat("-", 72) + "\n")
+	b.WriteString(paste.Content)
+	if !strings.HasSuffix(paste.Content, "\n") {
+		b.WriteByte('\n')
+	}
+	_, _ = w.Write([]byte(b.String()))
+}
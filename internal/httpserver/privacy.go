@@ -0,0 +1,80 @@
+package httpserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// In privacy mode client addresses never leave the request that carried
+// them. Stored records, audit entries, logs, rate limits and password
+// lockouts all see a salted hash instead, and the salt is replaced every
+// day and never written down, so a hash cannot be traced back to an
+// address once its day is over. Grouping pastes by address then only works
+// within a day, and restarting the server starts a new salt.
+
+// dailySalt hashes addresses under a random salt replaced at each UTC
+// midnight.
+type dailySalt struct {
+	mu   sync.Mutex
+	day  string
+	salt []byte
+}
+
+func (d *dailySalt) hash(addr string, now time.Time) string {
+	day := now.UTC().Format(time.DateOnly)
+	d.mu.Lock()
+	if d.day != day {
+		d.salt = make([]byte, 32)
+		if _, err := rand.Read(d.salt); err != nil {
+			panic(err)
+		}
+		d.day = day
+	}
+	mac := hmac.New(sha256.New, d.salt)
+	d.mu.Unlock()
+	mac.Write([]byte(addr))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// clientKey identifies the client sending r to rate limits and password
+// lockouts: its address, or the address's hash in privacy mode.
+func (s *Server) clientKey(r *http.Request) string {
+	ip := ClientIP(r, s.proxies)
+	if s.privacy != nil {
+		return s.ipHash(ip)
+	}
+	return ip
+}
+
+// scrubPath blanks the path segments that are secrets: capability link
+// tokens, draft tokens and ingest keys.
+func scrubPath(path string) string {
+	parts := strings.Split(path, "/")
+	redact := func(i int) {
+		if i < len(parts) && parts[i] != "" {
+			parts[i] = "-"
+		}
+	}
+	// parts[0] is the empty string before the leading slash.
+	if len(parts) > 1 {
+		switch parts[1] {
+		case "drafts", "ingest":
+			redact(2)
+		case "p":
+			if len(parts) > 3 {
+				switch parts[3] {
+				case "view", "edit", "delete":
+					redact(4)
+				}
+			}
+		}
+	}
+	return (&url.URL{Path: strings.Join(parts, "/")}).Path
+}
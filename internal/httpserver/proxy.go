@@ -0,0 +1,106 @@
+package httpserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies lists the networks of the reverse proxies in front of the
+// server. X-Forwarded-For, X-Real-IP, X-Forwarded-Proto and X-Request-ID are
+// honored only on requests whose peer address is in one of them; anyone
+// else could set them to anything.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses CIDRs such as "10.0.0.0/8", or single
+// addresses, which stand for themselves.
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	var out TrustedProxies
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", v, err)
+			}
+			addr = addr.Unmap()
+			out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", v, err)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+// contains reports whether addr is one of the trusted proxies.
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// trusts reports whether r came directly from a trusted proxy.
+func (t TrustedProxies) trusts(r *http.Request) bool {
+	if len(t) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(remoteHost(r))
+	return err == nil && t.contains(addr)
+}
+
+// clientIP resolves the client address of r. X-Forwarded-For is walked from
+// the right, as each proxy appends the address it received the request
+// from: the first address that is not a trusted proxy is the client. The
+// entries left of it were supplied by the client and are not believed.
+func (t TrustedProxies) clientIP(r *http.Request) string {
+	peer := remoteHost(r)
+	if !t.trusts(r) {
+		return peer
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return addr.Unmap().String()
+		}
+		return peer
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A garbled entry cannot be followed any further; the last
+			// good hop is the best guess.
+			break
+		}
+		client = addr.Unmap().String()
+		if !t.contains(addr) {
+			break
+		}
+	}
+	return client
+}
+
+// remoteHost is the address of the peer that sent r, without the port.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
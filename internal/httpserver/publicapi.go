@@ -0,0 +1,154 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	// publicPageSize and publicMaxPageSize bound the pastes listed per page
+	// of the public API.
+	publicPageSize    = 20
+	publicMaxPageSize = 100
+	// publicCursorTTL is how long a pagination cursor stays valid.
+	publicCursorTTL = 24 * time.Hour
+)
+
+// publicCursor is the state behind an opaque next_cursor: the page continues
+// with pastes created at or before Before, skipping the first Skip created
+// exactly then, which earlier pages already returned.
+type publicCursor struct {
+	Before time.Time `json:"b"`
+	Skip   int       `json:"s,omitempty"`
+	Syntax string    `json:"y,omitempty"`
+	Issued time.Time `json:"i"`
+}
+
+type publicListResponse struct {
+	Pastes     []pasteSummary `json:"pastes"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// publicRoutes mounts the anonymous read-only API. It only ever shows
+// pastes listed on /recent, so it cannot be used to enumerate unlisted,
+// protected or quarantined ones, and it has no write endpoints.
+func (s *Server) publicRoutes(pr chi.Router) {
+	pr.Use(allowAnyOrigin)
+	pr.Use(RateLimitMiddleware(s.publicLimiter, s.clientKey))
+	pr.Get("/pastes", s.handlePublicList)
+	pr.Get("/pastes/{id}", s.handlePublicGet)
+	pr.Get("/pastes/{id}/raw", s.handlePublicRaw)
+}
+
+// allowAnyOrigin lets browser frontends on other sites call the public API.
+// Responses never depend on cookies, so no credentials are allowed.
+func allowAnyOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handlePublicList pages through public pastes, newest first. Pages are
+// chained by the signed next_cursor rather than offsets, so they stay stable
+// while new pastes arrive and cannot be forged to reach other listings.
+func (s *Server) handlePublicList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := publicPageSize
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > publicMaxPageSize {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "limit must be between 1 and " + strconv.Itoa(publicMaxPageSize)})
+			return
+		}
+		limit = n
+	}
+	now := s.nowTime()
+	cur := publicCursor{Syntax: q.Get("syntax")}
+	if v := q.Get("cursor"); v != "" {
+		if !s.openValue("public-cursor", v, &cur) || now.Sub(cur.Issued) > publicCursorTTL {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid or expired cursor"})
+			return
+		}
+	}
+
+	opts := storage.ListOptions{
+		PublicOnly: true,
+		ActiveAt:   now,
+		Syntax:     cur.Syntax,
+		Offset:     cur.Skip,
+		Limit:      limit + 1,
+	}
+	if !cur.Before.IsZero() {
+		opts.CreatedBefore = cur.Before.Add(time.Nanosecond)
+	}
+	pastes, err := s.store.List(r.Context(), opts)
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+
+	resp := publicListResponse{Pastes: make([]pasteSummary, 0, min(len(pastes), limit))}
+	for _, p := range pastes[:min(len(pastes), limit)] {
+		resp.Pastes = append(resp.Pastes, s.summarize(r, p))
+	}
+	if len(pastes) > limit {
+		last := pastes[limit-1]
+		next := publicCursor{Before: last.CreatedAt, Syntax: cur.Syntax, Issued: now}
+		for _, p := range pastes[:limit] {
+			if p.CreatedAt.Equal(last.CreatedAt) {
+				next.Skip++
+			}
+		}
+		if last.CreatedAt.Equal(cur.Before) {
+			next.Skip += cur.Skip
+		}
+		if resp.NextCursor, err = s.sealValue("public-cursor", next); err != nil {
+			s.apiServerError(w, r, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handlePublicGet(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.publicPaste(w, r)
+	if !ok {
+		return
+	}
+	s.setPasteRobots(w, paste)
+	writeJSON(w, http.StatusOK, s.summarize(r, paste))
+}
+
+func (s *Server) handlePublicRaw(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.publicPaste(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	s.setPasteRobots(w, paste)
+	_, _ = w.Write([]byte(paste.Content))
+}
+
+// publicPaste loads the paste named in the URL if it is publicly listed,
+// answering 404 for every other paste.
+func (s *Server) publicPaste(w http.ResponseWriter, r *http.Request) (*storage.Paste, bool) {
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.apiServerError(w, r, err)
+		return nil, false
+	}
+	if err != nil || !(storage.ListOptions{PublicOnly: true}).Match(paste) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return nil, false
+	}
+	return paste, true
+}
@@ -0,0 +1,174 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// eventTimeout bounds delivery of a single event to the configured sink.
+const eventTimeout = 10 * time.Second
+
+// Actors recorded on events.
+const (
+	actorAdmin   = "admin"
+	actorOwner   = "owner"
+	actorScanner = "scanner"
+	actorQuota   = "quota"
+)
+
+// quarantinePaste hides paste from everyone but admins.
+func (s *Server) quarantinePaste(ctx context.Context, paste *storage.Paste, reason, actor string) error {
+	paste.Quarantined = true
+	paste.QuarantineReason = reason
+	if err := s.store.Save(ctx, paste); err != nil {
+		return err
+	}
+	s.emit(ctx, events.Event{Type: events.PasteQuarantined, PasteID: paste.ID, Reason: reason, Actor: actor})
+	return nil
+}
+
+// releasePaste makes a quarantined paste readable again.
+func (s *Server) releasePaste(ctx context.Context, paste *storage.Paste, actor string) error {
+	paste.Quarantined = false
+	paste.QuarantineReason = ""
+	if err := s.store.Save(ctx, paste); err != nil {
+		return err
+	}
+	s.emit(ctx, events.Event{Type: events.PasteReleased, PasteID: paste.ID, Actor: actor})
+	return nil
+}
+
+// deletePaste removes a paste on behalf of actor. With a delete grace period
+// the paste is only marked deleted for the janitor to purge later; deleting
+// it again purges it at once. Taken-down pastes are refused with
+// errLegalHold.
+func (s *Server) deletePaste(ctx context.Context, id, actor string) error {
+	paste, err := s.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if paste.TakenDown() {
+		return errLegalHold
+	}
+	if s.deleteGrace <= 0 || paste.Deleted() {
+		return s.removePaste(ctx, paste, actor)
+	}
+	paste.DeletedAt = s.nowTime().UTC()
+	paste.DeletedBy = actor
+	if err := s.store.Save(ctx, paste); err != nil {
+		return err
+	}
+	s.syntaxStats.remove(paste)
+	s.emit(ctx, events.Event{Type: events.PasteDeleted, PasteID: id, Actor: actor})
+	return nil
+}
+
+// emit records e in the audit log and delivers it to the event sink in the
+// background.
+func (s *Server) emit(ctx context.Context, e events.Event) {
+	s.recordEvent(ctx, e)
+	if s.events == nil {
+		return
+	}
+	e.Time = s.nowTime().UTC()
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), eventTimeout)
+		defer cancel()
+		if err := s.events.Emit(ctx, e); err != nil && s.logger != nil {
+			s.logger.WarnContext(ctx, "emit event", "type", string(e.Type), "id", e.PasteID, "error", err)
+		}
+	}()
+}
+
+type quarantineRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleQuarantineList returns every quarantined paste awaiting review.
+func (s *Server) handleQuarantineList(w http.ResponseWriter, r *http.Request) {
+	pastes, err := s.store.List(r.Context(), storage.ListOptions{Quarantined: true})
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	out := make([]pasteSummary, 0, len(pastes))
+	for _, p := range pastes {
+		out = append(out, s.summarize(r, p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleAPIQuarantine(w http.ResponseWriter, r *http.Request) {
+	var req quarantineRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "quarantined by admin"
+	}
+	s.apiTransition(w, r, func(ctx context.Context, p *storage.Paste) error {
+		return s.quarantinePaste(ctx, p, req.Reason, actorAdmin)
+	})
+}
+
+func (s *Server) handleAPIRelease(w http.ResponseWriter, r *http.Request) {
+	s.apiTransition(w, r, func(ctx context.Context, p *storage.Paste) error {
+		if !p.Quarantined {
+			return nil
+		}
+		return s.releasePaste(ctx, p, actorAdmin)
+	})
+}
+
+// apiTransition loads the paste named in the URL, applies apply and responds
+// with the resulting summary.
+func (s *Server) apiTransition(w http.ResponseWriter, r *http.Request, apply func(context.Context, *storage.Paste) error) {
+	paste, err := s.store.Get(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return
+	}
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if err := apply(r.Context(), paste); err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.summarize(r, paste))
+}
+
+// handleAdminRelease releases a quarantined paste from the dashboard.
+func (s *Server) handleAdminRelease(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	paste, err := s.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" no longer exists."), http.StatusSeeOther)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if paste.Quarantined {
+		if err := s.releasePaste(r.Context(), paste, actorAdmin); err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+	}
+	http.Redirect(w, r, adminReturnURL(r, "Released "+id+"."), http.StatusSeeOther)
+}
@@ -0,0 +1,223 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// QuotaPolicy decides what happens once the store is over its quota.
+type QuotaPolicy string
+
+const (
+	// QuotaEvict lets creates through and has the janitor delete the oldest
+	// expiring pastes until the store fits again.
+	QuotaEvict QuotaPolicy = "evict"
+	// QuotaReject refuses creates that would take the store over its quota.
+	QuotaReject QuotaPolicy = "reject"
+)
+
+// Quota caps the content a store may hold. Pastes without an expiry are
+// permanent and never evicted unless they were deleted.
+type Quota struct {
+	// MaxBytes caps the total content size; zero disables the cap.
+	MaxBytes int64
+	// MaxPastes caps the number of pastes; zero disables the cap.
+	MaxPastes int64
+	// Policy defaults to QuotaEvict.
+	Policy QuotaPolicy
+}
+
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+// CreatorQuota limits what one client address may create. Addresses are
+// matched through the keyed hash stored with each paste, so the limits
+// restart with the server unless the cookie secret is configured.
+type CreatorQuota struct {
+	// MaxPastes caps the pastes created within Window that have not expired;
+	// zero disables the cap.
+	MaxPastes int
+	// MaxBytes caps the content created within Window; zero disables the cap.
+	MaxBytes int64
+	// Window defaults to a day.
+	Window time.Duration
+}
+
+func (q CreatorQuota) enabled() bool {
+	return q.MaxPastes > 0 || q.MaxBytes > 0
+}
+
+// creatorQuotaError explains which creator limit a paste ran into.
+type creatorQuotaError struct {
+	msg        string
+	retryAfter time.Duration
+}
+
+func (e *creatorQuotaError) Error() string { return e.msg }
+
+// per names a window for error messages: "per day", "per 6h0m0s".
+func per(window time.Duration) string {
+	switch window {
+	case time.Hour:
+		return "per hour"
+	case 24 * time.Hour:
+		return "per day"
+	case 7 * 24 * time.Hour:
+		return "per week"
+	}
+	return "per " + window.String()
+}
+
+// checkCreatorQuota refuses new pastes totalling size bytes from the address
+// behind r once that address has used up its CreatorQuota.
+func (s *Server) checkCreatorQuota(r *http.Request, pastes, size int) error {
+	q := s.creatorQuota
+	if !q.enabled() {
+		return nil
+	}
+	hash := s.ipHash(ClientIP(r, s.proxies))
+	if hash == "" {
+		return nil
+	}
+	now := s.nowTime()
+	recent, err := s.store.List(r.Context(), storage.ListOptions{IPHash: hash, CreatedAfter: now.Add(-q.Window), ActiveAt: now})
+	if err != nil {
+		return err
+	}
+	var used int64
+	oldest := now
+	for _, p := range recent {
+		used += int64(len(p.Content))
+		if p.CreatedAt.Before(oldest) {
+			oldest = p.CreatedAt
+		}
+	}
+	retry := max(oldest.Add(q.Window).Sub(now), time.Second)
+	if q.MaxPastes > 0 && len(recent)+pastes > q.MaxPastes {
+		return &creatorQuotaError{msg: fmt.Sprintf("You can create at most %d pastes %s", q.MaxPastes, per(q.Window)), retryAfter: retry}
+	}
+	if q.MaxBytes > 0 && used+int64(size) > q.MaxBytes {
+		return &creatorQuotaError{msg: fmt.Sprintf("You can create at most %s of pastes %s", formatSize(int(q.MaxBytes)), per(q.Window)), retryAfter: retry}
+	}
+	return nil
+}
+
+func (q Quota) enabled() bool {
+	return q.MaxBytes > 0 || q.MaxPastes > 0
+}
+
+func (q Quota) validate() error {
+	if q.MaxBytes < 0 || q.MaxPastes < 0 {
+		return errors.New("quota limits must not be negative")
+	}
+	if q.Policy != "" && q.Policy != QuotaEvict && q.Policy != QuotaReject {
+		return fmt.Errorf("unknown quota policy %q (want %s or %s)", q.Policy, QuotaEvict, QuotaReject)
+	}
+	return nil
+}
+
+// exceeded reports whether t is over the quota.
+func (q Quota) exceeded(t storage.Totals) bool {
+	return (q.MaxBytes > 0 && t.Bytes > q.MaxBytes) || (q.MaxPastes > 0 && t.Pastes > q.MaxPastes)
+}
+
+// checkQuota refuses new pastes totalling size bytes when the reject policy
+// is in force and they would not fit.
+func (s *Server) checkQuota(ctx context.Context, pastes, size int) error {
+	if !s.quota.enabled() || s.quota.Policy != QuotaReject {
+		return nil
+	}
+	t, err := storage.CountTotals(ctx, s.store)
+	if err != nil {
+		return err
+	}
+	t.Pastes += int64(pastes)
+	t.Bytes += int64(size)
+	if s.quota.exceeded(t) {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// quotaAllowsAPI runs the quota checks for a JSON endpoint, answering 507
+// when the store is full and 429 when the creator has used up their quota.
+// Ingest deliveries pass creator false: their address is the sending system.
+func (s *Server) quotaAllowsAPI(w http.ResponseWriter, r *http.Request, pastes, size int, creator bool) bool {
+	err := s.checkQuota(r.Context(), pastes, size)
+	if err == nil && creator {
+		err = s.checkCreatorQuota(r, pastes, size)
+	}
+	var cqe *creatorQuotaError
+	switch {
+	case errors.Is(err, errQuotaExceeded):
+		writeJSON(w, http.StatusInsufficientStorage, apiError{Error: "storage quota exceeded"})
+		return false
+	case errors.As(err, &cqe):
+		w.Header().Set("Retry-After", strconv.Itoa(int(cqe.retryAfter.Seconds())))
+		writeJSON(w, http.StatusTooManyRequests, apiError{Error: cqe.msg})
+		return false
+	case err != nil:
+		s.apiServerError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// evictForQuota purges soft-deleted pastes and then the oldest expiring
+// pastes until the store is back within its quota, returning how many it
+// removed.
+func (s *Server) evictForQuota(ctx context.Context) (int, error) {
+	totals, err := storage.CountTotals(ctx, s.store)
+	if err != nil || !s.quota.exceeded(totals) {
+		return 0, err
+	}
+
+	type candidate struct {
+		id      string
+		deleted bool
+		created time.Time
+		size    int64
+	}
+	var candidates []candidate
+	err = s.store.Iterate(ctx, func(p *storage.Paste) error {
+		if p.HasExpiration() || p.Deleted() {
+			candidates = append(candidates, candidate{p.ID, p.Deleted(), p.CreatedAt, int64(len(p.Content))})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.deleted != b.deleted {
+			if a.deleted {
+				return -1
+			}
+			return 1
+		}
+		return a.created.Compare(b.created)
+	})
+
+	removed := 0
+	for _, c := range candidates {
+		if !s.quota.exceeded(totals) {
+			return removed, nil
+		}
+		if err := s.purgePaste(ctx, c.id, actorQuota); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return removed, err
+		}
+		totals.Pastes--
+		totals.Bytes -= c.size
+		removed++
+	}
+	if s.quota.exceeded(totals) && s.logger != nil {
+		s.logger.WarnContext(ctx, "storage quota exceeded by permanent pastes", "pastes", totals.Pastes, "bytes", totals.Bytes)
+	}
+	return removed, nil
+}
@@ -0,0 +1,225 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// maxReportDetails caps the free text sent with an abuse report.
+const maxReportDetails = 1000
+
+// reportReasons are the categories a reader picks from when reporting a
+// paste.
+var reportReasons = []option{
+	{Value: "spam", Label: "Spam or advertising"},
+	{Value: "malware", Label: "Malware or phishing"},
+	{Value: "personal", Label: "Personal or private information"},
+	{Value: "copyright", Label: "Copyright infringement"},
+	{Value: "illegal", Label: "Illegal content"},
+	{Value: "other", Label: "Something else"},
+}
+
+func reportReasonLabel(reason string) string {
+	for _, o := range reportReasons {
+		if o.Value == reason {
+			return o.Label
+		}
+	}
+	return reason
+}
+
+type reportPageData struct {
+	ID      string
+	Reasons []option
+	Details string
+	Error   string
+	// Sent thanks the reader once the report is stored.
+	Sent bool
+}
+
+func (d reportPageData) PageTitle() string { return "Report paste · Tiny Pastebin" }
+func (d reportPageData) NoIndex() bool     { return true }
+
+// reportedPaste loads the paste a report is about, answering with the usual
+// not-found and gone pages when it cannot be reported.
+func (s *Server) reportedPaste(w http.ResponseWriter, r *http.Request) (*storage.Paste, bool) {
+	id := chi.URLParam(r, "id")
+	paste, err := s.fetchPaste(r, id)
+	if err != nil {
+		var gone *goneError
+		switch {
+		case errors.As(err, &gone):
+			s.gonePaste(w, r, gone)
+		case errors.Is(err, storage.ErrNotFound):
+			s.notFoundPaste(w, r, id)
+		default:
+			s.serverError(w, r, err)
+		}
+		return nil, false
+	}
+	return paste, true
+}
+
+func (s *Server) handleReportForm(w http.ResponseWriter, r *http.Request) {
+	paste, ok := s.reportedPaste(w, r)
+	if !ok {
+		return
+	}
+	s.render(w, r, http.StatusOK, "report", reportPageData{ID: paste.ID, Reasons: reportReasons})
+}
+
+// handleReport stores a reader's report for the admins' review queue. A
+// reader reporting the same paste again replaces their earlier report.
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+	paste, ok := s.reportedPaste(w, r)
+	if !ok {
+		return
+	}
+	reason := r.PostFormValue("reason")
+	details := strings.TrimSpace(r.PostFormValue("details"))
+	data := reportPageData{ID: paste.ID, Details: details}
+	for _, o := range reportReasons {
+		o.Selected = o.Value == reason
+		data.Reasons = append(data.Reasons, o)
+	}
+	switch {
+	case reportReasonLabel(reason) == reason:
+		data.Error = "Choose a reason for the report"
+	case len(details) > maxReportDetails:
+		data.Error = "Details are limited to 1000 bytes"
+	}
+	if data.Error != "" {
+		s.render(w, r, http.StatusBadRequest, "report", data)
+		return
+	}
+
+	ipHash := s.ipHash(ClientIP(r, s.proxies))
+	report := &storage.Report{
+		ID:        randomToken(),
+		PasteID:   paste.ID,
+		Reason:    reason,
+		Details:   details,
+		IPHash:    ipHash,
+		CreatedAt: s.nowTime().UTC(),
+	}
+	existing, err := s.reports.ListReports(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	for _, prev := range existing {
+		if prev.PasteID == paste.ID && ipHash != "" && prev.IPHash == ipHash {
+			report.ID = prev.ID
+			break
+		}
+	}
+	if err := s.reports.SaveReport(r.Context(), report); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	s.emit(r.Context(), events.Event{Type: events.PasteReported, PasteID: paste.ID, Reason: reason})
+	s.render(w, r, http.StatusOK, "report", reportPageData{ID: paste.ID, Sent: true})
+}
+
+// adminReportRow is a report in the review queue with what is known of the
+// paste it is about.
+type adminReportRow struct {
+	*storage.Report
+	ReasonLabel string
+	Title       string
+	// Gone is set once the paste has been deleted or has expired.
+	Gone bool
+}
+
+type adminReportsPageData struct {
+	Reports []adminReportRow
+	CSRF    string
+	Notice  string
+}
+
+func (d adminReportsPageData) PageTitle() string { return "Reports · Tiny Pastebin" }
+func (d adminReportsPageData) NoIndex() bool     { return true }
+
+// handleAdminReports lists open reports, oldest first.
+func (s *Server) handleAdminReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := s.reports.ListReports(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	identity, _ := s.adminIdentity(r)
+	data := adminReportsPageData{CSRF: s.adminCSRF(identity), Notice: r.URL.Query().Get("notice")}
+	for _, report := range reports {
+		row := adminReportRow{Report: report, ReasonLabel: reportReasonLabel(report.Reason)}
+		paste, err := s.store.Get(r.Context(), report.PasteID)
+		switch {
+		case err == nil:
+			row.Title = paste.Title
+			row.Gone = paste.Deleted() || (paste.HasExpiration() && !paste.ExpiresAt.After(s.nowTime()))
+		case errors.Is(err, storage.ErrNotFound):
+			row.Gone = true
+		default:
+			s.serverError(w, r, err)
+			return
+		}
+		data.Reports = append(data.Reports, row)
+	}
+	s.render(w, r, http.StatusOK, "admin-reports", data)
+}
+
+// handleAdminTakedown deletes the reported paste and closes every report
+// about it.
+func (s *Server) handleAdminTakedown(w http.ResponseWriter, r *http.Request) {
+	reports, err := s.reports.ListReports(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	var pasteID string
+	for _, report := range reports {
+		if report.ID == chi.URLParam(r, "id") {
+			pasteID = report.PasteID
+		}
+	}
+	if pasteID == "" {
+		http.Redirect(w, r, "/admin/reports?notice="+url.QueryEscape("That report was already closed."), http.StatusSeeOther)
+		return
+	}
+	if err := s.deletePaste(r.Context(), pasteID, actorAdmin); err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, errLegalHold) {
+		s.serverError(w, r, err)
+		return
+	}
+	closed := 0
+	for _, report := range reports {
+		if report.PasteID != pasteID {
+			continue
+		}
+		if err := s.reports.DeleteReport(r.Context(), report.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			s.serverError(w, r, err)
+			return
+		}
+		closed++
+	}
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "reported paste taken down", "id", pasteID, "reports", closed)
+	}
+	notice := "Took down " + pasteID + " and closed " + plural(closed, "report") + "."
+	http.Redirect(w, r, "/admin/reports?notice="+url.QueryEscape(notice), http.StatusSeeOther)
+}
+
+// handleAdminDismiss closes a report, leaving the paste alone.
+func (s *Server) handleAdminDismiss(w http.ResponseWriter, r *http.Request) {
+	if err := s.reports.DeleteReport(r.Context(), chi.URLParam(r, "id")); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/reports?notice="+url.QueryEscape("Dismissed the report."), http.StatusSeeOther)
+}
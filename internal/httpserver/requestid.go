@@ -0,0 +1,87 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"tiny-pastebin/internal/tracing"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	maxRequestIDLen = 128
+)
+
+// RequestIDMiddleware assigns every request an ID, echoes it in the
+// X-Request-ID response header and stores it where chi's middleware.GetReqID
+// finds it. An incoming X-Request-ID is reused only from trusted proxies.
+func RequestIDMiddleware(proxies TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := ""
+			if proxies.trusts(r) {
+				id = sanitizeRequestID(r.Header.Get(requestIDHeader))
+			}
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(requestIDHeader, id)
+			ctx := context.WithValue(r.Context(), middleware.RequestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// sanitizeRequestID accepts IDs made of printable token characters only, so
+// forwarded values cannot inject content into logs or headers.
+func sanitizeRequestID(v string) string {
+	if v == "" || len(v) > maxRequestIDLen {
+		return ""
+	}
+	for _, c := range v {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '/' || c == '+' || c == '=':
+		default:
+			return ""
+		}
+	}
+	return v
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDHandler adds the request ID carried by the log call's context to
+// every record, and the trace ID when the request is being traced.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" {
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	if id := tracing.TraceID(ctx); id != "" {
+		rec.AddAttrs(slog.String("trace_id", id))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,58 @@
+package httpserver
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const robotsNoIndex = "noindex, nofollow"
+
+// defaultRobotsTxt keeps crawlers out of machine endpoints, and out of paste
+// pages entirely unless the operator opted into indexing.
+func defaultRobotsTxt(noIndexPastes bool) string {
+	lines := []string{"User-agent: *", "Disallow: /admin", "Disallow: /api/", "Disallow: /drafts/"}
+	if noIndexPastes {
+		lines = append(lines, "Disallow: /p/")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// noIndexer is implemented by page data that can opt out of indexing.
+type noIndexer interface {
+	NoIndex() bool
+}
+
+func (d viewPageData) NoIndex() bool {
+	return d.Paste != nil && d.Paste.NoIndex
+}
+
+func (d passwordPageData) NoIndex() bool {
+	return true
+}
+
+func (s *Server) handleRobots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	_, _ = io.WriteString(w, s.robotsTxt)
+}
+
+// pageNoIndex reports whether a rendered page should carry noindex directives.
+func (s *Server) pageNoIndex(data any) bool {
+	if s.noIndexPastes {
+		return true
+	}
+	if n, ok := data.(noIndexer); ok {
+		return n.NoIndex()
+	}
+	return false
+}
+
+// setPasteRobots marks non-HTML paste responses as not indexable when required.
+func (s *Server) setPasteRobots(w http.ResponseWriter, paste *storage.Paste) {
+	if s.noIndexPastes || paste.NoIndex || paste.PasswordHash != "" {
+		w.Header().Set("X-Robots-Tag", robotsNoIndex)
+	}
+}
@@ -0,0 +1,84 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route is one method and pattern the router serves, with the middlewares
+// wrapped around it from the outermost in.
+type Route struct {
+	Method      string   `json:"method"`
+	Pattern     string   `json:"pattern"`
+	Middlewares []string `json:"middlewares"`
+}
+
+// Routes lists what this server's router serves, which depends on the
+// configuration: admin, login, metrics and similar routes only exist when
+// enabled.
+func (s *Server) Routes() ([]Route, error) {
+	var out []Route
+	err := chi.Walk(s.router, func(method, pattern string, _ http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		rt := Route{Method: method, Pattern: pattern, Middlewares: make([]string, 0, len(middlewares))}
+		for _, mw := range middlewares {
+			rt.Middlewares = append(rt.Middlewares, funcName(mw))
+		}
+		out = append(out, rt)
+		return nil
+	})
+	slices.SortFunc(out, func(a, b Route) int {
+		if c := strings.Compare(a.Pattern, b.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method, b.Method)
+	})
+	return out, err
+}
+
+// funcName names a middleware after the function that made it, e.g.
+// "httpserver.(*Server).requireAdmin" or "httpserver.RateLimitMiddleware".
+func funcName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "?"
+	}
+	name := f.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	// Closures returned by constructors are named Constructor.func1.
+	for {
+		i := strings.LastIndex(name, ".func")
+		if i < 0 || strings.ContainsAny(name[i+len(".func"):], ".()") {
+			break
+		}
+		name = name[:i]
+	}
+	return name
+}
+
+// handleRoutes dumps the route table for debugging, as JSON or, with
+// ?format=text, as an aligned table.
+func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	routes, err := s.Routes()
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if r.URL.Query().Get("format") != "text" {
+		writeJSON(w, http.StatusOK, routes)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, rt := range routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", rt.Method, rt.Pattern, strings.Join(rt.Middlewares, " "))
+	}
+	_ = tw.Flush()
+}
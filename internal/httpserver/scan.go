@@ -0,0 +1,104 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// scanTimeout bounds a single background scan, including store access.
+const scanTimeout = time.Minute
+
+// scanAsync scans a freshly created paste in the background and quarantines
+// it when the scanner flags it. Scanner errors leave the paste readable.
+func (s *Server) scanAsync(ctx context.Context, id string) {
+	if s.scanner == nil {
+		return
+	}
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), scanTimeout)
+		defer cancel()
+		s.scanPaste(ctx, id)
+	}()
+}
+
+func (s *Server) scanPaste(ctx context.Context, id string) {
+	paste, ok := s.scanTarget(ctx, id)
+	if !ok {
+		return
+	}
+	verdict, err := s.scanner.Scan(ctx, paste.Content, paste.Metadata)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(ctx, "scan failed", "id", id, "error", err)
+		}
+		return
+	}
+	if !verdict.Flagged {
+		return
+	}
+	// The paste may have changed while it was scanned. Saving the copy read
+	// above would bring back a deleted paste or undo an edit, so quarantine
+	// the current one, unless its content was replaced: the edit is scanned
+	// on its own.
+	current, ok := s.scanTarget(ctx, id)
+	if !ok || current.Content != paste.Content {
+		return
+	}
+	if err := s.quarantinePaste(ctx, current, verdict.Reason, actorScanner); err != nil && s.logger != nil {
+		s.logger.WarnContext(ctx, "scan: quarantine paste", "id", id, "error", err)
+	}
+}
+
+// scanTarget loads the paste a background scan is about. Pastes deleted or
+// expired meanwhile leave it nothing to do.
+func (s *Server) scanTarget(ctx context.Context, id string) (*storage.Paste, bool) {
+	paste, err := s.store.Get(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, false
+	}
+	if err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(ctx, "scan: load paste", "id", id, "error", err)
+		}
+		return nil, false
+	}
+	if paste.Deleted() || paste.HasExpiration() && s.nowTime().After(paste.ExpiresAt) {
+		return nil, false
+	}
+	return paste, true
+}
+
+// scanInput scans content before it is saved when flagged content is
+// refused rather than quarantined. Refusals are reported as events, so
+// admins hear of them; scanner errors let the content through.
+func (s *Server) scanInput(ctx context.Context, in *pasteInput) error {
+	if s.scanner == nil || !s.scanReject {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+	verdict, err := s.scanner.Scan(ctx, in.Content, in.Metadata)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(ctx, "scan failed", "error", err)
+		}
+		return nil
+	}
+	if !verdict.Flagged {
+		return nil
+	}
+	s.emit(ctx, events.Event{Type: events.PasteRejected, Reason: verdict.Reason, Actor: actorScanner})
+	return inputError("This paste was rejected by the content scanner")
+}
+
+// Wait blocks until background work started by requests, such as content
+// scans and event delivery, has finished.
+func (s *Server) Wait() {
+	s.background.Wait()
+}
@@ -0,0 +1,94 @@
+package httpserver
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"tiny-pastebin/internal/security"
+	"tiny-pastebin/internal/storage"
+)
+
+// With SealProtected set, the content of password-protected pastes is stored
+// encrypted under a key derived from the password, so reading it from the
+// store takes the password. Readers who unlock a paste keep its key in a
+// signed cookie scoped to the paste, beside its auth cookie.
+
+// sealKeyTTL bounds how long a browser keeps the key of a sealed paste.
+const sealKeyTTL = 24 * time.Hour
+
+var errSealKeyMissing = errors.New("sealed paste key missing")
+
+func sealKeyCookie(id string) string {
+	return "key_" + id
+}
+
+// sealContent encrypts the content of a new protected paste in place.
+func (s *Server) sealContent(paste *storage.Paste, password string) error {
+	envelope, _, err := s.argon.Seal(password, paste.Content)
+	if err != nil {
+		return err
+	}
+	paste.Content = envelope
+	paste.Size = len(envelope)
+	paste.Sealed = true
+	return nil
+}
+
+// rememberSealKey derives the key of a sealed paste from the password its
+// reader just entered and keeps it in their browser.
+func (s *Server) rememberSealKey(w http.ResponseWriter, r *http.Request, paste *storage.Paste, password string) error {
+	if !paste.Sealed {
+		return nil
+	}
+	key, err := security.ContentKey(paste.Content, password)
+	if err != nil {
+		return err
+	}
+	ttl := sealKeyTTL
+	if paste.HasExpiration() {
+		ttl = min(ttl, paste.ExpiresAt.Sub(s.nowTime()))
+	}
+	return s.setSealedCookie(w, r, sealKeyCookie(paste.ID), "/p/"+paste.ID, base64.RawURLEncoding.EncodeToString(key), ttl)
+}
+
+// unseal decrypts the content of a sealed paste in place, with the key
+// derived from password or, when password is empty, the key the reader's
+// browser holds.
+func (s *Server) unseal(r *http.Request, paste *storage.Paste, password string) error {
+	if !paste.Sealed {
+		return nil
+	}
+	var key []byte
+	if password != "" {
+		var err error
+		if key, err = security.ContentKey(paste.Content, password); err != nil {
+			return err
+		}
+	} else {
+		var encoded string
+		if !s.readSealedCookie(r, sealKeyCookie(paste.ID), &encoded) {
+			return errSealKeyMissing
+		}
+		var err error
+		if key, err = base64.RawURLEncoding.DecodeString(encoded); err != nil {
+			return errSealKeyMissing
+		}
+	}
+	content, err := security.OpenContent(paste.Content, key)
+	if err != nil {
+		return err
+	}
+	paste.Content = content
+	return nil
+}
+
+// unlocked reports whether the reader may see a protected paste, decrypting
+// its content when it is sealed. Unprotected pastes are always unlocked.
+func (s *Server) unlocked(r *http.Request, paste *storage.Paste) bool {
+	if paste.PasswordHash == "" {
+		return true
+	}
+	return s.hasAuth(r, paste.ID) && s.unseal(r, paste, "") == nil
+}
@@ -0,0 +1,77 @@
+package httpserver
+
+import (
+	"fmt"
+	"strings"
+
+	"tiny-pastebin/internal/secrets"
+)
+
+// SecretPolicy decides what happens to new pastes that look like they hold
+// credentials, such as cloud access keys or private keys.
+type SecretPolicy string
+
+const (
+	// SecretsWarn asks form creators to confirm before publishing and lists
+	// what was found in the warnings of API responses.
+	SecretsWarn SecretPolicy = "warn"
+	// SecretsExpire caps the paste's expiry at secretsExpire, and warns like
+	// SecretsWarn does.
+	SecretsExpire SecretPolicy = "expire"
+	// SecretsReject refuses the paste.
+	SecretsReject SecretPolicy = "reject"
+)
+
+// secretsExpire is the longest expiry SecretsExpire allows.
+const secretsExpire = "1h"
+
+func (p SecretPolicy) validate() error {
+	switch p {
+	case "", SecretsWarn, SecretsExpire, SecretsReject:
+		return nil
+	}
+	return fmt.Errorf("unknown secret policy %q (want %s, %s or %s)", p, SecretsWarn, SecretsExpire, SecretsReject)
+}
+
+// screenSecrets looks for credentials in validated input, noting them for
+// the handler and applying the secret policy.
+func (s *Server) screenSecrets(in *pasteInput) error {
+	if s.secretPolicy == "" {
+		return nil
+	}
+	in.secrets = secrets.Detect(in.Content)
+	if len(in.secrets) == 0 {
+		return nil
+	}
+	switch s.secretPolicy {
+	case SecretsReject:
+		return inputError(secretsMessage(in.secrets) + "; remove them before publishing")
+	case SecretsExpire:
+		if d := expireMap[in.Expire]; d == 0 || d > expireMap[secretsExpire] {
+			in.Expire = secretsExpire
+		}
+	}
+	return nil
+}
+
+// secretsConfirmed reports whether a form creator may go ahead with in:
+// under SecretsWarn they must tick the confirmation box first.
+func (s *Server) secretsConfirmed(in pasteInput, confirmed bool) bool {
+	return s.secretPolicy != SecretsWarn || len(in.secrets) == 0 || confirmed
+}
+
+// secretWarnings returns what API responses report about credentials in in.
+func (s *Server) secretWarnings(in pasteInput) []string {
+	if len(in.secrets) == 0 {
+		return nil
+	}
+	msg := secretsMessage(in.secrets)
+	if s.secretPolicy == SecretsExpire {
+		msg += "; its expiry was capped at 1 hour"
+	}
+	return []string{msg}
+}
+
+func secretsMessage(kinds []string) string {
+	return "This paste looks like it contains credentials (" + strings.Join(kinds, ", ") + ")"
+}
@@ -12,13 +12,22 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
+	"tiny-pastebin/internal/apikey"
+	"tiny-pastebin/internal/diagram"
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/filter"
 	"tiny-pastebin/internal/id"
+	"tiny-pastebin/internal/ipacl"
+	"tiny-pastebin/internal/scan"
+	"tiny-pastebin/internal/security"
 	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/tracing"
 	"tiny-pastebin/web"
 )
 
@@ -28,25 +37,237 @@ type Config struct {
 	IDGenerator  *id.Generator
 	MaxBytes     int
 	RateLimiter  *RateLimiter
-	TrustProxy   bool
 	BaseURL      string
 	Logger       *slog.Logger
 	CookieSecret []byte
+	// TrustedProxies are the reverse proxies whose forwarding headers are
+	// believed.
+	TrustedProxies TrustedProxies
+	// JWT, when set, accepts JSON Web Tokens as API bearer tokens.
+	JWT *JWTAuth
+	// Site, when it has users or a token, makes the whole instance require
+	// credentials.
+	Site *SiteAccess
+	// AdminListener serves the dashboard and the admin API only through
+	// AdminHandler, for a separate listener requiring client certificates.
+	AdminListener bool
+	// Tracer, when set, records requests, the store calls they make and
+	// janitor sweeps as trace spans.
+	Tracer *tracing.Tracer
+	// Privacy keeps client addresses out of storage and logs: they are
+	// replaced by hashes under a salt rotated daily, rate limits and
+	// password lockouts are keyed by those hashes, request log lines are
+	// scrubbed, and addresses are not passed to the challenge provider.
+	Privacy bool
+	// PreviousCookieSecrets are retired cookie secrets. Cookies, CSRF and
+	// claim tokens signed with them are still accepted, so rotating
+	// CookieSecret does not sign everyone out at once; only CookieSecret
+	// signs new values.
+	PreviousCookieSecrets [][]byte
+	// ShortURL is a separate short domain (e.g. "https://pst.example") used
+	// only in canonical paste links and QR codes, as ShortURL/<id>. Requests
+	// arriving on its host are redirected to the matching page under BaseURL,
+	// which is required.
+	ShortURL string
+	// AdminToken enables the admin API when set; requests must present it as a bearer token.
+	AdminToken string
+	// MetadataLinks maps metadata keys to URL templates containing {value}.
+	MetadataLinks map[string]string
+	// DefaultSyntax is preselected on the create form; defaults to plaintext,
+	// or the first syntax left when plaintext is hidden.
+	DefaultSyntax string
+	// HiddenSyntaxes are removed from the form and rejected on create.
+	HiddenSyntaxes []string
+	// RawOnlyBytes is the size above which the view page links to the raw
+	// content instead of rendering it. Zero disables the threshold.
+	RawOnlyBytes int
+	// DisablePreviews omits OpenGraph/Twitter card tags from view pages.
+	DisablePreviews bool
+	// IndexPastes allows search engines to index pastes unless a paste opts
+	// out individually. By default every page is marked noindex.
+	IndexPastes bool
+	// RobotsTxt overrides the generated /robots.txt body.
+	RobotsTxt string
+	// Login enables sign-in through an external identity provider.
+	Login LoginProvider
+	// AdminUsers lists signed-in identities (e.g. "oidc:<subject>") granted
+	// access to the admin dashboard.
+	AdminUsers []string
+	// Challenge, when set, must be passed by anonymous creators: the form
+	// shows its widget, and API requests without an API key send the solved
+	// response in X-Challenge-Response.
+	Challenge Challenge
+	// GeoIP, when set, applies per-country rules to creating pastes.
+	GeoIP *GeoPolicy
+	// BotCheck, when set, silently drops create form submissions that look
+	// automated.
+	BotCheck *BotCheck
+	// Filter screens new pastes before they are saved, rejecting,
+	// quarantining or flagging them for review; admins manage its banned
+	// content hashes at /admin/filter.
+	Filter *filter.Filter
+	// IPAccess refuses clients outside its allowed ranges or inside its
+	// denied ones, before any other handling including rate limiting.
+	IPAccess *ipacl.List
+	// Secrets decides what happens to new pastes that look like they hold
+	// credentials; empty skips the check.
+	Secrets SecretPolicy
+	// SealProtected stores the content of password-protected pastes
+	// encrypted under a key derived from the password, so it cannot be read
+	// from the store, even by admins, without the password.
+	SealProtected bool
+	// Argon sets the Argon2id cost of new paste password hashes and sealed
+	// content; zero uses security.DefaultParams. Hashes made with weaker
+	// parameters are upgraded when their password is next entered.
+	Argon security.Params
+	// Scanner inspects new pastes in the background; flagged pastes are
+	// quarantined and hidden from readers.
+	Scanner scan.Scanner
+	// ScanReject runs Scanner before new content is saved instead, refusing
+	// flagged content outright.
+	ScanReject bool
+	// Diagrams renders fenced mermaid and plantuml blocks of markdown pastes
+	// to SVG; the results are cached by content hash. Nil shows them as text.
+	Diagrams diagram.Renderer
+	// Events receives paste state transitions such as quarantine and release.
+	Events events.Sink
+	// Ingest configures webhook endpoints that turn deliveries into pastes.
+	Ingest []IngestEndpoint
+	// PasswordAttempts is how many wrong passwords one client may try on one
+	// paste before it is locked out; zero means 5. The first lockout lasts
+	// PasswordBackoff (default 1s) and doubles with each further failure up
+	// to PasswordBackoffMax (default 15m). Any clients guessing at one paste,
+	// and one client guessing across pastes, get four times as many attempts
+	// before the same backoff applies to them.
+	PasswordAttempts   int
+	PasswordBackoff    time.Duration
+	PasswordBackoffMax time.Duration
+	// PasswordPasteMax caps the lockout of a paste across clients, which
+	// keeps out readers who know the password too; zero means 30s and a
+	// negative value turns that lockout off, leaving guessing at one paste
+	// from many addresses to the other limits.
+	PasswordPasteMax time.Duration
+	// TermsOfService, when set, must be acknowledged before creating pastes:
+	// once per browser through a checkbox remembered in a signed cookie, and
+	// on every API create through the X-Accept-Tos header. It is shown as
+	// plain text at /terms.
+	TermsOfService string
+	// Announcement is an initial banner shown above every page until a reader
+	// dismisses it. The admin API can replace or clear it at runtime.
+	Announcement string
+	// Quota caps what the store may hold; see QuotaPolicy for what happens
+	// when it is reached.
+	Quota Quota
+	// CreatorQuota limits what each client address may create.
+	CreatorQuota CreatorQuota
+	// SuggestIDs offers near-miss IDs of recent public pastes on paste 404s.
+	SuggestIDs bool
+	// DeleteGrace keeps deleted pastes hidden but recoverable for this long
+	// before the janitor purges them; admins can undelete them meanwhile.
+	// Zero deletes pastes immediately.
+	DeleteGrace time.Duration
+	// AuditRetention is how long entries are kept when the store keeps an
+	// audit log; the janitor prunes older ones. Zero keeps them forever.
+	AuditRetention time.Duration
+	// Archive is where the store passed to New keeps expired pastes, when it
+	// was wrapped with storage.WithArchive. It enables the admin API for
+	// listing and restoring them.
+	Archive storage.Store
+	// TombstoneWindow is how long links to expired or deleted pastes answer
+	// 410 Gone, saying when the paste went, instead of 404. Zero disables
+	// tombstones.
+	TombstoneWindow time.Duration
+	// Branding overrides the instance name, favicon, logo and footer links.
+	Branding Branding
+	// Metrics serves Prometheus gauges at /metrics. The endpoint is not
+	// authenticated, so expose it only to the scraper.
+	Metrics bool
+	// PublicAPI serves anonymous read-only endpoints under /api/v1/public
+	// for third-party frontends and bots: the public pastes listed on
+	// /recent, their metadata and raw content. PublicRateLimiter, when set,
+	// throttles each client on them on top of RateLimiter.
+	PublicAPI         bool
+	PublicRateLimiter *RateLimiter
+	// PlainAgents maps User-Agent product names such as "curl" or "lynx" to
+	// how paste pages answer them, so `curl https://host/p/abc` just works:
+	// "raw" serves the content as /raw does and "text" a plain-text page
+	// with the paste's details above the content. Nil uses the built-in
+	// list of command-line clients and text-mode browsers; an empty map
+	// always serves HTML.
+	PlainAgents map[string]string
 }
 
 // Server wraps HTTP handling logic.
 type Server struct {
-	store        storage.Store
-	idGen        *id.Generator
-	router       chi.Router
-	templates    *template.Template
-	maxBytes     int
-	limiter      *RateLimiter
-	trustProxy   bool
-	baseURL      *url.URL
-	logger       *slog.Logger
-	cookieSecret []byte
-	now          func() time.Time
+	store         storage.Store
+	content       storage.ContentStore
+	idGen         *id.Generator
+	router        chi.Router
+	templates     *template.Template
+	maxBytes      int
+	limiter       *RateLimiter
+	publicAPI     bool
+	publicLimiter *RateLimiter
+	proxies       TrustedProxies
+	site          *SiteAccess
+	jwt           *JWTAuth
+	// adminListener keeps admin routes and credentials off Handler.
+	adminListener bool
+	tracer        *tracing.Tracer
+	// privacy is set in privacy mode and salts ipHash.
+	privacy         *dailySalt
+	baseURL         *url.URL
+	shortURL        *url.URL
+	logger          *slog.Logger
+	cookieSecret    []byte
+	oldSecrets      [][]byte
+	adminToken      string
+	adminUsers      map[string]bool
+	metadataLinks   map[string]string
+	syntaxes        []string
+	defaultSyntax   string
+	rawOnlyBytes    int
+	plainAgents     map[string]string
+	formTokens      *formTokens
+	drafts          *draftStore
+	syntaxStats     *syntaxStats
+	passwordGuard   *passwordGuard
+	idempotencyKeys *idempotencyKeys
+	announcement    *announcement
+	terms           *terms
+	upstream        *upstreamFetcher
+	brand           *brand
+	quota           Quota
+	creatorQuota    CreatorQuota
+	suggestIDs      bool
+	deleteGrace     time.Duration
+	archive         storage.Store
+	tombstones      *tombstones
+	disablePreviews bool
+	noIndexPastes   bool
+	robotsTxt       string
+	login           LoginProvider
+	challenge       Challenge
+	botCheck        *BotCheck
+	geo             *GeoPolicy
+	filter          *filter.Filter
+	secretPolicy    SecretPolicy
+	sealProtected   bool
+	argon           security.Params
+	ipAccess        *ipacl.List
+	keys            storage.APIKeyStore
+	reports         storage.ReportStore
+	audit           storage.AuditStore
+	auditRetention  time.Duration
+	scanner         scan.Scanner
+	scanReject      bool
+	diagrams        diagram.Renderer
+	events          events.Sink
+	ingest          []ingestEndpoint
+	metrics         bool
+	background      sync.WaitGroup
+	claimMu         sync.Mutex
+	now             func() time.Time
 }
 
 // New constructs a new Server instance.
@@ -67,20 +288,7 @@ func New(cfg Config) (*Server, error) {
 			}
 			return t.Local().Format(time.RFC1123)
 		},
-		"formatSize": func(size int) string {
-			if size < 1024 {
-				return fmt.Sprintf("%d B", size)
-			}
-			const unit = 1024.0
-			kb := float64(size)
-			for _, suffix := range []string{"KB", "MB", "GB"} {
-				kb /= unit
-				if kb < unit {
-					return fmt.Sprintf("%.1f %s", kb, suffix)
-				}
-			}
-			return fmt.Sprintf("%d B", size)
-		},
+		"formatSize": formatSize,
 	}).ParseFS(web.Templates, "templates/*.tmpl")
 	if err != nil {
 		return nil, fmt.Errorf("parse templates: %w", err)
@@ -98,6 +306,24 @@ func New(cfg Config) (*Server, error) {
 		parsedBase.Path = strings.TrimSuffix(parsedBase.Path, "/")
 	}
 
+	var parsedShort *url.URL
+	if cfg.ShortURL != "" {
+		if parsedBase == nil {
+			return nil, errors.New("short url requires a base url")
+		}
+		parsedShort, err = url.Parse(cfg.ShortURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid short url: %w", err)
+		}
+		if parsedShort.Scheme == "" || parsedShort.Host == "" {
+			return nil, errors.New("short url must include scheme and host")
+		}
+		if strings.EqualFold(parsedShort.Host, parsedBase.Host) {
+			return nil, errors.New("short url must use a different host than the base url")
+		}
+		parsedShort.Path = strings.TrimSuffix(parsedShort.Path, "/")
+	}
+
 	secret := cfg.CookieSecret
 	if len(secret) == 0 {
 		secret = make([]byte, 32)
@@ -106,18 +332,166 @@ func New(cfg Config) (*Server, error) {
 		}
 	}
 
-	srv := &Server{
-		store:        cfg.Store,
-		idGen:        cfg.IDGenerator,
-		router:       chi.NewRouter(),
-		templates:    tmpl,
-		maxBytes:     cfg.MaxBytes,
-		limiter:      cfg.RateLimiter,
-		trustProxy:   cfg.TrustProxy,
-		baseURL:      parsedBase,
-		logger:       cfg.Logger,
-		cookieSecret: secret,
-		now:          time.Now,
+	links, err := parseMetadataLinks(cfg.MetadataLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	syntaxes, defaultSyntax, err := resolveSyntaxes(cfg.DefaultSyntax, cfg.HiddenSyntaxes)
+	if err != nil {
+		return nil, err
+	}
+
+	ingest, err := parseIngestEndpoints(cfg.Ingest, syntaxes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Quota.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Secrets.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Argon == (security.Params{}) {
+		cfg.Argon = security.DefaultParams
+	}
+	if err := cfg.Argon.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Quota.Policy == "" {
+		cfg.Quota.Policy = QuotaEvict
+	}
+	if cfg.CreatorQuota.MaxPastes < 0 || cfg.CreatorQuota.MaxBytes < 0 || cfg.CreatorQuota.Window < 0 {
+		return nil, errors.New("creator quota limits must not be negative")
+	}
+	if cfg.CreatorQuota.Window == 0 {
+		cfg.CreatorQuota.Window = 24 * time.Hour
+	}
+	if cfg.DeleteGrace < 0 {
+		return nil, errors.New("delete grace period must not be negative")
+	}
+	if cfg.AuditRetention < 0 {
+		return nil, errors.New("audit retention must not be negative")
+	}
+	// srv is assigned below; the hooks only run once it is.
+	var srv *Server
+	hooks := []storage.Hooks{storage.StampUpdates(func() time.Time { return srv.nowTime() })}
+	tombs := newTombstones(cfg.TombstoneWindow)
+	if tombs != nil {
+		hooks = append(hooks, tombs.hooks())
+	}
+	store := storage.WithHooks(cfg.Store, hooks...)
+
+	brand, err := newBrand(cfg.Branding)
+	if err != nil {
+		return nil, fmt.Errorf("branding: %w", err)
+	}
+
+	adminUsers := make(map[string]bool, len(cfg.AdminUsers))
+	for _, u := range cfg.AdminUsers {
+		adminUsers[u] = true
+	}
+
+	logger := cfg.Logger
+	if logger != nil {
+		logger = slog.New(requestIDHandler{logger.Handler()})
+	}
+
+	plainAgents, err := normalizePlainAgents(cfg.PlainAgents)
+	if err != nil {
+		return nil, err
+	}
+
+	robots := cfg.RobotsTxt
+	if robots == "" {
+		robots = defaultRobotsTxt(!cfg.IndexPastes)
+	}
+
+	srv = &Server{
+		store:           store,
+		idGen:           cfg.IDGenerator,
+		router:          chi.NewRouter(),
+		templates:       tmpl,
+		maxBytes:        cfg.MaxBytes,
+		limiter:         cfg.RateLimiter,
+		publicAPI:       cfg.PublicAPI,
+		publicLimiter:   cfg.PublicRateLimiter,
+		proxies:         cfg.TrustedProxies,
+		baseURL:         parsedBase,
+		shortURL:        parsedShort,
+		logger:          logger,
+		cookieSecret:    secret,
+		oldSecrets:      cfg.PreviousCookieSecrets,
+		adminToken:      cfg.AdminToken,
+		adminUsers:      adminUsers,
+		metadataLinks:   links,
+		syntaxes:        syntaxes,
+		defaultSyntax:   defaultSyntax,
+		rawOnlyBytes:    cfg.RawOnlyBytes,
+		plainAgents:     plainAgents,
+		formTokens:      newFormTokens(secret, cfg.PreviousCookieSecrets),
+		drafts:          newDraftStore(),
+		syntaxStats:     newSyntaxStats(),
+		passwordGuard:   newPasswordGuard(cfg.PasswordAttempts, cfg.PasswordBackoff, cfg.PasswordBackoffMax, cfg.PasswordPasteMax),
+		idempotencyKeys: newIdempotencyKeys(),
+		announcement:    &announcement{message: strings.TrimSpace(cfg.Announcement)},
+		terms:           newTerms(cfg.TermsOfService),
+		upstream:        newUpstreamFetcher(false),
+		brand:           brand,
+		quota:           cfg.Quota,
+		creatorQuota:    cfg.CreatorQuota,
+		suggestIDs:      cfg.SuggestIDs,
+		deleteGrace:     cfg.DeleteGrace,
+		archive:         cfg.Archive,
+		tombstones:      tombs,
+		disablePreviews: cfg.DisablePreviews,
+		noIndexPastes:   !cfg.IndexPastes,
+		robotsTxt:       robots,
+		login:           cfg.Login,
+		challenge:       cfg.Challenge,
+		botCheck:        cfg.BotCheck,
+		filter:          cfg.Filter,
+		secretPolicy:    cfg.Secrets,
+		sealProtected:   cfg.SealProtected,
+		argon:           cfg.Argon,
+		ipAccess:        cfg.IPAccess,
+		scanner:         cfg.Scanner,
+		scanReject:      cfg.ScanReject,
+		events:          cfg.Events,
+		auditRetention:  cfg.AuditRetention,
+		ingest:          ingest,
+		metrics:         cfg.Metrics,
+		now:             time.Now,
+	}
+	if keys, ok := storage.As[storage.APIKeyStore](cfg.Store); ok {
+		srv.keys = keys
+	}
+	if reports, ok := storage.As[storage.ReportStore](cfg.Store); ok {
+		srv.reports = reports
+	}
+	if audit, ok := storage.As[storage.AuditStore](cfg.Store); ok {
+		srv.audit = audit
+	}
+	if cfg.Diagrams != nil {
+		srv.diagrams = diagram.NewCache(cfg.Diagrams, diagramCacheSize)
+	}
+	if cs, ok := storage.Streamer(store); ok {
+		srv.content = cs
+	}
+	if cfg.Privacy {
+		srv.privacy = &dailySalt{}
+	}
+	if cfg.JWT != nil && cfg.JWT.Verifier != nil {
+		srv.jwt = cfg.JWT
+	}
+	if cfg.Site.enabled() {
+		srv.site = cfg.Site
+	}
+	srv.adminListener = cfg.AdminListener
+	srv.tracer = cfg.Tracer
+	if cfg.GeoIP != nil && cfg.GeoIP.Lookup != nil {
+		srv.geo = cfg.GeoIP
 	}
 	srv.routes()
 	return srv, nil
@@ -131,43 +505,177 @@ func (s *Server) Handler() http.Handler {
 func (s *Server) routes() {
 	r := s.router
 
-	r.Use(middleware.RequestID)
-	if s.trustProxy {
-		r.Use(middleware.RealIP)
+	if s.shortURL != nil {
+		r.Use(s.redirectShortDomain)
+	}
+	r.Use(RequestIDMiddleware(s.proxies))
+	if s.tracer != nil {
+		r.Use(s.traceRequest)
+	}
+	r.Use(IPAccessMiddleware(s.ipAccess, s.proxies))
+	if s.geo != nil {
+		r.Use(s.locateCountry)
 	}
-	r.Use(RateLimitMiddleware(s.limiter, func(r *http.Request) string {
-		return ClientIP(r, s.trustProxy)
-	}))
+	r.Use(s.authenticateAPIKey)
+	if s.jwt != nil {
+		r.Use(s.authenticateJWT)
+	}
+	r.Use(RateLimitMiddleware(s.limiter, s.rateLimitKey))
 	r.Use(middleware.Compress(5, "text/html", "text/plain", "application/javascript", "text/css"))
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Logger)
+	r.Use(s.accessLog)
+	if s.site != nil {
+		r.Use(s.requireSiteAccess)
+	}
 
 	fileServer := http.FileServer(http.FS(web.Static))
 	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
-	r.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
-		data, err := web.Static.ReadFile("static/favicon.ico")
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
-		w.Header().Set("Content-Type", "image/x-icon")
-		_, _ = w.Write(data)
-	})
+	r.Get("/favicon.ico", s.handleFavicon)
+	if s.brand.logo != nil {
+		r.Get("/brand/logo", s.handleLogo)
+	}
+
+	r.Get("/robots.txt", s.handleRobots)
+	if s.terms != nil {
+		r.Get("/terms", s.handleTerms)
+	}
 
 	r.Get("/", s.handleIndex)
-	r.Post("/pastes", s.handleCreate)
+	r.With(s.geoCreate).Post("/pastes", s.handleCreate)
+	r.Post("/drafts", s.handleSaveDraft)
+	r.Get("/drafts/{token}", s.handleGetDraft)
+	r.Get("/recent", s.handleRecent)
+	r.Get("/feed.atom", s.handleFeed)
+	r.Get("/stats", s.handleStats)
+	r.Get("/mine", s.handleMine)
+	r.Post("/mine/delete", s.handleMineDelete)
+	r.Post("/mine/claim", s.handleMineClaim)
+	r.Post("/announcement/dismiss", s.handleDismissAnnouncement)
 
 	r.Route("/p/{id}", func(pr chi.Router) {
 		pr.Get("/", s.handleView)
+		pr.Head("/", s.handleView)
 		pr.Post("/", s.handlePassword)
 		pr.Get("/raw", s.handleRaw)
+		pr.Head("/raw", s.handleRaw)
 		pr.Get("/qr", s.handleQR)
+		pr.Get("/embed", s.handleEmbed)
+		pr.Get("/diagrams/{n}", s.handleDiagram)
+		pr.Get("/upstream", s.handleUpstreamDiff)
+		pr.Post("/delete", s.handleDelete)
+		pr.Post("/share", s.handleShare)
+		pr.Get("/view/{token}", s.handleCapabilityView)
+		pr.Get("/edit/{token}", s.handleEditForm)
+		pr.Post("/edit/{token}", s.handleEdit)
+		pr.Get("/delete/{token}", s.handleDeleteForm)
+		pr.Post("/delete/{token}", s.handleCapabilityDelete)
+		if s.reports != nil {
+			pr.Get("/report", s.handleReportForm)
+			pr.Post("/report", s.handleReport)
+		}
 	})
+	r.Get("/oembed", s.handleOEmbed)
+	if len(s.ingest) > 0 {
+		r.Post("/ingest/{key}", s.handleIngest)
+	}
+
+	if s.login != nil {
+		r.Get("/auth/login", s.handleLogin)
+		r.Get("/auth/callback", s.handleLoginCallback)
+		r.Post("/auth/logout", s.handleLogout)
+	}
+
+	if s.adminEnabled() {
+		r.With(s.requireAdminListener).Get("/admin/login", s.handleAdminLoginForm)
+		r.With(s.requireAdminListener).Post("/admin/login", s.handleAdminLogin)
+		r.Route("/admin", func(ar chi.Router) {
+			ar.Use(s.requireAdminListener)
+			ar.Use(s.requireAdminPage)
+			ar.Use(s.auditAdmin)
+			ar.Get("/", s.handleAdminDashboard)
+			ar.Get("/stats", s.handleAdminStats)
+			ar.Post("/logout", s.handleAdminLogout)
+			ar.Post("/pastes/delete", s.handleAdminBulkDelete)
+			ar.Post("/pastes/{id}/expiry", s.handleAdminExpiry)
+			ar.Post("/pastes/{id}/release", s.handleAdminRelease)
+			ar.Post("/pastes/{id}/undelete", s.handleAdminUndelete)
+			ar.Post("/pastes/{id}/reviewed", s.handleAdminReviewed)
+			ar.Post("/pastes/{id}/takedown", s.handleAdminTakeDownPaste)
+			ar.Post("/pastes/{id}/purge", s.handleAdminPurge)
+			ar.Post("/identities/erase", s.handleAdminEraseIdentity)
+			if s.filter != nil {
+				ar.Get("/filter", s.handleAdminFilter)
+				ar.Post("/filter", s.handleAdminBan)
+				ar.Post("/filter/{hash}/unban", s.handleAdminUnban)
+			}
+			if s.reports != nil {
+				ar.Get("/reports", s.handleAdminReports)
+				ar.Post("/reports/{id}/takedown", s.handleAdminTakedown)
+				ar.Post("/reports/{id}/dismiss", s.handleAdminDismiss)
+			}
+			if s.keys != nil {
+				ar.Get("/keys", s.handleAdminKeys)
+				ar.Post("/keys", s.handleAdminCreateKey)
+				ar.Post("/keys/{id}/revoke", s.handleAdminRevokeKey)
+			}
+			if s.audit != nil {
+				ar.Get("/audit", s.handleAdminAudit)
+			}
+		})
+	}
 
-	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
+	r.Route("/api/v1", func(ar chi.Router) {
+		ar.Get("/openapi.json", s.handleOpenAPI)
+		ar.Get("/limits", s.handleLimits)
+		ar.Get("/stats", s.handleAPIStats)
+		if s.publicAPI {
+			ar.Route("/public", s.publicRoutes)
+		}
+		ar.With(requireScope(apikey.ScopeCreate), s.geoCreate).Post("/pastes", s.idempotent(int64(s.maxBytes)*2+8192, s.handleAPICreate))
+		ar.With(requireScope(apikey.ScopeCreate), s.geoCreate).Post("/pastes/batch", s.idempotent((int64(s.maxBytes)*2+8192)*maxBatchPastes, s.handleAPIBatchCreate))
+		ar.With(requireScope(apikey.ScopeRead)).Get("/pastes/{id}", s.handleAPIGet)
+		ar.With(requireScope(apikey.ScopeDelete)).Delete("/pastes/{id}", s.handleAPIDelete)
+		ar.With(requireScope(apikey.ScopeRead)).Get("/pastes/{id}/exists", s.handleAPIExists)
+		ar.With(requireScope(apikey.ScopeRead)).Get("/pastes/{id}/export", s.handleAPIExport)
+		ar.With(requireScope(apikey.ScopeRead)).Head("/pastes/{id}/exists", s.handleAPIExists)
+		ar.With(requireScope(apikey.ScopeCreate)).Post("/pastes/{id}/claim", s.handleAPIClaim)
+		ar.With(requireScope(apikey.ScopeRead)).Get("/pastes/{id}/upstream", s.handleAPIUpstreamDiff)
+		ar.Group(func(admin chi.Router) {
+			admin.Use(s.requireAdminListener)
+			admin.Use(s.requireAdmin)
+			admin.Use(s.auditAdmin)
+			admin.Get("/pastes", s.handleSearch)
+			admin.Post("/pastes/{id}/quarantine", s.handleAPIQuarantine)
+			admin.Post("/pastes/{id}/release", s.handleAPIRelease)
+			admin.Get("/quarantine", s.handleQuarantineList)
+			admin.Post("/pastes/{id}/undelete", s.handleAPIUndelete)
+			admin.Post("/pastes/{id}/takedown", s.handleAPITakeDown)
+			admin.Post("/pastes/{id}/purge", s.handleAPIPurge)
+			admin.Get("/identities/pastes", s.handleIdentityPastes)
+			admin.Post("/identities/erase", s.handleEraseIdentity)
+			admin.Get("/deleted", s.handleDeletedList)
+			if s.archive != nil {
+				admin.Get("/archive", s.handleArchiveList)
+				admin.Post("/archive/{id}/restore", s.handleArchiveRestore)
+			}
+			admin.Get("/backup", s.handleExportBackup)
+			admin.Post("/backup", s.handleImportBackup)
+			admin.Get("/announcement", s.handleGetAnnouncement)
+			admin.Put("/announcement", s.handleSetAnnouncement)
+			admin.Delete("/announcement", s.handleClearAnnouncement)
+			admin.Get("/routes", s.handleRoutes)
+			if s.audit != nil {
+				admin.Get("/audit", s.handleAuditList)
+			}
+		})
 	})
+
+	if s.metrics {
+		r.Get("/metrics", s.handleMetrics)
+	}
+
+	r.Get("/healthz", s.handleHealth)
+	r.Get("/readyz", s.handleReady)
 }
 
 func (s *Server) authCookieName(id string) string {
@@ -175,17 +683,22 @@ func (s *Server) authCookieName(id string) string {
 }
 
 func (s *Server) signValue(id string) string {
-	mac := hmac.New(sha256.New, s.cookieSecret)
-	mac.Write([]byte(id))
-	return hex.EncodeToString(mac.Sum(nil))
+	return signWith(s.cookieSecret, id)
 }
 
 func (s *Server) verifySignature(id, sig string) bool {
-	expected := s.signValue(id)
-	if len(expected) != len(sig) {
-		return false
+	for _, secret := range s.cookieSecrets() {
+		if hmac.Equal([]byte(signWith(secret, id)), []byte(sig)) {
+			return true
+		}
 	}
-	return hmac.Equal([]byte(expected), []byte(sig))
+	return false
+}
+
+func signWith(secret []byte, id string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 func (s *Server) setAuthCookie(w http.ResponseWriter, r *http.Request, id string, expires time.Time) {
@@ -207,7 +720,15 @@ func (s *Server) setAuthCookie(w http.ResponseWriter, r *http.Request, id string
 	http.SetCookie(w, cookie)
 }
 
+// hasAuth reports whether r may read protected paste id, having entered
+// its password or opened a share link for it.
 func (s *Server) hasAuth(r *http.Request, id string) bool {
+	return s.hasPasswordAuth(r, id) || s.hasShareCookie(r, id)
+}
+
+// hasPasswordAuth reports whether r carries the cookie left by entering
+// paste id's password or opening its view link.
+func (s *Server) hasPasswordAuth(r *http.Request, id string) bool {
 	cookie, err := r.Cookie(s.authCookieName(id))
 	if err != nil {
 		return false
@@ -227,6 +748,34 @@ func (s *Server) clearAuthCookie(w http.ResponseWriter, id string) {
 	})
 }
 
+// ipHash returns a short keyed hash of addr so pastes can be grouped by
+// origin without storing client addresses.
+func (s *Server) ipHash(addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if s.privacy != nil {
+		return s.privacy.hash(addr, s.nowTime())
+	}
+	return s.sealMAC("ip-hash", addr)[:16]
+}
+
+// formatSize renders a byte count for people, e.g. "1.5 MB".
+func formatSize(size int) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+	const unit = 1024.0
+	kb := float64(size)
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		kb /= unit
+		if kb < unit {
+			return fmt.Sprintf("%.1f %s", kb, suffix)
+		}
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
 func (s *Server) isSecureRequest(r *http.Request) bool {
 	if r.TLS != nil {
 		return true
@@ -234,7 +783,7 @@ func (s *Server) isSecureRequest(r *http.Request) bool {
 	if s.baseURL != nil && s.baseURL.Scheme == "https" {
 		return true
 	}
-	if s.trustProxy {
+	if s.proxies.trusts(r) {
 		proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto"))
 		if proto == "https" {
 			return true
@@ -243,12 +792,34 @@ func (s *Server) isSecureRequest(r *http.Request) bool {
 	return false
 }
 
+// canonicalURL is the public link for paste id, or for the home page when id
+// is empty. Paste links use the short domain when one is configured.
 func (s *Server) canonicalURL(r *http.Request, id string) string {
+	if id == "" {
+		return s.absoluteURL(r, "/")
+	}
+	if s.shortURL != nil {
+		u := *s.shortURL
+		u.Path += "/" + id
+		return u.String()
+	}
+	return s.absoluteURL(r, "/p/"+id)
+}
+
+// absoluteURL resolves path against the configured base URL or the request host.
+func (s *Server) absoluteURL(r *http.Request, path string) string {
+	return s.absoluteURLQuery(r, path, nil)
+}
+
+// absoluteURLQuery is absoluteURL with a query string. The query is kept out
+// of path, where a base URL would escape its "?".
+func (s *Server) absoluteURLQuery(r *http.Request, path string, query url.Values) string {
 	if s.baseURL != nil {
 		u := *s.baseURL
-		if id != "" {
-			u.Path = strings.TrimSuffix(u.Path, "/") + "/p/" + id
+		if path != "/" {
+			u.Path = strings.TrimSuffix(u.Path, "/") + path
 		}
+		u.RawQuery = query.Encode()
 		return u.String()
 	}
 
@@ -260,9 +831,8 @@ func (s *Server) canonicalURL(r *http.Request, id string) string {
 	if host == "" {
 		host = "localhost"
 	}
-	path := "/"
-	if id != "" {
-		path = "/p/" + id
+	if len(query) > 0 {
+		path += "?" + query.Encode()
 	}
 	return fmt.Sprintf("%s://%s%s", scheme, host, path)
 }
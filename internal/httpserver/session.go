@@ -0,0 +1,112 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"tiny-pastebin/internal/oidc"
+)
+
+const (
+	sessionCookie  = "tp_session"
+	loginCookie    = "tp_login"
+	sessionTTL     = 7 * 24 * time.Hour
+	loginStateTTL  = 10 * time.Minute
+	oidcOwnerScope = "oidc:"
+)
+
+// LoginProvider authenticates users against an external identity provider.
+type LoginProvider interface {
+	AuthCodeURL(state, nonce, verifier string) string
+	Exchange(ctx context.Context, code, verifier, nonce string) (*oidc.Identity, error)
+}
+
+type session struct {
+	Owner   string `json:"o"`
+	Name    string `json:"n"`
+	Expires int64  `json:"e"`
+}
+
+type loginState struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+}
+
+// currentSession returns the signed-in user, if any.
+func (s *Server) currentSession(r *http.Request) (session, bool) {
+	var sess session
+	if !s.readSealedCookie(r, sessionCookie, &sess) {
+		return session{}, false
+	}
+	if sess.Owner == "" || s.nowTime().Unix() > sess.Expires {
+		return session{}, false
+	}
+	return sess, true
+}
+
+// ownerOf returns the owner identity for the request: the signed-in user,
+// else the anonymous creator cookie, else "".
+func (s *Server) ownerOf(r *http.Request) string {
+	if sess, ok := s.currentSession(r); ok {
+		return sess.Owner
+	}
+	return s.creatorOwner(r)
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	st := loginState{State: randomToken(), Nonce: randomToken(), Verifier: randomToken() + randomToken()}
+	if err := s.setSealedCookie(w, r, loginCookie, "/auth", st, loginStateTTL); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, s.login.AuthCodeURL(st.State, st.Nonce, st.Verifier), http.StatusFound)
+}
+
+func (s *Server) handleLoginCallback(w http.ResponseWriter, r *http.Request) {
+	var st loginState
+	ok := s.readSealedCookie(r, loginCookie, &st)
+	s.clearCookie(w, loginCookie, "/auth")
+	if !ok || st.State == "" || r.URL.Query().Get("state") != st.State {
+		s.render(w, r, http.StatusBadRequest, "error", errorPageData{Message: "Login failed"})
+		return
+	}
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		s.render(w, r, http.StatusUnauthorized, "error", errorPageData{Message: "Login failed"})
+		return
+	}
+	ident, err := s.login.Exchange(r.Context(), r.URL.Query().Get("code"), st.Verifier, st.Nonce)
+	if err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(r.Context(), "login exchange failed", "error", err)
+		}
+		s.render(w, r, http.StatusUnauthorized, "error", errorPageData{Message: "Login failed"})
+		return
+	}
+	sess := session{
+		Owner:   oidcOwnerScope + ident.Subject,
+		Name:    ident.Name,
+		Expires: s.nowTime().Add(sessionTTL).Unix(),
+	}
+	if err := s.setSealedCookie(w, r, sessionCookie, "/", sess, sessionTTL); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	s.clearCookie(w, sessionCookie, "/")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func randomToken() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
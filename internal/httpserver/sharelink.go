@@ -0,0 +1,137 @@
+package httpserver
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// Share links let a reader who unlocked a protected paste with its password
+// pass it on for a while without passing on the password. The link is
+// /p/{id}?exp=...&sig=..., signed over the paste and the expiry. Opening it
+// leaves a cookie that unlocks the paste until the same expiry, so the raw
+// and download links work too.
+
+// shareLinkChoices are the lifetimes offered for share links. A link never
+// outlives its paste.
+var shareLinkChoices = []expireOption{
+	{Value: "1h", Label: "1 hour", Duration: time.Hour},
+	{Value: "1d", Label: "1 day", Duration: 24 * time.Hour},
+	{Value: "7d", Label: "7 days", Duration: 7 * 24 * time.Hour},
+}
+
+func shareCookieName(id string) string {
+	return "share_" + id
+}
+
+func sharePayload(p *storage.Paste, exp int64) string {
+	return capabilityPayload(p) + "\x00" + strconv.FormatInt(exp, 10)
+}
+
+// shareable reports whether share links can be minted for p. Sealed
+// content can only be opened with the password itself.
+func shareable(p *storage.Paste) bool {
+	return p.PasswordHash != "" && !p.Sealed
+}
+
+// shareLink returns a link unlocking p until exp.
+func (s *Server) shareLink(r *http.Request, p *storage.Paste, exp time.Time) string {
+	unix := exp.Unix()
+	q := url.Values{}
+	q.Set("exp", strconv.FormatInt(unix, 10))
+	q.Set("sig", s.sealMAC("paste-share", sharePayload(p, unix)))
+	return s.absoluteURLQuery(r, "/p/"+p.ID, q)
+}
+
+// errShareLinkInvalid is returned for share links that were tampered with
+// or have expired.
+var errShareLinkInvalid = errors.New("this share link is invalid or has expired")
+
+// acceptShareLink checks the share link r was made with and, when it is
+// good, leaves the cookie unlocking p until the link expires.
+func (s *Server) acceptShareLink(w http.ResponseWriter, r *http.Request, p *storage.Paste) error {
+	query := r.URL.Query()
+	exp, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil || !shareable(p) || s.nowTime().Unix() >= exp ||
+		!s.macMatches("paste-share", sharePayload(p, exp), query.Get("sig")) {
+		return errShareLinkInvalid
+	}
+	value := strconv.FormatInt(exp, 10)
+	expires := time.Unix(exp, 0)
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareCookieName(p.ID),
+		Value:    value + "." + s.sealMAC("paste-share-cookie", p.ID+"\x00"+value),
+		Path:     "/p/" + p.ID,
+		Expires:  expires,
+		MaxAge:   int(expires.Sub(s.nowTime()).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   s.isSecureRequest(r),
+	})
+	return nil
+}
+
+// hasShareCookie reports whether r carries an unexpired share cookie for
+// paste id. The expiry is checked here, not left to the browser.
+func (s *Server) hasShareCookie(r *http.Request, id string) bool {
+	cookie, err := r.Cookie(shareCookieName(id))
+	if err != nil {
+		return false
+	}
+	value, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return false
+	}
+	exp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || s.nowTime().Unix() >= exp {
+		return false
+	}
+	return s.macMatches("paste-share-cookie", id+"\x00"+value, sig)
+}
+
+type sharePageData struct {
+	ID        string
+	Link      string
+	ExpiresAt time.Time
+}
+
+func (d sharePageData) PageTitle() string { return "Share link · Tiny Pastebin" }
+func (d sharePageData) NoIndex() bool     { return true }
+
+// handleShare mints a share link for a reader who unlocked the paste with
+// its password. Readers let in by a share link cannot mint more, so access
+// ends when their link expires.
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if !shareable(paste) || !s.hasPasswordAuth(r, paste.ID) {
+		s.render(w, r, http.StatusForbidden, "error", errorPageData{Message: "Unlock the paste with its password to share it"})
+		return
+	}
+	d := shareLinkChoices[0].Duration
+	for _, c := range shareLinkChoices {
+		if c.Value == r.PostFormValue("for") {
+			d = c.Duration
+		}
+	}
+	exp := s.nowTime().Add(d).Truncate(time.Second)
+	if paste.HasExpiration() && paste.ExpiresAt.Before(exp) {
+		exp = paste.ExpiresAt.Truncate(time.Second)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	s.render(w, r, http.StatusOK, "share", sharePageData{ID: paste.ID, Link: s.shareLink(r, paste, exp), ExpiresAt: exp})
+}
@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// redirectShortDomain sends requests for the short domain on to the full
+// site: ShortURL/<id> and anything below it go to the paste pages under
+// BaseURL, every other path to the same path there. Requests for any other
+// host pass through.
+func (s *Server) redirectShortDomain(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isShortHost(r.Host) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		target := r.URL.Path
+		if rest, ok := strings.CutPrefix(r.URL.Path, s.shortURL.Path+"/"); ok {
+			target = "/"
+			if rest != "" {
+				target = "/p/" + rest
+			}
+		}
+		u := *s.baseURL
+		u.Path = strings.TrimSuffix(u.Path, "/") + target
+		u.RawQuery = r.URL.RawQuery
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+}
+
+// isShortHost reports whether host names the short domain. A port is only
+// compared when the short URL names one.
+func (s *Server) isShortHost(host string) bool {
+	if s.shortURL.Port() == "" {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	return strings.EqualFold(host, s.shortURL.Host)
+}
@@ -0,0 +1,116 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// siteTokenHeader carries the shared access token of a private instance,
+// leaving the Authorization header to API keys and the admin token.
+const siteTokenHeader = "X-Access-Token"
+
+// SiteAccess makes the whole instance private. Every request, including raw
+// and QR endpoints, must then carry HTTP basic credentials of one of Users
+// or the shared Token. Browsers are prompted for basic credentials; the
+// token is accepted there as the password with any user name, or sent by
+// scripts in the X-Access-Token header. API keys, JWTs and the admin token
+// also grant access, so API clients need nothing more. Health checks and keyed
+// ingest endpoints stay open.
+type SiteAccess struct {
+	// Users maps user names to passwords.
+	Users map[string]string
+	Token string
+	// Realm names the instance in the browser's prompt. Defaults to
+	// "tinypaste".
+	Realm string
+}
+
+func (a *SiteAccess) enabled() bool {
+	return a != nil && (len(a.Users) > 0 || a.Token != "")
+}
+
+// allows reports whether the basic credentials or token grant access.
+// Secrets are compared as digests, in constant time.
+func (a *SiteAccess) allows(user, password, token string) bool {
+	if token != "" && a.Token != "" && secretEqual(token, a.Token) {
+		return true
+	}
+	if password == "" {
+		return false
+	}
+	if a.Token != "" && secretEqual(password, a.Token) {
+		return true
+	}
+	want, ok := a.Users[user]
+	return ok && secretEqual(password, want)
+}
+
+func secretEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// siteAccessExempt lists path prefixes reachable without site credentials:
+// probes, and ingest endpoints, which check their own keys.
+var siteAccessExempt = []string{"/healthz", "/readyz", "/ingest/"}
+
+// requireSiteAccess refuses requests without site credentials on a private
+// instance.
+func (s *Server) requireSiteAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range siteAccessExempt {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		user, password, _ := r.BasicAuth()
+		allowed := s.site.allows(user, password, r.Header.Get(siteTokenHeader)) || apiCaller(r)
+		if !allowed {
+			identity, ok := s.adminIdentity(r)
+			allowed = ok && identity == "bearer"
+		}
+		if allowed {
+			next.ServeHTTP(&privateCacheWriter{ResponseWriter: w}, r)
+			return
+		}
+		realm := s.site.Realm
+		if realm == "" {
+			realm = "tinypaste"
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+strings.ReplaceAll(realm, `"`, "")+`", charset="UTF-8"`)
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// privateCacheWriter turns "public" Cache-Control responses private, so
+// shared caches never hand a private instance's pages to strangers.
+type privateCacheWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *privateCacheWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		h := w.Header()
+		if cc, ok := strings.CutPrefix(h.Get("Cache-Control"), "public"); ok {
+			h.Set("Cache-Control", "private"+cc)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *privateCacheWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *privateCacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,115 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// purgePaste removes a paste for good, skipping any delete grace period.
+func (s *Server) purgePaste(ctx context.Context, id, actor string) error {
+	paste, err := s.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.removePaste(ctx, paste, actor)
+}
+
+// removePaste deletes paste from the store. Soft-deleted pastes were already
+// uncounted and reported when they were marked.
+func (s *Server) removePaste(ctx context.Context, paste *storage.Paste, actor string) error {
+	if err := s.store.Delete(ctx, paste.ID); err != nil {
+		return err
+	}
+	if paste.Deleted() {
+		s.tombstones.bury(paste.ID, paste.DeletedAt)
+	} else {
+		s.tombstones.bury(paste.ID, s.nowTime().UTC())
+		s.syntaxStats.remove(paste)
+		s.emit(ctx, events.Event{Type: events.PasteDeleted, PasteID: paste.ID, Actor: actor})
+	}
+	return nil
+}
+
+// undeletePaste restores a soft-deleted paste.
+func (s *Server) undeletePaste(ctx context.Context, paste *storage.Paste, actor string) error {
+	paste.DeletedAt = time.Time{}
+	paste.DeletedBy = ""
+	if err := s.store.Save(ctx, paste); err != nil {
+		return err
+	}
+	s.syntaxStats.add(paste)
+	s.emit(ctx, events.Event{Type: events.PasteRestored, PasteID: paste.ID, Actor: actor})
+	return nil
+}
+
+// purgeDeleted removes pastes soft-deleted longer than the grace period ago.
+func (s *Server) purgeDeleted(ctx context.Context, now time.Time) (int, error) {
+	pastes, err := s.store.List(ctx, storage.ListOptions{Deleted: true})
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now.Add(-s.deleteGrace)
+	purged := 0
+	for _, p := range pastes {
+		if p.DeletedAt.After(cutoff) {
+			continue
+		}
+		if err := s.store.Delete(ctx, p.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return purged, err
+		}
+		s.tombstones.bury(p.ID, p.DeletedAt)
+		purged++
+	}
+	return purged, nil
+}
+
+// handleDeletedList returns the soft-deleted pastes that can still be undeleted.
+func (s *Server) handleDeletedList(w http.ResponseWriter, r *http.Request) {
+	pastes, err := s.store.List(r.Context(), storage.ListOptions{Deleted: true})
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	out := make([]pasteSummary, 0, len(pastes))
+	for _, p := range pastes {
+		out = append(out, s.summarize(r, p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleAPIUndelete(w http.ResponseWriter, r *http.Request) {
+	s.apiTransition(w, r, func(ctx context.Context, p *storage.Paste) error {
+		if !p.Deleted() {
+			return nil
+		}
+		return s.undeletePaste(ctx, p, actorAdmin)
+	})
+}
+
+// handleAdminUndelete restores a soft-deleted paste from the dashboard.
+func (s *Server) handleAdminUndelete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	paste, err := s.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" has already been purged."), http.StatusSeeOther)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if paste.Deleted() {
+		if err := s.undeletePaste(r.Context(), paste, actorAdmin); err != nil {
+			s.serverError(w, r, err)
+			return
+		}
+	}
+	http.Redirect(w, r, adminReturnURL(r, "Restored "+id+"."), http.StatusSeeOther)
+}
@@ -0,0 +1,245 @@
+package httpserver
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// syntaxStats keeps per-syntax paste counts and sizes. It is loaded from the
+// store on first use and then updated as pastes are created, deleted and
+// expire, so the stats page never scans the store.
+type syntaxStats struct {
+	mu      sync.Mutex
+	loaded  bool
+	tallies map[string]syntaxTally
+	// expiring remembers what to subtract when a paste with an expiry lapses.
+	expiring map[string]expiringPaste
+}
+
+type syntaxTally struct {
+	Count int
+	Bytes int
+}
+
+type expiringPaste struct {
+	syntax string
+	size   int
+	at     time.Time
+}
+
+func newSyntaxStats() *syntaxStats {
+	return &syntaxStats{tallies: make(map[string]syntaxTally), expiring: make(map[string]expiringPaste)}
+}
+
+// load builds the tallies from the active pastes in store, once.
+func (st *syntaxStats) load(ctx context.Context, store storage.Store, now time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.loaded {
+		return nil
+	}
+	pastes, err := store.List(ctx, storage.ListOptions{ActiveAt: now})
+	if err != nil {
+		return err
+	}
+	for _, p := range pastes {
+		st.addLocked(p)
+	}
+	st.loaded = true
+	return nil
+}
+
+// add counts a newly created paste. Before the first load it is a no-op, as
+// loading will pick the paste up from the store.
+func (st *syntaxStats) add(p *storage.Paste) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.loaded {
+		st.addLocked(p)
+	}
+}
+
+func (st *syntaxStats) addLocked(p *storage.Paste) {
+	t := st.tallies[p.Syntax]
+	t.Count++
+	t.Bytes += p.Size
+	st.tallies[p.Syntax] = t
+	if p.HasExpiration() {
+		st.expiring[p.ID] = expiringPaste{syntax: p.Syntax, size: p.Size, at: p.ExpiresAt}
+	}
+}
+
+// remove uncounts a deleted paste unless it already expired out of the tallies.
+func (st *syntaxStats) remove(p *storage.Paste) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.loaded {
+		return
+	}
+	if e, ok := st.expiring[p.ID]; ok {
+		delete(st.expiring, p.ID)
+		st.subtractLocked(e.syntax, e.size)
+		return
+	}
+	if !p.HasExpiration() {
+		st.subtractLocked(p.Syntax, p.Size)
+	}
+}
+
+// reset drops the tallies so the next use reloads them from the store, for
+// bulk changes such as a backup restore that are cheaper to recount.
+func (st *syntaxStats) reset() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.loaded = false
+	st.tallies = make(map[string]syntaxTally)
+	st.expiring = make(map[string]expiringPaste)
+}
+
+// prune uncounts pastes whose expiry has passed and reports how many.
+func (st *syntaxStats) prune(now time.Time) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.pruneLocked(now)
+}
+
+func (st *syntaxStats) pruneLocked(now time.Time) int {
+	n := 0
+	for id, e := range st.expiring {
+		if !e.at.After(now) {
+			delete(st.expiring, id)
+			st.subtractLocked(e.syntax, e.size)
+			n++
+		}
+	}
+	return n
+}
+
+func (st *syntaxStats) subtractLocked(syntax string, size int) {
+	t := st.tallies[syntax]
+	t.Count--
+	t.Bytes -= size
+	if t.Count <= 0 {
+		delete(st.tallies, syntax)
+		return
+	}
+	st.tallies[syntax] = t
+}
+
+// snapshot returns the current tallies by syntax.
+func (st *syntaxStats) snapshot(now time.Time) map[string]syntaxTally {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.pruneLocked(now)
+	out := make(map[string]syntaxTally, len(st.tallies))
+	for syntax, t := range st.tallies {
+		out[syntax] = t
+	}
+	return out
+}
+
+type statsResponse struct {
+	TotalPastes int           `json:"total_pastes"`
+	TotalBytes  int           `json:"total_bytes"`
+	Syntaxes    []syntaxShare `json:"syntaxes"`
+}
+
+type syntaxShare struct {
+	Syntax  string  `json:"syntax"`
+	Label   string  `json:"label"`
+	Count   int     `json:"count"`
+	Bytes   int     `json:"bytes"`
+	Percent float64 `json:"percent"`
+}
+
+type statsPageData struct {
+	statsResponse
+}
+
+func (d statsPageData) PageTitle() string { return "Statistics · Tiny Pastebin" }
+
+// instanceStats summarizes active pastes by syntax, most used first.
+func (s *Server) instanceStats(ctx context.Context) (statsResponse, error) {
+	now := s.nowTime()
+	if err := s.syntaxStats.load(ctx, s.store, now); err != nil {
+		return statsResponse{}, err
+	}
+	out := statsResponse{Syntaxes: []syntaxShare{}}
+	for syntax, t := range s.syntaxStats.snapshot(now) {
+		out.TotalPastes += t.Count
+		out.TotalBytes += t.Bytes
+		out.Syntaxes = append(out.Syntaxes, syntaxShare{Syntax: syntax, Label: syntaxLabel(syntax), Count: t.Count, Bytes: t.Bytes})
+	}
+	for i := range out.Syntaxes {
+		out.Syntaxes[i].Percent = math.Round(float64(out.Syntaxes[i].Count)*1000/float64(out.TotalPastes)) / 10
+	}
+	sort.Slice(out.Syntaxes, func(i, j int) bool {
+		a, b := out.Syntaxes[i], out.Syntaxes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Syntax < b.Syntax
+	})
+	return out, nil
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.instanceStats(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	s.render(w, r, http.StatusOK, "stats", statsPageData{stats})
+}
+
+func (s *Server) handleAPIStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.instanceStats(r.Context())
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// adminStatsPageData shows what the store holds, including pastes the
+// public stats page leaves out: expired ones not yet swept, and the
+// quarantined and password-protected pastes counted alongside the rest.
+type adminStatsPageData struct {
+	Stats    storage.Stats
+	Bytes    int
+	Syntaxes []adminSyntaxCount
+}
+
+type adminSyntaxCount struct {
+	Label string
+	Count int64
+}
+
+func (d adminStatsPageData) PageTitle() string { return "Store statistics · Tiny Pastebin" }
+func (d adminStatsPageData) NoIndex() bool     { return true }
+
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	st, err := s.store.Stats(r.Context())
+	if err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	data := adminStatsPageData{Stats: st, Bytes: int(st.Bytes)}
+	for syntax, n := range st.BySyntax {
+		data.Syntaxes = append(data.Syntaxes, adminSyntaxCount{Label: syntaxLabel(syntax), Count: n})
+	}
+	sort.Slice(data.Syntaxes, func(i, j int) bool {
+		a, b := data.Syntaxes[i], data.Syntaxes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Label < b.Label
+	})
+	s.render(w, r, http.StatusOK, "admin-stats", data)
+}
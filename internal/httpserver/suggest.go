@@ -0,0 +1,72 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	// suggestScanLimit bounds how many recent public pastes a missing ID is
+	// compared against.
+	suggestScanLimit = 500
+	maxSuggestions   = 3
+)
+
+// notFoundPaste renders the 404 for a missing paste ID, offering near-miss
+// IDs when suggestions are enabled.
+func (s *Server) notFoundPaste(w http.ResponseWriter, r *http.Request, id string) {
+	data := errorPageData{Message: "Not found or expired"}
+	if s.suggestIDs {
+		data.Suggestions = s.suggestPasteIDs(r.Context(), id)
+	}
+	s.render(w, r, http.StatusNotFound, "error", data)
+}
+
+// suggestPasteIDs finds recent pastes whose ID differs from id only in case
+// or by one character, as mistyped QR and short links do. Only publicly
+// listed pastes are candidates: an unlisted paste's ID is its secret, and
+// guessing near it must not reveal it.
+func (s *Server) suggestPasteIDs(ctx context.Context, id string) []string {
+	if id == "" || len(id) > 64 {
+		return nil
+	}
+	recent, err := s.store.List(ctx, storage.ListOptions{PublicOnly: true, ActiveAt: s.nowTime(), Limit: suggestScanLimit})
+	if err != nil {
+		if s.logger != nil {
+			s.logger.WarnContext(ctx, "list pastes for suggestions", "error", err)
+		}
+		return nil
+	}
+	var out []string
+	for _, p := range recent {
+		if p.ID != id && (strings.EqualFold(p.ID, id) || withinOneEdit(p.ID, id)) {
+			out = append(out, p.ID)
+			if len(out) == maxSuggestions {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// withinOneEdit reports whether a and b differ by at most one inserted,
+// deleted or substituted byte.
+func withinOneEdit(a, b string) bool {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a)-len(b) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(b) && a[i] == b[i] {
+		i++
+	}
+	if len(a) == len(b) {
+		return a[i+min(1, len(a)-i):] == b[i+min(1, len(b)-i):]
+	}
+	return a[i+1:] == b[i:]
+}
@@ -0,0 +1,148 @@
+package httpserver
+
+import (
+	"encoding/csv"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Tables above these limits are shown as text instead.
+	maxTableBytes = 1 << 20
+	maxTableCols  = 64
+	maxTableCells = 200_000
+	// maxTableRows caps the rows rendered; the rest are left to the raw view.
+	maxTableRows = 1000
+)
+
+// tableSyntaxes maps the tabular syntaxes to their field separator.
+var tableSyntaxes = map[string]rune{"csv": ',', "tsv": '\t'}
+
+type tableHeader struct {
+	Label   string
+	SortURL string
+	// Sorted is "asc" or "desc" for the column the rows are ordered by.
+	Sorted string
+}
+
+// tableView is a CSV or TSV paste parsed for display. The first record is
+// taken as the header.
+type tableView struct {
+	Header []tableHeader
+	Rows   [][]string
+	// Total counts the data rows in the paste, of which at most maxTableRows
+	// are rendered.
+	Total int
+}
+
+func (t *tableView) Truncated() bool { return t.Total > len(t.Rows) }
+
+// parseTable parses content of a tabular syntax, ordered by the column named
+// in the sort and desc query parameters. It returns nil when the syntax is
+// not tabular or the content is malformed or too large, so the paste falls
+// back to the text view.
+func parseTable(content, syntax string, query url.Values) *tableView {
+	sep, ok := tableSyntaxes[syntax]
+	if !ok || len(content) > maxTableBytes {
+		return nil
+	}
+	r := csv.NewReader(strings.NewReader(content))
+	r.Comma = sep
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+	width, cells := 0, 0
+	for _, rec := range records {
+		width = max(width, len(rec))
+		cells += len(rec)
+	}
+	if width > maxTableCols || cells > maxTableCells {
+		return nil
+	}
+	for i, rec := range records {
+		for len(rec) < width {
+			rec = append(rec, "")
+		}
+		records[i] = rec
+	}
+
+	col, _ := strconv.Atoi(query.Get("sort"))
+	desc := query.Get("desc") == "1"
+	rows := records[1:]
+	if col >= 1 && col <= width {
+		slices.SortStableFunc(rows, func(a, b []string) int {
+			c := compareCells(a[col-1], b[col-1])
+			if desc {
+				return -c
+			}
+			return c
+		})
+	}
+
+	t := &tableView{Total: len(rows)}
+	for i, label := range records[0] {
+		h := tableHeader{Label: label}
+		q := url.Values{"sort": {strconv.Itoa(i + 1)}}
+		if i+1 == col {
+			h.Sorted = "asc"
+			if desc {
+				h.Sorted = "desc"
+			} else {
+				q.Set("desc", "1")
+			}
+		}
+		h.SortURL = "?" + q.Encode()
+		t.Header = append(t.Header, h)
+	}
+	t.Rows = rows[:min(len(rows), maxTableRows)]
+	return t
+}
+
+// compareCells orders numbers numerically and anything else as text, with
+// numbers before text.
+func compareCells(a, b string) int {
+	fa, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	fb, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	switch {
+	case errA == nil && errB == nil:
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// detectTableSyntax guesses whether content is a TSV or CSV table: at least
+// three lines that split into the same number of fields, two or more. It
+// returns "" when content does not look tabular.
+func detectTableSyntax(content string) string {
+	lines := strings.Split(strings.TrimRight(content, "\r\n"), "\n")
+	if len(lines) < 3 {
+		return ""
+	}
+	lines = lines[:min(len(lines), 20)]
+	for _, syntax := range []string{"tsv", "csv"} {
+		r := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+		r.Comma = tableSyntaxes[syntax]
+		records, err := r.ReadAll()
+		if err != nil || len(records) < 3 || len(records[0]) < 2 {
+			continue
+		}
+		// ReadAll enforces the first record's field count on the rest.
+		return syntax
+	}
+	return ""
+}
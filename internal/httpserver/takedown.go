@@ -0,0 +1,169 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/events"
+	"tiny-pastebin/internal/storage"
+)
+
+// maxTakedownNotice bounds the notice shown in place of a taken-down paste.
+const maxTakedownNotice = 1000
+
+// errLegalHold is returned when deleting a paste that was taken down. Only
+// an admin purge removes it.
+var errLegalHold = errors.New("paste is under legal hold")
+
+// takeDownPaste replaces paste for readers with a notice, answering 451 when
+// legal is set and 410 otherwise. The content is held: the paste stops
+// expiring, is restored if it was soft-deleted, and cannot be deleted until
+// purgeTakenDown removes it. Taking a paste down again updates the notice.
+func (s *Server) takeDownPaste(ctx context.Context, paste *storage.Paste, notice string, legal bool, actor string) error {
+	previous := *paste
+	if !paste.TakenDown() {
+		paste.TakenDownAt = s.nowTime().UTC()
+	}
+	paste.TakedownNotice = notice
+	paste.TakedownLegal = legal
+	paste.ExpiresAt = time.Time{}
+	paste.DeletedAt, paste.DeletedBy = time.Time{}, ""
+	if err := s.store.Save(ctx, paste); err != nil {
+		return err
+	}
+	if !previous.TakenDown() && !previous.Deleted() {
+		s.syntaxStats.remove(&previous)
+	}
+	s.emit(ctx, events.Event{Type: events.PasteTakenDown, PasteID: paste.ID, Reason: notice, Actor: actor})
+	return nil
+}
+
+// purgeTakenDown releases the legal hold on a taken-down paste by removing
+// it for good.
+func (s *Server) purgeTakenDown(ctx context.Context, paste *storage.Paste, actor string) error {
+	if err := s.store.Delete(ctx, paste.ID); err != nil {
+		return err
+	}
+	s.tombstones.bury(paste.ID, s.nowTime().UTC())
+	s.emit(ctx, events.Event{Type: events.PasteDeleted, PasteID: paste.ID, Reason: "purged from legal hold", Actor: actor})
+	return nil
+}
+
+// takedownForm reads the notice and the status choice from a takedown
+// request.
+func takedownForm(notice, status string) (string, bool, error) {
+	notice = strings.TrimSpace(notice)
+	if len(notice) > maxTakedownNotice {
+		return "", false, inputError("The notice is too long")
+	}
+	switch status {
+	case "", "451":
+		return notice, true, nil
+	case "410":
+		return notice, false, nil
+	}
+	return "", false, inputError("The status must be 451 or 410")
+}
+
+// handleAdminTakeDownPaste takes a paste down from the dashboard.
+func (s *Server) handleAdminTakeDownPaste(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	notice, legal, err := takedownForm(r.PostFormValue("notice"), r.PostFormValue("status"))
+	if err != nil {
+		http.Redirect(w, r, adminReturnURL(r, err.Error()+"."), http.StatusSeeOther)
+		return
+	}
+	paste, err := s.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" no longer exists."), http.StatusSeeOther)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if err := s.takeDownPaste(r.Context(), paste, notice, legal, actorAdmin); err != nil {
+		s.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, adminReturnURL(r, "Took down "+id+"."), http.StatusSeeOther)
+}
+
+// handleAdminPurge removes a taken-down paste from the dashboard.
+func (s *Server) handleAdminPurge(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	paste, err := s.store.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" no longer exists."), http.StatusSeeOther)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	if !paste.TakenDown() {
+		http.Redirect(w, r, adminReturnURL(r, "Paste "+id+" is not taken down; delete it instead."), http.StatusSeeOther)
+		return
+	}
+	if err := s.purgeTakenDown(r.Context(), paste, actorAdmin); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.serverError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, adminReturnURL(r, "Purged "+id+"."), http.StatusSeeOther)
+}
+
+type takedownRequest struct {
+	Notice string `json:"notice"`
+	// Status is 451 (the default) or 410.
+	Status int `json:"status"`
+}
+
+func (s *Server) handleAPITakeDown(w http.ResponseWriter, r *http.Request) {
+	var req takedownRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	status := ""
+	if req.Status != 0 {
+		status = strconv.Itoa(req.Status)
+	}
+	notice, legal, err := takedownForm(req.Notice, status)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	s.apiTransition(w, r, func(ctx context.Context, p *storage.Paste) error {
+		return s.takeDownPaste(ctx, p, notice, legal, actorAdmin)
+	})
+}
+
+func (s *Server) handleAPIPurge(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.store.Get(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return
+	}
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if !paste.TakenDown() {
+		writeJSON(w, http.StatusConflict, apiError{Error: "paste is not taken down; delete it instead"})
+		return
+	}
+	if err := s.purgeTakenDown(r.Context(), paste, actorAdmin); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		s.apiServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	termsCookie = "tos_accepted"
+	termsHeader = "X-Accept-Tos"
+	termsTTL    = 365 * 24 * time.Hour
+)
+
+// terms is the acknowledgment gate in front of paste creation. The version
+// is derived from the text, so editing the terms asks everyone again.
+type terms struct {
+	text    string
+	version string
+}
+
+func newTerms(text string) *terms {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(text))
+	return &terms{text: text, version: hex.EncodeToString(sum[:6])}
+}
+
+type termsAcceptance struct {
+	Version string `json:"v"`
+}
+
+type termsPageData struct {
+	Text    string
+	Version string
+}
+
+func (d termsPageData) PageTitle() string { return "Terms of service · Tiny Pastebin" }
+
+func (s *Server) handleTerms(w http.ResponseWriter, r *http.Request) {
+	s.render(w, r, http.StatusOK, "terms", termsPageData{Text: s.terms.text, Version: s.terms.version})
+}
+
+// termsPending reports whether the browser behind r still has to accept the
+// current terms before creating a paste.
+func (s *Server) termsPending(r *http.Request) bool {
+	if s.terms == nil {
+		return false
+	}
+	var acc termsAcceptance
+	return !s.readSealedCookie(r, termsCookie, &acc) || acc.Version != s.terms.version
+}
+
+// acceptTermsFromForm checks a form submission against the gate, recording a
+// fresh acceptance in a signed cookie so the box is not shown again.
+func (s *Server) acceptTermsFromForm(w http.ResponseWriter, r *http.Request) bool {
+	if !s.termsPending(r) {
+		return true
+	}
+	if r.FormValue("accept_tos") != "on" {
+		return false
+	}
+	if err := s.setSealedCookie(w, r, termsCookie, "/", termsAcceptance{Version: s.terms.version}, termsTTL); err != nil && s.logger != nil {
+		s.logger.WarnContext(r.Context(), "record terms acceptance", "error", err)
+	}
+	return true
+}
+
+// termsAcceptedByHeader checks an API request. Clients send X-Accept-Tos: 1,
+// or the version from /api/v1/limits to be refused once the terms change.
+func (s *Server) termsAcceptedByHeader(r *http.Request) bool {
+	if s.terms == nil {
+		return true
+	}
+	switch v := strings.TrimSpace(r.Header.Get(termsHeader)); v {
+	case "1", "true", s.terms.version:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,187 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// Why a paste is gone.
+const (
+	goneExpired   = "expired"
+	goneDeleted   = "deleted"
+	goneTakenDown = "taken down"
+)
+
+// goneError reports a paste removed within the tombstone window, or taken
+// down. It wraps storage.ErrNotFound, so callers that do not tell them
+// apart keep answering 404.
+type goneError struct {
+	reason string
+	at     time.Time
+	// notice and legal describe a takedown.
+	notice string
+	legal  bool
+}
+
+// status is the HTTP status answering requests for the paste.
+func (e *goneError) status() int {
+	if e.reason == goneTakenDown && e.legal {
+		return http.StatusUnavailableForLegalReasons
+	}
+	return http.StatusGone
+}
+
+func (e *goneError) Error() string { return "paste " + e.reason }
+func (e *goneError) Unwrap() error { return storage.ErrNotFound }
+
+// tombstones remembers recently expired and deleted pastes so links to them
+// can answer 410 Gone rather than 404 for a while. To notice expiries it
+// tracks when every live paste expires, loading them from the store on first
+// use and then following saves through a store hook. Tombstones are held in
+// memory, so pastes removed before a restart are forgotten.
+type tombstones struct {
+	mu       sync.Mutex
+	window   time.Duration
+	loaded   bool
+	expiring map[string]time.Time
+	gone     map[string]goneError
+}
+
+func newTombstones(window time.Duration) *tombstones {
+	if window <= 0 {
+		return nil
+	}
+	return &tombstones{window: window, expiring: make(map[string]time.Time), gone: make(map[string]goneError)}
+}
+
+// hooks follows saves so the expiry of every loaded paste stays known.
+func (t *tombstones) hooks() storage.Hooks {
+	return storage.Hooks{BeforeSave: func(_ context.Context, p *storage.Paste) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.gone, p.ID)
+		if !t.loaded {
+			return nil
+		}
+		if p.HasExpiration() {
+			t.expiring[p.ID] = p.ExpiresAt
+		} else {
+			delete(t.expiring, p.ID)
+		}
+		return nil
+	}}
+}
+
+// load records the expiry of the active pastes in store, once.
+func (t *tombstones) load(ctx context.Context, store storage.Store, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.loaded {
+		return nil
+	}
+	pastes, err := store.List(ctx, storage.ListOptions{ActiveAt: now})
+	if err != nil {
+		return err
+	}
+	for _, p := range pastes {
+		if p.HasExpiration() {
+			t.expiring[p.ID] = p.ExpiresAt
+		}
+	}
+	t.loaded = true
+	return nil
+}
+
+// bury records that the paste id was deleted at the given time.
+func (t *tombstones) bury(id string, at time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.expiring, id)
+	t.gone[id] = goneError{reason: goneDeleted, at: at}
+}
+
+// lookup reports why the paste id is gone, if that happened within the window.
+func (t *tombstones) lookup(id string, now time.Time) (*goneError, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if g, ok := t.gone[id]; ok && now.Before(g.at.Add(t.window)) {
+		return &g, true
+	}
+	if at, ok := t.expiring[id]; ok && !at.After(now) && now.Before(at.Add(t.window)) {
+		return &goneError{reason: goneExpired, at: at}, true
+	}
+	return nil, false
+}
+
+// prune forgets pastes that left the window and reports how many.
+func (t *tombstones) prune(now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for id, g := range t.gone {
+		if !now.Before(g.at.Add(t.window)) {
+			delete(t.gone, id)
+			n++
+		}
+	}
+	for id, at := range t.expiring {
+		if !now.Before(at.Add(t.window)) {
+			delete(t.expiring, id)
+			n++
+		}
+	}
+	return n
+}
+
+// goneErr returns the error for a paste that expired or was deleted at the
+// given time: a goneError within the tombstone window, ErrNotFound after it.
+func (s *Server) goneErr(reason string, at time.Time) error {
+	if s.tombstones == nil || !s.nowTime().Before(at.Add(s.tombstones.window)) {
+		return storage.ErrNotFound
+	}
+	return &goneError{reason: reason, at: at}
+}
+
+// tombstone looks up a paste missing from the store.
+func (s *Server) tombstone(ctx context.Context, id string) (*goneError, bool) {
+	if s.tombstones == nil {
+		return nil, false
+	}
+	now := s.nowTime()
+	if err := s.tombstones.load(ctx, s.store, now); err != nil && s.logger != nil {
+		s.logger.WarnContext(ctx, "load tombstones", "error", err)
+	}
+	return s.tombstones.lookup(id, now)
+}
+
+// gonePaste renders the 410 page for a paste that expired or was deleted
+// within the tombstone window, and the 410 or 451 notice for a paste that
+// was taken down.
+func (s *Server) gonePaste(w http.ResponseWriter, r *http.Request, g *goneError) {
+	data := errorPageData{Message: "Paste expired"}
+	date := g.at.UTC().Format("January 2, 2006 at 15:04 UTC")
+	switch g.reason {
+	case goneDeleted:
+		data.Message = "Paste deleted"
+		data.Description = "This paste was deleted on " + date + "."
+	case goneTakenDown:
+		data.Message = "Paste taken down"
+		if g.legal {
+			data.Message = "Paste unavailable for legal reasons"
+		}
+		data.Description = "This paste was taken down on " + date + "."
+		if g.notice != "" {
+			data.Description += " " + g.notice
+		}
+	default:
+		data.Description = "This paste expired on " + date + "."
+	}
+	s.render(w, r, g.status(), "error", data)
+}
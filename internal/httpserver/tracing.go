@@ -0,0 +1,51 @@
+package httpserver
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"tiny-pastebin/internal/tracing"
+)
+
+// traceRequest records every request as a server span, named after its
+// route so paste IDs and tokens stay out of traces. The trace is continued
+// from a traceparent header only when a trusted proxy sent it.
+func (s *Server) traceRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.WithTracer(r.Context(), s.tracer)
+		if s.proxies.trusts(r) {
+			ctx = tracing.Extract(ctx, r.Header)
+		}
+		ctx, span := tracing.Start(ctx, tracing.KindServer, r.Method)
+		defer span.End()
+		span.SetAttr("http.request.method", r.Method)
+		if id := middleware.GetReqID(ctx); id != "" {
+			span.SetAttr("request_id", id)
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttr("http.route", pattern)
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttr("http.response.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.Fail(errStatus(status))
+		}
+	})
+}
+
+// errStatus describes a failed response in a span.
+type errStatus int
+
+func (e errStatus) Error() string { return http.StatusText(int(e)) }
@@ -0,0 +1,277 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"tiny-pastebin/internal/diff"
+	"tiny-pastebin/internal/storage"
+)
+
+const (
+	maxSourceURLLen   = 2048
+	upstreamTimeout   = 10 * time.Second
+	upstreamRedirects = 3
+)
+
+var errUpstreamAddress = errors.New("upstream address is not allowed")
+
+// reservedPrefixes are special-purpose ranges not covered by the netip
+// predicates in publicAddr.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// publicAddr reports whether a is a globally routable unicast address.
+func publicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	if !a.IsGlobalUnicast() || a.IsPrivate() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// upstreamFetcher downloads the source of a paste for comparison. Every
+// connection, including those made for redirects, is checked after name
+// resolution so a source URL cannot reach the instance's own network.
+type upstreamFetcher struct {
+	client *http.Client
+}
+
+func newUpstreamFetcher(allowPrivate bool) *upstreamFetcher {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(ap.Addr()) {
+				return errUpstreamAddress
+			}
+			return nil
+		}
+	}
+	return &upstreamFetcher{client: &http.Client{
+		Timeout: upstreamTimeout,
+		Transport: &http.Transport{
+			// No proxy: it would make the connection checks meaningless.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: upstreamTimeout,
+			MaxIdleConns:          4,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > upstreamRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("redirect to unsupported scheme")
+			}
+			return nil
+		},
+	}}
+}
+
+// fetch returns the text at rawURL, refusing bodies over limit bytes and
+// anything that is not UTF-8.
+func (f *upstreamFetcher) fetch(ctx context.Context, rawURL string, limit int) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "tiny-pastebin")
+	req.Header.Set("Accept", "text/plain, */*;q=0.5")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errUpstreamAddress) {
+			return "", errUpstreamAddress
+		}
+		return "", fmt.Errorf("fetch upstream: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return "", fmt.Errorf("read upstream: %w", err)
+	}
+	if len(body) > limit {
+		return "", fmt.Errorf("upstream is larger than %d bytes", limit)
+	}
+	if !utf8.Valid(body) {
+		return "", errors.New("upstream is not text")
+	}
+	return string(body), nil
+}
+
+// validateSourceURL checks the optional source URL of in.
+func validateSourceURL(in *pasteInput) error {
+	in.SourceURL = strings.TrimSpace(in.SourceURL)
+	if in.SourceURL == "" {
+		return nil
+	}
+	u, err := url.Parse(in.SourceURL)
+	if err != nil || len(in.SourceURL) > maxSourceURLLen || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return inputError("Source URL must be an http or https URL")
+	}
+	return nil
+}
+
+type upstreamLine struct {
+	Class string
+	Text  string
+}
+
+type upstreamHunk struct {
+	Header string
+	Lines  []upstreamLine
+}
+
+type upstreamPageData struct {
+	Paste     *storage.Paste
+	Hunks     []upstreamHunk
+	Added     int
+	Removed   int
+	Identical bool
+	Error     string
+}
+
+func (d upstreamPageData) PageTitle() string { return "Compare with source · Tiny Pastebin" }
+func (d upstreamPageData) NoIndex() bool     { return true }
+
+type upstreamDiffResponse struct {
+	SourceURL string `json:"source_url"`
+	Identical bool   `json:"identical"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Diff      string `json:"diff"`
+}
+
+// handleUpstreamDiff shows the paste's owner, or an admin, how the paste
+// differs from the content currently at its source URL.
+func (s *Server) handleUpstreamDiff(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			s.notFound(w, r)
+			return
+		}
+		s.serverError(w, r, err)
+		return
+	}
+	_, admin := s.adminIdentity(r)
+	isOwner := paste.Owner != "" && paste.Owner == s.ownerOf(r)
+	if paste.SourceURL == "" || !(isOwner || admin) || (paste.Sealed && !s.unlocked(r, paste)) {
+		s.notFound(w, r)
+		return
+	}
+
+	data := upstreamPageData{Paste: paste}
+	upstream, err := s.upstream.fetch(r.Context(), paste.SourceURL, s.maxBytes)
+	if err != nil {
+		s.logUpstreamError(r, paste, err)
+		data.Error = err.Error()
+		s.render(w, r, http.StatusBadGateway, "upstream", data)
+		return
+	}
+	for _, h := range diff.Hunks(diff.Lines(upstream, paste.Content), 3) {
+		uh := upstreamHunk{Header: h.Header()}
+		for _, l := range h.Lines {
+			class := "diff-context"
+			switch l.Op {
+			case diff.Insert:
+				class = "diff-insert"
+				data.Added++
+			case diff.Delete:
+				class = "diff-delete"
+				data.Removed++
+			}
+			uh.Lines = append(uh.Lines, upstreamLine{Class: class, Text: string(l.Op) + l.Text})
+		}
+		data.Hunks = append(data.Hunks, uh)
+	}
+	data.Identical = len(data.Hunks) == 0
+	w.Header().Set("Cache-Control", "no-store")
+	s.render(w, r, http.StatusOK, "upstream", data)
+}
+
+// handleAPIUpstreamDiff returns a unified diff from the paste's source URL
+// to the paste, for its owner or an admin.
+func (s *Server) handleAPIUpstreamDiff(w http.ResponseWriter, r *http.Request) {
+	paste, err := s.fetchPaste(r, chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "paste not found"})
+		return
+	}
+	if err != nil {
+		s.apiServerError(w, r, err)
+		return
+	}
+	if identity, ok := s.adminIdentity(r); !ok || identity != "bearer" {
+		owner := s.apiOwner(r)
+		if owner == "" || paste.Owner != owner {
+			writeJSON(w, http.StatusForbidden, apiError{Error: "only the owner can compare this paste"})
+			return
+		}
+	}
+	if paste.SourceURL == "" {
+		writeJSON(w, http.StatusConflict, apiError{Error: "paste has no source_url"})
+		return
+	}
+	if err := s.unseal(r, paste, r.Header.Get(pastePasswordHeader)); err != nil {
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "paste content is sealed; send its password in " + pastePasswordHeader})
+		return
+	}
+
+	upstream, err := s.upstream.fetch(r.Context(), paste.SourceURL, s.maxBytes)
+	if err != nil {
+		s.logUpstreamError(r, paste, err)
+		writeJSON(w, http.StatusBadGateway, apiError{Error: err.Error()})
+		return
+	}
+	lines := diff.Lines(upstream, paste.Content)
+	resp := upstreamDiffResponse{
+		SourceURL: paste.SourceURL,
+		Identical: !diff.Changed(lines),
+		Diff:      diff.Unified(paste.SourceURL, s.canonicalURL(r, paste.ID), upstream, paste.Content),
+	}
+	for _, l := range lines {
+		switch l.Op {
+		case diff.Insert:
+			resp.Added++
+		case diff.Delete:
+			resp.Removed++
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) logUpstreamError(r *http.Request, paste *storage.Paste, err error) {
+	if s.logger != nil {
+		s.logger.InfoContext(r.Context(), "upstream fetch failed", "id", paste.ID, "error", err)
+	}
+}
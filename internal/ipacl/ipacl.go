@@ -0,0 +1,119 @@
+// Package ipacl decides which client addresses may reach the server, from
+// CIDR allow and deny lists given on the command line or kept in a file that
+// can be reloaded while the server runs.
+package ipacl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+)
+
+// List is an allow and deny list of address ranges. An address is refused
+// when it falls in a denied range, or when allowed ranges exist and it falls
+// in none of them. It is safe for concurrent use.
+type List struct {
+	allow, deny []netip.Prefix
+	path        string
+
+	mu                  sync.RWMutex
+	fileAllow, fileDeny []netip.Prefix
+}
+
+// ParsePrefix reads a CIDR range, or a bare address as a range of one.
+func ParsePrefix(v string) (netip.Prefix, error) {
+	v = strings.TrimSpace(v)
+	if strings.Contains(v, "/") {
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR range %q", v)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q", v)
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// New returns a list of the fixed allow and deny ranges together with those
+// in the file at path, if any. The file holds one "allow <range>" or "deny
+// <range>" per line; blank lines and lines starting with # are skipped.
+func New(allow, deny []netip.Prefix, path string) (*List, error) {
+	l := &List{allow: allow, deny: deny, path: path}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload rereads the file, keeping the ranges already loaded when it cannot
+// be read or holds an invalid line.
+func (l *List) Reload() error {
+	if l.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return fmt.Errorf("read ip list: %w", err)
+	}
+	var allow, deny []netip.Prefix
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		verb, value, _ := strings.Cut(line, " ")
+		p, err := ParsePrefix(value)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", l.path, n, err)
+		}
+		switch verb {
+		case "allow":
+			allow = append(allow, p)
+		case "deny":
+			deny = append(deny, p)
+		default:
+			return fmt.Errorf("%s line %d: expected allow or deny, got %q", l.path, n, verb)
+		}
+	}
+	l.mu.Lock()
+	l.fileAllow, l.fileDeny = allow, deny
+	l.mu.Unlock()
+	return nil
+}
+
+// Allowed reports whether addr may reach the server. Addresses that do not
+// parse are refused whenever any range is configured.
+func (l *List) Allowed(addr string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	restricted := len(l.allow) > 0 || len(l.fileAllow) > 0
+	if !restricted && len(l.deny) == 0 && len(l.fileDeny) == 0 {
+		return true
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	if contains(l.deny, ip) || contains(l.fileDeny, ip) {
+		return false
+	}
+	return !restricted || contains(l.allow, ip) || contains(l.fileAllow, ip)
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package ipacl
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func prefixes(t *testing.T, values ...string) []netip.Prefix {
+	t.Helper()
+	var out []netip.Prefix
+	for _, v := range values {
+		p, err := ParsePrefix(v)
+		if err != nil {
+			t.Fatalf("parse %q: %v", v, err)
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func TestAllowed(t *testing.T) {
+	empty, err := New(nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty.Allowed("203.0.113.7") || !empty.Allowed("garbage") {
+		t.Fatal("expected an empty list to allow everyone")
+	}
+
+	l, err := New(prefixes(t, "10.0.0.0/8", "fd00::/8"), prefixes(t, "10.6.6.6", "10.9.0.0/16"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"10.1.2.3":          true,
+		"::ffff:10.1.2.3":   true,
+		"fd00::1":           true,
+		"10.6.6.6":          false,
+		"10.9.1.1":          false,
+		"192.168.1.1":       false,
+		"2001:db8::1":       false,
+		"not an ip address": false,
+	} {
+		if got := l.Allowed(addr); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", addr, got, want)
+		}
+	}
+
+	if _, err := ParsePrefix("10.0.0.0/33"); err == nil {
+		t.Error("expected an invalid range refused")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("# abusive ranges\ndeny 198.51.100.0/24\n")
+	l, err := New(nil, prefixes(t, "203.0.113.9"), path)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if l.Allowed("198.51.100.4") || l.Allowed("203.0.113.9") || !l.Allowed("192.0.2.1") {
+		t.Fatal("expected the file and flag ranges both denied")
+	}
+
+	write("allow 192.0.2.0/24\n")
+	if err := l.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if l.Allowed("198.51.100.4") || !l.Allowed("192.0.2.1") || l.Allowed("203.0.113.9") {
+		t.Fatal("expected the reloaded file to replace the old ranges")
+	}
+
+	write("permit 192.0.2.0/24\n")
+	if err := l.Reload(); err == nil {
+		t.Fatal("expected an invalid file refused")
+	}
+	if !l.Allowed("192.0.2.1") {
+		t.Fatal("expected the previous ranges kept after a failed reload")
+	}
+}
@@ -0,0 +1,198 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const jwksMinRefresh = time.Minute
+
+// jwksFetchTimeout bounds a key set fetch, which outlives the request that
+// started it.
+const jwksFetchTimeout = 10 * time.Second
+
+// JWKS is a KeySource backed by a remote JSON Web Key Set. Keys are cached
+// and refetched when a token references an unknown key ID, at most once a
+// minute. A fetch runs apart from the lock and from the request that
+// started it, and concurrent lookups wait for the same fetch.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	mu   sync.Mutex
+	keys map[string]any
+	// fetched is when the last fetch started, whether or not it succeeded.
+	fetched time.Time
+	// fetching is closed when the fetch in progress ends; err is its error.
+	fetching chan struct{}
+	err      error
+}
+
+// NewJWKS returns a key source for the key set published at url.
+func NewJWKS(url string, client *http.Client) *JWKS {
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+	return &JWKS{url: url, client: client}
+}
+
+// Key implements KeySource.
+func (j *JWKS) Key(ctx context.Context, kid, alg string) (any, error) {
+	j.mu.Lock()
+	if key, ok := j.lookup(kid); ok {
+		j.mu.Unlock()
+		return key, nil
+	}
+	done := j.fetching
+	if done == nil {
+		if !j.fetched.IsZero() && time.Since(j.fetched) < jwksMinRefresh {
+			j.mu.Unlock()
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+		}
+		done = make(chan struct{})
+		j.fetching = done
+		j.fetched = time.Now()
+		go j.refresh(context.WithoutCancel(ctx), done)
+	}
+	j.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if key, ok := j.lookup(kid); ok {
+		return key, nil
+	}
+	if j.err != nil {
+		return nil, j.err
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+}
+
+func (j *JWKS) lookup(kid string) (any, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, k := range j.keys {
+			return k, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// refresh fetches the key set and closes done.
+func (j *JWKS) refresh(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	keys, err := j.fetch(ctx)
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+	}
+	j.err = err
+	j.fetching = nil
+	j.mu.Unlock()
+	close(done)
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build jwks request: %w", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errors.New("unsupported key type")
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,280 @@
+// Package jwt verifies compact JSON Web Tokens signed with RSA, ECDSA, or HMAC keys.
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for malformed tokens or bad signatures.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpired is returned when the token is outside its validity window.
+	ErrExpired = errors.New("token expired")
+)
+
+// Claims holds the decoded token payload.
+type Claims map[string]any
+
+// String returns a string claim, formatting numeric claims without exponent.
+func (c Claims) String(name string) string {
+	switch v := c[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return ""
+	}
+}
+
+// Strings returns a claim that may be encoded as a string or a list of strings.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// Time returns a NumericDate claim.
+func (c Claims) Time(name string) (time.Time, bool) {
+	switch v := c[name].(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(int64(f), 0), true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// KeySource resolves the verification key for a token header.
+// RSA and ECDSA keys are *rsa.PublicKey and *ecdsa.PublicKey; HMAC keys are []byte.
+type KeySource interface {
+	Key(ctx context.Context, kid, alg string) (any, error)
+}
+
+// StaticKey is a KeySource that always returns the same key.
+type StaticKey struct {
+	K any
+}
+
+// Key implements KeySource.
+func (s StaticKey) Key(context.Context, string, string) (any, error) {
+	return s.K, nil
+}
+
+// Verifier checks signatures and registered claims.
+type Verifier struct {
+	Keys KeySource
+	// Issuer, when set, must equal the iss claim.
+	Issuer string
+	// Audience, when set, must appear in the aud claim.
+	Audience string
+	// Algorithms restricts accepted alg values; empty accepts all supported ones.
+	Algorithms []string
+	Leeway     time.Duration
+	Now        func() time.Time
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify validates token and returns its claims. Tokens must carry exp.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !v.algAllowed(h.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %q not allowed", ErrInvalidToken, h.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, err := v.Keys.Key(ctx, h.Kid, h.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) algAllowed(alg string) bool {
+	if _, ok := hashes[alg]; !ok {
+		return false
+	}
+	if len(v.Algorithms) == 0 {
+		return true
+	}
+	for _, a := range v.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) checkClaims(c Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	// A token without exp would never lapse, so it could only be revoked by
+	// rotating the key.
+	exp, ok := c.Time("exp")
+	if !ok {
+		return fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	if now.After(exp.Add(v.Leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := c.Time("nbf"); ok && now.Add(v.Leeway).Before(nbf) {
+		return ErrExpired
+	}
+	if v.Issuer != "" && c.String("iss") != v.Issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.Audience != "" {
+		found := false
+		for _, aud := range c.Strings("aud") {
+			if aud == v.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+		}
+	}
+	return nil
+}
+
+var hashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+}
+
+func verifySignature(alg string, key any, signed, sig []byte) error {
+	h := hashes[alg]
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%w: HMAC key required", ErrInvalidToken)
+		}
+		mac := hmac.New(hashFunc(h), secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidToken
+		}
+		return nil
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: RSA key required", ErrInvalidToken)
+		}
+		digest := sum(h, signed)
+		var err error
+		if alg[:2] == "RS" {
+			err = rsa.VerifyPKCS1v15(pub, h, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, h, digest, sig, nil)
+		}
+		if err != nil {
+			return ErrInvalidToken
+		}
+		return nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: ECDSA key required", ErrInvalidToken)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrInvalidToken
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, sum(h, signed), r, s) {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+	return ErrInvalidToken
+}
+
+func hashFunc(h crypto.Hash) func() hash.Hash {
+	switch h {
+	case crypto.SHA384:
+		return sha512.New384
+	case crypto.SHA512:
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+func sum(h crypto.Hash, data []byte) []byte {
+	hf := hashFunc(h)()
+	hf.Write(data)
+	return hf.Sum(nil)
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
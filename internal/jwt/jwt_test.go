@@ -0,0 +1,163 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func sign(t *testing.T, alg string, key any, claims map[string]any) string {
+	t.Helper()
+	hdr, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(body)
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerifyHMACAndRSA(t *testing.T) {
+	now := time.Now()
+	claims := map[string]any{"sub": "user-1", "iss": "https://idp", "aud": "paste", "exp": now.Add(time.Hour).Unix()}
+
+	secret := []byte("top-secret")
+	v := &Verifier{Keys: StaticKey{K: secret}, Issuer: "https://idp", Audience: "paste"}
+	got, err := v.Verify(context.Background(), sign(t, "HS256", secret, claims))
+	if err != nil {
+		t.Fatalf("verify hmac: %v", err)
+	}
+	if got.String("sub") != "user-1" {
+		t.Fatalf("unexpected subject %q", got.String("sub"))
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	rv := &Verifier{Keys: StaticKey{K: &priv.PublicKey}, Audience: "paste"}
+	if _, err := rv.Verify(context.Background(), sign(t, "RS256", priv, claims)); err != nil {
+		t.Fatalf("verify rsa: %v", err)
+	}
+
+	// An HMAC token must not validate against an RSA key (algorithm confusion).
+	if _, err := rv.Verify(context.Background(), sign(t, "HS256", secret, claims)); err == nil {
+		t.Fatalf("expected HS256 token rejected by RSA verifier")
+	}
+}
+
+func TestVerifyRejectsExpiredAndWrongAudience(t *testing.T) {
+	secret := []byte("k")
+	v := &Verifier{Keys: StaticKey{K: secret}, Audience: "paste"}
+
+	expired := sign(t, "HS256", secret, map[string]any{"aud": "paste", "exp": time.Now().Add(-time.Hour).Unix()})
+	if _, err := v.Verify(context.Background(), expired); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+
+	wrongAud := sign(t, "HS256", secret, map[string]any{"aud": []string{"other"}, "exp": time.Now().Add(time.Hour).Unix()})
+	if _, err := v.Verify(context.Background(), wrongAud); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+
+	noExpiry := sign(t, "HS256", secret, map[string]any{"aud": "paste"})
+	if _, err := v.Verify(context.Background(), noExpiry); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected a token without exp rejected, got %v", err)
+	}
+
+	tampered := sign(t, "HS256", []byte("other"), map[string]any{"aud": "paste"})
+	if _, err := v.Verify(context.Background(), tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected bad signature rejected, got %v", err)
+	}
+}
+
+func TestJWKSFetchesOnceOutsideTheRequest(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	var fetches atomic.Int32
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		started <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "k1", "kty": "RSA",
+			"n": base64.RawURLEncoding.EncodeToString(priv.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.E)).Bytes()),
+		}}})
+	}))
+	defer srv.Close()
+	j := NewJWKS(srv.URL, nil)
+
+	// A caller giving up does not abort the fetch others wait for.
+	ctx, cancel := context.WithCancel(context.Background())
+	gaveUp := make(chan error, 1)
+	go func() {
+		_, err := j.Key(ctx, "k1", "RS256")
+		gaveUp <- err
+	}()
+	<-started
+	waited := make(chan error, 1)
+	go func() {
+		_, err := j.Key(context.Background(), "k1", "RS256")
+		waited <- err
+	}()
+	cancel()
+	if err := <-gaveUp; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to return, got %v", err)
+	}
+	close(release)
+	if err := <-waited; err != nil {
+		t.Fatalf("expected the waiting caller to get the key, got %v", err)
+	}
+	if _, err := j.Key(context.Background(), "k2", "RS256"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected an unknown key refused, got %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected one fetch, got %d", n)
+	}
+}
+
+func TestJWKSThrottlesAfterFailedFetch(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	j := NewJWKS(srv.URL, nil)
+	if _, err := j.Key(context.Background(), "k1", "RS256"); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the fetch error, got %v", err)
+	}
+	if _, err := j.Key(context.Background(), "k1", "RS256"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the key refused until the next refresh, got %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected a failed fetch to count toward the throttle, got %d fetches", n)
+	}
+}
@@ -0,0 +1,156 @@
+// Package logging builds the process logger from an output specification so
+// deployments can log to files, syslog or journald without external wrappers.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config selects where and how log records are written.
+type Config struct {
+	// Output is one of "stdout", "stderr", "syslog", "syslog://host:port"
+	// (UDP), "syslog+tcp://host:port", "journald", or a file path.
+	Output string
+	// Format is "text" or "json". Syslog and journald always receive text.
+	Format string
+	Level  slog.Level
+
+	// Rotation settings for file output. Zero disables the corresponding limit.
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+}
+
+// New returns a logger writing to cfg.Output and a closer releasing the
+// underlying sink.
+func New(cfg Config) (*slog.Logger, io.Closer, error) {
+	if cfg.Format != "" && cfg.Format != "text" && cfg.Format != "json" {
+		return nil, nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	output := cfg.Output
+	if output == "" {
+		output = "stdout"
+	}
+
+	switch {
+	case output == "stdout":
+		return newStream(os.Stdout, cfg.Format, opts, nopCloser{})
+	case output == "stderr":
+		return newStream(os.Stderr, cfg.Format, opts, nopCloser{})
+	case output == "journald":
+		w, err := dialJournald()
+		if err != nil {
+			return nil, nil, err
+		}
+		return slog.New(newLeveled(w, opts)), w, nil
+	case output == "syslog" || strings.HasPrefix(output, "syslog://") || strings.HasPrefix(output, "syslog+tcp://"):
+		network, addr := "", ""
+		if rest, ok := strings.CutPrefix(output, "syslog://"); ok {
+			network, addr = "udp", rest
+		} else if rest, ok := strings.CutPrefix(output, "syslog+tcp://"); ok {
+			network, addr = "tcp", rest
+		}
+		w, err := dialSyslog(network, addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return slog.New(newLeveled(w, opts)), w, nil
+	default:
+		f, err := OpenRotating(output, cfg.MaxSize, cfg.MaxAge, cfg.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newStream(f, cfg.Format, opts, f)
+	}
+}
+
+func newStream(w io.Writer, format string, opts *slog.HandlerOptions, c io.Closer) (*slog.Logger, io.Closer, error) {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts)), c, nil
+	}
+	return slog.New(slog.NewTextHandler(w, opts)), c, nil
+}
+
+// ParseLevel accepts debug, info, warn or error.
+func ParseLevel(v string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(v)); err != nil {
+		return 0, errors.New("log level must be debug, info, warn or error")
+	}
+	return level, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// levelWriter receives one formatted record at a time along with its level,
+// for sinks that carry severity out of band.
+type levelWriter interface {
+	WriteLevel(level slog.Level, msg []byte) error
+}
+
+// leveledHandler formats records as text and hands them to a levelWriter
+// together with their level.
+type leveledHandler struct {
+	inner slog.Handler
+	out   *levelBuffer
+}
+
+type levelBuffer struct {
+	mu    sync.Mutex
+	w     levelWriter
+	level slog.Level
+}
+
+func (b *levelBuffer) Write(p []byte) (int, error) {
+	// Sinks add their own timestamp and line framing.
+	if err := b.w.WriteLevel(b.level, bytes.TrimSuffix(p, []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func newLeveled(w levelWriter, opts *slog.HandlerOptions) *leveledHandler {
+	out := &levelBuffer{w: w}
+	inner := slog.NewTextHandler(out, &slog.HandlerOptions{
+		Level:     opts.Level,
+		AddSource: opts.AddSource,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return &leveledHandler{inner: inner, out: out}
+}
+
+func (h *leveledHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *leveledHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.level = r.Level
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *leveledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &leveledHandler{inner: h.inner.WithAttrs(attrs), out: h.out}
+}
+
+func (h *leveledHandler) WithGroup(name string) slog.Handler {
+	return &leveledHandler{inner: h.inner.WithGroup(name), out: h.out}
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileBySizeAndAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f, err := OpenRotating(path, 10, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	f.now = func() time.Time { return now }
+	f.opened = now
+
+	write := func(s string) {
+		t.Helper()
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+	write("12345678\n")
+	write("abc\n") // exceeds 10 bytes, rotates first
+	write("d\n")
+	now = now.Add(2 * time.Hour)
+	write("late\n") // too old, rotates again
+	write("x\n")
+	write("0123456789\n") // third rotation, oldest backup pruned
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	current, _ := os.ReadFile(path)
+	if string(current) != "0123456789\n" {
+		t.Fatalf("unexpected current file %q", current)
+	}
+	newest, _ := os.ReadFile(backups[1])
+	if string(newest) != "late\nx\n" {
+		t.Fatalf("unexpected newest backup %q", newest)
+	}
+}
+
+type recordedLine struct {
+	level slog.Level
+	msg   string
+}
+
+type recordingWriter struct {
+	lines []recordedLine
+}
+
+func (w *recordingWriter) WriteLevel(level slog.Level, msg []byte) error {
+	w.lines = append(w.lines, recordedLine{level, string(msg)})
+	return nil
+}
+
+func TestLeveledHandlerCarriesSeverity(t *testing.T) {
+	w := &recordingWriter{}
+	logger := slog.New(newLeveled(w, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	logger.Debug("hidden")
+	logger.With("request_id", "r1").Warn("slow", "ms", 900)
+	logger.ErrorContext(context.Background(), "boom")
+
+	if len(w.lines) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", w.lines)
+	}
+	if w.lines[0].level != slog.LevelWarn || w.lines[0].msg != "msg=slow request_id=r1 ms=900" {
+		t.Fatalf("unexpected warn line %+v", w.lines[0])
+	}
+	if w.lines[1].level != slog.LevelError || strings.Contains(w.lines[1].msg, "time=") {
+		t.Fatalf("unexpected error line %+v", w.lines[1])
+	}
+}
+
+func TestNewFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "json.log")
+	logger, closer, err := New(Config{Output: path, Format: "json"})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	logger.Info("hello", "k", "v")
+	if err := closer.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"msg":"hello"`) {
+		t.Fatalf("expected json record, got %q", data)
+	}
+
+	if _, _, err := New(Config{Format: "xml"}); err == nil {
+		t.Fatalf("expected unknown format rejected")
+	}
+}
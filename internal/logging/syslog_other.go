@@ -0,0 +1,23 @@
+//go:build windows || plan9
+
+package logging
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var errUnsupported = errors.New("syslog and journald output are not supported on this platform")
+
+type unsupportedWriter struct{}
+
+func (unsupportedWriter) WriteLevel(slog.Level, []byte) error { return errUnsupported }
+func (unsupportedWriter) Close() error                        { return nil }
+
+func dialSyslog(network, addr string) (unsupportedWriter, error) {
+	return unsupportedWriter{}, errUnsupported
+}
+
+func dialJournald() (unsupportedWriter, error) {
+	return unsupportedWriter{}, errUnsupported
+}
@@ -0,0 +1,109 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+// dialSyslog connects to the local syslog daemon when network is empty, or
+// to a remote one otherwise.
+func dialSyslog(network, addr string) (*syslogWriter, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, identifier())
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog: %w", err)
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) WriteLevel(level slog.Level, msg []byte) error {
+	m := string(msg)
+	switch {
+	case level >= slog.LevelError:
+		return s.w.Err(m)
+	case level >= slog.LevelWarn:
+		return s.w.Warning(m)
+	case level >= slog.LevelInfo:
+		return s.w.Info(m)
+	default:
+		return s.w.Debug(m)
+	}
+}
+
+func (s *syslogWriter) Close() error { return s.w.Close() }
+
+// journaldWriter speaks the native journal protocol over its datagram socket.
+type journaldWriter struct {
+	conn *net.UnixConn
+	tag  string
+}
+
+func dialJournald() (*journaldWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("connect to journald: %w", err)
+	}
+	return &journaldWriter{conn: conn, tag: identifier()}, nil
+}
+
+func (j *journaldWriter) WriteLevel(level slog.Level, msg []byte) error {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "PRIORITY", []byte(strconv.Itoa(journalPriority(level))))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", []byte(j.tag))
+	writeJournalField(&buf, "MESSAGE", msg)
+	_, err := j.conn.Write(buf.Bytes())
+	return err
+}
+
+func (j *journaldWriter) Close() error { return j.conn.Close() }
+
+// writeJournalField uses the length-prefixed form for values containing
+// newlines, as the protocol requires.
+func writeJournalField(buf *bytes.Buffer, key string, value []byte) {
+	buf.WriteString(key)
+	if bytes.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.Write(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	var size [8]byte
+	n := uint64(len(value))
+	for i := range size {
+		size[i] = byte(n >> (8 * i))
+	}
+	buf.Write(size[:])
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
+
+func journalPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+func identifier() string {
+	return filepath.Base(os.Args[0])
+}
@@ -0,0 +1,236 @@
+// Package oidc implements the OAuth2 authorization code flow (with PKCE) against
+// OpenID Connect providers, plus plain OAuth2 providers such as GitHub that
+// expose identity through a userinfo endpoint.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/jwt"
+)
+
+// Config describes a login provider.
+type Config struct {
+	// Issuer enables discovery via /.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// SubjectClaim names the claim mapped to the owner identity; defaults to "sub".
+	SubjectClaim string
+
+	// Explicit endpoints override discovery; required when Issuer is empty.
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+
+	HTTPClient *http.Client
+}
+
+// Identity is the authenticated user as reported by the provider.
+type Identity struct {
+	Subject string
+	Name    string
+}
+
+// Provider performs logins against one configured provider.
+type Provider struct {
+	cfg      Config
+	client   *http.Client
+	verifier *jwt.Verifier
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// New validates cfg and, when an issuer is configured, runs discovery.
+func New(ctx context.Context, cfg Config) (*Provider, error) {
+	if cfg.ClientID == "" {
+		return nil, errors.New("oidc client id required")
+	}
+	if cfg.RedirectURL == "" {
+		return nil, errors.New("oidc redirect url required")
+	}
+	if cfg.SubjectClaim == "" {
+		cfg.SubjectClaim = "sub"
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	p := &Provider{cfg: cfg, client: client}
+
+	if cfg.Issuer != "" {
+		if len(cfg.Scopes) == 0 {
+			p.cfg.Scopes = []string{"openid", "profile", "email"}
+		}
+		var doc discovery
+		wellKnown := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := p.getJSON(ctx, wellKnown, "", &doc); err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		if doc.Issuer != cfg.Issuer && doc.Issuer != strings.TrimSuffix(cfg.Issuer, "/") {
+			return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", doc.Issuer)
+		}
+		p.cfg.AuthURL = firstNonEmpty(cfg.AuthURL, doc.AuthorizationEndpoint)
+		p.cfg.TokenURL = firstNonEmpty(cfg.TokenURL, doc.TokenEndpoint)
+		p.cfg.UserInfoURL = firstNonEmpty(cfg.UserInfoURL, doc.UserInfoEndpoint)
+		if doc.JWKSURI != "" {
+			p.verifier = &jwt.Verifier{
+				Keys:     jwt.NewJWKS(doc.JWKSURI, client),
+				Issuer:   doc.Issuer,
+				Audience: cfg.ClientID,
+				Leeway:   time.Minute,
+			}
+		}
+	}
+	if p.cfg.AuthURL == "" || p.cfg.TokenURL == "" {
+		return nil, errors.New("oidc provider needs an issuer or explicit auth and token URLs")
+	}
+	if p.verifier == nil && p.cfg.UserInfoURL == "" {
+		return nil, errors.New("oidc provider without jwks needs a userinfo URL")
+	}
+	return p, nil
+}
+
+// AuthCodeURL returns the provider URL that starts a login.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("state", state)
+	if len(p.cfg.Scopes) > 0 {
+		q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	}
+	if p.verifier != nil {
+		q.Set("nonce", nonce)
+	}
+	q.Set("code_challenge", codeChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(p.cfg.AuthURL, "?") {
+		sep = "&"
+	}
+	return p.cfg.AuthURL + sep + q.Encode()
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code and resolves the user's identity.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var tok tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tok); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("token request: %s %s", tok.Error, tok.Description)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request: status %d", resp.StatusCode)
+	}
+
+	var claims jwt.Claims
+	if tok.IDToken != "" && p.verifier != nil {
+		claims, err = p.verifier.Verify(ctx, tok.IDToken)
+		if err != nil {
+			return nil, fmt.Errorf("verify id token: %w", err)
+		}
+		if claims.String("nonce") != nonce {
+			return nil, errors.New("id token nonce mismatch")
+		}
+	}
+	if claims.String(p.cfg.SubjectClaim) == "" && p.cfg.UserInfoURL != "" {
+		if tok.AccessToken == "" {
+			return nil, errors.New("token response missing access token")
+		}
+		claims = jwt.Claims{}
+		if err := p.getJSON(ctx, p.cfg.UserInfoURL, tok.AccessToken, &claims); err != nil {
+			return nil, fmt.Errorf("userinfo: %w", err)
+		}
+	}
+
+	subject := claims.String(p.cfg.SubjectClaim)
+	if subject == "" {
+		return nil, fmt.Errorf("identity missing %q claim", p.cfg.SubjectClaim)
+	}
+	name := firstNonEmpty(claims.String("preferred_username"), claims.String("login"), claims.String("name"), claims.String("email"), subject)
+	return &Identity{Subject: subject, Name: name}, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint, bearer string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	dec := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
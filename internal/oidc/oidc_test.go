@@ -0,0 +1,99 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryAndExchange(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	var (
+		issuer    string
+		challenge string
+		nonce     string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": issuer + "/authorize",
+			"token_endpoint":         issuer + "/token",
+			"jwks_uri":               issuer + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(priv.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(priv.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		hdr, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+		body, _ := json.Marshal(map[string]any{
+			"iss": issuer, "aud": "client-1", "sub": "alice-123", "preferred_username": "alice",
+			"nonce": nonce, "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		signed := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(body)
+		digest := sha256.Sum256([]byte(signed))
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "at",
+			"id_token":     signed + "." + base64.RawURLEncoding.EncodeToString(sig),
+		})
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	issuer = ts.URL
+
+	p, err := New(context.Background(), Config{Issuer: issuer, ClientID: "client-1", RedirectURL: "https://paste.example/auth/callback"})
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+
+	authURL, err := url.Parse(p.AuthCodeURL("st", "n-1", "verifier-verifier-verifier"))
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+	challenge = authURL.Query().Get("code_challenge")
+	nonce = authURL.Query().Get("nonce")
+	if authURL.Query().Get("state") != "st" || nonce != "n-1" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	ident, err := p.Exchange(context.Background(), "good-code", "verifier-verifier-verifier", "n-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if ident.Subject != "alice-123" || ident.Name != "alice" {
+		t.Fatalf("unexpected identity %+v", ident)
+	}
+
+	if _, err := p.Exchange(context.Background(), "good-code", "wrong-verifier", "n-1"); err == nil {
+		t.Fatalf("expected PKCE mismatch to fail")
+	}
+}
@@ -0,0 +1,100 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const clamdChunkSize = 64 * 1024
+
+// Clamd scans content with a ClamAV daemon using the INSTREAM command.
+type Clamd struct {
+	// Network is "tcp" or "unix".
+	Network string
+	// Address is host:port for tcp or a socket path for unix.
+	Address string
+	// Timeout bounds a single scan; zero means 30 seconds.
+	Timeout time.Duration
+}
+
+// NewClamd returns a scanner for addr, treating values containing a slash as
+// a unix socket path.
+func NewClamd(addr string) *Clamd {
+	network := "tcp"
+	if strings.Contains(addr, "/") {
+		network = "unix"
+	}
+	return &Clamd{Network: network, Address: addr}
+}
+
+// Scan implements Scanner. Metadata is not sent to clamd.
+func (c *Clamd) Scan(ctx context.Context, content string, _ map[string]string) (Verdict, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("dial clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Verdict{}, fmt.Errorf("write clamd command: %w", err)
+	}
+	data := []byte(content)
+	var size [4]byte
+	for len(data) > 0 {
+		n := min(len(data), clamdChunkSize)
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		if _, err := conn.Write(size[:]); err != nil {
+			return Verdict{}, fmt.Errorf("write clamd chunk: %w", err)
+		}
+		if _, err := conn.Write(data[:n]); err != nil {
+			return Verdict{}, fmt.Errorf("write clamd chunk: %w", err)
+		}
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return Verdict{}, fmt.Errorf("finish clamd stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Verdict{}, fmt.Errorf("read clamd reply: %w", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply interprets replies such as "stream: OK" and
+// "stream: Eicar-Signature FOUND".
+func parseClamdReply(reply string) (Verdict, error) {
+	_, result, ok := strings.Cut(reply, ": ")
+	if !ok {
+		return Verdict{}, fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+	switch {
+	case result == "OK":
+		return Verdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Flagged: true, Reason: strings.TrimSuffix(result, " FOUND")}, nil
+	case strings.HasSuffix(result, " ERROR"):
+		return Verdict{}, errors.New("clamd: " + strings.TrimSuffix(result, " ERROR"))
+	default:
+		return Verdict{}, fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+}
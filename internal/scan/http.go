@@ -0,0 +1,77 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"tiny-pastebin/internal/tracing"
+)
+
+// HTTP scans content with an external scanning service. The content is
+// POSTed as the request body and the service answers with a JSON verdict:
+//
+//	{"flagged": true, "reason": "Win.Test.EICAR_HDB-1"}
+//
+// Any status other than 200 is an error.
+type HTTP struct {
+	URL string
+	// Token, when set, is sent as a bearer token.
+	Token string
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// Timeout bounds a single scan; zero means 30 seconds.
+	Timeout time.Duration
+}
+
+// Scan implements Scanner. Metadata is sent as JSON in the
+// X-Paste-Metadata header.
+func (h *HTTP) Scan(ctx context.Context, content string, metadata map[string]string) (Verdict, error) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader([]byte(content)))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	tracing.Inject(ctx, req.Header)
+	if len(metadata) > 0 {
+		meta, err := json.Marshal(metadata)
+		if err != nil {
+			return Verdict{}, err
+		}
+		req.Header.Set("X-Paste-Metadata", string(meta))
+	}
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("scan service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("scan service returned %s", resp.Status)
+	}
+	var reply struct {
+		Flagged bool   `json:"flagged"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&reply); err != nil {
+		return Verdict{}, fmt.Errorf("decode scan service reply: %w", err)
+	}
+	return Verdict{Flagged: reply.Flagged, Reason: reply.Reason}, nil
+}
@@ -0,0 +1,103 @@
+// Package scan defines content scanners that inspect pastes after creation
+// and flag them for quarantine.
+package scan
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Verdict is the outcome of scanning a paste.
+type Verdict struct {
+	// Flagged is true when the paste should be quarantined.
+	Flagged bool
+	// Reason is a short human-readable explanation, such as a signature name.
+	Reason string
+}
+
+// Scanner inspects paste content and metadata.
+type Scanner interface {
+	Scan(ctx context.Context, content string, metadata map[string]string) (Verdict, error)
+}
+
+// Nop never flags anything.
+type Nop struct{}
+
+// Scan implements Scanner.
+func (Nop) Scan(context.Context, string, map[string]string) (Verdict, error) {
+	return Verdict{}, nil
+}
+
+// Regex flags content or metadata values matching any of its patterns.
+type Regex struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRegex compiles patterns into a Regex scanner.
+func NewRegex(patterns []string) (*Regex, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("compile scan pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return &Regex{patterns: compiled}, nil
+}
+
+// Scan implements Scanner.
+func (s *Regex) Scan(ctx context.Context, content string, metadata map[string]string) (Verdict, error) {
+	for _, re := range s.patterns {
+		if err := ctx.Err(); err != nil {
+			return Verdict{}, err
+		}
+		if re.MatchString(content) {
+			return Verdict{Flagged: true, Reason: "matched pattern " + re.String()}, nil
+		}
+		for key, value := range metadata {
+			if re.MatchString(value) {
+				return Verdict{Flagged: true, Reason: fmt.Sprintf("metadata %s matched pattern %s", key, re.String())}, nil
+			}
+		}
+	}
+	return Verdict{}, nil
+}
+
+// Chain runs scanners in order and returns the first flagged verdict.
+type Chain []Scanner
+
+// Scan implements Scanner.
+func (c Chain) Scan(ctx context.Context, content string, metadata map[string]string) (Verdict, error) {
+	for _, s := range c {
+		v, err := s.Scan(ctx, content, metadata)
+		if err != nil || v.Flagged {
+			return v, err
+		}
+	}
+	return Verdict{}, nil
+}
+
+// Selective runs Scanner only over content worth its cost: content of at
+// least MinBytes, or content that looks binary. It keeps slow scanners such
+// as clamd off ordinary text pastes.
+type Selective struct {
+	Scanner  Scanner
+	MinBytes int
+}
+
+// Scan implements Scanner.
+func (s Selective) Scan(ctx context.Context, content string, metadata map[string]string) (Verdict, error) {
+	if len(content) < s.MinBytes && !looksBinary(content) {
+		return Verdict{}, nil
+	}
+	return s.Scanner.Scan(ctx, content, metadata)
+}
+
+// looksBinary reports whether content is not UTF-8 text or holds NUL bytes.
+func looksBinary(content string) bool {
+	return !utf8.ValidString(content) || strings.ContainsRune(content, 0)
+}
@@ -0,0 +1,142 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegexScansContentAndMetadata(t *testing.T) {
+	s, err := NewRegex([]string{`(?i)bad\s+stuff`})
+	if err != nil {
+		t.Fatalf("new regex: %v", err)
+	}
+	ctx := context.Background()
+
+	if v, _ := s.Scan(ctx, "harmless", map[string]string{"ticket": "1"}); v.Flagged {
+		t.Fatalf("unexpected flag: %+v", v)
+	}
+	if v, _ := s.Scan(ctx, "some BAD   stuff here", nil); !v.Flagged {
+		t.Fatalf("expected content flagged")
+	}
+	if v, _ := s.Scan(ctx, "ok", map[string]string{"note": "bad stuff"}); !v.Flagged || !strings.Contains(v.Reason, "note") {
+		t.Fatalf("expected metadata flagged, got %+v", v)
+	}
+
+	if _, err := NewRegex([]string{"("}); err == nil {
+		t.Fatalf("expected invalid pattern error")
+	}
+}
+
+func TestChainStopsAtFirstFlag(t *testing.T) {
+	re, _ := NewRegex([]string{"x"})
+	v, err := Chain{Nop{}, re}.Scan(context.Background(), "xyz", nil)
+	if err != nil || !v.Flagged {
+		t.Fatalf("expected chain to flag, got %+v %v", v, err)
+	}
+}
+
+func TestSelectiveSkipsSmallText(t *testing.T) {
+	re, _ := NewRegex([]string{"x"})
+	s := Selective{Scanner: re, MinBytes: 8}
+	if v, _ := s.Scan(context.Background(), "xyz", nil); v.Flagged {
+		t.Fatalf("expected small text skipped")
+	}
+	if v, _ := s.Scan(context.Background(), "x\x00", nil); !v.Flagged {
+		t.Fatalf("expected binary content scanned")
+	}
+	if v, _ := s.Scan(context.Background(), "xxxxxxxx", nil); !v.Flagged {
+		t.Fatalf("expected large content scanned")
+	}
+}
+
+func TestHTTPScanner(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "EICAR") {
+			_, _ = io.WriteString(w, `{"flagged": true, "reason": "Eicar-Test"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"flagged": false}`)
+	}))
+	defer ts.Close()
+
+	h := &HTTP{URL: ts.URL, Token: "secret"}
+	if v, err := h.Scan(context.Background(), "clean", map[string]string{"a": "b"}); err != nil || v.Flagged {
+		t.Fatalf("expected clean verdict, got %+v %v", v, err)
+	}
+	if v, err := h.Scan(context.Background(), "X5O EICAR test", nil); err != nil || !v.Flagged || v.Reason != "Eicar-Test" {
+		t.Fatalf("expected flagged verdict, got %+v %v", v, err)
+	}
+	h.Token = "wrong"
+	if _, err := h.Scan(context.Background(), "clean", nil); err == nil {
+		t.Fatalf("expected an error for a refused request")
+	}
+}
+
+func TestClamdInstream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					return
+				}
+				var body []byte
+				for {
+					var size [4]byte
+					if _, err := io.ReadFull(r, size[:]); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size[:])
+					if n == 0 {
+						break
+					}
+					chunk := make([]byte, n)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					body = append(body, chunk...)
+				}
+				if strings.Contains(string(body), "EICAR") {
+					_, _ = conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					return
+				}
+				_, _ = conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+
+	c := NewClamd(ln.Addr().String())
+	if c.Network != "tcp" {
+		t.Fatalf("expected tcp network, got %q", c.Network)
+	}
+	v, err := c.Scan(context.Background(), strings.Repeat("a", clamdChunkSize+10), nil)
+	if err != nil || v.Flagged {
+		t.Fatalf("expected clean verdict, got %+v %v", v, err)
+	}
+	v, err = c.Scan(context.Background(), "X5O EICAR test", nil)
+	if err != nil || !v.Flagged || v.Reason != "Eicar-Signature" {
+		t.Fatalf("expected infected verdict, got %+v %v", v, err)
+	}
+}
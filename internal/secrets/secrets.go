@@ -0,0 +1,37 @@
+// Package secrets spots credentials pasted by accident, such as cloud access
+// keys, private keys and bearer tokens, so creators can be warned before
+// they publish them.
+package secrets
+
+import "regexp"
+
+// pattern recognises one kind of credential.
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// patterns favour well-known prefixes and framing over entropy guesses, so
+// ordinary text rarely trips them.
+var patterns = []pattern{
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+]{40}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY(?: BLOCK)?-----`)},
+	{"bearer token", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]{20,}=*`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Stripe secret key", regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{20,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+}
+
+// Detect returns the names of the kinds of credential found in text, each
+// once, or nil when it looks clean.
+func Detect(text string) []string {
+	var found []string
+	for _, p := range patterns {
+		if p.re.MatchString(text) {
+			found = append(found, p.name)
+		}
+	}
+	return found
+}
@@ -19,8 +19,37 @@ const (
 	saltLen      = 16
 )
 
+// Params are the Argon2id cost parameters of new hashes.
+type Params struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is the memory used, in KiB.
+	Memory uint32
+	// Threads is the degree of parallelism.
+	Threads uint8
+}
+
+// DefaultParams are the parameters HashPassword and SealContent use.
+var DefaultParams = Params{Time: argonTime, Memory: argonMemory, Threads: argonThreads}
+
+// Validate reports parameters Argon2id cannot run with.
+func (p Params) Validate() error {
+	if p.Time < 1 || p.Threads < 1 {
+		return errors.New("argon time and threads must be at least 1")
+	}
+	if p.Memory < 8*uint32(p.Threads) {
+		return fmt.Errorf("argon memory must be at least %d KiB for %d threads", 8*uint32(p.Threads), p.Threads)
+	}
+	return nil
+}
+
 // HashPassword hashes the provided password using Argon2id.
 func HashPassword(password string) (string, error) {
+	return DefaultParams.Hash(password)
+}
+
+// Hash hashes the provided password using Argon2id with p.
+func (p Params) Hash(password string) (string, error) {
 	if password == "" {
 		return "", nil
 	}
@@ -28,8 +57,22 @@ func HashPassword(password string) (string, error) {
 	if _, err := rand.Read(salt); err != nil {
 		return "", fmt.Errorf("generate salt: %w", err)
 	}
-	hash := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
-	return encodeHash(salt, hash), nil
+	hash := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, argonKeyLen)
+	return encodeHash(p, salt, hash), nil
+}
+
+// NeedsRehash reports whether encoded, a hash or sealed envelope, was made
+// with weaker parameters than p in any respect. Hashes that do not parse
+// are left alone.
+func (p Params) NeedsRehash(encoded string) bool {
+	if encoded == "" {
+		return false
+	}
+	old, _, _, err := decodeHash(encoded)
+	if err != nil {
+		return false
+	}
+	return old.Time < p.Time || old.Memory < p.Memory || old.Threads < p.Threads
 }
 
 // VerifyPassword checks whether the provided password matches the stored hash.
@@ -41,58 +84,52 @@ func VerifyPassword(encoded, password string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	hash := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(expected)))
+	hash := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(expected)))
 	if subtle.ConstantTimeCompare(hash, expected) == 1 {
 		return true, nil
 	}
 	return false, nil
 }
 
-type argonParams struct {
-	time    uint32
-	memory  uint32
-	threads uint8
-}
-
-func encodeHash(salt, hash []byte) string {
+func encodeHash(p Params, salt, hash []byte) string {
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
-	return fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s", argonMemory, argonTime, argonThreads, b64Salt, b64Hash)
+	return fmt.Sprintf("$argon2id$v=19$m=%d,t=%d,p=%d$%s$%s", p.Memory, p.Time, p.Threads, b64Salt, b64Hash)
 }
 
-func decodeHash(encoded string) (argonParams, []byte, []byte, error) {
+func decodeHash(encoded string) (Params, []byte, []byte, error) {
 	parts := strings.Split(encoded, "$")
 	if len(parts) != 6 {
-		return argonParams{}, nil, nil, errors.New("invalid hash format")
+		return Params{}, nil, nil, errors.New("invalid hash format")
 	}
 	if parts[1] != "argon2id" {
-		return argonParams{}, nil, nil, errors.New("invalid algorithm")
+		return Params{}, nil, nil, errors.New("invalid algorithm")
 	}
 	var (
-		params    argonParams
+		params    Params
 		memTmp    int
 		timeTmp   int
 		threadTmp int
 	)
 	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memTmp, &timeTmp, &threadTmp); err != nil {
-		return argonParams{}, nil, nil, fmt.Errorf("parse params: %w", err)
+		return Params{}, nil, nil, fmt.Errorf("parse params: %w", err)
 	}
 	if memTmp <= 0 || timeTmp <= 0 || threadTmp <= 0 {
-		return argonParams{}, nil, nil, errors.New("invalid argon params")
+		return Params{}, nil, nil, errors.New("invalid argon params")
 	}
-	params.memory = uint32(memTmp)
-	params.time = uint32(timeTmp)
+	params.Memory = uint32(memTmp)
+	params.Time = uint32(timeTmp)
 	if threadTmp > 255 {
-		return argonParams{}, nil, nil, errors.New("argon threads out of range")
+		return Params{}, nil, nil, errors.New("argon threads out of range")
 	}
-	params.threads = uint8(threadTmp)
+	params.Threads = uint8(threadTmp)
 	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
-		return argonParams{}, nil, nil, fmt.Errorf("decode salt: %w", err)
+		return Params{}, nil, nil, fmt.Errorf("decode salt: %w", err)
 	}
 	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
 	if err != nil {
-		return argonParams{}, nil, nil, fmt.Errorf("decode hash: %w", err)
+		return Params{}, nil, nil, fmt.Errorf("decode hash: %w", err)
 	}
 	return params, salt, hash, nil
 }
@@ -1,6 +1,11 @@
 package security
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
 
 func TestHashAndVerifyPassword(t *testing.T) {
 	hash, err := HashPassword("secret")
@@ -35,3 +40,67 @@ func TestVerifyEmpty(t *testing.T) {
 		t.Fatalf("expected empty passwords to match")
 	}
 }
+
+func TestSealContent(t *testing.T) {
+	envelope, key, err := SealContent("secret", "hello, world")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if strings.Contains(envelope, "hello") {
+		t.Fatal("expected the envelope not to contain the content")
+	}
+	derived, err := ContentKey(envelope, "secret")
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	if !bytes.Equal(derived, key) {
+		t.Fatal("expected the password to derive the sealing key")
+	}
+	if got, err := OpenContent(envelope, derived); err != nil || got != "hello, world" {
+		t.Fatalf("open = %q, %v", got, err)
+	}
+
+	wrong, err := ContentKey(envelope, "guess")
+	if err != nil {
+		t.Fatalf("derive wrong: %v", err)
+	}
+	if _, err := OpenContent(envelope, wrong); !errors.Is(err, ErrUnseal) {
+		t.Fatalf("expected a wrong password refused, got %v", err)
+	}
+	if _, err := OpenContent("not an envelope", key); err == nil {
+		t.Fatal("expected a malformed envelope refused")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weak := Params{Time: 1, Memory: 1024, Threads: 1}
+	hash, err := weak.Hash("secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if ok, err := VerifyPassword(hash, "secret"); err != nil || !ok {
+		t.Fatalf("expected the weak hash to verify, got %v, %v", ok, err)
+	}
+	if weak.NeedsRehash(hash) {
+		t.Fatal("expected no rehash under the same parameters")
+	}
+	if !DefaultParams.NeedsRehash(hash) {
+		t.Fatal("expected a rehash under stronger parameters")
+	}
+	if (Params{Time: 1, Memory: 512, Threads: 1}).NeedsRehash(hash) {
+		t.Fatal("expected no rehash under weaker parameters")
+	}
+	if DefaultParams.NeedsRehash("") || DefaultParams.NeedsRehash("plaintext") {
+		t.Fatal("expected empty and unparseable hashes left alone")
+	}
+
+	if err := (Params{Time: 1, Memory: 4, Threads: 1}).Validate(); err == nil {
+		t.Fatal("expected too little memory refused")
+	}
+	if err := (Params{Memory: 1024, Threads: 1}).Validate(); err == nil {
+		t.Fatal("expected zero passes refused")
+	}
+	if err := DefaultParams.Validate(); err != nil {
+		t.Fatalf("default params: %v", err)
+	}
+}
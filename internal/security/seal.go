@@ -0,0 +1,94 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// contentInfo binds keys derived for paste content to that one use.
+const contentInfo = "tiny-pastebin paste content v1"
+
+// ErrUnseal is returned when sealed content cannot be decrypted, because the
+// key or password is wrong or the envelope was altered.
+var ErrUnseal = errors.New("content cannot be decrypted")
+
+// SealContent encrypts content with AES-GCM under a key derived from
+// password with Argon2id and HKDF. The envelope records the Argon2
+// parameters and salt in the same form as HashPassword, followed by the
+// nonce and ciphertext. The key is returned too, so callers can unseal the
+// content again without repeating the derivation.
+func SealContent(password, content string) (envelope string, key []byte, err error) {
+	return DefaultParams.Seal(password, content)
+}
+
+// Seal is SealContent deriving the key with p.
+func (p Params) Seal(password, content string) (envelope string, key []byte, err error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", nil, fmt.Errorf("generate salt: %w", err)
+	}
+	key, err = deriveContentKey(password, p, salt)
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := contentCipher(key)
+	if err != nil {
+		return "", nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(content), nil)
+	return encodeHash(p, salt, sealed), key, nil
+}
+
+// ContentKey derives the key that sealed envelope from password. A wrong
+// password yields a key that OpenContent refuses.
+func ContentKey(envelope, password string) ([]byte, error) {
+	params, salt, _, err := decodeHash(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return deriveContentKey(password, params, salt)
+}
+
+// OpenContent decrypts an envelope made by SealContent with its key.
+func OpenContent(envelope string, key []byte) (string, error) {
+	_, _, sealed, err := decodeHash(envelope)
+	if err != nil {
+		return "", err
+	}
+	aead, err := contentCipher(key)
+	if err != nil {
+		return "", ErrUnseal
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrUnseal
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrUnseal
+	}
+	return string(plain), nil
+}
+
+func deriveContentKey(password string, params Params, salt []byte) ([]byte, error) {
+	secret := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, argonKeyLen)
+	return hkdf.Key(sha256.New, secret, salt, contentInfo, 32)
+}
+
+func contentCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
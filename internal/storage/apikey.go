@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// APIKey is a credential for the JSON API. Only a hash of the secret is stored.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	SecretHash string    `json:"secret_hash"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	// RateLimit is the sustained requests per second allowed for the key and
+	// Burst its bucket size; zero values fall back to the server defaults.
+	RateLimit float64 `json:"rate_limit,omitempty"`
+	Burst     int     `json:"burst,omitempty"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyStore is implemented by stores that can persist API keys. Lookups of
+// unknown keys return ErrNotFound.
+type APIKeyStore interface {
+	SaveAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// ExpiryStore is implemented by stores that index pastes by ExpiresAt, so
+// expired pastes can be found without decoding every paste.
+type ExpiryStore interface {
+	// IterateExpired calls fn with every paste that expires at or before
+	// before, in no particular order, stopping at the first error fn
+	// returns.
+	IterateExpired(ctx context.Context, before time.Time, fn func(*Paste) error) error
+}
+
+// IterateExpired calls fn with the pastes in s that DeleteExpired(before)
+// would remove, through s's expiry index when it keeps one and by scanning
+// every paste otherwise.
+func IterateExpired(ctx context.Context, s Store, before time.Time, fn func(*Paste) error) error {
+	if es, ok := As[ExpiryStore](s); ok {
+		return es.IterateExpired(ctx, before, fn)
+	}
+	return s.Iterate(ctx, func(p *Paste) error {
+		if !p.HasExpiration() || p.ExpiresAt.After(before) {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+// ArchivedAtKey is the metadata key under which archived pastes keep the
+// time they expired, formatted as RFC 3339.
+const ArchivedAtKey = "archive.expired_at"
+
+// WithArchive decorates store so DeleteExpired copies expired pastes into
+// archive before removing them, keeping their content recoverable after an
+// aggressive expiry. The archived copy records its original expiry under
+// ArchivedAtKey and instead expires retention later, so the archive's own
+// DeleteExpired, run by the same sweep, prunes it; zero retention keeps
+// archived pastes forever. Pastes removed by Delete are not archived.
+func WithArchive(store, archive Store, retention time.Duration) Store {
+	return &archiveStore{Store: store, archive: archive, retention: retention}
+}
+
+type archiveStore struct {
+	Store
+	archive   Store
+	retention time.Duration
+}
+
+func (a *archiveStore) Unwrap() Store { return a.Store }
+
+// DeleteExpired archives before deleting, so a paste is never gone from
+// both stores. A failed copy leaves the expired pastes in place for the next
+// sweep.
+func (a *archiveStore) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	err := IterateExpired(ctx, a.Store, before, func(p *Paste) error {
+		if err := a.archive.Save(ctx, archived(p, a.retention)); err != nil {
+			return fmt.Errorf("archive paste %s: %w", p.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, err := a.Store.DeleteExpired(ctx, before)
+	if err != nil {
+		return n, err
+	}
+	if _, err := a.archive.DeleteExpired(ctx, before); err != nil {
+		return n, fmt.Errorf("prune archive: %w", err)
+	}
+	return n, nil
+}
+
+// Close closes both stores.
+func (a *archiveStore) Close() error {
+	err := a.Store.Close()
+	if archiveErr := a.archive.Close(); err == nil {
+		err = archiveErr
+	}
+	return err
+}
+
+// archived returns the copy of an expired paste kept in the archive.
+func archived(p *Paste, retention time.Duration) *Paste {
+	cp := *p
+	cp.Metadata = maps.Clone(p.Metadata)
+	if cp.Metadata == nil {
+		cp.Metadata = make(map[string]string, 1)
+	}
+	cp.Metadata[ArchivedAtKey] = p.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	cp.ExpiresAt = time.Time{}
+	if retention > 0 {
+		cp.ExpiresAt = p.ExpiresAt.Add(retention)
+	}
+	return &cp
+}
+
+// Unarchive returns p as it was before it was archived, without an expiry,
+// and the time it originally expired.
+func Unarchive(p *Paste) (*Paste, time.Time) {
+	cp := *p
+	cp.ExpiresAt = time.Time{}
+	cp.Metadata = maps.Clone(p.Metadata)
+	expired, _ := time.Parse(time.RFC3339Nano, cp.Metadata[ArchivedAtKey])
+	delete(cp.Metadata, ArchivedAtKey)
+	if len(cp.Metadata) == 0 {
+		cp.Metadata = nil
+	}
+	return &cp, expired
+}
@@ -0,0 +1,66 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/storagetest"
+)
+
+func TestArchiveConformance(t *testing.T) {
+	storagetest.TestStore(t, func() storage.Store {
+		return storage.WithArchive(newMemstore(t), newMemstore(t), time.Hour)
+	})
+}
+
+func TestArchiveKeepsExpiredPastesForTheRetention(t *testing.T) {
+	ctx := context.Background()
+	archive := newMemstore(t)
+	store := storage.WithArchive(newMemstore(t), archive, 24*time.Hour)
+	now := time.Now().UTC()
+	for _, p := range []*storage.Paste{
+		{ID: "expired", Content: "recover me", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)},
+		{ID: "live", Content: "still here", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	} {
+		if err := store.Save(ctx, p); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	if n, err := store.DeleteExpired(ctx, now); err != nil || n != 1 {
+		t.Fatalf("delete expired: %d, %v", n, err)
+	}
+	if _, err := store.Get(ctx, "expired"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the expired paste gone from the store, got %v", err)
+	}
+	p, err := archive.Get(ctx, "expired")
+	if err != nil || p.Content != "recover me" {
+		t.Fatalf("expected the paste archived, got %+v (%v)", p, err)
+	}
+	restored, expiredAt := storage.Unarchive(p)
+	if !expiredAt.Equal(now.Add(-time.Minute)) || restored.HasExpiration() || restored.Metadata != nil {
+		t.Fatalf("expected the original expiry recorded, got %v and %+v", expiredAt, restored)
+	}
+	if _, err := archive.Get(ctx, "live"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected live pastes left out of the archive, got %v", err)
+	}
+
+	if _, err := store.DeleteExpired(ctx, now.Add(23*time.Hour)); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if _, err := archive.Get(ctx, "expired"); err != nil {
+		t.Fatalf("expected the paste kept within the retention, got %v", err)
+	}
+	if _, err := archive.Get(ctx, "live"); err != nil {
+		t.Fatalf("expected the paste that expired meanwhile archived, got %v", err)
+	}
+	if _, err := store.DeleteExpired(ctx, now.Add(25*time.Hour)); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if _, err := archive.Get(ctx, "expired"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the paste pruned after the retention, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// AuditEntry records one security-relevant action, such as a paste being
+// created, unlocked or deleted, a wrong password, or an admin action.
+// Entries are never changed once appended.
+type AuditEntry struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Action names what happened, such as "paste.created".
+	Action  string `json:"action"`
+	PasteID string `json:"paste_id,omitempty"`
+	// Actor is who acted: an admin identity, "owner", "link" and so on.
+	Actor  string `json:"actor,omitempty"`
+	IPHash string `json:"ip_hash,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// Country is the ISO code of the country the request came from, when
+	// GeoIP is configured.
+	Country string `json:"country,omitempty"`
+}
+
+// AuditQuery selects audit entries. Zero fields match everything.
+type AuditQuery struct {
+	Action  string
+	PasteID string
+	Actor   string
+	IPHash  string
+	// Since and Until bound the entry time, inclusive and exclusive.
+	Since time.Time
+	Until time.Time
+	// Limit caps the entries returned, newest first; zero means no cap.
+	Limit int
+}
+
+// Matches reports whether e is selected by q, ignoring Limit.
+func (q AuditQuery) Matches(e *AuditEntry) bool {
+	switch {
+	case q.Action != "" && e.Action != q.Action,
+		q.PasteID != "" && e.PasteID != q.PasteID,
+		q.Actor != "" && e.Actor != q.Actor,
+		q.IPHash != "" && e.IPHash != q.IPHash,
+		!q.Since.IsZero() && e.Time.Before(q.Since),
+		!q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// AuditStore is implemented by stores that can keep an append-only audit
+// log. Entries can only be removed by age, through PruneAudit.
+type AuditStore interface {
+	AppendAudit(ctx context.Context, entry *AuditEntry) error
+	// ListAudit returns the entries q selects, newest first.
+	ListAudit(ctx context.Context, q AuditQuery) ([]*AuditEntry, error)
+	// PruneAudit removes the entries older than before and returns how many
+	// it removed.
+	PruneAudit(ctx context.Context, before time.Time) (int, error)
+}
+
+// SortAudit orders entries newest first, breaking ties by ID.
+func SortAudit(entries []*AuditEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.After(entries[j].Time)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+}
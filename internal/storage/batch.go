@@ -0,0 +1,25 @@
+package storage
+
+import "context"
+
+// BatchStore is implemented by stores that can save several pastes in one
+// transaction.
+type BatchStore interface {
+	// SaveBatch saves every paste or, on error, none of them.
+	SaveBatch(ctx context.Context, pastes []*Paste) error
+}
+
+// SaveBatch saves pastes in s, atomically when s supports it. Other stores
+// save them one at a time, stopping at the first error, so a failure may
+// leave the earlier pastes saved.
+func SaveBatch(ctx context.Context, s Store, pastes []*Paste) error {
+	if bs, ok := As[BatchStore](s); ok {
+		return bs.SaveBatch(ctx, pastes)
+	}
+	for _, p := range pastes {
+		if err := s.Save(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// WithBlobs decorates store so the content of pastes of threshold bytes or
+// more is kept in one file per paste under dir, leaving only the metadata in
+// the backend. Large values then stay out of bolt pages, SQLite rows and JSON
+// encoding, and raw views stream them straight from disk. Large pastes saved
+// before blobs were enabled are moved out the first time they are read.
+func WithBlobs(store Store, dir string, threshold int) (Store, error) {
+	if threshold <= 0 {
+		return nil, errors.New("blob threshold must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create blob dir: %w", err)
+	}
+	return &blobStore{Store: store, dir: dir, threshold: threshold}, nil
+}
+
+type blobStore struct {
+	Store
+	dir       string
+	threshold int
+}
+
+func (b *blobStore) Unwrap() Store { return b.Store }
+
+// path names the blob file of a paste. IDs are hex encoded so any ID makes a
+// safe file name.
+func (b *blobStore) path(id string) string {
+	return filepath.Join(b.dir, hex.EncodeToString([]byte(id)))
+}
+
+func (b *blobStore) Save(ctx context.Context, paste *Paste) error {
+	cp := *paste
+	cp.InFile = false
+	if len(paste.Content) < b.threshold {
+		if err := b.Store.Save(ctx, &cp); err != nil {
+			return err
+		}
+		return b.removeBlob(paste.ID)
+	}
+	if err := b.writeBlob(paste.ID, paste.Content); err != nil {
+		return err
+	}
+	cp.Content, cp.InFile = "", true
+	return b.Store.Save(ctx, &cp)
+}
+
+// SaveBatch writes the blob files first, so the batch only commits records
+// whose content is already on disk. Files left by a failed batch are removed
+// by the next DeleteExpired.
+func (b *blobStore) SaveBatch(ctx context.Context, pastes []*Paste) error {
+	cps := make([]*Paste, len(pastes))
+	for i, paste := range pastes {
+		cp := *paste
+		cp.InFile = false
+		if len(paste.Content) >= b.threshold {
+			if err := b.writeBlob(paste.ID, paste.Content); err != nil {
+				return err
+			}
+			cp.Content, cp.InFile = "", true
+		}
+		cps[i] = &cp
+	}
+	if err := SaveBatch(ctx, b.Store, cps); err != nil {
+		return err
+	}
+	for _, cp := range cps {
+		if !cp.InFile {
+			if err := b.removeBlob(cp.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *blobStore) Get(ctx context.Context, id string) (*Paste, error) {
+	paste, err := b.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !paste.InFile {
+		if len(paste.Content) >= b.threshold {
+			// A failed move leaves the paste inline, to be retried on the
+			// next read.
+			_ = b.Save(ctx, paste)
+		}
+		return paste, nil
+	}
+	if err := b.load(paste); err != nil {
+		return nil, err
+	}
+	return paste, nil
+}
+
+func (b *blobStore) List(ctx context.Context, opts ListOptions) ([]*Paste, error) {
+	pastes, err := b.Store.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pastes {
+		if err := b.load(p); err != nil {
+			return nil, err
+		}
+	}
+	return pastes, nil
+}
+
+// Iterate loads blob content too, so tools copying between backends get
+// complete pastes.
+func (b *blobStore) Iterate(ctx context.Context, fn func(*Paste) error) error {
+	return b.Store.Iterate(ctx, func(p *Paste) error {
+		if err := b.load(p); err != nil {
+			return err
+		}
+		return fn(p)
+	})
+}
+
+// IterateChanged loads blob content like Iterate, using the backend's
+// update index when it has one.
+func (b *blobStore) IterateChanged(ctx context.Context, since time.Time, fn func(*Paste) error) error {
+	return IterateChanged(ctx, b.Store, since, func(p *Paste) error {
+		if err := b.load(p); err != nil {
+			return err
+		}
+		return fn(p)
+	})
+}
+
+// IterateExpired loads blob content like Iterate, so archived copies are
+// complete.
+func (b *blobStore) IterateExpired(ctx context.Context, before time.Time, fn func(*Paste) error) error {
+	return IterateExpired(ctx, b.Store, before, func(p *Paste) error {
+		if err := b.load(p); err != nil {
+			return err
+		}
+		return fn(p)
+	})
+}
+
+func (b *blobStore) Delete(ctx context.Context, id string) error {
+	if err := b.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	return b.removeBlob(id)
+}
+
+// DeleteExpired sweeps the backend and then removes the blobs whose record
+// is gone.
+func (b *blobStore) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	n, err := b.Store.DeleteExpired(ctx, before)
+	if err != nil {
+		return n, err
+	}
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return n, fmt.Errorf("read blob dir: %w", err)
+	}
+	for _, e := range entries {
+		id, err := hex.DecodeString(e.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := b.Store.Get(ctx, string(id)); errors.Is(err, ErrNotFound) {
+			if err := b.removeBlob(string(id)); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Totals adds the blob files to the backend's totals, which count blob
+// records as empty.
+func (b *blobStore) Totals(ctx context.Context) (Totals, error) {
+	t, err := CountTotals(ctx, b.Store)
+	if err != nil {
+		return t, err
+	}
+	n, err := b.blobBytes()
+	t.Bytes += n
+	return t, err
+}
+
+func (b *blobStore) Stats(ctx context.Context) (Stats, error) {
+	st, err := b.Store.Stats(ctx)
+	if err != nil {
+		return st, err
+	}
+	n, err := b.blobBytes()
+	st.Bytes += n
+	return st, err
+}
+
+// GetMeta fetches a paste without reading its blob.
+func (b *blobStore) GetMeta(ctx context.Context, id string) (*Paste, error) {
+	paste, err := b.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	paste.Size = len(paste.Content)
+	if paste.InFile {
+		fi, err := os.Stat(b.path(id))
+		if err != nil {
+			return nil, fmt.Errorf("stat blob %s: %w", id, err)
+		}
+		paste.Size, paste.InFile = int(fi.Size()), false
+	}
+	paste.Content = ""
+	return paste, nil
+}
+
+// GetContent opens the blob file of a paste, or reads inline content from
+// the record.
+func (b *blobStore) GetContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	paste, err := b.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !paste.InFile {
+		return io.NopCloser(strings.NewReader(paste.Content)), nil
+	}
+	f, err := os.Open(b.path(id))
+	if err != nil {
+		return nil, fmt.Errorf("open blob %s: %w", id, err)
+	}
+	return f, nil
+}
+
+// load fills in the content of a blob record.
+func (b *blobStore) load(p *Paste) error {
+	if !p.InFile {
+		return nil
+	}
+	data, err := os.ReadFile(b.path(p.ID))
+	if err != nil {
+		return fmt.Errorf("read blob %s: %w", p.ID, err)
+	}
+	p.Content, p.InFile = string(data), false
+	return nil
+}
+
+// writeBlob replaces the blob of a paste atomically, so readers never see a
+// partial file.
+func (b *blobStore) writeBlob(id, content string) error {
+	f, err := os.CreateTemp(b.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create blob: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.WriteString(f, content); err != nil {
+		f.Close()
+		return fmt.Errorf("write blob %s: %w", id, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write blob %s: %w", id, err)
+	}
+	if err := os.Rename(f.Name(), b.path(id)); err != nil {
+		return fmt.Errorf("write blob %s: %w", id, err)
+	}
+	return nil
+}
+
+func (b *blobStore) removeBlob(id string) error {
+	if err := os.Remove(b.path(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove blob %s: %w", id, err)
+	}
+	return nil
+}
+
+// blobBytes sums the size of the blob files.
+func (b *blobStore) blobBytes() (int64, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return 0, fmt.Errorf("read blob dir: %w", err)
+	}
+	var n int64
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		n += fi.Size()
+	}
+	return n, nil
+}
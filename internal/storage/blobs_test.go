@@ -0,0 +1,104 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/boltstore"
+	"tiny-pastebin/internal/storage/memstore"
+	"tiny-pastebin/internal/storage/sqlitestore"
+	"tiny-pastebin/internal/storage/storagetest"
+)
+
+func newBlobStore(t *testing.T, inner storage.Store, threshold int) (storage.Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store, err := storage.WithBlobs(inner, dir, threshold)
+	if err != nil {
+		t.Fatalf("with blobs: %v", err)
+	}
+	return store, dir
+}
+
+func newMemstore(t *testing.T) *memstore.Store {
+	t.Helper()
+	s, err := memstore.New(memstore.Options{})
+	if err != nil {
+		t.Fatalf("memstore: %v", err)
+	}
+	return s
+}
+
+// TestBlobConformance uses a tiny threshold so most pastes in the suite end up
+// in blob files, over each backend with its own record encoding.
+func TestBlobConformance(t *testing.T) {
+	backends := map[string]func() storage.Store{
+		"memory": func() storage.Store { return newMemstore(t) },
+		"bolt": func() storage.Store {
+			s, err := boltstore.Open(filepath.Join(t.TempDir(), "pastes.db"))
+			if err != nil {
+				t.Fatalf("open bolt: %v", err)
+			}
+			return s
+		},
+		"sqlite": func() storage.Store {
+			s, err := sqlitestore.Open(filepath.Join(t.TempDir(), "pastes.sqlite"))
+			if err != nil {
+				t.Fatalf("open sqlite: %v", err)
+			}
+			return s
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			storagetest.TestStore(t, func() storage.Store {
+				s, _ := newBlobStore(t, open(), 4)
+				return s
+			})
+		})
+	}
+}
+
+func TestBlobsMoveLargeContentOutOfTheBackend(t *testing.T) {
+	ctx := context.Background()
+	inner := newMemstore(t)
+	store, dir := newBlobStore(t, inner, 10)
+	large := strings.Repeat("x", 32)
+
+	if err := inner.Save(ctx, &storage.Paste{ID: "old", Content: large, Size: len(large), CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("save inline: %v", err)
+	}
+	got, err := store.Get(ctx, "old")
+	if err != nil || got.Content != large || got.InFile {
+		t.Fatalf("get old: %+v, %v", got, err)
+	}
+	raw, _ := inner.Get(ctx, "old")
+	if !raw.InFile || raw.Content != "" {
+		t.Fatalf("expected old paste migrated on read, got %+v", raw)
+	}
+
+	if err := store.Save(ctx, &storage.Paste{ID: "old", Content: "short", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("shrink: %v", err)
+	}
+	if raw, _ := inner.Get(ctx, "old"); raw.InFile || raw.Content != "short" {
+		t.Fatalf("expected shrunk paste back inline, got %+v", raw)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected blob removed, found %d files", len(entries))
+	}
+
+	if err := store.Save(ctx, &storage.Paste{ID: "new", Content: large, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("save large: %v", err)
+	}
+	if err := store.Delete(ctx, "new"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "6e6577")); !os.IsNotExist(err) {
+		t.Fatalf("expected blob deleted with its paste, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package boltstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"tiny-pastebin/internal/storage"
+)
+
+var auditBucket = []byte("audit")
+
+// AppendAudit adds an entry to the audit log.
+func (s *Store) AppendAudit(ctx context.Context, entry *storage.AuditEntry) error {
+	if entry == nil {
+		return errors.New("audit entry is nil")
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	cp := *entry
+	cp.Time = cp.Time.UTC()
+	data, err := json.Marshal(&cp)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(auditBucket)
+		if bucket == nil {
+			return errors.New("audit bucket missing")
+		}
+		if err := bucket.Put([]byte(cp.ID), data); err != nil {
+			return fmt.Errorf("append audit entry: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListAudit returns the entries q selects, newest first.
+func (s *Store) ListAudit(ctx context.Context, q storage.AuditQuery) ([]*storage.AuditEntry, error) {
+	var out []*storage.AuditEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(auditBucket)
+		if bucket == nil {
+			return errors.New("audit bucket missing")
+		}
+		n := 0
+		return bucket.ForEach(func(_, raw []byte) error {
+			if n++; n%checkEvery == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			var e storage.AuditEntry
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return fmt.Errorf("unmarshal audit entry: %w", err)
+			}
+			if q.Matches(&e) {
+				out = append(out, &e)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	storage.SortAudit(out)
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// PruneAudit removes the entries older than before.
+func (s *Store) PruneAudit(ctx context.Context, before time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(auditBucket)
+		if bucket == nil {
+			return errors.New("audit bucket missing")
+		}
+		var stale [][]byte
+		err := bucket.ForEach(func(k, raw []byte) error {
+			var e storage.AuditEntry
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return fmt.Errorf("unmarshal audit entry: %w", err)
+			}
+			if e.Time.Before(before) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return fmt.Errorf("prune audit log: %w", err)
+			}
+		}
+		removed = len(stale)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,131 @@
+package boltstore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// Paste bodies are stored once per distinct content in the blobs bucket,
+// keyed by the hex SHA-256 of the content. Each blob value is an 8-byte
+// reference count followed by the content; the paste record keeps its
+// metadata plus the blob hash. Reference counts change in the same
+// transaction as the paste records, so a blob is dropped exactly when the
+// last paste using it goes. Records written before deduplication carry their
+// content inline and are read as they are.
+
+var blobBucket = []byte("blobs")
+
+// record is the stored form of a paste.
+type record struct {
+	storage.Paste
+	Blob string `json:"blob,omitempty"`
+}
+
+// encodePaste stores the content of p as a blob, taking a reference on it,
+// and returns the record to put in the paste bucket.
+func encodePaste(tx *bolt.Tx, p *storage.Paste) ([]byte, error) {
+	rec := record{Paste: *p}
+	if p.Content != "" {
+		sum := sha256.Sum256([]byte(p.Content))
+		rec.Blob = hex.EncodeToString(sum[:])
+		rec.Content = ""
+		if err := retainBlob(tx, rec.Blob, p.Content); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal paste: %w", err)
+	}
+	return data, nil
+}
+
+// decodeRecord decodes a stored paste without resolving its blob.
+func decodeRecord(raw []byte) (*record, error) {
+	var rec record
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, fmt.Errorf("unmarshal paste: %w", err)
+	}
+	return &rec, nil
+}
+
+// decodePaste decodes a stored paste and fills in its content.
+func decodePaste(tx *bolt.Tx, raw []byte) (*storage.Paste, error) {
+	rec, err := decodeRecord(raw)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Blob != "" {
+		val := tx.Bucket(blobBucket).Get([]byte(rec.Blob))
+		if len(val) < 8 {
+			return nil, fmt.Errorf("paste %s: blob %s missing", rec.ID, rec.Blob)
+		}
+		rec.Content = string(val[8:])
+	}
+	return &rec.Paste, nil
+}
+
+// recordSize reports the content length of a stored paste.
+func recordSize(tx *bolt.Tx, rec *record) int64 {
+	if rec.Blob == "" {
+		return int64(len(rec.Content))
+	}
+	if val := tx.Bucket(blobBucket).Get([]byte(rec.Blob)); len(val) >= 8 {
+		return int64(len(val) - 8)
+	}
+	return 0
+}
+
+func retainBlob(tx *bolt.Tx, hash, content string) error {
+	bucket := tx.Bucket(blobBucket)
+	key := []byte(hash)
+	var val []byte
+	if cur := bucket.Get(key); len(cur) >= 8 {
+		val = make([]byte, len(cur))
+		copy(val, cur)
+		binary.BigEndian.PutUint64(val, binary.BigEndian.Uint64(cur)+1)
+	} else {
+		val = make([]byte, 8+len(content))
+		binary.BigEndian.PutUint64(val, 1)
+		copy(val[8:], content)
+	}
+	if err := bucket.Put(key, val); err != nil {
+		return fmt.Errorf("store blob: %w", err)
+	}
+	return nil
+}
+
+// releaseBlob drops one reference to the blob of rec, deleting the blob with
+// its last reference.
+func releaseBlob(tx *bolt.Tx, rec *record) error {
+	if rec.Blob == "" {
+		return nil
+	}
+	bucket := tx.Bucket(blobBucket)
+	key := []byte(rec.Blob)
+	cur := bucket.Get(key)
+	if len(cur) < 8 {
+		return nil
+	}
+	refs := binary.BigEndian.Uint64(cur)
+	if refs <= 1 {
+		if err := bucket.Delete(key); err != nil {
+			return fmt.Errorf("delete blob: %w", err)
+		}
+		return nil
+	}
+	val := make([]byte, len(cur))
+	copy(val, cur)
+	binary.BigEndian.PutUint64(val, refs-1)
+	if err := bucket.Put(key, val); err != nil {
+		return fmt.Errorf("release blob: %w", err)
+	}
+	return nil
+}
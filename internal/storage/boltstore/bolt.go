@@ -6,6 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -13,18 +17,67 @@ import (
 	"tiny-pastebin/internal/storage"
 )
 
+// DefaultDeleteBatchSize bounds how many expired pastes one DeleteExpired
+// transaction removes when Options leaves it unset.
+const DefaultDeleteBatchSize = 1000
+
+// checkEvery is how often scans poll ctx.
+var checkEvery = 256
+
 var (
 	pasteBucket  = []byte("pastes")
 	expireBucket = []byte("expires")
+	updateBucket = []byte("updates")
+	apiKeyBucket = []byte("apikeys")
+	reportBucket = []byte("reports")
+	metaBucket   = []byte("meta")
+	totalsKey    = []byte("totals")
 )
 
+func init() {
+	storage.Register("bolt", openDSN)
+}
+
+// openDSN opens bolt:///path.db, optionally with ?delete_batch=N.
+func openDSN(dsn string) (storage.Store, error) {
+	path, query, _ := strings.Cut(storage.DSNPath(dsn), "?")
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("parse bolt dsn: %w", err)
+	}
+	var opts Options
+	if v := q.Get("delete_batch"); v != "" {
+		if opts.DeleteBatchSize, err = strconv.Atoi(v); err != nil || opts.DeleteBatchSize <= 0 {
+			return nil, fmt.Errorf("invalid delete_batch %q", v)
+		}
+	}
+	return OpenOptions(path, opts)
+}
+
+// Options tunes a bolt store.
+type Options struct {
+	// DeleteBatchSize caps the expired pastes removed per write transaction,
+	// so a large backlog never holds the write lock for long. Zero means
+	// DefaultDeleteBatchSize.
+	DeleteBatchSize int
+}
+
 // Store implements storage.Store backed by BoltDB.
 type Store struct {
-	db *bolt.DB
+	db          *bolt.DB
+	deleteBatch int
 }
 
 // Open initializes a BoltDB-backed store located at path.
 func Open(path string) (*Store, error) {
+	return OpenOptions(path, Options{})
+}
+
+// OpenOptions is Open with explicit options.
+func OpenOptions(path string, opts Options) (*Store, error) {
+	if opts.DeleteBatchSize <= 0 {
+		opts.DeleteBatchSize = DefaultDeleteBatchSize
+	}
 	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
 	if err != nil {
 		return nil, fmt.Errorf("open bolt db: %w", err)
@@ -37,19 +90,50 @@ func Open(path string) (*Store, error) {
 		if _, err := tx.CreateBucketIfNotExists(expireBucket); err != nil {
 			return fmt.Errorf("create expire bucket: %w", err)
 		}
+		if _, err := tx.CreateBucketIfNotExists(updateBucket); err != nil {
+			return fmt.Errorf("create update bucket: %w", err)
+		}
+		if _, err := tx.CreateBucketIfNotExists(apiKeyBucket); err != nil {
+			return fmt.Errorf("create api key bucket: %w", err)
+		}
+		if _, err := tx.CreateBucketIfNotExists(reportBucket); err != nil {
+			return fmt.Errorf("create report bucket: %w", err)
+		}
+		if _, err := tx.CreateBucketIfNotExists(blobBucket); err != nil {
+			return fmt.Errorf("create blob bucket: %w", err)
+		}
+		if _, err := tx.CreateBucketIfNotExists(auditBucket); err != nil {
+			return fmt.Errorf("create audit bucket: %w", err)
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return fmt.Errorf("create meta bucket: %w", err)
+		}
+		if meta.Get(totalsKey) == nil {
+			return initTotals(tx)
+		}
 		return nil
 	}); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
 
-	return &Store{db: db}, nil
+	return &Store{db: db, deleteBatch: opts.DeleteBatchSize}, nil
 }
 
-// Save persists or updates a paste entry.
+// Save persists or updates a paste entry. The content goes to a shared blob,
+// so identical bodies are stored once.
 func (s *Store) Save(ctx context.Context, paste *storage.Paste) error {
-	if paste == nil {
-		return errors.New("paste is nil")
+	return s.SaveBatch(ctx, []*storage.Paste{paste})
+}
+
+// SaveBatch saves several pastes in one transaction, so either all of them
+// are stored or none is. A batch interrupted by ctx is rolled back.
+func (s *Store) SaveBatch(ctx context.Context, pastes []*storage.Paste) error {
+	for _, paste := range pastes {
+		if paste == nil {
+			return errors.New("paste is nil")
+		}
 	}
 	select {
 	case <-ctx.Done():
@@ -57,43 +141,75 @@ func (s *Store) Save(ctx context.Context, paste *storage.Paste) error {
 	default:
 	}
 
+	return s.db.Update(func(tx *bolt.Tx) error {
+		scanned := 0
+		for _, paste := range pastes {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			if err := putPaste(tx, paste); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// putPaste writes one paste with its indexes, blob reference and totals.
+func putPaste(tx *bolt.Tx, paste *storage.Paste) error {
 	// Normalize timestamps to UTC for consistency.
 	paste.CreatedAt = paste.CreatedAt.UTC()
 	paste.ExpiresAt = paste.ExpiresAt.UTC()
+	paste.UpdatedAt = paste.UpdatedAt.UTC()
 
-	data, err := json.Marshal(paste)
-	if err != nil {
-		return fmt.Errorf("marshal paste: %w", err)
+	pBucket := tx.Bucket(pasteBucket)
+	eBucket := tx.Bucket(expireBucket)
+	if pBucket == nil || eBucket == nil {
+		return errors.New("buckets not initialized")
 	}
 
-	return s.db.Update(func(tx *bolt.Tx) error {
-		pBucket := tx.Bucket(pasteBucket)
-		eBucket := tx.Bucket(expireBucket)
-		if pBucket == nil || eBucket == nil {
-			return errors.New("buckets not initialized")
-		}
+	// Take the new reference before releasing the old one, so resaving
+	// unchanged content never drops its blob.
+	data, err := encodePaste(tx, paste)
+	if err != nil {
+		return err
+	}
 
-		if existing := pBucket.Get([]byte(paste.ID)); existing != nil {
-			var prev storage.Paste
-			if err := json.Unmarshal(existing, &prev); err == nil && prev.HasExpiration() {
-				if err := eBucket.Delete(expireKey(prev.ExpiresAt, prev.ID)); err != nil {
+	delta := storage.Totals{Pastes: 1, Bytes: int64(len(paste.Content))}
+	if existing := pBucket.Get([]byte(paste.ID)); existing != nil {
+		delta.Pastes = 0
+		if prev, err := decodeRecord(existing); err == nil {
+			delta.Bytes -= recordSize(tx, prev)
+			if err := releaseBlob(tx, prev); err != nil {
+				return err
+			}
+			if prev.HasExpiration() {
+				if err := eBucket.Delete(timeKey(prev.ExpiresAt, prev.ID)); err != nil {
 					return fmt.Errorf("remove previous expiry index: %w", err)
 				}
 			}
+			if err := unindexUpdate(tx, &prev.Paste); err != nil {
+				return err
+			}
 		}
+	}
 
-		if err := pBucket.Put([]byte(paste.ID), data); err != nil {
-			return fmt.Errorf("save paste: %w", err)
-		}
+	if err := pBucket.Put([]byte(paste.ID), data); err != nil {
+		return fmt.Errorf("save paste: %w", err)
+	}
 
-		if paste.HasExpiration() {
-			if err := eBucket.Put(expireKey(paste.ExpiresAt, paste.ID), []byte(paste.ID)); err != nil {
-				return fmt.Errorf("index expiry: %w", err)
-			}
+	if paste.HasExpiration() {
+		if err := eBucket.Put(timeKey(paste.ExpiresAt, paste.ID), []byte(paste.ID)); err != nil {
+			return fmt.Errorf("index expiry: %w", err)
+		}
+	}
+	if !paste.UpdatedAt.IsZero() {
+		if err := tx.Bucket(updateBucket).Put(timeKey(paste.UpdatedAt, paste.ID), []byte(paste.ID)); err != nil {
+			return fmt.Errorf("index update: %w", err)
 		}
+	}
 
-		return nil
-	})
+	return adjustTotals(tx, delta)
 }
 
 // Get retrieves a paste by id.
@@ -114,11 +230,11 @@ func (s *Store) Get(ctx context.Context, id string) (*storage.Paste, error) {
 		if raw == nil {
 			return storage.ErrNotFound
 		}
-		var paste storage.Paste
-		if err := json.Unmarshal(raw, &paste); err != nil {
-			return fmt.Errorf("unmarshal paste: %w", err)
+		paste, err := decodePaste(tx, raw)
+		if err != nil {
+			return err
 		}
-		out = &paste
+		out = paste
 		return nil
 	})
 
@@ -143,29 +259,59 @@ func (s *Store) Delete(ctx context.Context, id string) error {
 		if raw == nil {
 			return storage.ErrNotFound
 		}
-		var paste storage.Paste
-		if err := json.Unmarshal(raw, &paste); err == nil && paste.HasExpiration() {
-			if err := eBucket.Delete(expireKey(paste.ExpiresAt, paste.ID)); err != nil {
-				return fmt.Errorf("delete expiry index: %w", err)
+		delta := storage.Totals{Pastes: -1}
+		if rec, err := decodeRecord(raw); err == nil {
+			delta.Bytes = -recordSize(tx, rec)
+			if err := releaseBlob(tx, rec); err != nil {
+				return err
+			}
+			if rec.HasExpiration() {
+				if err := eBucket.Delete(timeKey(rec.ExpiresAt, rec.ID)); err != nil {
+					return fmt.Errorf("delete expiry index: %w", err)
+				}
+			}
+			if err := unindexUpdate(tx, &rec.Paste); err != nil {
+				return err
 			}
 		}
 		if err := pBucket.Delete([]byte(id)); err != nil {
 			return fmt.Errorf("delete paste: %w", err)
 		}
-		return nil
+		return adjustTotals(tx, delta)
 	})
 }
 
-// DeleteExpired removes all pastes with expiry before or equal to the provided time.
+// DeleteExpired removes all pastes with expiry before or equal to the provided
+// time. It works in batches, one short write transaction each, yielding
+// between them so readers and writers are not starved by a large backlog.
+// Every batch re-reads the expiry index, so concurrent sweeps and saves are
+// safe. Once ctx is done it stops, between batches or partway through one,
+// keeping the pastes already removed and returning their count with ctx's
+// error.
 func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
-	select {
-	case <-ctx.Done():
-		return 0, ctx.Err()
-	default:
+	cutoff := toTimestamp(before.UTC())
+	var removed int
+	for {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		n, err := s.deleteExpiredBatch(ctx, cutoff)
+		removed += n
+		if err != nil || n < s.deleteBatch {
+			return removed, err
+		}
+		runtime.Gosched()
 	}
+}
 
-	before = before.UTC()
-	var removed int
+// deleteExpiredBatch removes up to deleteBatch expired pastes in one
+// transaction. When ctx is done partway through it commits what it removed
+// so far and returns ctx's error.
+func (s *Store) deleteExpiredBatch(ctx context.Context, cutoff uint64) (int, error) {
+	var (
+		removed int
+		stopped error
+	)
 	err := s.db.Update(func(tx *bolt.Tx) error {
 		pBucket := tx.Bucket(pasteBucket)
 		eBucket := tx.Bucket(expireBucket)
@@ -173,14 +319,30 @@ func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int, error
 			return errors.New("buckets not initialized")
 		}
 
+		var delta storage.Totals
+		scanned := 0
 		cursor := eBucket.Cursor()
-		cutoff := toTimestamp(before)
-		for key, val := cursor.First(); key != nil; key, val = cursor.Next() {
+		for key, val := cursor.First(); key != nil && removed < s.deleteBatch; key, val = cursor.Next() {
 			ts := binary.BigEndian.Uint64(key[:8])
 			if ts > cutoff {
 				break
 			}
+			if stopped = poll(ctx, &scanned); stopped != nil {
+				break
+			}
 			id := string(val)
+			if raw := pBucket.Get(val); raw != nil {
+				delta.Pastes--
+				if rec, err := decodeRecord(raw); err == nil {
+					delta.Bytes -= recordSize(tx, rec)
+					if err := releaseBlob(tx, rec); err != nil {
+						return err
+					}
+					if err := unindexUpdate(tx, &rec.Paste); err != nil {
+						return err
+					}
+				}
+			}
 			if err := pBucket.Delete([]byte(id)); err != nil {
 				return fmt.Errorf("delete expired paste %s: %w", id, err)
 			}
@@ -189,10 +351,437 @@ func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int, error
 			}
 			removed++
 		}
+		return adjustTotals(tx, delta)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, stopped
+}
+
+// List scans all pastes and returns those matching opts, newest first.
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) ([]*storage.Paste, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	var out []*storage.Paste
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pasteBucket)
+		if bucket == nil {
+			return errors.New("pastes bucket missing")
+		}
+		scanned := 0
+		return bucket.ForEach(func(_, raw []byte) error {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			rec, err := decodeRecord(raw)
+			if err != nil {
+				return err
+			}
+			if !opts.Match(&rec.Paste) {
+				return nil
+			}
+			paste, err := decodePaste(tx, raw)
+			if err != nil {
+				return err
+			}
+			out = append(out, paste)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	storage.SortNewestFirst(out)
+	return opts.Page(out), nil
+}
+
+// Count returns the number of pastes matching opts.
+func (s *Store) Count(ctx context.Context, opts storage.ListOptions) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	var n int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pasteBucket)
+		if bucket == nil {
+			return errors.New("pastes bucket missing")
+		}
+		scanned := 0
+		return bucket.ForEach(func(_, raw []byte) error {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			rec, err := decodeRecord(raw)
+			if err != nil {
+				return err
+			}
+			if opts.Match(&rec.Paste) {
+				n++
+			}
+			return nil
+		})
+	})
+	return n, err
+}
+
+// Iterate calls fn with every stored paste from a single read transaction.
+func (s *Store) Iterate(ctx context.Context, fn func(*storage.Paste) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pasteBucket)
+		if bucket == nil {
+			return errors.New("pastes bucket missing")
+		}
+		scanned := 0
+		return bucket.ForEach(func(_, raw []byte) error {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			paste, err := decodePaste(tx, raw)
+			if err != nil {
+				return err
+			}
+			return fn(paste)
+		})
+	})
+}
+
+// IterateChanged calls fn with the pastes saved after since, walking the
+// update index from that point.
+func (s *Store) IterateChanged(ctx context.Context, since time.Time, fn func(*storage.Paste) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		pBucket := tx.Bucket(pasteBucket)
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, toTimestamp(since)+1)
+		scanned := 0
+		cursor := tx.Bucket(updateBucket).Cursor()
+		for key, id := cursor.Seek(start); key != nil; key, id = cursor.Next() {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			raw := pBucket.Get(id)
+			if raw == nil {
+				continue
+			}
+			paste, err := decodePaste(tx, raw)
+			if err != nil {
+				return err
+			}
+			if err := fn(paste); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IterateExpired calls fn with the pastes expiring at or before before,
+// walking the expiry index up to that point.
+func (s *Store) IterateExpired(ctx context.Context, before time.Time, fn func(*storage.Paste) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cutoff := toTimestamp(before.UTC())
+	return s.db.View(func(tx *bolt.Tx) error {
+		pBucket := tx.Bucket(pasteBucket)
+		scanned := 0
+		cursor := tx.Bucket(expireBucket).Cursor()
+		for key, id := cursor.First(); key != nil && binary.BigEndian.Uint64(key[:8]) <= cutoff; key, id = cursor.Next() {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			raw := pBucket.Get(id)
+			if raw == nil {
+				continue
+			}
+			paste, err := decodePaste(tx, raw)
+			if err != nil {
+				return err
+			}
+			if err := fn(paste); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
+}
 
-	return removed, err
+// unindexUpdate removes p from the update index.
+func unindexUpdate(tx *bolt.Tx, p *storage.Paste) error {
+	if p.UpdatedAt.IsZero() {
+		return nil
+	}
+	if err := tx.Bucket(updateBucket).Delete(timeKey(p.UpdatedAt, p.ID)); err != nil {
+		return fmt.Errorf("delete update index: %w", err)
+	}
+	return nil
+}
+
+// Totals reports the running paste count and content size.
+func (s *Store) Totals(ctx context.Context) (storage.Totals, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Totals{}, err
+	}
+	var t storage.Totals
+	err := s.db.View(func(tx *bolt.Tx) error {
+		t = readTotals(tx)
+		return nil
+	})
+	return t, err
+}
+
+// Stats reports the stored pastes: totals from the running counters,
+// expiring counts from the expiry index and syntaxes from one scan of the
+// records, without loading their content.
+func (s *Store) Stats(ctx context.Context) (storage.Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Stats{}, err
+	}
+	now := time.Now().UTC()
+	st := storage.Stats{BySyntax: make(map[string]int64)}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		t := readTotals(tx)
+		st.Pastes, st.Bytes = t.Pastes, t.Bytes
+
+		cursor := tx.Bucket(expireBucket).Cursor()
+		horizon := toTimestamp(now.Add(storage.ExpiringToday))
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, toTimestamp(now)+1)
+		for key, _ := cursor.Seek(start); key != nil; key, _ = cursor.Next() {
+			ts := binary.BigEndian.Uint64(key[:8])
+			if ts > horizon {
+				break
+			}
+			st.AddExpiry(time.Unix(0, int64(ts)), now)
+		}
+
+		scanned := 0
+		return tx.Bucket(pasteBucket).ForEach(func(_, raw []byte) error {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			rec, err := decodeRecord(raw)
+			if err != nil {
+				return err
+			}
+			st.BySyntax[rec.Syntax]++
+			return nil
+		})
+	})
+	if err != nil {
+		return storage.Stats{}, err
+	}
+	return st, nil
+}
+
+// Ping opens a read transaction and checks the buckets are in place.
+func (s *Store) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{pasteBucket, expireBucket, metaBucket} {
+			if tx.Bucket(name) == nil {
+				return fmt.Errorf("bucket %s missing", name)
+			}
+		}
+		return nil
+	})
+}
+
+// SaveAPIKey persists or replaces an API key.
+func (s *Store) SaveAPIKey(ctx context.Context, key *storage.APIKey) error {
+	if key == nil {
+		return errors.New("api key is nil")
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal api key: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(apiKeyBucket)
+		if bucket == nil {
+			return errors.New("api key bucket missing")
+		}
+		if err := bucket.Put([]byte(key.ID), data); err != nil {
+			return fmt.Errorf("save api key: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetAPIKey retrieves an API key by id.
+func (s *Store) GetAPIKey(ctx context.Context, id string) (*storage.APIKey, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	var out *storage.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(apiKeyBucket)
+		if bucket == nil {
+			return errors.New("api key bucket missing")
+		}
+		raw := bucket.Get([]byte(id))
+		if raw == nil {
+			return storage.ErrNotFound
+		}
+		var key storage.APIKey
+		if err := json.Unmarshal(raw, &key); err != nil {
+			return fmt.Errorf("unmarshal api key: %w", err)
+		}
+		out = &key
+		return nil
+	})
+	return out, err
+}
+
+// ListAPIKeys returns every API key ordered by id.
+func (s *Store) ListAPIKeys(ctx context.Context) ([]*storage.APIKey, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	var out []*storage.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(apiKeyBucket)
+		if bucket == nil {
+			return errors.New("api key bucket missing")
+		}
+		return bucket.ForEach(func(_, raw []byte) error {
+			var key storage.APIKey
+			if err := json.Unmarshal(raw, &key); err != nil {
+				return fmt.Errorf("unmarshal api key: %w", err)
+			}
+			out = append(out, &key)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// DeleteAPIKey revokes an API key.
+func (s *Store) DeleteAPIKey(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(apiKeyBucket)
+		if bucket == nil {
+			return errors.New("api key bucket missing")
+		}
+		if bucket.Get([]byte(id)) == nil {
+			return storage.ErrNotFound
+		}
+		if err := bucket.Delete([]byte(id)); err != nil {
+			return fmt.Errorf("delete api key: %w", err)
+		}
+		return nil
+	})
+}
+
+// SaveReport persists or replaces an abuse report.
+func (s *Store) SaveReport(ctx context.Context, report *storage.Report) error {
+	if report == nil {
+		return errors.New("report is nil")
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(reportBucket)
+		if bucket == nil {
+			return errors.New("report bucket missing")
+		}
+		if err := bucket.Put([]byte(report.ID), data); err != nil {
+			return fmt.Errorf("save report: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListReports returns every report, oldest first.
+func (s *Store) ListReports(ctx context.Context) ([]*storage.Report, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	var out []*storage.Report
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(reportBucket)
+		if bucket == nil {
+			return errors.New("report bucket missing")
+		}
+		return bucket.ForEach(func(_, raw []byte) error {
+			var report storage.Report
+			if err := json.Unmarshal(raw, &report); err != nil {
+				return fmt.Errorf("unmarshal report: %w", err)
+			}
+			out = append(out, &report)
+			return nil
+		})
+	})
+	storage.SortReports(out)
+	return out, err
+}
+
+// DeleteReport removes a report.
+func (s *Store) DeleteReport(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(reportBucket)
+		if bucket == nil {
+			return errors.New("report bucket missing")
+		}
+		if bucket.Get([]byte(id)) == nil {
+			return storage.ErrNotFound
+		}
+		if err := bucket.Delete([]byte(id)); err != nil {
+			return fmt.Errorf("delete report: %w", err)
+		}
+		return nil
+	})
 }
 
 // Close closes the underlying database.
@@ -203,7 +792,67 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
-func expireKey(t time.Time, id string) []byte {
+// initTotals seeds the running totals of a database written before they were
+// kept, with one full scan.
+func initTotals(tx *bolt.Tx) error {
+	var t storage.Totals
+	if err := tx.Bucket(pasteBucket).ForEach(func(_, raw []byte) error {
+		t.Pastes++
+		if rec, err := decodeRecord(raw); err == nil {
+			t.Bytes += recordSize(tx, rec)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("count pastes: %w", err)
+	}
+	return writeTotals(tx, t)
+}
+
+func readTotals(tx *bolt.Tx) storage.Totals {
+	raw := tx.Bucket(metaBucket).Get(totalsKey)
+	if len(raw) != 16 {
+		return storage.Totals{}
+	}
+	return storage.Totals{
+		Pastes: int64(binary.BigEndian.Uint64(raw[:8])),
+		Bytes:  int64(binary.BigEndian.Uint64(raw[8:])),
+	}
+}
+
+func writeTotals(tx *bolt.Tx, t storage.Totals) error {
+	raw := make([]byte, 16)
+	binary.BigEndian.PutUint64(raw[:8], uint64(t.Pastes))
+	binary.BigEndian.PutUint64(raw[8:], uint64(t.Bytes))
+	if err := tx.Bucket(metaBucket).Put(totalsKey, raw); err != nil {
+		return fmt.Errorf("update totals: %w", err)
+	}
+	return nil
+}
+
+// adjustTotals applies delta within the transaction that changed the pastes,
+// so the totals commit or roll back with them.
+func adjustTotals(tx *bolt.Tx, delta storage.Totals) error {
+	if delta == (storage.Totals{}) {
+		return nil
+	}
+	t := readTotals(tx)
+	t.Pastes += delta.Pastes
+	t.Bytes += delta.Bytes
+	return writeTotals(tx, t)
+}
+
+// poll reports ctx's error every checkEvery calls, so long scans abort
+// promptly without paying for a check on every key.
+func poll(ctx context.Context, n *int) error {
+	*n++
+	if *n%checkEvery != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// timeKey orders the expiry and update indexes by time, then id.
+func timeKey(t time.Time, id string) []byte {
 	key := make([]byte, 8+len(id))
 	binary.BigEndian.PutUint64(key, toTimestamp(t))
 	copy(key[8:], id)
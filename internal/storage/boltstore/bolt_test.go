@@ -2,11 +2,22 @@ package boltstore
 
 import (
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	bolt "go.etcd.io/bbolt"
+
 	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/storagetest"
 )
 
 func TestStoreCRUD(t *testing.T) {
@@ -46,6 +57,28 @@ func TestStoreCRUD(t *testing.T) {
 	}
 }
 
+func TestSaveBatchIsAtomic(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	err = store.SaveBatch(ctx, []*storage.Paste{{ID: "a", Content: "x", CreatedAt: now}, nil})
+	if err == nil {
+		t.Fatalf("expected a batch with a nil paste rejected")
+	}
+	if _, err := store.Get(ctx, "a"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected nothing saved, got %v", err)
+	}
+	totals, err := store.Totals(ctx)
+	if err != nil || totals.Pastes != 0 {
+		t.Fatalf("expected empty totals, got %+v (%v)", totals, err)
+	}
+}
+
 func TestDeleteExpired(t *testing.T) {
 	dir := t.TempDir()
 	store, err := Open(filepath.Join(dir, "exp.db"))
@@ -80,3 +113,487 @@ func TestDeleteExpired(t *testing.T) {
 		t.Fatalf("expected alive paste: %v", err)
 	}
 }
+
+// cancelAfter is a context whose Err starts failing after n successful calls,
+// standing in for a deadline that lapses partway through a long operation.
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestCancellationInterruptsLongOperations(t *testing.T) {
+	store, err := OpenOptions(filepath.Join(t.TempDir(), "cancel.db"), Options{DeleteBatchSize: 2})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	oldEvery := checkEvery
+	checkEvery = 1
+	t.Cleanup(func() { checkEvery = oldEvery })
+
+	now := time.Now().UTC()
+	for i := range 5 {
+		p := &storage.Paste{ID: fmt.Sprintf("p%d", i), Content: "x", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)}
+		if err := store.Save(context.Background(), p); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	if _, err := store.List(&cancelAfter{Context: context.Background(), n: 2}, storage.ListOptions{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected list scan to stop on cancellation, got %v", err)
+	}
+
+	// Cancellation partway through a batch keeps what it already removed.
+	removed, err := store.DeleteExpired(&cancelAfter{Context: context.Background(), n: 2}, now)
+	if !errors.Is(err, context.Canceled) || removed != 1 {
+		t.Fatalf("expected 1 removed before cancel, got %d (%v)", removed, err)
+	}
+	if n, _ := store.Count(context.Background(), storage.ListOptions{}); n != 4 {
+		t.Fatalf("expected 4 pastes left after partial sweep, got %d", n)
+	}
+
+	// A full batch commits before cancellation is noticed between batches.
+	removed, err = store.DeleteExpired(&cancelAfter{Context: context.Background(), n: 3}, now)
+	if !errors.Is(err, context.Canceled) || removed != 2 {
+		t.Fatalf("expected one batch of 2 removed before cancel, got %d (%v)", removed, err)
+	}
+	if totals, _ := store.Totals(context.Background()); totals.Pastes != 2 {
+		t.Fatalf("expected totals to follow partial sweeps, got %+v", totals)
+	}
+
+	removed, err = store.DeleteExpired(context.Background(), now)
+	if err != nil || removed != 2 {
+		t.Fatalf("expected remaining 2 removed, got %d (%v)", removed, err)
+	}
+
+	batch := []*storage.Paste{{ID: "b1", Content: "x", CreatedAt: now}, {ID: "b2", Content: "y", CreatedAt: now}}
+	if err := store.SaveBatch(&cancelAfter{Context: context.Background(), n: 1}, batch); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the batch interrupted, got %v", err)
+	}
+	if _, err := store.Get(context.Background(), "b1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected an interrupted batch rolled back, got %v", err)
+	}
+}
+
+func TestParallelBatchedSweeps(t *testing.T) {
+	store, err := OpenOptions(filepath.Join(t.TempDir(), "sweep.db"), Options{DeleteBatchSize: 7})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for i := range 200 {
+		p := &storage.Paste{ID: fmt.Sprintf("old%03d", i), Content: "x", CreatedAt: now, ExpiresAt: now.Add(-time.Duration(i+1) * time.Second)}
+		if err := store.Save(ctx, p); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	// Two janitors sweep at once while a writer keeps saving live pastes;
+	// every expired paste is removed exactly once and no live one is lost.
+	var (
+		wg      sync.WaitGroup
+		removed [2]int
+		errs    [3]error
+	)
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			removed[i], errs[i] = store.DeleteExpired(ctx, now)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			p := &storage.Paste{ID: fmt.Sprintf("new%02d", i), Content: "y", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+			if errs[2] = store.Save(ctx, p); errs[2] != nil {
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent sweep: %v", err)
+		}
+	}
+	if removed[0]+removed[1] != 200 {
+		t.Fatalf("expected 200 removed across sweeps, got %v", removed)
+	}
+	if n, _ := store.Count(ctx, storage.ListOptions{}); n != 50 {
+		t.Fatalf("expected 50 live pastes, got %d", n)
+	}
+}
+
+func TestTotalsSeededForOlderDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		if err := store.Save(ctx, &storage.Paste{ID: id, Content: "1234", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	// Simulate a database written before totals were kept.
+	if err := store.db.Update(func(tx *bolt.Tx) error { return tx.DeleteBucket(metaBucket) }); err != nil {
+		t.Fatalf("drop meta: %v", err)
+	}
+	store.Close()
+
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+	if got, err := store.Totals(ctx); err != nil || got != (storage.Totals{Pastes: 2, Bytes: 8}) {
+		t.Fatalf("expected seeded totals, got %+v (%v)", got, err)
+	}
+}
+
+func TestContentDeduplication(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "dedup.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	now := time.Now()
+
+	blobs := func() map[string]uint64 {
+		refs := map[string]uint64{}
+		_ = store.db.View(func(tx *bolt.Tx) error {
+			return tx.Bucket(blobBucket).ForEach(func(k, v []byte) error {
+				refs[string(k)] = binary.BigEndian.Uint64(v)
+				return nil
+			})
+		})
+		return refs
+	}
+	refCounts := func() []uint64 {
+		var out []uint64
+		for _, n := range blobs() {
+			out = append(out, n)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+		return out
+	}
+
+	log := strings.Repeat("build step ok\n", 100)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Save(ctx, &storage.Paste{ID: id, Content: log, CreatedAt: now}); err != nil {
+			t.Fatalf("save %s: %v", id, err)
+		}
+	}
+	if err := store.Save(ctx, &storage.Paste{ID: "d", Content: "other", CreatedAt: now, ExpiresAt: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("save d: %v", err)
+	}
+	if got := refCounts(); !reflect.DeepEqual(got, []uint64{1, 3}) {
+		t.Fatalf("expected one shared and one single blob, got %v", got)
+	}
+
+	// Records keep only the hash; reads see the full content.
+	_ = store.db.View(func(tx *bolt.Tx) error {
+		if raw := tx.Bucket(pasteBucket).Get([]byte("a")); strings.Contains(string(raw), "build step") {
+			t.Errorf("expected content out of the record, got %s", raw)
+		}
+		return nil
+	})
+	if got, err := store.Get(ctx, "b"); err != nil || got.Content != log {
+		t.Fatalf("expected content through blob, got %v", err)
+	}
+
+	// Resaving unchanged content keeps the count; changing it moves the reference.
+	if err := store.Save(ctx, &storage.Paste{ID: "a", Content: log, CreatedAt: now}); err != nil {
+		t.Fatalf("resave: %v", err)
+	}
+	if err := store.Save(ctx, &storage.Paste{ID: "c", Content: "other", CreatedAt: now}); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if got := refCounts(); !reflect.DeepEqual(got, []uint64{2, 2}) {
+		t.Fatalf("expected references moved, got %v", got)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.DeleteExpired(ctx, now.Add(time.Hour)); err != nil {
+		t.Fatalf("delete expired: %v", err)
+	}
+	if got := refCounts(); !reflect.DeepEqual(got, []uint64{1, 1}) {
+		t.Fatalf("expected references released, got %v", got)
+	}
+	if got, err := store.Totals(ctx); err != nil || got != (storage.Totals{Pastes: 2, Bytes: int64(len(log) + len("other"))}) {
+		t.Fatalf("expected totals of logical content, got %+v (%v)", got, err)
+	}
+	for _, id := range []string{"b", "c"} {
+		if err := store.Delete(ctx, id); err != nil {
+			t.Fatalf("delete %s: %v", id, err)
+		}
+	}
+	if got := blobs(); len(got) != 0 {
+		t.Fatalf("expected no blobs left, got %v", got)
+	}
+}
+
+func TestInlineRecordsStillReadable(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "inline.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	// Records written before deduplication carry their content inline.
+	raw, _ := json.Marshal(&storage.Paste{ID: "old", Content: "inline body", CreatedAt: time.Now().UTC()})
+	if err := store.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(pasteBucket).Put([]byte("old"), raw); err != nil {
+			return err
+		}
+		return adjustTotals(tx, storage.Totals{Pastes: 1, Bytes: int64(len("inline body"))})
+	}); err != nil {
+		t.Fatalf("write legacy record: %v", err)
+	}
+	if got, err := store.Get(ctx, "old"); err != nil || got.Content != "inline body" {
+		t.Fatalf("expected inline content, got %+v (%v)", got, err)
+	}
+	if err := store.Delete(ctx, "old"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got, err := store.Totals(ctx); err != nil || got != (storage.Totals{}) {
+		t.Fatalf("expected empty totals, got %+v (%v)", got, err)
+	}
+}
+
+func TestOpenDSNOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsn.db")
+	s, err := storage.Open("bolt://" + path + "?delete_batch=50")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	if got := s.(*Store).deleteBatch; got != 50 {
+		t.Fatalf("expected delete batch 50, got %d", got)
+	}
+	if _, err := storage.Open("bolt://" + path + "?delete_batch=none"); err == nil {
+		t.Fatalf("expected invalid delete_batch rejected")
+	}
+}
+
+func TestListByMetadata(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "list.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now().UTC().Round(time.Second)
+	pastes := []*storage.Paste{
+		{ID: "older", Content: "a", Syntax: "plaintext", CreatedAt: now.Add(-time.Hour), Size: 1, Metadata: map[string]string{"ticket": "INC-1"}},
+		{ID: "newer", Content: "b", Syntax: "plaintext", CreatedAt: now, Size: 1, Metadata: map[string]string{"ticket": "INC-1", "team": "db"}},
+		{ID: "other", Content: "c", Syntax: "plaintext", CreatedAt: now, Size: 1, Metadata: map[string]string{"ticket": "INC-2"}},
+	}
+	for _, p := range pastes {
+		if err := store.Save(context.Background(), p); err != nil {
+			t.Fatalf("save %s: %v", p.ID, err)
+		}
+	}
+
+	out, err := store.List(context.Background(), storage.ListOptions{Metadata: map[string]string{"ticket": "INC-1"}})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(out) != 2 || out[0].ID != "newer" || out[1].ID != "older" {
+		t.Fatalf("unexpected list result: %+v", out)
+	}
+
+	out, err = store.List(context.Background(), storage.ListOptions{Metadata: map[string]string{"ticket": "INC-1"}, Limit: 1})
+	if err != nil {
+		t.Fatalf("list limited: %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected limit to apply, got %d", len(out))
+	}
+}
+
+func TestListFiltersPagingAndCount(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "filters.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now().UTC().Round(time.Second)
+	for i, p := range []*storage.Paste{
+		{ID: "g1", Syntax: "go", Size: 100, IPHash: "aa"},
+		{ID: "g2", Syntax: "go", Size: 5000, IPHash: "bb"},
+		{ID: "g3", Syntax: "go", Size: 200, IPHash: "aa"},
+		{ID: "y1", Syntax: "yaml", Size: 300, IPHash: "aa"},
+	} {
+		p.Content = "x"
+		p.CreatedAt = now.Add(time.Duration(i) * time.Minute)
+		if err := store.Save(context.Background(), p); err != nil {
+			t.Fatalf("save %s: %v", p.ID, err)
+		}
+	}
+
+	opts := storage.ListOptions{Syntax: "go", MaxSize: 1000}
+	n, err := store.Count(context.Background(), opts)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 small go pastes, got %d (%v)", n, err)
+	}
+
+	opts = storage.ListOptions{IPHash: "aa", Offset: 1, Limit: 1}
+	out, err := store.List(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(out) != 1 || out[0].ID != "g3" {
+		t.Fatalf("expected second newest aa paste g3, got %+v", out)
+	}
+	if n, _ := store.Count(context.Background(), opts); n != 3 {
+		t.Fatalf("count should ignore paging, got %d", n)
+	}
+
+	out, _ = store.List(context.Background(), storage.ListOptions{CreatedBefore: now.Add(time.Minute)})
+	if len(out) != 1 || out[0].ID != "g1" {
+		t.Fatalf("expected only g1 created before cutoff, got %+v", out)
+	}
+}
+
+func TestAPIKeyCRUD(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	ctx := context.Background()
+
+	key := &storage.APIKey{ID: "k1", Name: "ci", SecretHash: "h", Scopes: []string{"paste:read"}, Burst: 3}
+	if err := store.SaveAPIKey(ctx, key); err != nil {
+		t.Fatalf("save key: %v", err)
+	}
+	got, err := store.GetAPIKey(ctx, "k1")
+	if err != nil || got.Name != "ci" || got.Burst != 3 || !got.HasScope("paste:read") {
+		t.Fatalf("get key: %+v (%v)", got, err)
+	}
+	if list, err := store.ListAPIKeys(ctx); err != nil || len(list) != 1 {
+		t.Fatalf("list keys: %d (%v)", len(list), err)
+	}
+	if err := store.DeleteAPIKey(ctx, "k1"); err != nil {
+		t.Fatalf("delete key: %v", err)
+	}
+	if _, err := store.GetAPIKey(ctx, "k1"); err != storage.ErrNotFound {
+		t.Fatalf("expected not found after revoke, got %v", err)
+	}
+	if err := store.DeleteAPIKey(ctx, "k1"); err != storage.ErrNotFound {
+		t.Fatalf("expected not found on second revoke, got %v", err)
+	}
+}
+
+func TestCheckFindsAndRepairsInconsistencies(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "fsck.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	for _, id := range []string{"a", "b", "c"} {
+		p := &storage.Paste{ID: id, Content: "shared", Size: 6, CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+		if err := store.Save(ctx, p); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	if report, err := store.Check(ctx, false); err != nil || len(report.Problems) != 0 || report.Pastes != 3 {
+		t.Fatalf("expected a clean database, got %+v (%v)", report, err)
+	}
+
+	// Break it the ways a crash or a buggy older version could.
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		p := tx.Bucket(pasteBucket)
+		if err := p.Put([]byte("junk"), []byte("{not json")); err != nil {
+			return err
+		}
+		rec, _ := decodeRecord(p.Get([]byte("a")))
+		rec.Size = 99
+		data, _ := json.Marshal(rec)
+		if err := p.Put([]byte("a"), data); err != nil {
+			return err
+		}
+		if err := p.Delete([]byte("b")); err != nil {
+			return err
+		}
+		return tx.Bucket(expireBucket).Delete(timeKey(rec.ExpiresAt, "c"))
+	})
+	if err != nil {
+		t.Fatalf("corrupt: %v", err)
+	}
+
+	report, err := store.Check(ctx, false)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	kinds := make(map[string]int)
+	for _, p := range report.Problems {
+		kinds[p.Kind]++
+	}
+	want := map[string]int{
+		ProblemCorruptRecord: 1,
+		ProblemSizeMismatch:  1,
+		ProblemOrphanIndex:   1, // b's expiry entry
+		ProblemMissingIndex:  1, // c's expiry entry
+		ProblemBlobRefs:      1,
+		ProblemTotals:        1,
+	}
+	if !reflect.DeepEqual(kinds, want) || report.Unrepaired() != 6 {
+		t.Fatalf("expected problems %v, got %v", want, report.Problems)
+	}
+
+	if report, err := store.Check(ctx, true); err != nil || report.Unrepaired() != 0 {
+		t.Fatalf("repair: %+v (%v)", report, err)
+	}
+	if report, err := store.Check(ctx, false); err != nil || len(report.Problems) != 0 {
+		t.Fatalf("expected a clean database after repair, got %v (%v)", report.Problems, err)
+	}
+	if got, _ := store.Totals(ctx); got != (storage.Totals{Pastes: 2, Bytes: 12}) {
+		t.Fatalf("expected rebuilt totals, got %+v", got)
+	}
+	if n, err := store.DeleteExpired(ctx, now.Add(2*time.Hour)); err != nil || n != 2 {
+		t.Fatalf("expected both pastes expirable after repair, got %d (%v)", n, err)
+	}
+	_ = store.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(lostBucket).Get([]byte("junk")) == nil {
+			t.Fatalf("expected the corrupt record kept in lost+found")
+		}
+		if tx.Bucket(blobBucket).Stats().KeyN != 0 {
+			t.Fatalf("expected the shared blob released with its last paste")
+		}
+		return nil
+	})
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.TestStore(t, func() storage.Store {
+		store, err := Open(filepath.Join(t.TempDir(), "conformance.db"))
+		if err != nil {
+			t.Fatalf("open store: %v", err)
+		}
+		return store
+	})
+}
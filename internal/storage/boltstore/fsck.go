@@ -0,0 +1,293 @@
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// lostBucket keeps paste records Check could not decode, so a repair never
+// throws data away.
+var lostBucket = []byte("lost+found")
+
+// Kinds of problem reported by Check.
+const (
+	ProblemCorruptRecord = "corrupt_record"
+	ProblemMissingBlob   = "missing_blob"
+	ProblemSizeMismatch  = "size_mismatch"
+	ProblemOrphanIndex   = "orphan_index"
+	ProblemMissingIndex  = "missing_index"
+	ProblemBlobRefs      = "blob_refs"
+	ProblemOrphanBlob    = "orphan_blob"
+	ProblemTotals        = "totals"
+)
+
+// Problem is one inconsistency found by Check.
+type Problem struct {
+	Kind string
+	// Key is the paste id, blob hash or index entry concerned.
+	Key    string
+	Detail string
+	// Repaired is set when Check fixed the problem.
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("%s %s: %s", p.Kind, p.Key, p.Detail)
+	if p.Repaired {
+		s += " (repaired)"
+	}
+	return s
+}
+
+// CheckReport is the outcome of Check.
+type CheckReport struct {
+	Pastes   int
+	Blobs    int
+	Problems []Problem
+}
+
+// Unrepaired counts the problems still present.
+func (r *CheckReport) Unrepaired() int {
+	n := 0
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// indexCheck describes one of the time-ordered indexes of paste records.
+type indexCheck struct {
+	name   string
+	bucket []byte
+	at     func(*storage.Paste) time.Time
+}
+
+var indexChecks = []indexCheck{
+	{"expiry", expireBucket, func(p *storage.Paste) time.Time { return p.ExpiresAt }},
+	{"update", updateBucket, func(p *storage.Paste) time.Time { return p.UpdatedAt }},
+}
+
+// Check scans the database for inconsistencies: paste records that do not
+// decode or reference a missing blob, sizes that disagree with the content,
+// expiry and update index entries pointing at missing or changed pastes,
+// pastes missing from those indexes, wrong blob reference counts, unused
+// blobs and drifted totals. With repair it also fixes them in the same
+// transaction: undecodable records are moved to the lost+found bucket,
+// indexes, reference counts and totals are rebuilt, and sizes are corrected.
+// Records whose blob is gone are only reported. Check holds a single
+// transaction for the whole scan, so run it with the server stopped.
+func (s *Store) Check(ctx context.Context, repair bool) (*CheckReport, error) {
+	report := &CheckReport{}
+	check := func(tx *bolt.Tx) error {
+		return checkTx(ctx, tx, repair, report)
+	}
+	var err error
+	if repair {
+		err = s.db.Update(check)
+	} else {
+		err = s.db.View(check)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func checkTx(ctx context.Context, tx *bolt.Tx, repair bool, report *CheckReport) error {
+	pBucket := tx.Bucket(pasteBucket)
+	blobs := tx.Bucket(blobBucket)
+	add := func(kind, key, detail string) {
+		report.Problems = append(report.Problems, Problem{Kind: kind, Key: key, Detail: detail, Repaired: repair})
+	}
+
+	// Pass 1: decode every record, collecting what the indexes, blobs and
+	// totals should hold.
+	var (
+		totals  storage.Totals
+		refs    = make(map[string]uint64)
+		records = make(map[string]*record)
+		corrupt [][]byte
+		resized []*record
+		scanned int
+	)
+	err := pBucket.ForEach(func(key, raw []byte) error {
+		if err := poll(ctx, &scanned); err != nil {
+			return err
+		}
+		report.Pastes++
+		rec, err := decodeRecord(raw)
+		if err != nil {
+			corrupt = append(corrupt, bytes.Clone(key))
+			add(ProblemCorruptRecord, string(key), err.Error())
+			return nil
+		}
+		if rec.ID != string(key) {
+			corrupt = append(corrupt, bytes.Clone(key))
+			add(ProblemCorruptRecord, string(key), fmt.Sprintf("record holds id %q", rec.ID))
+			return nil
+		}
+		records[rec.ID] = rec
+		totals.Pastes++
+		size := int64(len(rec.Content))
+		if rec.Blob != "" {
+			val := blobs.Get([]byte(rec.Blob))
+			if len(val) < 8 {
+				report.Problems = append(report.Problems, Problem{Kind: ProblemMissingBlob, Key: rec.ID, Detail: "blob " + rec.Blob + " is gone"})
+				return nil
+			}
+			refs[rec.Blob]++
+			size = int64(len(val) - 8)
+		}
+		totals.Bytes += size
+		// Records whose content lives in a file beside the store carry the
+		// file's size.
+		if !rec.InFile && int64(rec.Size) != size {
+			add(ProblemSizeMismatch, rec.ID, fmt.Sprintf("size %d, content %d bytes", rec.Size, size))
+			rec.Size = int(size)
+			resized = append(resized, rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if repair {
+		lost, err := tx.CreateBucketIfNotExists(lostBucket)
+		if err != nil {
+			return fmt.Errorf("create lost+found bucket: %w", err)
+		}
+		for _, key := range corrupt {
+			if err := lost.Put(key, bytes.Clone(pBucket.Get(key))); err != nil {
+				return fmt.Errorf("keep corrupt record: %w", err)
+			}
+			if err := pBucket.Delete(key); err != nil {
+				return fmt.Errorf("remove corrupt record: %w", err)
+			}
+		}
+		for _, rec := range resized {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				return fmt.Errorf("marshal paste: %w", err)
+			}
+			if err := pBucket.Put([]byte(rec.ID), data); err != nil {
+				return fmt.Errorf("fix size of %s: %w", rec.ID, err)
+			}
+		}
+	}
+
+	// Pass 2: every index entry must match a record, and every record with
+	// a time must be indexed.
+	for _, ic := range indexChecks {
+		bucket := tx.Bucket(ic.bucket)
+		indexed := make(map[string]bool)
+		var stale [][]byte
+		err := bucket.ForEach(func(key, val []byte) error {
+			if err := poll(ctx, &scanned); err != nil {
+				return err
+			}
+			id := string(val)
+			rec, ok := records[id]
+			switch {
+			case len(key) < 8 || !bytes.Equal(key[8:], val):
+				add(ProblemOrphanIndex, fmt.Sprintf("%x", key), ic.name+" entry is malformed")
+			case !ok:
+				add(ProblemOrphanIndex, id, ic.name+" entry points at a missing paste")
+			case !bytes.Equal(key, timeKey(ic.at(&rec.Paste), id)):
+				add(ProblemOrphanIndex, id, ic.name+" entry does not match the paste")
+			default:
+				indexed[id] = true
+				return nil
+			}
+			stale = append(stale, bytes.Clone(key))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		var missing []*record
+		for id, rec := range records {
+			if !ic.at(&rec.Paste).IsZero() && !indexed[id] {
+				add(ProblemMissingIndex, id, "paste is not in the "+ic.name+" index")
+				missing = append(missing, rec)
+			}
+		}
+		if !repair {
+			continue
+		}
+		for _, key := range stale {
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("remove %s entry: %w", ic.name, err)
+			}
+		}
+		for _, rec := range missing {
+			if err := bucket.Put(timeKey(ic.at(&rec.Paste), rec.ID), []byte(rec.ID)); err != nil {
+				return fmt.Errorf("index %s of %s: %w", ic.name, rec.ID, err)
+			}
+		}
+	}
+
+	// Pass 3: blob reference counts must match the records using them.
+	type blobFix struct {
+		key  []byte
+		refs uint64
+		val  []byte
+	}
+	var fixes []blobFix
+	err = blobs.ForEach(func(key, val []byte) error {
+		if err := poll(ctx, &scanned); err != nil {
+			return err
+		}
+		report.Blobs++
+		want := refs[string(key)]
+		var have uint64
+		if len(val) >= 8 {
+			have = binary.BigEndian.Uint64(val)
+		}
+		if have == want {
+			return nil
+		}
+		if want == 0 {
+			add(ProblemOrphanBlob, string(key), fmt.Sprintf("no paste uses it (count %d)", have))
+		} else {
+			add(ProblemBlobRefs, string(key), fmt.Sprintf("count %d, used by %d pastes", have, want))
+		}
+		fixes = append(fixes, blobFix{key: bytes.Clone(key), refs: want, val: bytes.Clone(val)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if repair {
+		for _, f := range fixes {
+			if f.refs == 0 {
+				if err := blobs.Delete(f.key); err != nil {
+					return fmt.Errorf("delete blob: %w", err)
+				}
+				continue
+			}
+			binary.BigEndian.PutUint64(f.val, f.refs)
+			if err := blobs.Put(f.key, f.val); err != nil {
+				return fmt.Errorf("fix blob count: %w", err)
+			}
+		}
+	}
+
+	if have := readTotals(tx); have != totals {
+		add(ProblemTotals, string(totalsKey), fmt.Sprintf("recorded %d pastes and %d bytes, found %d and %d", have.Pastes, have.Bytes, totals.Pastes, totals.Bytes))
+		if repair {
+			return writeTotals(tx, totals)
+		}
+	}
+	return nil
+}
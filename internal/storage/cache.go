@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithCache decorates store with an in-memory LRU cache of pastes read by
+// Get, bounded to maxPastes entries and maxBytes of content. Pastes larger
+// than a sixteenth of maxBytes are not cached, so one large paste cannot
+// flush the rest. Saves and deletes through the cache invalidate it; writes
+// made by other processes sharing the backend are not seen until the entry
+// is evicted, so only cache a store this process alone writes to.
+func WithCache(store Store, maxPastes int, maxBytes int64) (Store, error) {
+	if maxPastes <= 0 || maxBytes <= 0 {
+		return nil, errors.New("cache limits must be positive")
+	}
+	return &cachedStore{
+		Store:     store,
+		maxPastes: maxPastes,
+		maxBytes:  maxBytes,
+		order:     list.New(),
+		entries:   make(map[string]*list.Element),
+	}, nil
+}
+
+type cachedStore struct {
+	Store
+	maxPastes int
+	maxBytes  int64
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	bytes   int64
+	// version counts writes, so a read that raced with one does not cache
+	// what it loaded before the write.
+	version uint64
+}
+
+func (c *cachedStore) Unwrap() Store { return c.Store }
+
+func (c *cachedStore) Save(ctx context.Context, paste *Paste) error {
+	defer c.invalidate(paste.ID)
+	return c.Store.Save(ctx, paste)
+}
+
+func (c *cachedStore) SaveBatch(ctx context.Context, pastes []*Paste) error {
+	defer func() {
+		for _, p := range pastes {
+			c.invalidate(p.ID)
+		}
+	}()
+	return SaveBatch(ctx, c.Store, pastes)
+}
+
+func (c *cachedStore) Delete(ctx context.Context, id string) error {
+	defer c.invalidate(id)
+	return c.Store.Delete(ctx, id)
+}
+
+func (c *cachedStore) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	n, err := c.Store.DeleteExpired(ctx, before)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	for id, el := range c.entries {
+		if p := el.Value.(*Paste); p.HasExpiration() && p.ExpiresAt.Before(before) {
+			c.remove(id, el)
+		}
+	}
+	return n, err
+}
+
+func (c *cachedStore) Get(ctx context.Context, id string) (*Paste, error) {
+	if p, ok := c.lookup(id); ok {
+		return p, nil
+	}
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+
+	paste, err := c.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.put(paste, version)
+	return paste, nil
+}
+
+// GetMeta answers from the cache or a streaming backend, loading pastes
+// small enough to cache through Get so the content read that usually follows
+// is a hit.
+func (c *cachedStore) GetMeta(ctx context.Context, id string) (*Paste, error) {
+	if p, ok := c.lookup(id); ok {
+		p.Size, p.Content = len(p.Content), ""
+		return p, nil
+	}
+	if cs, ok := Streamer(c.Store); ok {
+		meta, err := cs.GetMeta(ctx, id)
+		if err != nil || !c.fits(int64(meta.Size)) {
+			return meta, err
+		}
+	}
+	p, err := c.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p.Size, p.Content = len(p.Content), ""
+	return p, nil
+}
+
+func (c *cachedStore) GetContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	if p, ok := c.lookup(id); ok {
+		return io.NopCloser(strings.NewReader(p.Content)), nil
+	}
+	if cs, ok := Streamer(c.Store); ok {
+		return cs.GetContent(ctx, id)
+	}
+	p, err := c.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(p.Content)), nil
+}
+
+// lookup returns a copy of the cached paste id, dropping it once expired.
+func (c *cachedStore) lookup(id string) (*Paste, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	p := el.Value.(*Paste)
+	if p.HasExpiration() && !p.ExpiresAt.After(time.Now()) {
+		c.remove(id, el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return clonePaste(p), true
+}
+
+func (c *cachedStore) fits(size int64) bool {
+	return size <= c.maxBytes/16
+}
+
+// put caches a copy of paste unless a write happened since version.
+func (c *cachedStore) put(paste *Paste, version uint64) {
+	size := int64(len(paste.Content))
+	if !c.fits(size) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != version {
+		return
+	}
+	if el, ok := c.entries[paste.ID]; ok {
+		c.remove(paste.ID, el)
+	}
+	c.entries[paste.ID] = c.order.PushFront(clonePaste(paste))
+	c.bytes += size
+	for c.order.Len() > c.maxPastes || c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		c.remove(oldest.Value.(*Paste).ID, oldest)
+	}
+}
+
+func (c *cachedStore) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	if el, ok := c.entries[id]; ok {
+		c.remove(id, el)
+	}
+}
+
+// remove drops an entry; c.mu must be held.
+func (c *cachedStore) remove(id string, el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, id)
+	c.bytes -= int64(len(el.Value.(*Paste).Content))
+}
+
+func clonePaste(p *Paste) *Paste {
+	cp := *p
+	cp.Metadata = maps.Clone(p.Metadata)
+	return &cp
+}
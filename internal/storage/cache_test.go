@@ -0,0 +1,91 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/storagetest"
+)
+
+// countingStore counts the reads that reach the backend.
+type countingStore struct {
+	storage.Store
+	gets int
+}
+
+func (c *countingStore) Get(ctx context.Context, id string) (*storage.Paste, error) {
+	c.gets++
+	return c.Store.Get(ctx, id)
+}
+
+func TestCacheConformance(t *testing.T) {
+	storagetest.TestStore(t, func() storage.Store {
+		s, err := storage.WithCache(newMemstore(t), 4, 1<<20)
+		if err != nil {
+			t.Fatalf("with cache: %v", err)
+		}
+		return s
+	})
+}
+
+func TestCacheServesHotPastesAndInvalidatesOnWrites(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingStore{Store: newMemstore(t)}
+	store, err := storage.WithCache(inner, 2, 1600)
+	if err != nil {
+		t.Fatalf("with cache: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Save(ctx, &storage.Paste{ID: id, Content: id + "-v1", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	get := func(id string) string {
+		t.Helper()
+		p, err := store.Get(ctx, id)
+		if err != nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		p.Content += " (modified by caller)"
+		p, _ = store.Get(ctx, id)
+		return p.Content
+	}
+
+	if got := get("a"); got != "a-v1" || inner.gets != 1 {
+		t.Fatalf("expected one backend read for a hot paste, got %q after %d reads", got, inner.gets)
+	}
+	if err := store.Save(ctx, &storage.Paste{ID: "a", Content: "a-v2", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := get("a"); got != "a-v2" || inner.gets != 2 {
+		t.Fatalf("expected the update to invalidate the cache, got %q after %d reads", got, inner.gets)
+	}
+
+	get("b")
+	get("c") // evicts a, the least recently used
+	inner.gets = 0
+	get("a")
+	if inner.gets != 1 {
+		t.Fatalf("expected an evicted paste to be read again, got %d reads", inner.gets)
+	}
+
+	big := strings.Repeat("x", 101)
+	if err := store.Save(ctx, &storage.Paste{ID: "big", Content: big, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("save big: %v", err)
+	}
+	inner.gets = 0
+	get("big")
+	if inner.gets != 2 {
+		t.Fatalf("expected pastes over a sixteenth of the budget to skip the cache, got %d reads", inner.gets)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "a"); err != storage.ErrNotFound {
+		t.Fatalf("expected a deleted paste to be gone, got %v", err)
+	}
+}
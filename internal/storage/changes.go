@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ChangeStore is implemented by stores that index pastes by UpdatedAt, so
+// incremental backups need not decode every paste to find the changed ones.
+type ChangeStore interface {
+	// IterateChanged calls fn with every paste whose UpdatedAt is after
+	// since, in no particular order, stopping at the first error fn returns.
+	IterateChanged(ctx context.Context, since time.Time, fn func(*Paste) error) error
+}
+
+// IterateChanged calls fn with the pastes in s saved after since, through
+// s's index when it keeps one and by scanning every paste otherwise.
+func IterateChanged(ctx context.Context, s Store, since time.Time, fn func(*Paste) error) error {
+	if cs, ok := As[ChangeStore](s); ok {
+		return cs.IterateChanged(ctx, since, fn)
+	}
+	return s.Iterate(ctx, func(p *Paste) error {
+		if !p.UpdatedAt.After(since) {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+// StampUpdates returns hooks that set UpdatedAt on every save to now().
+func StampUpdates(now func() time.Time) Hooks {
+	return Hooks{BeforeSave: func(_ context.Context, p *Paste) error {
+		p.UpdatedAt = now().UTC()
+		return nil
+	}}
+}
+
+// FormatCursor and ParseCursor convert the point an incremental backup
+// continues from to and from the opaque string handed to clients.
+func FormatCursor(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// ParseCursor is the inverse of FormatCursor.
+func ParseCursor(cursor string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, cursor)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid backup cursor %q", cursor)
+	}
+	return t, nil
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// ContentStore is implemented by stores that can load a paste's metadata and
+// its content separately, so large pastes can be streamed to clients rather
+// than copied around whole.
+type ContentStore interface {
+	// GetMeta fetches a paste like Get but leaves Content empty. Size holds
+	// the content length in bytes.
+	GetMeta(ctx context.Context, id string) (*Paste, error)
+	// GetContent opens the content of the paste id. Callers must close it.
+	GetContent(ctx context.Context, id string) (io.ReadCloser, error)
+}
+
+// Streamer finds the ContentStore behind s. Streamed reads skip the wrappers
+// in between, so it reports false when one of them rewrites loaded pastes.
+func Streamer(s Store) (ContentStore, bool) {
+	for s != nil {
+		if cs, ok := s.(ContentStore); ok {
+			return cs, true
+		}
+		if h, ok := s.(*hookedStore); ok && h.rewritesGets() {
+			break
+		}
+		w, ok := s.(Wrapper)
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	return nil, false
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// CopyOptions tunes Copy.
+type CopyOptions struct {
+	// DryRun reads everything from the source but writes nothing.
+	DryRun bool
+	// Progress, if set, is called every ProgressEvery pastes. Copy also
+	// calls it once more when it finishes; Import leaves the final tally to
+	// its return value.
+	Progress      func(CopyStats)
+	ProgressEvery int
+}
+
+// CopyStats counts what Copy, Export or Import moved.
+type CopyStats struct {
+	Pastes  int
+	Bytes   int64
+	APIKeys int
+	// Skipped counts backup records Import could not use.
+	Skipped int
+}
+
+// Copy streams every paste from src into dst, keeping ids, expiry, password
+// hashes and the rest of each record as stored. API keys are copied too when
+// both stores support them. Pastes already in dst with the same id are
+// overwritten, so an interrupted copy can simply be run again.
+func Copy(ctx context.Context, dst, src Store, opts CopyOptions) (CopyStats, error) {
+	if opts.ProgressEvery <= 0 {
+		opts.ProgressEvery = 1000
+	}
+	var stats CopyStats
+	report := func() {
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+	}
+
+	err := src.Iterate(ctx, func(p *Paste) error {
+		if !opts.DryRun {
+			if err := dst.Save(ctx, p); err != nil {
+				return fmt.Errorf("save paste %s: %w", p.ID, err)
+			}
+		}
+		stats.Pastes++
+		stats.Bytes += int64(len(p.Content))
+		if stats.Pastes%opts.ProgressEvery == 0 {
+			report()
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	srcKeys, srcOK := As[APIKeyStore](src)
+	dstKeys, dstOK := As[APIKeyStore](dst)
+	if srcOK && dstOK {
+		keys, err := srcKeys.ListAPIKeys(ctx)
+		if err != nil {
+			return stats, fmt.Errorf("list api keys: %w", err)
+		}
+		for _, key := range keys {
+			if !opts.DryRun {
+				if err := dstKeys.SaveAPIKey(ctx, key); err != nil {
+					return stats, fmt.Errorf("save api key %s: %w", key.ID, err)
+				}
+			}
+			stats.APIKeys++
+		}
+	}
+	report()
+	return stats, nil
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCopyMovesPastesAndReportsProgress(t *testing.T) {
+	src := &mapStore{pastes: make(map[string]Paste)}
+	expires := time.Now().Add(time.Hour).UTC()
+	for _, id := range []string{"a", "b", "c"} {
+		src.pastes[id] = Paste{ID: id, Content: "xx", ExpiresAt: expires, PasswordHash: "h-" + id}
+	}
+	ctx := context.Background()
+
+	dry := &mapStore{pastes: make(map[string]Paste)}
+	stats, err := Copy(ctx, dry, src, CopyOptions{DryRun: true})
+	if err != nil || stats.Pastes != 3 || stats.Bytes != 6 {
+		t.Fatalf("dry run: got %+v, %v", stats, err)
+	}
+	if len(dry.pastes) != 0 {
+		t.Fatalf("dry run wrote %d pastes", len(dry.pastes))
+	}
+
+	dst := &mapStore{pastes: make(map[string]Paste)}
+	var progress []int
+	stats, err = Copy(ctx, dst, src, CopyOptions{
+		ProgressEvery: 2,
+		Progress:      func(s CopyStats) { progress = append(progress, s.Pastes) },
+	})
+	if err != nil || stats.Pastes != 3 {
+		t.Fatalf("copy: got %+v, %v", stats, err)
+	}
+	if len(progress) != 2 || progress[0] != 2 || progress[1] != 3 {
+		t.Fatalf("expected progress at 2 and on completion, got %v", progress)
+	}
+	b := dst.pastes["b"]
+	if !b.ExpiresAt.Equal(expires) || b.PasswordHash != "h-b" {
+		t.Fatalf("expected expiry and password hash kept, got %+v", b)
+	}
+}
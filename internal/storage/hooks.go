@@ -0,0 +1,143 @@
+package storage
+
+import "context"
+
+// Hooks intercept store operations so cross-cutting features can be layered
+// over any backend. Nil fields are skipped; an error aborts the operation.
+type Hooks struct {
+	// BeforeSave may inspect or rewrite a paste before it is persisted. It
+	// receives a copy, so changes do not leak back to the caller.
+	BeforeSave func(ctx context.Context, p *Paste) error
+	// AfterGet may inspect or rewrite a paste loaded by Get or List.
+	AfterGet func(ctx context.Context, p *Paste) error
+	// BeforeDelete runs before Delete. DeleteExpired does not call it.
+	//
+	// Iterate is not hooked: it hands out stored values as they are, for
+	// tools that copy raw data between backends.
+	BeforeDelete func(ctx context.Context, id string) error
+}
+
+// Wrapper is implemented by stores that decorate another store.
+type Wrapper interface {
+	Unwrap() Store
+}
+
+// As finds the first store in the wrapping chain that implements T, for
+// optional interfaces such as APIKeyStore.
+func As[T any](s Store) (T, bool) {
+	for s != nil {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		w, ok := s.(Wrapper)
+		if !ok {
+			break
+		}
+		s = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// WithHooks decorates store with hooks. Before hooks run in the order given
+// and AfterGet hooks in reverse, so a hook that transforms content on save
+// sees it first again on the way out. List filters apply to stored values,
+// before AfterGet.
+func WithHooks(store Store, hooks ...Hooks) Store {
+	if len(hooks) == 0 {
+		return store
+	}
+	return &hookedStore{Store: store, hooks: hooks}
+}
+
+type hookedStore struct {
+	Store
+	hooks []Hooks
+}
+
+func (h *hookedStore) Unwrap() Store { return h.Store }
+
+func (h *hookedStore) Save(ctx context.Context, paste *Paste) error {
+	cp := *paste
+	for _, hk := range h.hooks {
+		if hk.BeforeSave != nil {
+			if err := hk.BeforeSave(ctx, &cp); err != nil {
+				return err
+			}
+		}
+	}
+	return h.Store.Save(ctx, &cp)
+}
+
+// SaveBatch runs the BeforeSave hooks on every paste before saving any.
+func (h *hookedStore) SaveBatch(ctx context.Context, pastes []*Paste) error {
+	cps := make([]*Paste, len(pastes))
+	for i, paste := range pastes {
+		cp := *paste
+		for _, hk := range h.hooks {
+			if hk.BeforeSave != nil {
+				if err := hk.BeforeSave(ctx, &cp); err != nil {
+					return err
+				}
+			}
+		}
+		cps[i] = &cp
+	}
+	return SaveBatch(ctx, h.Store, cps)
+}
+
+func (h *hookedStore) Get(ctx context.Context, id string) (*Paste, error) {
+	paste, err := h.Store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.afterGet(ctx, paste); err != nil {
+		return nil, err
+	}
+	return paste, nil
+}
+
+func (h *hookedStore) List(ctx context.Context, opts ListOptions) ([]*Paste, error) {
+	pastes, err := h.Store.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pastes {
+		if err := h.afterGet(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return pastes, nil
+}
+
+func (h *hookedStore) Delete(ctx context.Context, id string) error {
+	for _, hk := range h.hooks {
+		if hk.BeforeDelete != nil {
+			if err := hk.BeforeDelete(ctx, id); err != nil {
+				return err
+			}
+		}
+	}
+	return h.Store.Delete(ctx, id)
+}
+
+// rewritesGets reports whether any hook sees pastes on the way out.
+func (h *hookedStore) rewritesGets() bool {
+	for _, hk := range h.hooks {
+		if hk.AfterGet != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *hookedStore) afterGet(ctx context.Context, paste *Paste) error {
+	for i := len(h.hooks) - 1; i >= 0; i-- {
+		if fn := h.hooks[i].AfterGet; fn != nil {
+			if err := fn(ctx, paste); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mapStore struct {
+	pastes map[string]Paste
+}
+
+func (m *mapStore) Save(ctx context.Context, p *Paste) error {
+	m.pastes[p.ID] = *p
+	return nil
+}
+
+func (m *mapStore) Get(ctx context.Context, id string) (*Paste, error) {
+	p, ok := m.pastes[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &p, nil
+}
+
+func (m *mapStore) Delete(ctx context.Context, id string) error {
+	delete(m.pastes, id)
+	return nil
+}
+
+func (m *mapStore) DeleteExpired(ctx context.Context, before time.Time) (int, error) { return 0, nil }
+
+func (m *mapStore) List(ctx context.Context, opts ListOptions) ([]*Paste, error) {
+	var out []*Paste
+	for _, p := range m.pastes {
+		out = append(out, &p)
+	}
+	return out, nil
+}
+
+func (m *mapStore) Count(ctx context.Context, opts ListOptions) (int, error) {
+	return len(m.pastes), nil
+}
+
+func (m *mapStore) Iterate(ctx context.Context, fn func(*Paste) error) error {
+	for _, p := range m.pastes {
+		if err := fn(&p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mapStore) Stats(ctx context.Context) (Stats, error) {
+	return ScanStats(ctx, m, time.Now())
+}
+
+func (m *mapStore) Ping(ctx context.Context) error { return ctx.Err() }
+
+func (m *mapStore) Close() error { return nil }
+
+type keyedMapStore struct {
+	*mapStore
+	APIKeyStore
+}
+
+func TestWithHooksOrderingAndCopy(t *testing.T) {
+	inner := &mapStore{pastes: make(map[string]Paste)}
+	var trace []string
+	wrap := func(tag string) Hooks {
+		return Hooks{
+			BeforeSave: func(ctx context.Context, p *Paste) error {
+				trace = append(trace, "save:"+tag)
+				p.Content = tag + "(" + p.Content + ")"
+				return nil
+			},
+			AfterGet: func(ctx context.Context, p *Paste) error {
+				trace = append(trace, "get:"+tag)
+				p.Content = strings.TrimSuffix(strings.TrimPrefix(p.Content, tag+"("), ")")
+				return nil
+			},
+		}
+	}
+	store := WithHooks(inner, wrap("a"), wrap("b"))
+	ctx := context.Background()
+
+	paste := &Paste{ID: "x", Content: "plain"}
+	if err := store.Save(ctx, paste); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if paste.Content != "plain" {
+		t.Fatalf("hook leaked into caller's paste: %q", paste.Content)
+	}
+	if got := inner.pastes["x"].Content; got != "b(a(plain))" {
+		t.Fatalf("unexpected stored content %q", got)
+	}
+	got, err := store.Get(ctx, "x")
+	if err != nil || got.Content != "plain" {
+		t.Fatalf("get: %+v (%v)", got, err)
+	}
+	if want := "save:a save:b get:b get:a"; strings.Join(trace, " ") != want {
+		t.Fatalf("unexpected hook order %v", trace)
+	}
+	list, err := store.List(ctx, ListOptions{})
+	if err != nil || len(list) != 1 || list[0].Content != "plain" {
+		t.Fatalf("list: %+v (%v)", list, err)
+	}
+}
+
+func TestWithHooksBeforeDeleteCanVeto(t *testing.T) {
+	inner := &mapStore{pastes: map[string]Paste{"keep": {ID: "keep"}}}
+	errHeld := errors.New("legal hold")
+	store := WithHooks(inner, Hooks{BeforeDelete: func(ctx context.Context, id string) error {
+		if id == "keep" {
+			return errHeld
+		}
+		return nil
+	}})
+	if err := store.Delete(context.Background(), "keep"); !errors.Is(err, errHeld) {
+		t.Fatalf("expected veto, got %v", err)
+	}
+	if _, ok := inner.pastes["keep"]; !ok {
+		t.Fatalf("vetoed paste was deleted")
+	}
+}
+
+func TestAsUnwrapsDecorators(t *testing.T) {
+	plain := &mapStore{pastes: make(map[string]Paste)}
+	if _, ok := As[APIKeyStore](WithHooks(plain, Hooks{})); ok {
+		t.Fatalf("plain store should not offer api keys")
+	}
+	keyed := keyedMapStore{mapStore: plain}
+	if _, ok := As[APIKeyStore](WithHooks(keyed, Hooks{})); !ok {
+		t.Fatalf("expected api key store found through hooks")
+	}
+}
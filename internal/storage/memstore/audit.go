@@ -0,0 +1,60 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// AppendAudit adds an entry to the audit log.
+func (s *Store) AppendAudit(ctx context.Context, entry *storage.AuditEntry) error {
+	if entry == nil {
+		return errors.New("audit entry is nil")
+	}
+	cp := *entry
+	cp.Time = cp.Time.UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = append(s.audit, &cp)
+	s.changes++
+	return nil
+}
+
+// ListAudit returns the entries q selects, newest first.
+func (s *Store) ListAudit(ctx context.Context, q storage.AuditQuery) ([]*storage.AuditEntry, error) {
+	s.mu.RLock()
+	var out []*storage.AuditEntry
+	for _, e := range s.audit {
+		if q.Matches(e) {
+			cp := *e
+			out = append(out, &cp)
+		}
+	}
+	s.mu.RUnlock()
+	storage.SortAudit(out)
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return out, nil
+}
+
+// PruneAudit removes the entries older than before.
+func (s *Store) PruneAudit(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.audit[:0]
+	for _, e := range s.audit {
+		if !e.Time.Before(before) {
+			kept = append(kept, e)
+		}
+	}
+	removed := len(s.audit) - len(kept)
+	clear(s.audit[len(kept):])
+	s.audit = kept
+	if removed > 0 {
+		s.changes++
+	}
+	return removed, nil
+}
@@ -0,0 +1,493 @@
+// Package memstore implements storage.Store in process memory, for demos
+// and throwaway CI instances that should not touch disk. The store is
+// bounded: once full it drops expired pastes, then the oldest ones.
+package memstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// Options bounds the store and optionally persists it across restarts.
+type Options struct {
+	// MaxPastes caps the number of pastes held; zero means no limit.
+	MaxPastes int
+	// MaxBytes caps the total content size held; zero means no limit.
+	MaxBytes int64
+	// SnapshotPath, when set, is loaded on start and written on Close.
+	SnapshotPath string
+	// SnapshotInterval, when positive alongside SnapshotPath, also writes the
+	// snapshot periodically so a crash loses at most one interval of changes.
+	SnapshotInterval time.Duration
+	// OnSnapshotError reports failed periodic snapshots; nil drops them.
+	OnSnapshotError func(error)
+}
+
+func init() {
+	storage.Register("memory", openDSN)
+}
+
+// openDSN opens
+// memory://?max_pastes=N&max_bytes=N&snapshot=/path&snapshot_interval=1m.
+func openDSN(dsn string) (storage.Store, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse memory dsn: %w", err)
+	}
+	q := u.Query()
+	var opts Options
+	if v := q.Get("max_pastes"); v != "" {
+		if opts.MaxPastes, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid max_pastes %q", v)
+		}
+	}
+	if v := q.Get("max_bytes"); v != "" {
+		if opts.MaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid max_bytes %q", v)
+		}
+	}
+	opts.SnapshotPath = q.Get("snapshot")
+	if v := q.Get("snapshot_interval"); v != "" {
+		if opts.SnapshotInterval, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid snapshot_interval %q", v)
+		}
+	}
+	return New(opts)
+}
+
+// Store implements storage.Store in memory.
+type Store struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	pastes  map[string]*storage.Paste
+	keys    map[string]*storage.APIKey
+	reports map[string]*storage.Report
+	audit   []*storage.AuditEntry
+	bytes   int64
+	// changes counts mutations so periodic snapshots can skip idle stores.
+	changes uint64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New returns an empty store, or one restored from opts.SnapshotPath if that
+// file exists. With a SnapshotInterval it also starts the periodic snapshot
+// loop, which Close stops.
+func New(opts Options) (*Store, error) {
+	s := &Store{
+		opts:    opts,
+		now:     time.Now,
+		pastes:  make(map[string]*storage.Paste),
+		keys:    make(map[string]*storage.APIKey),
+		reports: make(map[string]*storage.Report),
+	}
+	if opts.SnapshotPath != "" {
+		if err := s.load(opts.SnapshotPath); err != nil {
+			return nil, err
+		}
+		if opts.SnapshotInterval > 0 {
+			s.stop = make(chan struct{})
+			s.done = make(chan struct{})
+			go s.snapshotLoop(opts.SnapshotPath, opts.SnapshotInterval, s.changes)
+		}
+	}
+	return s, nil
+}
+
+// Save persists or updates a paste entry, evicting others if the store is
+// over its bounds.
+func (s *Store) Save(ctx context.Context, paste *storage.Paste) error {
+	if paste == nil {
+		return errors.New("paste is nil")
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if s.opts.MaxBytes > 0 && int64(len(paste.Content)) > s.opts.MaxBytes {
+		return fmt.Errorf("paste of %d bytes exceeds the memory store capacity", len(paste.Content))
+	}
+
+	paste.CreatedAt = paste.CreatedAt.UTC()
+	paste.ExpiresAt = paste.ExpiresAt.UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(copyPaste(paste))
+	s.evictLocked(paste.ID)
+	return nil
+}
+
+func (s *Store) putLocked(p *storage.Paste) {
+	if prev, ok := s.pastes[p.ID]; ok {
+		s.bytes -= int64(len(prev.Content))
+	}
+	s.pastes[p.ID] = p
+	s.bytes += int64(len(p.Content))
+	s.changes++
+}
+
+func (s *Store) removeLocked(id string) {
+	if prev, ok := s.pastes[id]; ok {
+		s.bytes -= int64(len(prev.Content))
+		delete(s.pastes, id)
+		s.changes++
+	}
+}
+
+func (s *Store) overLocked() bool {
+	return (s.opts.MaxPastes > 0 && len(s.pastes) > s.opts.MaxPastes) ||
+		(s.opts.MaxBytes > 0 && s.bytes > s.opts.MaxBytes)
+}
+
+// evictLocked brings the store back within bounds, dropping expired pastes
+// first and then the oldest, but never keep.
+func (s *Store) evictLocked(keep string) {
+	if !s.overLocked() {
+		return
+	}
+	now := s.now()
+	for id, p := range s.pastes {
+		if id != keep && expired(p, now) {
+			s.removeLocked(id)
+		}
+	}
+	if !s.overLocked() {
+		return
+	}
+	oldest := make([]*storage.Paste, 0, len(s.pastes))
+	for _, p := range s.pastes {
+		if p.ID != keep {
+			oldest = append(oldest, p)
+		}
+	}
+	sort.Slice(oldest, func(i, j int) bool { return oldest[i].CreatedAt.Before(oldest[j].CreatedAt) })
+	for _, p := range oldest {
+		if !s.overLocked() {
+			return
+		}
+		s.removeLocked(p.ID)
+	}
+}
+
+// Get retrieves a paste by id. Expired pastes are never returned, even
+// before DeleteExpired removes them.
+func (s *Store) Get(ctx context.Context, id string) (*storage.Paste, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.pastes[id]
+	if !ok || expired(p, s.now()) {
+		return nil, storage.ErrNotFound
+	}
+	return copyPaste(p), nil
+}
+
+// GetMeta fetches a paste without its content.
+func (s *Store) GetMeta(ctx context.Context, id string) (*storage.Paste, error) {
+	p, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p.Size = len(p.Content)
+	p.Content = ""
+	return p, nil
+}
+
+// GetContent reads the content of a paste straight from the stored string.
+func (s *Store) GetContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	p, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(p.Content)), nil
+}
+
+// Delete removes a paste.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pastes[id]; !ok {
+		return storage.ErrNotFound
+	}
+	s.removeLocked(id)
+	return nil
+}
+
+// DeleteExpired removes all pastes with expiry before or equal to the provided time.
+func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for id, p := range s.pastes {
+		if expired(p, before) {
+			s.removeLocked(id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// List returns the unexpired pastes matching opts, newest first.
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) ([]*storage.Paste, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	now := s.now()
+	var out []*storage.Paste
+	for _, p := range s.pastes {
+		if !expired(p, now) && opts.Match(p) {
+			out = append(out, copyPaste(p))
+		}
+	}
+	s.mu.RUnlock()
+
+	storage.SortNewestFirst(out)
+	return opts.Page(out), nil
+}
+
+// Count returns the number of unexpired pastes matching opts.
+func (s *Store) Count(ctx context.Context, opts storage.ListOptions) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	n := 0
+	for _, p := range s.pastes {
+		if !expired(p, now) && opts.Match(p) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Iterate calls fn with a copy of every unexpired paste. It works from a
+// point-in-time copy, so the store stays unlocked while fn runs.
+func (s *Store) Iterate(ctx context.Context, fn func(*storage.Paste) error) error {
+	s.mu.RLock()
+	now := s.now()
+	out := make([]*storage.Paste, 0, len(s.pastes))
+	for _, p := range s.pastes {
+		if !expired(p, now) {
+			out = append(out, copyPaste(p))
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, p := range out {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IterateExpired calls fn with the pastes that expire at or before before,
+// which Iterate hides.
+func (s *Store) IterateExpired(ctx context.Context, before time.Time, fn func(*storage.Paste) error) error {
+	s.mu.RLock()
+	var out []*storage.Paste
+	for _, p := range s.pastes {
+		if expired(p, before) {
+			out = append(out, copyPaste(p))
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, p := range out {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Totals reports the pastes held and their content size.
+func (s *Store) Totals(ctx context.Context) (storage.Totals, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return storage.Totals{Pastes: int64(len(s.pastes)), Bytes: s.bytes}, nil
+}
+
+// Stats summarizes every held paste.
+func (s *Store) Stats(ctx context.Context) (storage.Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := s.now()
+	st := storage.Stats{BySyntax: make(map[string]int64)}
+	for _, p := range s.pastes {
+		st.Add(p, now)
+	}
+	return st, nil
+}
+
+// Ping always succeeds; the store is in process.
+func (s *Store) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// SaveAPIKey persists or replaces an API key.
+func (s *Store) SaveAPIKey(ctx context.Context, key *storage.APIKey) error {
+	if key == nil {
+		return errors.New("api key is nil")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = copyKey(key)
+	s.changes++
+	return nil
+}
+
+// GetAPIKey retrieves an API key by id.
+func (s *Store) GetAPIKey(ctx context.Context, id string) (*storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return copyKey(k), nil
+}
+
+// ListAPIKeys returns every API key ordered by id.
+func (s *Store) ListAPIKeys(ctx context.Context) ([]*storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*storage.APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, copyKey(k))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// DeleteAPIKey revokes an API key.
+func (s *Store) DeleteAPIKey(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[id]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.keys, id)
+	s.changes++
+	return nil
+}
+
+// SaveReport persists or replaces an abuse report.
+func (s *Store) SaveReport(ctx context.Context, report *storage.Report) error {
+	if report == nil {
+		return errors.New("report is nil")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *report
+	s.reports[report.ID] = &cp
+	s.changes++
+	return nil
+}
+
+// ListReports returns every report, oldest first.
+func (s *Store) ListReports(ctx context.Context) ([]*storage.Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*storage.Report, 0, len(s.reports))
+	for _, r := range s.reports {
+		cp := *r
+		out = append(out, &cp)
+	}
+	storage.SortReports(out)
+	return out, nil
+}
+
+// DeleteReport removes a report.
+func (s *Store) DeleteReport(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.reports[id]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.reports, id)
+	s.changes++
+	return nil
+}
+
+// Close stops periodic snapshots and writes a final one, if configured.
+func (s *Store) Close() error {
+	if s == nil || s.opts.SnapshotPath == "" {
+		return nil
+	}
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+			<-s.done
+		}
+		s.closeErr = s.Snapshot(s.opts.SnapshotPath)
+	})
+	return s.closeErr
+}
+
+func expired(p *storage.Paste, at time.Time) bool {
+	return p.HasExpiration() && !p.ExpiresAt.After(at)
+}
+
+// copyPaste keeps callers from mutating stored pastes through shared maps.
+func copyPaste(p *storage.Paste) *storage.Paste {
+	cp := *p
+	cp.Metadata = maps.Clone(p.Metadata)
+	return &cp
+}
+
+func copyKey(k *storage.APIKey) *storage.APIKey {
+	cp := *k
+	cp.Scopes = append([]string(nil), k.Scopes...)
+	return &cp
+}
@@ -0,0 +1,186 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/storagetest"
+)
+
+func TestStoreHidesExpiredAndCopies(t *testing.T) {
+	s, err := New(Options{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	p := &storage.Paste{ID: "a", Content: "hello", Size: 5, CreatedAt: now, ExpiresAt: now.Add(time.Minute), Metadata: map[string]string{"k": "v"}}
+	if err := s.Save(ctx, p); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	p.Metadata["k"] = "changed"
+	got, err := s.Get(ctx, "a")
+	if err != nil || got.Metadata["k"] != "v" {
+		t.Fatalf("expected stored copy isolated from caller, got %+v (%v)", got, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected expired paste hidden, got %v", err)
+	}
+	if n, _ := s.Count(ctx, storage.ListOptions{}); n != 0 {
+		t.Fatalf("expected expired paste uncounted, got %d", n)
+	}
+	if n, err := s.DeleteExpired(ctx, now); err != nil || n != 1 {
+		t.Fatalf("delete expired: %d (%v)", n, err)
+	}
+	if err := s.Delete(ctx, "a"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestStoreEvictsExpiredThenOldest(t *testing.T) {
+	s, err := New(Options{MaxPastes: 3, MaxBytes: 20})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	save := func(id string, size int, age time.Duration, expires time.Time) {
+		t.Helper()
+		p := &storage.Paste{ID: id, Content: string(make([]byte, size)), Size: size, CreatedAt: now.Add(-age), ExpiresAt: expires}
+		if err := s.Save(ctx, p); err != nil {
+			t.Fatalf("save %s: %v", id, err)
+		}
+	}
+	has := func(id string) bool {
+		_, err := s.Get(ctx, id)
+		return err == nil
+	}
+
+	save("old", 5, 3*time.Hour, time.Time{})
+	save("lapsed", 5, time.Minute, now.Add(-time.Second))
+	save("mid", 5, 2*time.Hour, time.Time{})
+	save("new", 5, 0, time.Time{})
+	if !has("old") || !has("mid") || !has("new") || len(s.pastes) != 3 {
+		t.Fatalf("expected the expired paste evicted first, have %d pastes", len(s.pastes))
+	}
+
+	save("big", 10, 0, time.Time{})
+	if has("old") || !has("mid") || !has("big") || s.bytes > 20 {
+		t.Fatalf("expected oldest evicted to fit bytes, bytes=%d old=%v", s.bytes, has("old"))
+	}
+
+	if err := s.Save(ctx, &storage.Paste{ID: "huge", Content: string(make([]byte, 21))}); err == nil {
+		t.Fatalf("expected paste larger than the store rejected")
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pastes.ndjson")
+	s, err := New(Options{SnapshotPath: path})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+	if err := s.Save(ctx, &storage.Paste{ID: "a", Content: "héllo ✓", Size: 9, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := s.SaveAPIKey(ctx, &storage.APIKey{ID: "k", Scopes: []string{"paste:read"}}); err != nil {
+		t.Fatalf("save key: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	restored, err := New(Options{SnapshotPath: path})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if p, err := restored.Get(ctx, "a"); err != nil || p.Content != "héllo ✓" {
+		t.Fatalf("expected paste restored, got %+v (%v)", p, err)
+	}
+	if k, err := restored.GetAPIKey(ctx, "k"); err != nil || !k.HasScope("paste:read") {
+		t.Fatalf("expected key restored, got %+v (%v)", k, err)
+	}
+	if restored.bytes != int64(len("héllo ✓")) {
+		t.Fatalf("expected size accounting restored, got %d", restored.bytes)
+	}
+}
+
+func TestPeriodicSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pastes.ndjson")
+	s, err := New(Options{SnapshotPath: path, SnapshotInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	defer s.Close()
+	if err := s.Save(context.Background(), &storage.Paste{ID: "a", Content: "crash-safe", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	// Without calling Close, a second store must eventually see the paste.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		restored, err := New(Options{SnapshotPath: path})
+		if err != nil {
+			t.Fatalf("restore: %v", err)
+		}
+		if p, err := restored.Get(context.Background(), "a"); err == nil && p.Content == "crash-safe" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected periodic snapshot to persist the paste")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if again, err := os.Stat(path); err != nil || !again.ModTime().Equal(info.ModTime()) {
+		t.Fatalf("expected idle store not to rewrite its snapshot")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+}
+
+func TestOpenDSN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snap.ndjson")
+	store, err := storage.Open("memory://?max_pastes=2&max_bytes=1024&snapshot_interval=30s&snapshot=" + path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	s := store.(*Store)
+	if s.opts.MaxPastes != 2 || s.opts.MaxBytes != 1024 || s.opts.SnapshotPath != path || s.opts.SnapshotInterval != 30*time.Second {
+		t.Fatalf("unexpected options %+v", s.opts)
+	}
+	defer s.Close()
+	if _, err := storage.Open("memory://?max_pastes=lots"); err == nil {
+		t.Fatalf("expected invalid option rejected")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.TestStore(t, func() storage.Store {
+		s, err := New(Options{})
+		if err != nil {
+			t.Fatalf("new: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,152 @@
+package memstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// snapshotRecord is one line of a snapshot file; exactly one field is set.
+type snapshotRecord struct {
+	Paste  *storage.Paste      `json:"paste,omitempty"`
+	APIKey *storage.APIKey     `json:"api_key,omitempty"`
+	Report *storage.Report     `json:"report,omitempty"`
+	Audit  *storage.AuditEntry `json:"audit,omitempty"`
+}
+
+// Snapshot writes every paste, API key, report and audit entry to path as newline-delimited JSON.
+// The file is replaced atomically, so a crash mid-write keeps the old one.
+func (s *Store) Snapshot(path string) error {
+	_, err := s.snapshot(path)
+	return err
+}
+
+// snapshot writes the file and reports the change count it captured.
+func (s *Store) snapshot(path string) (uint64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	s.mu.RLock()
+	changes := s.changes
+	for _, p := range s.pastes {
+		if err = enc.Encode(snapshotRecord{Paste: p}); err != nil {
+			break
+		}
+	}
+	for _, k := range s.keys {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(snapshotRecord{APIKey: k})
+	}
+	for _, r := range s.reports {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(snapshotRecord{Report: r})
+	}
+	for _, e := range s.audit {
+		if err != nil {
+			break
+		}
+		err = enc.Encode(snapshotRecord{Audit: e})
+	}
+	s.mu.RUnlock()
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return 0, fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("replace snapshot: %w", err)
+	}
+	return changes, nil
+}
+
+// snapshotLoop rewrites the snapshot every interval until Close, skipping
+// ticks where nothing changed since the last successful write; saved is the
+// change count already on disk.
+func (s *Store) snapshotLoop(path string, interval time.Duration, saved uint64) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.RLock()
+		changes := s.changes
+		s.mu.RUnlock()
+		if changes == saved {
+			continue
+		}
+		n, err := s.snapshot(path)
+		if err != nil {
+			if s.opts.OnSnapshotError != nil {
+				s.opts.OnSnapshotError(err)
+			}
+			continue
+		}
+		saved = n
+	}
+}
+
+// load restores a snapshot written by Snapshot. A missing file is not an
+// error: the store simply starts empty.
+func (s *Store) load(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var rec snapshotRecord
+		if err := dec.Decode(&rec); err != nil {
+			return fmt.Errorf("read snapshot: %w", err)
+		}
+		switch {
+		case rec.Paste != nil:
+			s.putLocked(rec.Paste)
+		case rec.APIKey != nil:
+			s.keys[rec.APIKey.ID] = rec.APIKey
+		case rec.Report != nil:
+			s.reports[rec.Report.ID] = rec.Report
+		case rec.Audit != nil:
+			s.audit = append(s.audit, rec.Audit)
+		}
+	}
+	s.evictLocked("")
+	return nil
+}
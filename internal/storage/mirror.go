@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// mirrorDrainTimeout bounds how long Close waits for queued writes to reach
+// the secondary store.
+const mirrorDrainTimeout = 30 * time.Second
+
+// MirrorOptions tunes WithMirror.
+type MirrorOptions struct {
+	// ReconcileEvery, when positive, runs Reconcile this often in the
+	// background, starting right away so writes lost in a crash are repaired
+	// soon after a restart.
+	ReconcileEvery time.Duration
+	// OnError reports writes that could not be mirrored and failed
+	// reconciliations; nil drops them. Either way the next reconciliation
+	// repairs the secondary.
+	OnError func(error)
+}
+
+// ReconcileStats counts what Reconcile repaired on the secondary store.
+type ReconcileStats struct {
+	Copied  int
+	Deleted int
+	APIKeys int
+}
+
+// MirrorStore writes to a primary store and mirrors every save and delete to
+// a secondary one in the background.
+type MirrorStore struct {
+	Store
+	secondary Store
+	opts      MirrorOptions
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	expire  time.Time
+	wake    chan struct{}
+
+	// flushMu serializes flushes, so the secondary sees writes to one paste
+	// in order.
+	flushMu   sync.Mutex
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// WithMirror decorates primary so saves and deletes are also applied to
+// secondary, e.g. a local bolt file mirrored to S3 for cheap off-site
+// durability. Callers only wait for primary: reads come from it alone and its
+// errors are the ones returned. Mirrored writes are queued by id and re-read
+// from primary when applied, so a burst of edits costs one secondary write.
+// Writes that fail to mirror, or were still queued when the process died, are
+// repaired by Reconcile, which is also the only way API keys reach secondary.
+// Close flushes the queue and closes both stores.
+func WithMirror(primary, secondary Store, opts MirrorOptions) *MirrorStore {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &MirrorStore{
+		Store:     primary,
+		secondary: secondary,
+		opts:      opts,
+		pending:   make(map[string]struct{}),
+		wake:      make(chan struct{}, 1),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	go m.run(ctx)
+	return m
+}
+
+func (m *MirrorStore) Unwrap() Store { return m.Store }
+
+// Secondary returns the store being mirrored to.
+func (m *MirrorStore) Secondary() Store { return m.secondary }
+
+func (m *MirrorStore) Save(ctx context.Context, paste *Paste) error {
+	if err := m.Store.Save(ctx, paste); err != nil {
+		return err
+	}
+	m.enqueue(paste.ID)
+	return nil
+}
+
+func (m *MirrorStore) SaveBatch(ctx context.Context, pastes []*Paste) error {
+	err := SaveBatch(ctx, m.Store, pastes)
+	// Pastes saved before a non-atomic batch failed still need mirroring;
+	// mirror re-reads primary, so queueing the rest is harmless.
+	for _, p := range pastes {
+		m.requeue(p.ID, time.Time{})
+	}
+	m.signal()
+	return err
+}
+
+func (m *MirrorStore) Delete(ctx context.Context, id string) error {
+	if err := m.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	m.enqueue(id)
+	return nil
+}
+
+// DeleteExpired sweeps primary now and secondary with the next flush.
+func (m *MirrorStore) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	n, err := m.Store.DeleteExpired(ctx, before)
+	if err != nil {
+		return n, err
+	}
+	m.mu.Lock()
+	m.expire = later(m.expire, before)
+	m.mu.Unlock()
+	m.signal()
+	return n, nil
+}
+
+// Flush applies the queued writes to secondary, returning the errors of
+// those that failed. Writes interrupted by ctx stay queued.
+func (m *MirrorStore) Flush(ctx context.Context) error {
+	m.flushMu.Lock()
+	defer m.flushMu.Unlock()
+	m.mu.Lock()
+	ids, before := m.pending, m.expire
+	m.pending, m.expire = make(map[string]struct{}), time.Time{}
+	m.mu.Unlock()
+
+	var errs []error
+	for id := range ids {
+		if ctx.Err() != nil {
+			m.requeue(id, time.Time{})
+			continue
+		}
+		if err := m.mirror(ctx, id); err != nil {
+			if ctx.Err() != nil {
+				m.requeue(id, time.Time{})
+				continue
+			}
+			errs = append(errs, err)
+		}
+	}
+	if !before.IsZero() {
+		if _, err := m.secondary.DeleteExpired(ctx, before); err != nil {
+			if ctx.Err() != nil {
+				m.requeue("", before)
+			} else {
+				errs = append(errs, fmt.Errorf("mirror expiry sweep: %w", err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mirror copies the current state of one paste from primary to secondary.
+func (m *MirrorStore) mirror(ctx context.Context, id string) error {
+	paste, err := m.Store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		if err := m.secondary.Delete(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("mirror delete %s: %w", id, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("mirror read %s: %w", id, err)
+	}
+	if err := m.secondary.Save(ctx, paste); err != nil {
+		return fmt.Errorf("mirror save %s: %w", id, err)
+	}
+	return nil
+}
+
+// Reconcile makes secondary match primary: pastes missing or different on
+// secondary are copied over, pastes primary no longer has are deleted, and
+// API keys are copied when both stores support them. It reads every paste of
+// both stores, so it is meant to run rarely.
+func (m *MirrorStore) Reconcile(ctx context.Context) (ReconcileStats, error) {
+	var stats ReconcileStats
+	mirrored := make(map[string][sha256.Size]byte)
+	err := m.secondary.Iterate(ctx, func(p *Paste) error {
+		mirrored[p.ID] = fingerprint(p)
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("read secondary: %w", err)
+	}
+	err = m.Store.Iterate(ctx, func(p *Paste) error {
+		sum, ok := mirrored[p.ID]
+		delete(mirrored, p.ID)
+		if ok && sum == fingerprint(p) {
+			return nil
+		}
+		if err := m.secondary.Save(ctx, p); err != nil {
+			return fmt.Errorf("save paste %s: %w", p.ID, err)
+		}
+		stats.Copied++
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	for id := range mirrored {
+		// The paste may have been saved after primary was read; only drop
+		// it once primary confirms it is gone.
+		if _, err := m.Store.Get(ctx, id); !errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err := m.secondary.Delete(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			return stats, fmt.Errorf("delete paste %s: %w", id, err)
+		}
+		stats.Deleted++
+	}
+
+	srcKeys, srcOK := As[APIKeyStore](m.Store)
+	dstKeys, dstOK := As[APIKeyStore](m.secondary)
+	if !srcOK || !dstOK {
+		return stats, nil
+	}
+	keys, err := srcKeys.ListAPIKeys(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("list api keys: %w", err)
+	}
+	stale, err := dstKeys.ListAPIKeys(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("list mirrored api keys: %w", err)
+	}
+	keep := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		keep[key.ID] = true
+		if err := dstKeys.SaveAPIKey(ctx, key); err != nil {
+			return stats, fmt.Errorf("save api key %s: %w", key.ID, err)
+		}
+		stats.APIKeys++
+	}
+	for _, key := range stale {
+		if keep[key.ID] {
+			continue
+		}
+		if err := dstKeys.DeleteAPIKey(ctx, key.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return stats, fmt.Errorf("delete api key %s: %w", key.ID, err)
+		}
+	}
+	return stats, nil
+}
+
+// Close flushes queued writes and closes both stores. It is safe to call
+// more than once.
+func (m *MirrorStore) Close() error {
+	m.closeOnce.Do(func() {
+		m.cancel()
+		<-m.done
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorDrainTimeout)
+		defer cancel()
+		m.closeErr = errors.Join(m.Flush(ctx), m.secondary.Close(), m.Store.Close())
+	})
+	return m.closeErr
+}
+
+func (m *MirrorStore) run(ctx context.Context) {
+	defer close(m.done)
+	var tick <-chan time.Time
+	if m.opts.ReconcileEvery > 0 {
+		ticker := time.NewTicker(m.opts.ReconcileEvery)
+		defer ticker.Stop()
+		tick = ticker.C
+		m.reconcile(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+			m.report(m.Flush(ctx))
+		case <-tick:
+			m.reconcile(ctx)
+		}
+	}
+}
+
+func (m *MirrorStore) reconcile(ctx context.Context) {
+	if _, err := m.Reconcile(ctx); err != nil && ctx.Err() == nil {
+		m.report(fmt.Errorf("reconcile mirror: %w", err))
+	}
+}
+
+func (m *MirrorStore) report(err error) {
+	if err != nil && m.opts.OnError != nil {
+		m.opts.OnError(err)
+	}
+}
+
+func (m *MirrorStore) enqueue(id string) {
+	m.requeue(id, time.Time{})
+	m.signal()
+}
+
+// requeue adds a paste id, an expiry sweep, or both to the queue without
+// waking the worker.
+func (m *MirrorStore) requeue(id string, before time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id != "" {
+		m.pending[id] = struct{}{}
+	}
+	m.expire = later(m.expire, before)
+}
+
+func (m *MirrorStore) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// fingerprint summarizes a paste for comparing copies kept by different
+// backends, ignoring how each one represents times and sizes.
+func fingerprint(p *Paste) [sha256.Size]byte {
+	cp := *p
+	cp.CreatedAt, cp.ExpiresAt = cp.CreatedAt.UTC(), cp.ExpiresAt.UTC()
+	cp.DeletedAt, cp.UpdatedAt, cp.TakenDownAt = cp.DeletedAt.UTC(), cp.UpdatedAt.UTC(), cp.TakenDownAt.UTC()
+	cp.Size, cp.InFile = len(cp.Content), false
+	data, _ := json.Marshal(&cp)
+	return sha256.Sum256(data)
+}
@@ -0,0 +1,80 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/storagetest"
+)
+
+func TestMirrorConformance(t *testing.T) {
+	storagetest.TestStore(t, func() storage.Store {
+		return storage.WithMirror(newMemstore(t), newMemstore(t), storage.MirrorOptions{})
+	})
+}
+
+func TestMirrorCopiesWritesAndReconciles(t *testing.T) {
+	ctx := context.Background()
+	secondary := newMemstore(t)
+	store := storage.WithMirror(newMemstore(t), secondary, storage.MirrorOptions{})
+	defer store.Close()
+
+	now := time.Now().UTC()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := store.Save(ctx, &storage.Paste{ID: id, Content: id, CreatedAt: now}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	if err := store.Delete(ctx, "c"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if p, err := secondary.Get(ctx, "a"); err != nil || p.Content != "a" {
+		t.Fatalf("expected a mirrored, got %+v (%v)", p, err)
+	}
+	if _, err := secondary.Get(ctx, "c"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the delete mirrored, got %v", err)
+	}
+
+	// Drift the secondary as a lost write or an outage would.
+	if err := secondary.Save(ctx, &storage.Paste{ID: "a", Content: "stale", CreatedAt: now}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := secondary.Delete(ctx, "b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := secondary.Save(ctx, &storage.Paste{ID: "orphan", Content: "x", CreatedAt: now}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := secondary.SaveAPIKey(ctx, &storage.APIKey{ID: "old", CreatedAt: now}); err != nil {
+		t.Fatalf("save key: %v", err)
+	}
+	if err := store.Unwrap().(storage.APIKeyStore).SaveAPIKey(ctx, &storage.APIKey{ID: "k", CreatedAt: now}); err != nil {
+		t.Fatalf("save key: %v", err)
+	}
+
+	stats, err := store.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if stats != (storage.ReconcileStats{Copied: 2, Deleted: 1, APIKeys: 1}) {
+		t.Fatalf("unexpected reconcile stats %+v", stats)
+	}
+	if p, err := secondary.Get(ctx, "a"); err != nil || p.Content != "a" {
+		t.Fatalf("expected a repaired, got %+v (%v)", p, err)
+	}
+	if _, err := secondary.Get(ctx, "orphan"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected the orphan removed, got %v", err)
+	}
+	if keys, _ := secondary.ListAPIKeys(ctx); len(keys) != 1 || keys[0].ID != "k" {
+		t.Fatalf("expected api keys mirrored, got %+v", keys)
+	}
+	if stats, _ := store.Reconcile(ctx); stats.Copied != 0 || stats.Deleted != 0 {
+		t.Fatalf("expected nothing left to repair, got %+v", stats)
+	}
+}
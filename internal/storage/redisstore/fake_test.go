@@ -0,0 +1,245 @@
+package redisstore
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis speaks just enough RESP to exercise the store.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	offset   time.Duration
+	strings  map[string]string
+	expires  map[string]time.Time
+	sets     map[string]map[string]bool
+	hashes   map[string]map[string]string
+	password string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{
+		ln:       ln,
+		password: password,
+		strings:  make(map[string]string),
+		expires:  make(map[string]time.Time),
+		sets:     make(map[string]map[string]bool),
+		hashes:   make(map[string]map[string]string),
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) url() string { return "redis://" + f.ln.Addr().String() }
+
+// advance moves the fake clock forward so TTLs lapse.
+func (f *fakeRedis) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.offset += d
+}
+
+// nowLocked is real time shifted by advance, so short TTLs also lapse on
+// their own.
+func (f *fakeRedis) nowLocked() time.Time {
+	return time.Now().Add(f.offset)
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	authed := f.password == ""
+	var queue [][]string
+	inMulti := false
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]any)
+		args := make([]string, len(items))
+		for i, it := range items {
+			args[i] = string(it.([]byte))
+		}
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case cmd == "AUTH":
+			authed = args[len(args)-1] == f.password
+			if authed {
+				w.WriteString("+OK\r\n")
+			} else {
+				w.WriteString("-WRONGPASS invalid password\r\n")
+			}
+		case !authed:
+			w.WriteString("-NOAUTH Authentication required.\r\n")
+		case cmd == "MULTI":
+			inMulti = true
+			queue = nil
+			w.WriteString("+OK\r\n")
+		case cmd == "EXEC":
+			fmt.Fprintf(w, "*%d\r\n", len(queue))
+			for _, q := range queue {
+				w.WriteString(f.exec(q))
+			}
+			inMulti = false
+		case inMulti:
+			queue = append(queue, args)
+			w.WriteString("+QUEUED\r\n")
+		default:
+			w.WriteString(f.exec(args))
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, at := range f.expires {
+		if !at.After(f.nowLocked()) {
+			delete(f.strings, k)
+			delete(f.expires, k)
+		}
+	}
+	bulk := func(v string, ok bool) string {
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	}
+	array := func(vals []string) string {
+		out := fmt.Sprintf("*%d\r\n", len(vals))
+		for _, v := range vals {
+			out += bulk(v, true)
+		}
+		return out
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		f.strings[args[1]] = args[2]
+		delete(f.expires, args[1])
+		if len(args) == 5 && strings.EqualFold(args[3], "PX") {
+			ms, _ := strconv.Atoi(args[4])
+			f.expires[args[1]] = f.nowLocked().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "GET":
+		v, ok := f.strings[args[1]]
+		return bulk(v, ok)
+	case "MGET":
+		out := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, k := range args[1:] {
+			v, ok := f.strings[k]
+			out += bulk(v, ok)
+		}
+		return out
+	case "DEL":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := f.strings[k]; ok {
+				delete(f.strings, k)
+				delete(f.expires, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "SADD":
+		set := f.sets[args[1]]
+		if set == nil {
+			set = make(map[string]bool)
+			f.sets[args[1]] = set
+		}
+		n := 0
+		for _, m := range args[2:] {
+			if !set[m] {
+				set[m] = true
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "SREM":
+		n := 0
+		for _, m := range args[2:] {
+			if f.sets[args[1]][m] {
+				delete(f.sets[args[1]], m)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "SMEMBERS":
+		var out []string
+		for m := range f.sets[args[1]] {
+			out = append(out, m)
+		}
+		sort.Strings(out)
+		return array(out)
+	case "HSET":
+		h := f.hashes[args[1]]
+		if h == nil {
+			h = make(map[string]string)
+			f.hashes[args[1]] = h
+		}
+		_, existed := h[args[2]]
+		h[args[2]] = args[3]
+		if existed {
+			return ":0\r\n"
+		}
+		return ":1\r\n"
+	case "HGET":
+		v, ok := f.hashes[args[1]][args[2]]
+		return bulk(v, ok)
+	case "HVALS":
+		var out []string
+		for _, v := range f.hashes[args[1]] {
+			out = append(out, v)
+		}
+		return array(out)
+	case "HDEL":
+		n := 0
+		for _, field := range args[2:] {
+			if _, ok := f.hashes[args[1]][field]; ok {
+				delete(f.hashes[args[1]], field)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "SCARD":
+		return fmt.Sprintf(":%d\r\n", len(f.sets[args[1]]))
+	case "INCRBY":
+		cur, _ := strconv.ParseInt(f.strings[args[1]], 10, 64)
+		by, _ := strconv.ParseInt(args[2], 10, 64)
+		f.strings[args[1]] = strconv.FormatInt(cur+by, 10)
+		return fmt.Sprintf(":%d\r\n", cur+by)
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
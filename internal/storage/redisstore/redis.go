@@ -0,0 +1,461 @@
+// Package redisstore implements storage.Store on Redis. Expiring pastes are
+// written with native TTLs, so Redis removes them on time by itself and the
+// janitor only tidies the index.
+package redisstore
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// defaultPrefix namespaces every key so an instance can share a database.
+const defaultPrefix = "tinypaste:"
+
+// mgetBatch bounds the keys fetched per MGET while scanning.
+const mgetBatch = 500
+
+func init() {
+	open := func(dsn string) (storage.Store, error) { return Open(dsn) }
+	storage.Register("redis", open)
+	storage.Register("rediss", open)
+}
+
+// Store implements storage.Store backed by Redis.
+type Store struct {
+	c      *client
+	prefix string
+	now    func() time.Time
+}
+
+// Open connects to the server described by rawURL:
+//
+//	redis://[[user]:password@]host[:port][/db][?prefix=tinypaste:]
+//
+// rediss:// connects over TLS.
+func Open(rawURL string) (*Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	c := &client{addr: u.Host}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("unsupported redis url scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		c.addr = u.Host + ":6379"
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+		if c.password == "" {
+			// redis://:password@host and redis://password@host both mean a
+			// bare password.
+			c.username, c.password = "", c.username
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	prefix := defaultPrefix
+	if p, ok := u.Query()["prefix"]; ok {
+		prefix = p[0]
+	}
+
+	s := &Store{c: c, prefix: prefix, now: time.Now}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	if _, err := c.do(ctx, "PING"); err != nil {
+		_ = c.close()
+		return nil, fmt.Errorf("connect to redis: %w", err)
+	}
+	if err := s.initTotals(ctx); err != nil {
+		_ = c.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) pasteKey(id string) string { return s.prefix + "paste:" + id }
+func (s *Store) indexKey() string          { return s.prefix + "pastes" }
+func (s *Store) apiKeysKey() string        { return s.prefix + "apikeys" }
+func (s *Store) sizesKey() string          { return s.prefix + "sizes" }
+func (s *Store) bytesKey() string          { return s.prefix + "bytes" }
+
+// Save persists or updates a paste entry, with a TTL when it expires.
+func (s *Store) Save(ctx context.Context, paste *storage.Paste) error {
+	if paste == nil {
+		return errors.New("paste is nil")
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	paste.CreatedAt = paste.CreatedAt.UTC()
+	paste.ExpiresAt = paste.ExpiresAt.UTC()
+	data, err := json.Marshal(paste)
+	if err != nil {
+		return fmt.Errorf("marshal paste: %w", err)
+	}
+
+	set := []string{"SET", s.pasteKey(paste.ID), string(data)}
+	if paste.HasExpiration() {
+		ttl := paste.ExpiresAt.Sub(s.now()).Milliseconds()
+		if ttl < 1 {
+			ttl = 1
+		}
+		set = append(set, "PX", strconv.FormatInt(ttl, 10))
+	}
+	size := int64(len(paste.Content))
+	prev, err := s.storedSize(ctx, paste.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.c.tx(ctx, set,
+		[]string{"SADD", s.indexKey(), paste.ID},
+		[]string{"HSET", s.sizesKey(), paste.ID, strconv.FormatInt(size, 10)},
+		[]string{"INCRBY", s.bytesKey(), strconv.FormatInt(size-prev, 10)},
+	); err != nil {
+		return fmt.Errorf("save paste: %w", err)
+	}
+	return nil
+}
+
+// storedSize returns the content size recorded for id, or zero.
+func (s *Store) storedSize(ctx context.Context, id string) (int64, error) {
+	reply, err := s.c.do(ctx, "HGET", s.sizesKey(), id)
+	if err != nil {
+		return 0, fmt.Errorf("read paste size: %w", err)
+	}
+	raw, _ := reply.([]byte)
+	if raw == nil {
+		return 0, nil
+	}
+	n, _ := strconv.ParseInt(string(raw), 10, 64)
+	return n, nil
+}
+
+// Get retrieves a paste by id.
+func (s *Store) Get(ctx context.Context, id string) (*storage.Paste, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	reply, err := s.c.do(ctx, "GET", s.pasteKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("get paste: %w", err)
+	}
+	raw, _ := reply.([]byte)
+	if raw == nil {
+		return nil, storage.ErrNotFound
+	}
+	var paste storage.Paste
+	if err := json.Unmarshal(raw, &paste); err != nil {
+		return nil, fmt.Errorf("unmarshal paste: %w", err)
+	}
+	return &paste, nil
+}
+
+// Delete removes a paste.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	size, err := s.storedSize(ctx, id)
+	if err != nil {
+		return err
+	}
+	replies, err := s.c.tx(ctx,
+		[]string{"DEL", s.pasteKey(id)},
+		[]string{"SREM", s.indexKey(), id},
+		[]string{"HDEL", s.sizesKey(), id},
+		[]string{"INCRBY", s.bytesKey(), strconv.FormatInt(-size, 10)},
+	)
+	if err != nil {
+		return fmt.Errorf("delete paste: %w", err)
+	}
+	if n, _ := replies[0].(int64); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteExpired drops index entries for pastes Redis has already expired and
+// reports how many there were. Expiry itself is handled by key TTLs.
+func (s *Store) DeleteExpired(ctx context.Context, _ time.Time) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	var gone []string
+	err := s.scan(ctx, func(id string) {
+		gone = append(gone, id)
+	}, nil)
+	if err != nil || len(gone) == 0 {
+		return 0, err
+	}
+	var freed int64
+	for _, id := range gone {
+		size, err := s.storedSize(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		freed += size
+	}
+	if _, err := s.c.tx(ctx,
+		append([]string{"SREM", s.indexKey()}, gone...),
+		append([]string{"HDEL", s.sizesKey()}, gone...),
+		[]string{"INCRBY", s.bytesKey(), strconv.FormatInt(-freed, 10)},
+	); err != nil {
+		return 0, fmt.Errorf("prune index: %w", err)
+	}
+	return len(gone), nil
+}
+
+// Totals reports the indexed paste count and their content size. Pastes
+// whose TTL lapsed stay counted until DeleteExpired prunes the index.
+func (s *Store) Totals(ctx context.Context) (storage.Totals, error) {
+	replies, err := s.c.pipeline(ctx, [][]string{{"SCARD", s.indexKey()}, {"GET", s.bytesKey()}})
+	if err != nil {
+		return storage.Totals{}, fmt.Errorf("read totals: %w", err)
+	}
+	for _, r := range replies {
+		if e, ok := r.(redisError); ok {
+			return storage.Totals{}, fmt.Errorf("read totals: %w", e)
+		}
+	}
+	t := storage.Totals{}
+	t.Pastes, _ = replies[0].(int64)
+	if raw, ok := replies[1].([]byte); ok {
+		t.Bytes, _ = strconv.ParseInt(string(raw), 10, 64)
+	}
+	return t, nil
+}
+
+// initTotals records the size of every paste in a keyspace written before
+// sizes were tracked. It runs once, when the byte counter does not exist.
+func (s *Store) initTotals(ctx context.Context) error {
+	reply, err := s.c.do(ctx, "GET", s.bytesKey())
+	if err != nil {
+		return fmt.Errorf("read totals: %w", err)
+	}
+	if reply != nil {
+		return nil
+	}
+	var total int64
+	cmds := [][]string{}
+	err = s.scan(ctx, nil, func(p *storage.Paste) error {
+		size := int64(len(p.Content))
+		total += size
+		cmds = append(cmds, []string{"HSET", s.sizesKey(), p.ID, strconv.FormatInt(size, 10)})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("count pastes: %w", err)
+	}
+	cmds = append(cmds, []string{"SET", s.bytesKey(), strconv.FormatInt(total, 10)})
+	if _, err := s.c.tx(ctx, cmds...); err != nil {
+		return fmt.Errorf("seed totals: %w", err)
+	}
+	return nil
+}
+
+// List returns pastes matching opts, newest first.
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) ([]*storage.Paste, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	var out []*storage.Paste
+	err := s.scan(ctx, nil, func(p *storage.Paste) error {
+		if opts.Match(p) {
+			out = append(out, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	storage.SortNewestFirst(out)
+	return opts.Page(out), nil
+}
+
+// Count returns the number of pastes matching opts.
+func (s *Store) Count(ctx context.Context, opts storage.ListOptions) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	n := 0
+	err := s.scan(ctx, nil, func(p *storage.Paste) error {
+		if opts.Match(p) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Iterate calls fn with every stored paste, loading them in MGET batches.
+func (s *Store) Iterate(ctx context.Context, fn func(*storage.Paste) error) error {
+	return s.scan(ctx, nil, fn)
+}
+
+// scan walks the index, calling missing for ids whose key has expired and
+// found for every stored paste, stopping at the first error found returns.
+// Either callback may be nil.
+func (s *Store) scan(ctx context.Context, missing func(id string), found func(*storage.Paste) error) error {
+	reply, err := s.c.do(ctx, "SMEMBERS", s.indexKey())
+	if err != nil {
+		return fmt.Errorf("list paste index: %w", err)
+	}
+	members, _ := reply.([]any)
+	for start := 0; start < len(members); start += mgetBatch {
+		end := min(start+mgetBatch, len(members))
+		ids := make([]string, 0, end-start)
+		args := make([]string, 0, end-start+1)
+		args = append(args, "MGET")
+		for _, m := range members[start:end] {
+			id := string(m.([]byte))
+			ids = append(ids, id)
+			args = append(args, s.pasteKey(id))
+		}
+		reply, err := s.c.do(ctx, args...)
+		if err != nil {
+			return fmt.Errorf("load pastes: %w", err)
+		}
+		values, _ := reply.([]any)
+		for i, v := range values {
+			raw, _ := v.([]byte)
+			if raw == nil {
+				if missing != nil {
+					missing(ids[i])
+				}
+				continue
+			}
+			if found == nil {
+				continue
+			}
+			var paste storage.Paste
+			if err := json.Unmarshal(raw, &paste); err != nil {
+				return fmt.Errorf("unmarshal paste: %w", err)
+			}
+			if err := found(&paste); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Stats scans every indexed paste.
+func (s *Store) Stats(ctx context.Context) (storage.Stats, error) {
+	return storage.ScanStats(ctx, s, time.Now())
+}
+
+// Ping round-trips a PING to the server.
+func (s *Store) Ping(ctx context.Context) error {
+	if _, err := s.c.do(ctx, "PING"); err != nil {
+		return fmt.Errorf("ping redis: %w", err)
+	}
+	return nil
+}
+
+// SaveAPIKey persists or replaces an API key.
+func (s *Store) SaveAPIKey(ctx context.Context, key *storage.APIKey) error {
+	if key == nil {
+		return errors.New("api key is nil")
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal api key: %w", err)
+	}
+	if _, err := s.c.do(ctx, "HSET", s.apiKeysKey(), key.ID, string(data)); err != nil {
+		return fmt.Errorf("save api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey retrieves an API key by id.
+func (s *Store) GetAPIKey(ctx context.Context, id string) (*storage.APIKey, error) {
+	reply, err := s.c.do(ctx, "HGET", s.apiKeysKey(), id)
+	if err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	raw, _ := reply.([]byte)
+	if raw == nil {
+		return nil, storage.ErrNotFound
+	}
+	var key storage.APIKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("unmarshal api key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns every API key ordered by id.
+func (s *Store) ListAPIKeys(ctx context.Context) ([]*storage.APIKey, error) {
+	reply, err := s.c.do(ctx, "HVALS", s.apiKeysKey())
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	values, _ := reply.([]any)
+	out := make([]*storage.APIKey, 0, len(values))
+	for _, v := range values {
+		var key storage.APIKey
+		if err := json.Unmarshal(v.([]byte), &key); err != nil {
+			return nil, fmt.Errorf("unmarshal api key: %w", err)
+		}
+		out = append(out, &key)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// DeleteAPIKey revokes an API key.
+func (s *Store) DeleteAPIKey(ctx context.Context, id string) error {
+	reply, err := s.c.do(ctx, "HDEL", s.apiKeysKey(), id)
+	if err != nil {
+		return fmt.Errorf("delete api key: %w", err)
+	}
+	if n, _ := reply.(int64); n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// Close closes idle connections.
+func (s *Store) Close() error {
+	if s == nil || s.c == nil {
+		return nil
+	}
+	return s.c.close()
+}
@@ -0,0 +1,127 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/storagetest"
+)
+
+func openFake(t *testing.T) (*Store, *fakeRedis) {
+	t.Helper()
+	f := newFakeRedis(t, "")
+	s, err := Open(f.url() + "/2?prefix=test:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	// Align the store's TTL arithmetic with the fake clock.
+	s.now = func() time.Time {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.nowLocked()
+	}
+	return s, f
+}
+
+func TestStoreCRUDAndNativeExpiry(t *testing.T) {
+	s, f := openFake(t)
+	ctx := context.Background()
+	now := s.now()
+
+	keep := &storage.Paste{ID: "keep", Content: "hello", Syntax: "go", Size: 5, CreatedAt: now, Metadata: map[string]string{"ticket": "1"}}
+	brief := &storage.Paste{ID: "brief", Content: "bye", Syntax: "plaintext", Size: 3, CreatedAt: now.Add(time.Second), ExpiresAt: now.Add(time.Minute)}
+	for _, p := range []*storage.Paste{keep, brief} {
+		if err := s.Save(ctx, p); err != nil {
+			t.Fatalf("save %s: %v", p.ID, err)
+		}
+	}
+	if _, ok := f.strings["test:paste:keep"]; !ok {
+		t.Fatalf("expected prefixed key, have %v", f.strings)
+	}
+
+	got, err := s.Get(ctx, "keep")
+	if err != nil || got.Content != "hello" || got.Metadata["ticket"] != "1" {
+		t.Fatalf("get: %+v (%v)", got, err)
+	}
+	list, err := s.List(ctx, storage.ListOptions{})
+	if err != nil || len(list) != 2 || list[0].ID != "brief" {
+		t.Fatalf("list: %v (%v)", list, err)
+	}
+	if n, err := s.Count(ctx, storage.ListOptions{Syntax: "go"}); err != nil || n != 1 {
+		t.Fatalf("count: %d (%v)", n, err)
+	}
+
+	f.advance(2 * time.Minute)
+	if _, err := s.Get(ctx, "brief"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ttl to expire paste, got %v", err)
+	}
+	if list, _ := s.List(ctx, storage.ListOptions{}); len(list) != 1 {
+		t.Fatalf("expected expired paste skipped by list, got %d", len(list))
+	}
+	if n, err := s.DeleteExpired(ctx, time.Now()); err != nil || n != 1 {
+		t.Fatalf("delete expired: %d (%v)", n, err)
+	}
+	if f.sets["test:pastes"]["brief"] {
+		t.Fatalf("expected index pruned")
+	}
+
+	if err := s.Delete(ctx, "keep"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := s.Delete(ctx, "keep"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected not found on second delete, got %v", err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	s, _ := openFake(t)
+	ctx := context.Background()
+	for _, id := range []string{"b", "a"} {
+		if err := s.SaveAPIKey(ctx, &storage.APIKey{ID: id, Name: "k" + id, Scopes: []string{"paste:read"}}); err != nil {
+			t.Fatalf("save key: %v", err)
+		}
+	}
+	keys, err := s.ListAPIKeys(ctx)
+	if err != nil || len(keys) != 2 || keys[0].ID != "a" {
+		t.Fatalf("list keys: %v (%v)", keys, err)
+	}
+	if k, err := s.GetAPIKey(ctx, "b"); err != nil || k.Name != "kb" {
+		t.Fatalf("get key: %+v (%v)", k, err)
+	}
+	if err := s.DeleteAPIKey(ctx, "b"); err != nil {
+		t.Fatalf("delete key: %v", err)
+	}
+	if _, err := s.GetAPIKey(ctx, "b"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected revoked key missing, got %v", err)
+	}
+}
+
+func TestOpenAuthAndURLErrors(t *testing.T) {
+	f := newFakeRedis(t, "sekret")
+	if _, err := Open(f.url()); err == nil || !strings.Contains(err.Error(), "NOAUTH") {
+		t.Fatalf("expected auth required, got %v", err)
+	}
+	if _, err := Open("redis://:wrong@" + f.ln.Addr().String()); err == nil {
+		t.Fatalf("expected wrong password rejected")
+	}
+	s, err := Open("redis://:sekret@" + f.ln.Addr().String())
+	if err != nil {
+		t.Fatalf("open with password: %v", err)
+	}
+	s.Close()
+	if _, err := Open("memcache://localhost"); err == nil {
+		t.Fatalf("expected unsupported scheme rejected")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.TestStore(t, func() storage.Store {
+		s, _ := openFake(t)
+		return s
+	})
+}
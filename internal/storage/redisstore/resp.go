@@ -0,0 +1,271 @@
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultTimeout bounds a command when the context has no deadline.
+const defaultTimeout = 5 * time.Second
+
+// maxIdle caps the connections kept open between commands.
+const maxIdle = 8
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// client is a minimal RESP2 client with a small connection pool.
+type client struct {
+	addr     string
+	tls      *tls.Config
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do runs one command and returns its reply.
+func (c *client) do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.pipeline(ctx, [][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := replies[0].(redisError); ok {
+		return nil, e
+	}
+	return replies[0], nil
+}
+
+// tx runs cmds atomically inside MULTI/EXEC and returns their replies.
+func (c *client) tx(ctx context.Context, cmds ...[]string) ([]any, error) {
+	batch := append([][]string{{"MULTI"}}, cmds...)
+	batch = append(batch, []string{"EXEC"})
+	replies, err := c.pipeline(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replies[:len(replies)-1] {
+		if e, ok := r.(redisError); ok {
+			return nil, e
+		}
+	}
+	results, ok := replies[len(replies)-1].([]any)
+	if !ok {
+		return nil, errors.New("redis: transaction aborted")
+	}
+	for _, r := range results {
+		if e, ok := r.(redisError); ok {
+			return nil, e
+		}
+	}
+	return results, nil
+}
+
+// pipeline writes every command before reading the replies. Error replies
+// are returned in place rather than as an error.
+func (c *client) pipeline(ctx context.Context, cmds [][]string) ([]any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	_ = cn.SetDeadline(deadline)
+
+	replies := make([]any, 0, len(cmds))
+	err = func() error {
+		for _, args := range cmds {
+			writeCommand(cn.w, args)
+		}
+		if err := cn.w.Flush(); err != nil {
+			return err
+		}
+		for range cmds {
+			reply, err := readReply(cn.r)
+			if err != nil {
+				return err
+			}
+			replies = append(replies, reply)
+		}
+		return nil
+	}()
+	if err != nil {
+		_ = cn.Close()
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	c.put(cn)
+	return replies, nil
+}
+
+func (c *client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+func (c *client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= maxIdle {
+		_ = cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (c *client) dial(ctx context.Context) (*conn, error) {
+	var (
+		nc  net.Conn
+		err error
+	)
+	if c.tls != nil {
+		d := tls.Dialer{Config: c.tls}
+		nc, err = d.DialContext(ctx, "tcp", c.addr)
+	} else {
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %s: %w", c.addr, err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	var setup [][]string
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []string{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = nc.SetDeadline(deadline)
+		} else {
+			_ = nc.SetDeadline(time.Now().Add(defaultTimeout))
+		}
+		for _, args := range setup {
+			writeCommand(cn.w, args)
+		}
+		if err := cn.w.Flush(); err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		for range setup {
+			reply, err := readReply(cn.r)
+			if err == nil {
+				if e, ok := reply.(redisError); ok {
+					err = e
+				}
+			}
+			if err != nil {
+				_ = nc.Close()
+				return nil, err
+			}
+		}
+	}
+	return cn, nil
+}
+
+func (c *client) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var first error
+	for _, cn := range c.idle {
+		if err := cn.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	c.idle = nil
+	return first
+}
+
+func writeCommand(w *bufio.Writer, args []string) {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+	}
+}
+
+// readReply decodes one RESP2 value: string, redisError, int64, []byte (nil
+// for a null bulk string) or []any (nil for a null array).
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return redisError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return []byte(nil), nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed array length %q", body)
+		}
+		if n < 0 {
+			return []any(nil), nil
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply type %q", kind)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Opener opens a store from a DSN such as "bolt:///var/lib/tinypaste.db".
+// It receives the whole DSN, scheme included.
+type Opener func(dsn string) (Store, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = make(map[string]Opener)
+)
+
+// Register makes a backend available to Open under scheme. Backends call it
+// from init, so importing a backend package is enough to enable it. Like
+// database/sql.Register it panics if scheme is registered twice.
+func Register(scheme string, open Opener) {
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	if open == nil {
+		panic("storage: Register opener is nil")
+	}
+	if _, dup := openers[scheme]; dup {
+		panic("storage: Register called twice for scheme " + scheme)
+	}
+	openers[scheme] = open
+}
+
+// Schemes lists the registered backend schemes in sorted order.
+func Schemes() []string {
+	openersMu.RLock()
+	defer openersMu.RUnlock()
+	out := make([]string, 0, len(openers))
+	for scheme := range openers {
+		out = append(out, scheme)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Open opens the store named by dsn, dispatching on its scheme.
+func Open(dsn string) (Store, error) {
+	scheme, _, ok := strings.Cut(dsn, "://")
+	if !ok || scheme == "" {
+		return nil, fmt.Errorf("storage: %q is not a <scheme>://... DSN", dsn)
+	}
+	openersMu.RLock()
+	open := openers[scheme]
+	openersMu.RUnlock()
+	if open == nil {
+		return nil, fmt.Errorf("storage: unknown backend %q (have %s)", scheme, strings.Join(Schemes(), ", "))
+	}
+	return open(dsn)
+}
+
+// DSNPath returns the file path of a file-backed DSN: "bolt:///abs/path.db"
+// gives "/abs/path.db" and "bolt://relative.db" gives "relative.db".
+func DSNPath(dsn string) string {
+	_, path, _ := strings.Cut(dsn, "://")
+	return path
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegistryDispatchesOnScheme(t *testing.T) {
+	var got string
+	Register("testfake", func(dsn string) (Store, error) {
+		got = dsn
+		return nil, errors.New("opened")
+	})
+	if _, err := Open("testfake:///tmp/x.db"); err == nil || err.Error() != "opened" || got != "testfake:///tmp/x.db" {
+		t.Fatalf("expected fake opener called with the full dsn, got %q (%v)", got, err)
+	}
+	if DSNPath(got) != "/tmp/x.db" || DSNPath("testfake://rel.db") != "rel.db" {
+		t.Fatalf("unexpected DSN paths")
+	}
+	if _, err := Open("nope://x"); err == nil || !strings.Contains(err.Error(), "testfake") {
+		t.Fatalf("expected unknown scheme to list registered ones, got %v", err)
+	}
+	if _, err := Open("/plain/path.db"); err == nil {
+		t.Fatalf("expected scheme-less dsn rejected")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected duplicate registration to panic")
+		}
+	}()
+	Register("testfake", func(string) (Store, error) { return nil, nil })
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Report is a reader's complaint about a paste, kept until an admin takes
+// the paste down or dismisses the report.
+type Report struct {
+	ID      string `json:"id"`
+	PasteID string `json:"paste_id"`
+	// Reason is one of a fixed set of categories, such as "spam".
+	Reason    string    `json:"reason"`
+	Details   string    `json:"details,omitempty"`
+	IPHash    string    `json:"ip_hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReportStore is implemented by stores that can keep abuse reports.
+// Deleting an unknown report returns ErrNotFound.
+type ReportStore interface {
+	SaveReport(ctx context.Context, report *Report) error
+	// ListReports returns every report, oldest first.
+	ListReports(ctx context.Context) ([]*Report, error)
+	DeleteReport(ctx context.Context, id string) error
+}
+
+// SortReports orders reports oldest first, breaking ties by ID.
+func SortReports(reports []*Report) {
+	sort.Slice(reports, func(i, j int) bool {
+		if !reports[i].CreatedAt.Equal(reports[j].CreatedAt) {
+			return reports[i].CreatedAt.Before(reports[j].CreatedAt)
+		}
+		return reports[i].ID < reports[j].ID
+	})
+}
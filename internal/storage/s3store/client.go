@@ -0,0 +1,184 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// metaPrefix marks user-defined object metadata headers.
+const metaPrefix = "X-Amz-Meta-"
+
+// client speaks the handful of S3 REST calls the store needs, using
+// path-style addressing so MinIO and R2 work without DNS setup.
+type client struct {
+	endpoint *url.URL
+	bucket   string
+	creds    credentials
+	http     *http.Client
+	now      func() time.Time
+}
+
+// object is a fetched object body with its user metadata, keyed by the
+// lower-case name after x-amz-meta-.
+type object struct {
+	Body []byte
+	Meta map[string]string
+}
+
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (c *client) objectURL(key string) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket + "/" + key
+	u.RawPath = ""
+	return &u
+}
+
+func (c *client) do(ctx context.Context, method string, u *url.URL, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// Keep the path exactly as signed rather than Go's re-escaped form.
+	req.URL.Opaque = "//" + u.Host + uriEncode(u.Path, false)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	payloadHash := emptyHash
+	if len(body) > 0 {
+		payloadHash = hashBytesHex(body)
+	}
+	c.creds.sign(req, payloadHash, c.now())
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, storage.ErrNotFound
+	}
+	var e s3Error
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if xml.Unmarshal(data, &e) != nil || e.Code == "" {
+		e.Code = res.Status
+	}
+	return nil, fmt.Errorf("s3 %s %s: %s %s", method, u.Path, e.Code, e.Message)
+}
+
+// put uploads body under key with the given user metadata.
+func (c *client) put(ctx context.Context, key string, body []byte, meta map[string]string) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	for k, v := range meta {
+		header.Set(metaPrefix+k, v)
+	}
+	res, err := c.do(ctx, http.MethodPut, c.objectURL(key), body, header)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (c *client) get(ctx context.Context, key string) (*object, error) {
+	res, err := c.do(ctx, http.MethodGet, c.objectURL(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &object{Body: body, Meta: userMeta(res.Header)}, nil
+}
+
+// head returns only the object's metadata.
+func (c *client) head(ctx context.Context, key string) (map[string]string, error) {
+	res, err := c.do(ctx, http.MethodHead, c.objectURL(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	return userMeta(res.Header), nil
+}
+
+// remove deletes key. S3 reports success for missing keys too.
+func (c *client) remove(ctx context.Context, key string) error {
+	res, err := c.do(ctx, http.MethodDelete, c.objectURL(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// list calls fn with every key under prefix in lexical order until fn
+// returns false.
+func (c *client) list(ctx context.Context, prefix string, fn func(key string) bool) error {
+	token := ""
+	for {
+		u := *c.endpoint
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + c.bucket
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		res, err := c.do(ctx, http.MethodGet, &u, nil, nil)
+		if err != nil {
+			return err
+		}
+		var out listResult
+		err = xml.NewDecoder(res.Body).Decode(&out)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("decode object list: %w", err)
+		}
+		for _, item := range out.Contents {
+			if !fn(item.Key) {
+				return nil
+			}
+		}
+		if !out.IsTruncated || out.NextContinuationToken == "" {
+			return nil
+		}
+		token = out.NextContinuationToken
+	}
+}
+
+func userMeta(h http.Header) map[string]string {
+	meta := make(map[string]string)
+	for k, v := range h {
+		if name, ok := strings.CutPrefix(http.CanonicalHeaderKey(k), metaPrefix); ok && len(v) > 0 {
+			meta[strings.ToLower(name)] = v[0]
+		}
+	}
+	return meta
+}
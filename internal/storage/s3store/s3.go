@@ -0,0 +1,486 @@
+// Package s3store implements storage.Store on S3-compatible object storage
+// (AWS S3, MinIO, Cloudflare R2), so the server can run as a stateless
+// container.
+//
+// Each paste is one JSON object under "<prefix>pastes/". Its expiry is also
+// kept in the object's metadata, so reads reject expired pastes even if
+// nothing has cleaned them up yet. Expiring pastes get an empty marker object
+// under "<prefix>expires/" whose key sorts by expiry time; DeleteExpired
+// scans those markers in order and stops at the first one still in the
+// future. A bucket lifecycle rule can additionally bound how long anything
+// lingers, but is not required.
+package s3store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// metaExpiresAt is the metadata key holding a paste's expiry in RFC 3339.
+const metaExpiresAt = "expires-at"
+
+// fetchWorkers bounds concurrent GETs while scanning every paste.
+const fetchWorkers = 8
+
+func init() {
+	storage.Register("s3", func(dsn string) (storage.Store, error) { return Open(dsn) })
+}
+
+// Store implements storage.Store backed by an S3 bucket.
+type Store struct {
+	c      *client
+	prefix string
+	now    func() time.Time
+}
+
+// Open connects to the bucket described by rawURL:
+//
+//	s3://[access-key:secret-key@]bucket[/prefix][?endpoint=https://host&region=us-east-1]
+//
+// Credentials default to $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and
+// $AWS_SESSION_TOKEN, the region to $AWS_REGION or us-east-1, and the
+// endpoint to AWS itself. R2 uses region "auto".
+func Open(rawURL string) (*Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse s3 url: %w", err)
+	}
+	if u.Scheme != "s3" {
+		return nil, fmt.Errorf("unsupported s3 url scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("s3 url needs a bucket")
+	}
+	q := u.Query()
+
+	creds := credentials{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Region:       q.Get("region"),
+	}
+	if u.User != nil {
+		creds.AccessKey = u.User.Username()
+		creds.SecretKey, _ = u.User.Password()
+		creds.SessionToken = ""
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return nil, errors.New("s3 credentials missing: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if creds.Region == "" {
+		creds.Region = os.Getenv("AWS_REGION")
+	}
+	if creds.Region == "" {
+		creds.Region = "us-east-1"
+	}
+
+	rawEndpoint := q.Get("endpoint")
+	if rawEndpoint == "" {
+		rawEndpoint = "https://s3." + creds.Region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(rawEndpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", rawEndpoint)
+	}
+
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	s := &Store{
+		c: &client{
+			endpoint: endpoint,
+			bucket:   u.Host,
+			creds:    creds,
+			http:     &http.Client{Timeout: 30 * time.Second},
+			now:      time.Now,
+		},
+		prefix: prefix,
+		now:    time.Now,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := s.c.list(ctx, s.pastePrefix(), func(string) bool { return false }); err != nil {
+		return nil, fmt.Errorf("connect to s3: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Store) pastePrefix() string        { return s.prefix + "pastes/" }
+func (s *Store) expirePrefix() string       { return s.prefix + "expires/" }
+func (s *Store) pasteKey(id string) string  { return s.pastePrefix() + id }
+func (s *Store) apiKeyPrefix() string       { return s.prefix + "apikeys/" }
+func (s *Store) apiKeyKey(id string) string { return s.apiKeyPrefix() + id }
+
+// expireKey sorts lexically by expiry: a zero-padded UnixNano then the id.
+func (s *Store) expireKey(at time.Time, id string) string {
+	return fmt.Sprintf("%s%020d/%s", s.expirePrefix(), at.UTC().UnixNano(), id)
+}
+
+// Save persists or updates a paste entry and its expiry marker.
+func (s *Store) Save(ctx context.Context, paste *storage.Paste) error {
+	if paste == nil {
+		return errors.New("paste is nil")
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	paste.CreatedAt = paste.CreatedAt.UTC()
+	paste.ExpiresAt = paste.ExpiresAt.UTC()
+	data, err := json.Marshal(paste)
+	if err != nil {
+		return fmt.Errorf("marshal paste: %w", err)
+	}
+
+	prev, err := s.c.head(ctx, s.pasteKey(paste.ID))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("load previous paste: %w", err)
+	}
+	meta := map[string]string{}
+	if paste.HasExpiration() {
+		meta[metaExpiresAt] = paste.ExpiresAt.Format(time.RFC3339Nano)
+	}
+	if err := s.c.put(ctx, s.pasteKey(paste.ID), data, meta); err != nil {
+		return fmt.Errorf("save paste: %w", err)
+	}
+
+	if at, ok := metaExpiry(prev); ok && !at.Equal(paste.ExpiresAt) {
+		if err := s.c.remove(ctx, s.expireKey(at, paste.ID)); err != nil {
+			return fmt.Errorf("remove previous expiry marker: %w", err)
+		}
+	}
+	if paste.HasExpiration() {
+		if err := s.c.put(ctx, s.expireKey(paste.ExpiresAt, paste.ID), nil, nil); err != nil {
+			return fmt.Errorf("index expiry: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get retrieves a paste by id. Pastes past their expiry read as missing even
+// before DeleteExpired removes them.
+func (s *Store) Get(ctx context.Context, id string) (*storage.Paste, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	obj, err := s.c.get(ctx, s.pasteKey(id))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("get paste: %w", err)
+	}
+	if at, ok := metaExpiry(obj.Meta); ok && !at.After(s.now()) {
+		return nil, storage.ErrNotFound
+	}
+	var paste storage.Paste
+	if err := json.Unmarshal(obj.Body, &paste); err != nil {
+		return nil, fmt.Errorf("unmarshal paste: %w", err)
+	}
+	return &paste, nil
+}
+
+// Delete removes a paste and its expiry marker.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	meta, err := s.c.head(ctx, s.pasteKey(id))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("load paste: %w", err)
+	}
+	if err := s.c.remove(ctx, s.pasteKey(id)); err != nil {
+		return fmt.Errorf("delete paste: %w", err)
+	}
+	if at, ok := metaExpiry(meta); ok {
+		if err := s.c.remove(ctx, s.expireKey(at, id)); err != nil {
+			return fmt.Errorf("delete expiry marker: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired walks the expiry markers in time order and removes every
+// paste expiring at or before the provided time.
+func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	cutoff := before.UTC().UnixNano()
+	var due []string
+	err := s.c.list(ctx, s.expirePrefix(), func(key string) bool {
+		ts, _, ok := strings.Cut(strings.TrimPrefix(key, s.expirePrefix()), "/")
+		n, err := strconv.ParseInt(ts, 10, 64)
+		if !ok || err != nil {
+			return true
+		}
+		if n > cutoff {
+			return false
+		}
+		due = append(due, key)
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("list expiry markers: %w", err)
+	}
+
+	removed := 0
+	for _, marker := range due {
+		id := marker[strings.LastIndexByte(marker, '/')+1:]
+		// The marker may be stale if a save failed to clean it up, so only
+		// delete the paste when its own expiry agrees.
+		meta, err := s.c.head(ctx, s.pasteKey(id))
+		switch {
+		case err == nil:
+			if at, ok := metaExpiry(meta); ok && at.UnixNano() <= cutoff {
+				if err := s.c.remove(ctx, s.pasteKey(id)); err != nil {
+					return removed, fmt.Errorf("delete expired paste %s: %w", id, err)
+				}
+				removed++
+			}
+		case !errors.Is(err, storage.ErrNotFound):
+			return removed, fmt.Errorf("load expired paste %s: %w", id, err)
+		}
+		if err := s.c.remove(ctx, marker); err != nil {
+			return removed, fmt.Errorf("delete expiry marker: %w", err)
+		}
+	}
+	return removed, nil
+}
+
+// List fetches every paste and returns those matching opts, newest first.
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) ([]*storage.Paste, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	var out []*storage.Paste
+	err := s.scan(ctx, func(p *storage.Paste) error {
+		if opts.Match(p) {
+			out = append(out, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	storage.SortNewestFirst(out)
+	return opts.Page(out), nil
+}
+
+// Count returns the number of pastes matching opts.
+func (s *Store) Count(ctx context.Context, opts storage.ListOptions) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	n := 0
+	err := s.scan(ctx, func(p *storage.Paste) error {
+		if opts.Match(p) {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Iterate calls fn with every stored paste, including expired ones whose
+// objects DeleteExpired has not removed yet.
+func (s *Store) Iterate(ctx context.Context, fn func(*storage.Paste) error) error {
+	return s.scan(ctx, fn)
+}
+
+// scan calls fn, one call at a time, with every stored paste, stopping at
+// the first error.
+func (s *Store) scan(parent context.Context, fn func(*storage.Paste) error) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var keys []string
+	if err := s.c.list(ctx, s.pastePrefix(), func(key string) bool {
+		keys = append(keys, key)
+		return true
+	}); err != nil {
+		return fmt.Errorf("list pastes: %w", err)
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+		next     = make(chan string)
+	)
+	for i := 0; i < fetchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range next {
+				obj, err := s.c.get(ctx, key)
+				var paste storage.Paste
+				if err == nil {
+					err = json.Unmarshal(obj.Body, &paste)
+				}
+				mu.Lock()
+				switch {
+				case errors.Is(err, storage.ErrNotFound):
+					// Deleted since the listing.
+				case err != nil:
+					if firstErr == nil {
+						firstErr = fmt.Errorf("load %s: %w", key, err)
+					}
+				case firstErr != nil:
+					// Already failing; drain the queue.
+				default:
+					if err := fn(&paste); err != nil {
+						firstErr = err
+						cancel()
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, key := range keys {
+		select {
+		case next <- key:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = parent.Err()
+	}
+	return firstErr
+}
+
+// Stats scans every paste object.
+func (s *Store) Stats(ctx context.Context) (storage.Stats, error) {
+	return storage.ScanStats(ctx, s, time.Now())
+}
+
+// Ping lists the first page of paste objects, as Open does.
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.c.list(ctx, s.pastePrefix(), func(string) bool { return false }); err != nil {
+		return fmt.Errorf("ping s3: %w", err)
+	}
+	return nil
+}
+
+// SaveAPIKey persists or replaces an API key.
+func (s *Store) SaveAPIKey(ctx context.Context, key *storage.APIKey) error {
+	if key == nil {
+		return errors.New("api key is nil")
+	}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("marshal api key: %w", err)
+	}
+	if err := s.c.put(ctx, s.apiKeyKey(key.ID), data, nil); err != nil {
+		return fmt.Errorf("save api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey retrieves an API key by id.
+func (s *Store) GetAPIKey(ctx context.Context, id string) (*storage.APIKey, error) {
+	obj, err := s.c.get(ctx, s.apiKeyKey(id))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	var key storage.APIKey
+	if err := json.Unmarshal(obj.Body, &key); err != nil {
+		return nil, fmt.Errorf("unmarshal api key: %w", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns every API key ordered by id.
+func (s *Store) ListAPIKeys(ctx context.Context) ([]*storage.APIKey, error) {
+	var ids []string
+	if err := s.c.list(ctx, s.apiKeyPrefix(), func(key string) bool {
+		ids = append(ids, strings.TrimPrefix(key, s.apiKeyPrefix()))
+		return true
+	}); err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	var out []*storage.APIKey
+	for _, id := range ids {
+		key, err := s.GetAPIKey(ctx, id)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+// DeleteAPIKey revokes an API key.
+func (s *Store) DeleteAPIKey(ctx context.Context, id string) error {
+	if _, err := s.c.head(ctx, s.apiKeyKey(id)); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("load api key: %w", err)
+	}
+	if err := s.c.remove(ctx, s.apiKeyKey(id)); err != nil {
+		return fmt.Errorf("delete api key: %w", err)
+	}
+	return nil
+}
+
+// Close releases idle connections; the bucket needs no teardown.
+func (s *Store) Close() error {
+	if s == nil || s.c == nil {
+		return nil
+	}
+	s.c.http.CloseIdleConnections()
+	return nil
+}
+
+func metaExpiry(meta map[string]string) (time.Time, bool) {
+	raw := meta[metaExpiresAt]
+	if raw == "" {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, raw)
+	return at, err == nil
+}
@@ -0,0 +1,137 @@
+package s3store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	amzDateFormat  = "20060102T150405Z"
+	emptyHash      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	signAlgorithm  = "AWS4-HMAC-SHA256"
+	contentSHA256H = "X-Amz-Content-Sha256"
+)
+
+// credentials sign requests with AWS Signature Version 4.
+type credentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+}
+
+// sign adds the SigV4 Authorization header to req. Every header already set
+// on req is signed along with the host.
+func (c credentials) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set(contentSHA256H, payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	signed := []string{"host"}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); lower != "authorization" {
+			signed = append(signed, lower)
+		}
+	}
+	sort.Strings(signed)
+
+	scope := now.Format("20060102") + "/" + c.Region + "/s3/aws4_request"
+	signature := c.signature(req, signed, payloadHash, now, scope)
+	req.Header.Set("Authorization", signAlgorithm+" Credential="+c.AccessKey+"/"+scope+
+		",SignedHeaders="+strings.Join(signed, ";")+",Signature="+signature)
+}
+
+func (c credentials) signature(req *http.Request, signed []string, payloadHash string, now time.Time, scope string) string {
+	toSign := signAlgorithm + "\n" + now.Format(amzDateFormat) + "\n" + scope + "\n" +
+		hashHex(canonicalRequest(req, signed, payloadHash))
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func canonicalRequest(req *http.Request, signed []string, payloadHash string) string {
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	return req.Method + "\n" +
+		uriEncode(req.URL.Path, false) + "\n" +
+		canonicalQuery(req) + "\n" +
+		headers.String() + "\n" +
+		strings.Join(signed, ";") + "\n" +
+		payloadHash
+}
+
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but RFC 3986 unreserved characters,
+// as SigV4 requires. Slashes are kept in paths.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[ch>>4])
+			b.WriteByte(hexDigits[ch&15])
+		}
+	}
+	return b.String()
+}
+
+func hashHex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashBytesHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,88 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// AppendAudit adds an entry to the audit log. The table is only ever
+// inserted into and pruned by age.
+func (s *Store) AppendAudit(ctx context.Context, entry *storage.AuditEntry) error {
+	if entry == nil {
+		return errors.New("audit entry is nil")
+	}
+	const q = `INSERT INTO audit_log (id, time, action, paste_id, actor, ip_hash, detail, country) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := s.db.ExecContext(ctx, q, entry.ID, entry.Time.UTC(), entry.Action, entry.PasteID, entry.Actor, entry.IPHash, entry.Detail, entry.Country); err != nil {
+		return fmt.Errorf("append audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAudit returns the entries q selects, newest first.
+func (s *Store) ListAudit(ctx context.Context, q storage.AuditQuery) ([]*storage.AuditEntry, error) {
+	var (
+		where []string
+		args  []any
+	)
+	for _, f := range []struct{ column, value string }{
+		{"action", q.Action},
+		{"paste_id", q.PasteID},
+		{"actor", q.Actor},
+		{"ip_hash", q.IPHash},
+	} {
+		if f.value != "" {
+			where = append(where, f.column+" = ?")
+			args = append(args, f.value)
+		}
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, q.Until.UTC())
+	}
+	query := `SELECT id, time, action, COALESCE(paste_id, ''), COALESCE(actor, ''), COALESCE(ip_hash, ''), COALESCE(detail, ''), COALESCE(country, '') FROM audit_log`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(q.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query+";", args...)
+	if err != nil {
+		return nil, fmt.Errorf("list audit log: %w", err)
+	}
+	defer rows.Close()
+	var out []*storage.AuditEntry
+	for rows.Next() {
+		var e storage.AuditEntry
+		if err := rows.Scan(&e.ID, &e.Time, &e.Action, &e.PasteID, &e.Actor, &e.IPHash, &e.Detail, &e.Country); err != nil {
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		e.Time = e.Time.UTC()
+		out = append(out, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list audit log: %w", err)
+	}
+	return out, nil
+}
+
+// PruneAudit removes the entries older than before.
+func (s *Store) PruneAudit(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM audit_log WHERE time < ?;`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("prune audit log: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
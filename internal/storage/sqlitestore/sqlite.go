@@ -1,12 +1,18 @@
-//go:build sqlite
-
 package sqlitestore
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -14,17 +20,120 @@ import (
 	"tiny-pastebin/internal/storage"
 )
 
+func init() {
+	storage.Register("sqlite", openDSN)
+}
+
+// Defaults applied when Options leaves a field unset.
+const (
+	DefaultJournalMode = "WAL"
+	DefaultSynchronous = "NORMAL"
+	DefaultBusyTimeout = 5 * time.Second
+)
+
+// openDSN opens sqlite:///path.db, optionally tuned with journal_mode,
+// synchronous, busy_timeout (a duration) and max_open_conns. Other query
+// parameters are handed to the driver unchanged.
+func openDSN(dsn string) (storage.Store, error) {
+	path, query, _ := strings.Cut(storage.DSNPath(dsn), "?")
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("parse sqlite dsn: %w", err)
+	}
+	var opts Options
+	opts.JournalMode = q.Get("journal_mode")
+	opts.Synchronous = q.Get("synchronous")
+	if v := q.Get("busy_timeout"); v != "" {
+		if opts.BusyTimeout, err = time.ParseDuration(v); err != nil || opts.BusyTimeout <= 0 {
+			return nil, fmt.Errorf("invalid busy_timeout %q", v)
+		}
+	}
+	if v := q.Get("max_open_conns"); v != "" {
+		if opts.MaxOpenConns, err = strconv.Atoi(v); err != nil || opts.MaxOpenConns <= 0 {
+			return nil, fmt.Errorf("invalid max_open_conns %q", v)
+		}
+	}
+	for _, k := range []string{"journal_mode", "synchronous", "busy_timeout", "max_open_conns"} {
+		q.Del(k)
+	}
+	if rest := q.Encode(); rest != "" {
+		path += "?" + rest
+	}
+	return OpenOptions(path, opts)
+}
+
+// Options tunes a SQLite store.
+type Options struct {
+	// JournalMode is the journal_mode pragma; zero means DefaultJournalMode.
+	// WAL lets readers carry on while a write is in progress.
+	JournalMode string
+	// Synchronous is the synchronous pragma; zero means DefaultSynchronous,
+	// which is durable across application crashes in WAL mode and only risks
+	// the last transactions on power loss.
+	Synchronous string
+	// BusyTimeout is how long a connection waits for a competing writer
+	// before failing with SQLITE_BUSY; zero means DefaultBusyTimeout.
+	BusyTimeout time.Duration
+	// MaxOpenConns caps the connection pool; zero means one per CPU, at
+	// least four. SQLite still admits one writer at a time.
+	MaxOpenConns int
+}
+
+var (
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	syncModes    = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
 // Store implements storage.Store using SQLite.
 type Store struct {
 	db *sql.DB
 }
 
-// Open initializes the SQLite database at path.
+// Open initializes the SQLite database at path with default options.
 func Open(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path)
+	return OpenOptions(path, Options{})
+}
+
+// OpenOptions is Open with explicit options.
+func OpenOptions(path string, opts Options) (*Store, error) {
+	if opts.JournalMode == "" {
+		opts.JournalMode = DefaultJournalMode
+	}
+	if opts.Synchronous == "" {
+		opts.Synchronous = DefaultSynchronous
+	}
+	if opts.BusyTimeout <= 0 {
+		opts.BusyTimeout = DefaultBusyTimeout
+	}
+	if opts.MaxOpenConns <= 0 {
+		opts.MaxOpenConns = max(runtime.NumCPU(), 4)
+	}
+	opts.JournalMode = strings.ToUpper(opts.JournalMode)
+	opts.Synchronous = strings.ToUpper(opts.Synchronous)
+	if !slices.Contains(journalModes, opts.JournalMode) {
+		return nil, fmt.Errorf("invalid journal mode %q", opts.JournalMode)
+	}
+	if !slices.Contains(syncModes, opts.Synchronous) {
+		return nil, fmt.Errorf("invalid synchronous mode %q", opts.Synchronous)
+	}
+
+	// Pragmas in the DSN run on every connection the pool opens, so each one
+	// gets the busy timeout and synchronous setting, not just the first.
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	pragmas := url.Values{"_pragma": {
+		fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()),
+		"journal_mode(" + opts.JournalMode + ")",
+		"synchronous(" + opts.Synchronous + ")",
+	}}
+	db, err := sql.Open("sqlite", path+sep+pragmas.Encode())
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxOpenConns)
 	if err := initialize(db); err != nil {
 		_ = db.Close()
 		return nil, err
@@ -41,37 +150,244 @@ CREATE TABLE IF NOT EXISTS pastes (
     created_at DATETIME NOT NULL,
     expires_at DATETIME,
     password_hash TEXT,
-    size INTEGER NOT NULL
+    size INTEGER NOT NULL,
+    metadata TEXT,
+    title TEXT,
+    public INTEGER NOT NULL DEFAULT 0,
+    noindex INTEGER NOT NULL DEFAULT 0,
+    owner TEXT,
+    quarantined INTEGER NOT NULL DEFAULT 0,
+    quarantine_reason TEXT,
+    ip_hash TEXT,
+    license TEXT,
+    attribution TEXT,
+    source_url TEXT,
+    deleted_at DATETIME,
+    deleted_by TEXT,
+    in_file INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME,
+    encrypted INTEGER NOT NULL DEFAULT 0,
+    sealed INTEGER NOT NULL DEFAULT 0,
+    taken_down_at DATETIME,
+    takedown_notice TEXT,
+    takedown_legal INTEGER NOT NULL DEFAULT 0
 );
 CREATE INDEX IF NOT EXISTS idx_pastes_expires_at ON pastes (expires_at);
 `
 	if _, err := db.Exec(schema); err != nil {
 		return fmt.Errorf("apply schema: %w", err)
 	}
+	for _, col := range []struct{ name, decl string }{
+		{"metadata", "TEXT"},
+		{"title", "TEXT"},
+		{"public", "INTEGER NOT NULL DEFAULT 0"},
+		{"noindex", "INTEGER NOT NULL DEFAULT 0"},
+		{"owner", "TEXT"},
+		{"quarantined", "INTEGER NOT NULL DEFAULT 0"},
+		{"quarantine_reason", "TEXT"},
+		{"ip_hash", "TEXT"},
+		{"license", "TEXT"},
+		{"attribution", "TEXT"},
+		{"source_url", "TEXT"},
+		{"deleted_at", "DATETIME"},
+		{"deleted_by", "TEXT"},
+		{"in_file", "INTEGER NOT NULL DEFAULT 0"},
+		{"updated_at", "DATETIME"},
+		{"encrypted", "INTEGER NOT NULL DEFAULT 0"},
+		{"sealed", "INTEGER NOT NULL DEFAULT 0"},
+		{"taken_down_at", "DATETIME"},
+		{"takedown_notice", "TEXT"},
+		{"takedown_legal", "INTEGER NOT NULL DEFAULT 0"},
+	} {
+		if err := addColumnIfMissing(db, "pastes", col.name, col.decl); err != nil {
+			return err
+		}
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_pastes_owner ON pastes (owner);`); err != nil {
+		return fmt.Errorf("create owner index: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_pastes_ip_hash ON pastes (ip_hash);`); err != nil {
+		return fmt.Errorf("create ip hash index: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_pastes_updated_at ON pastes (updated_at);`); err != nil {
+		return fmt.Errorf("create updated index: %w", err)
+	}
+	if _, err := db.Exec(`
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    secret_hash TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    rate_limit REAL NOT NULL DEFAULT 0,
+    burst INTEGER NOT NULL DEFAULT 0
+);`); err != nil {
+		return fmt.Errorf("create api key table: %w", err)
+	}
+	if _, err := db.Exec(`
+CREATE TABLE IF NOT EXISTS reports (
+    id TEXT PRIMARY KEY,
+    paste_id TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT,
+    ip_hash TEXT,
+    created_at DATETIME NOT NULL
+);`); err != nil {
+		return fmt.Errorf("create report table: %w", err)
+	}
+	if _, err := db.Exec(`
+CREATE TABLE IF NOT EXISTS audit_log (
+    id TEXT PRIMARY KEY,
+    time DATETIME NOT NULL,
+    action TEXT NOT NULL,
+    paste_id TEXT,
+    actor TEXT,
+    ip_hash TEXT,
+    detail TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_time ON audit_log (time);`); err != nil {
+		return fmt.Errorf("create audit table: %w", err)
+	}
+	if err := addColumnIfMissing(db, "audit_log", "country", "TEXT"); err != nil {
+		return err
+	}
+	// paste_totals holds one row of running totals, kept by triggers so every
+	// writer, old or new, updates it in the same statement as the paste. The
+	// seed counts databases created before the table existed.
+	if _, err := db.Exec(`
+CREATE TABLE IF NOT EXISTS paste_totals (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    pastes INTEGER NOT NULL,
+    bytes INTEGER NOT NULL
+);
+INSERT OR IGNORE INTO paste_totals (id, pastes, bytes)
+    SELECT 1, COUNT(*), COALESCE(SUM(length(content)), 0) FROM pastes;
+CREATE TRIGGER IF NOT EXISTS paste_totals_insert AFTER INSERT ON pastes BEGIN
+    UPDATE paste_totals SET pastes = pastes + 1, bytes = bytes + length(NEW.content) WHERE id = 1;
+END;
+CREATE TRIGGER IF NOT EXISTS paste_totals_update AFTER UPDATE OF content ON pastes BEGIN
+    UPDATE paste_totals SET bytes = bytes - length(OLD.content) + length(NEW.content) WHERE id = 1;
+END;
+CREATE TRIGGER IF NOT EXISTS paste_totals_delete AFTER DELETE ON pastes BEGIN
+    UPDATE paste_totals SET pastes = pastes - 1, bytes = bytes - length(OLD.content) WHERE id = 1;
+END;`); err != nil {
+		return fmt.Errorf("create paste totals: %w", err)
+	}
+	return nil
+}
+
+// addColumnIfMissing upgrades databases created before a column was introduced.
+func addColumnIfMissing(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull int
+			dflt    sql.NullString
+			pk      int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("inspect %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table, column, decl)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
+// pasteColumns lists the columns read by scanPaste and written by Save, in order.
+const pasteColumns = "id, content, syntax, created_at, expires_at, password_hash, size, metadata, title, public, noindex, owner, quarantined, quarantine_reason, ip_hash, license, attribution, source_url, deleted_at, deleted_by, in_file, updated_at, encrypted, sealed, taken_down_at, takedown_notice, takedown_legal"
+
 // Save inserts or updates a paste.
 func (s *Store) Save(ctx context.Context, paste *storage.Paste) error {
+	return savePaste(ctx, s.db, paste)
+}
+
+// SaveBatch saves several pastes in one transaction, so either all of them
+// are stored or none is.
+func (s *Store) SaveBatch(ctx context.Context, pastes []*storage.Paste) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin batch: %w", err)
+	}
+	defer tx.Rollback()
+	for _, paste := range pastes {
+		if err := savePaste(ctx, tx, paste); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit batch: %w", err)
+	}
+	return nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func savePaste(ctx context.Context, db execer, paste *storage.Paste) error {
 	if paste == nil {
 		return errors.New("paste is nil")
 	}
 
 	paste.CreatedAt = paste.CreatedAt.UTC()
 	paste.ExpiresAt = paste.ExpiresAt.UTC()
+	paste.DeletedAt = paste.DeletedAt.UTC()
+	paste.UpdatedAt = paste.UpdatedAt.UTC()
+	paste.TakenDownAt = paste.TakenDownAt.UTC()
+
+	metadata, err := encodeMetadata(paste.Metadata)
+	if err != nil {
+		return err
+	}
 
 	const q = `
-INSERT INTO pastes (id, content, syntax, created_at, expires_at, password_hash, size)
-VALUES (?, ?, ?, ?, ?, ?, ?)
+INSERT INTO pastes (` + pasteColumns + `)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(id) DO UPDATE SET
     content=excluded.content,
     syntax=excluded.syntax,
     created_at=excluded.created_at,
     expires_at=excluded.expires_at,
     password_hash=excluded.password_hash,
-    size=excluded.size;
+    size=excluded.size,
+    metadata=excluded.metadata,
+    title=excluded.title,
+    public=excluded.public,
+    noindex=excluded.noindex,
+    owner=excluded.owner,
+    quarantined=excluded.quarantined,
+    quarantine_reason=excluded.quarantine_reason,
+    ip_hash=excluded.ip_hash,
+    license=excluded.license,
+    attribution=excluded.attribution,
+    source_url=excluded.source_url,
+    deleted_at=excluded.deleted_at,
+    deleted_by=excluded.deleted_by,
+    in_file=excluded.in_file,
+    updated_at=excluded.updated_at,
+    encrypted=excluded.encrypted,
+    sealed=excluded.sealed,
+    taken_down_at=excluded.taken_down_at,
+    takedown_notice=excluded.takedown_notice,
+    takedown_legal=excluded.takedown_legal;
 `
-	_, err := s.db.ExecContext(ctx, q,
+	_, err = db.ExecContext(ctx, q,
 		paste.ID,
 		[]byte(paste.Content),
 		paste.Syntax,
@@ -79,6 +395,26 @@ ON CONFLICT(id) DO UPDATE SET
 		nullableTime(paste.ExpiresAt),
 		nullString(paste.PasswordHash),
 		paste.Size,
+		metadata,
+		nullString(paste.Title),
+		paste.Public,
+		paste.NoIndex,
+		nullString(paste.Owner),
+		paste.Quarantined,
+		nullString(paste.QuarantineReason),
+		nullString(paste.IPHash),
+		nullString(paste.License),
+		nullString(paste.Attribution),
+		nullString(paste.SourceURL),
+		nullableTime(paste.DeletedAt),
+		nullString(paste.DeletedBy),
+		paste.InFile,
+		nullableTime(paste.UpdatedAt),
+		paste.Encrypted,
+		paste.Sealed,
+		nullableTime(paste.TakenDownAt),
+		nullString(paste.TakedownNotice),
+		paste.TakedownLegal,
 	)
 	if err != nil {
 		return fmt.Errorf("save paste: %w", err)
@@ -89,40 +425,272 @@ ON CONFLICT(id) DO UPDATE SET
 // Get fetches a paste by id.
 func (s *Store) Get(ctx context.Context, id string) (*storage.Paste, error) {
 	const q = `
-SELECT id, content, syntax, created_at, expires_at, password_hash, size
+SELECT ` + pasteColumns + `
 FROM pastes WHERE id = ?;
 `
-	row := s.db.QueryRowContext(ctx, q, id)
+	paste, err := scanPaste(s.db.QueryRowContext(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("query paste: %w", err)
+	}
+	return paste, nil
+}
 
-	var (
-		content   []byte
-		syntax    string
-		createdAt time.Time
-		expiresAt sql.NullTime
-		password  sql.NullString
-		size      int
-	)
-	if err := row.Scan(&id, &content, &syntax, &createdAt, &expiresAt, &password, &size); err != nil {
+// metaColumns are pasteColumns with the content left out and its size
+// measured by SQLite, which does not need to read the content for it.
+var metaColumns = strings.NewReplacer("content,", "X'',", " size,", " length(content),").Replace(pasteColumns)
+
+// GetMeta fetches a paste without its content.
+func (s *Store) GetMeta(ctx context.Context, id string) (*storage.Paste, error) {
+	q := `SELECT ` + metaColumns + ` FROM pastes WHERE id = ?;`
+	paste, err := scanPaste(s.db.QueryRowContext(ctx, q, id))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrNotFound
 		}
 		return nil, fmt.Errorf("query paste: %w", err)
 	}
+	return paste, nil
+}
+
+// GetContent reads the content of a paste. database/sql cannot stream a
+// column, so the content is read once into a buffer the reader hands out
+// without further copies.
+func (s *Store) GetContent(ctx context.Context, id string) (io.ReadCloser, error) {
+	var content []byte
+	err := s.db.QueryRowContext(ctx, `SELECT content FROM pastes WHERE id = ?;`, id).Scan(&content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("query paste content: %w", err)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// List returns pastes matching opts, newest first.
+func (s *Store) List(ctx context.Context, opts storage.ListOptions) ([]*storage.Paste, error) {
+	const q = `
+SELECT ` + pasteColumns + `
+FROM pastes ORDER BY created_at DESC, id ASC;
+`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("list pastes: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		out     []*storage.Paste
+		skipped int
+	)
+	for rows.Next() {
+		paste, err := scanPaste(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan paste: %w", err)
+		}
+		if !opts.Match(paste) {
+			continue
+		}
+		if skipped < opts.Offset {
+			skipped++
+			continue
+		}
+		out = append(out, paste)
+		if opts.Limit > 0 && len(out) >= opts.Limit {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list pastes: %w", err)
+	}
+	return out, nil
+}
+
+// Iterate calls fn with every stored paste in id order.
+func (s *Store) Iterate(ctx context.Context, fn func(*storage.Paste) error) error {
+	return s.iterate(ctx, fn, `SELECT `+pasteColumns+` FROM pastes ORDER BY id;`)
+}
+
+// IterateChanged calls fn with the pastes saved after since, found through
+// the updated_at index.
+func (s *Store) IterateChanged(ctx context.Context, since time.Time, fn func(*storage.Paste) error) error {
+	return s.iterate(ctx, fn, `SELECT `+pasteColumns+` FROM pastes WHERE updated_at > ? ORDER BY updated_at;`, since.UTC())
+}
+
+// IterateExpired calls fn with the pastes expiring at or before before.
+func (s *Store) IterateExpired(ctx context.Context, before time.Time, fn func(*storage.Paste) error) error {
+	return s.iterate(ctx, fn, `SELECT `+pasteColumns+` FROM pastes WHERE expires_at IS NOT NULL AND expires_at <= ? ORDER BY expires_at;`, before.UTC())
+}
+
+func (s *Store) iterate(ctx context.Context, fn func(*storage.Paste) error, q string, args ...any) error {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("iterate pastes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		paste, err := scanPaste(rows)
+		if err != nil {
+			return fmt.Errorf("scan paste: %w", err)
+		}
+		if err := fn(paste); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate pastes: %w", err)
+	}
+	return nil
+}
+
+// Totals reports the running paste count and content size.
+func (s *Store) Totals(ctx context.Context) (storage.Totals, error) {
+	var t storage.Totals
+	err := s.db.QueryRowContext(ctx, `SELECT pastes, bytes FROM paste_totals WHERE id = 1;`).Scan(&t.Pastes, &t.Bytes)
+	if err != nil {
+		return storage.Totals{}, fmt.Errorf("read paste totals: %w", err)
+	}
+	return t, nil
+}
+
+// Stats reports the stored pastes from the running totals and two aggregate
+// queries.
+func (s *Store) Stats(ctx context.Context) (storage.Stats, error) {
+	t, err := s.Totals(ctx)
+	if err != nil {
+		return storage.Stats{}, err
+	}
+	st := storage.Stats{Pastes: t.Pastes, Bytes: t.Bytes, BySyntax: make(map[string]int64)}
+	rows, err := s.db.QueryContext(ctx, `SELECT syntax, COUNT(*) FROM pastes GROUP BY syntax;`)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("count syntaxes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			syntax string
+			n      int64
+		)
+		if err := rows.Scan(&syntax, &n); err != nil {
+			return storage.Stats{}, fmt.Errorf("scan syntax count: %w", err)
+		}
+		st.BySyntax[syntax] = n
+	}
+	if err := rows.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("count syntaxes: %w", err)
+	}
+
+	now := time.Now().UTC()
+	const q = `SELECT COUNT(CASE WHEN expires_at <= ? THEN 1 END), COUNT(*) FROM pastes WHERE expires_at > ? AND expires_at <= ?;`
+	if err := s.db.QueryRowContext(ctx, q, now.Add(storage.ExpiringSoon), now, now.Add(storage.ExpiringToday)).Scan(&st.ExpiringWithinHour, &st.ExpiringWithinDay); err != nil {
+		return storage.Stats{}, fmt.Errorf("count expiring pastes: %w", err)
+	}
+	return st, nil
+}
+
+// Ping runs a trivial query.
+func (s *Store) Ping(ctx context.Context) error {
+	var one int
+	if err := s.db.QueryRowContext(ctx, `SELECT 1;`).Scan(&one); err != nil {
+		return fmt.Errorf("ping sqlite: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of pastes matching opts.
+func (s *Store) Count(ctx context.Context, opts storage.ListOptions) (int, error) {
+	opts.Offset, opts.Limit = 0, 0
+	pastes, err := s.List(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+	return len(pastes), nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPaste(row rowScanner) (*storage.Paste, error) {
+	var (
+		id          string
+		content     []byte
+		syntax      string
+		createdAt   time.Time
+		expiresAt   sql.NullTime
+		password    sql.NullString
+		size        int
+		metadata    sql.NullString
+		title       sql.NullString
+		public      bool
+		noindex     bool
+		owner       sql.NullString
+		quarantined bool
+		reason      sql.NullString
+		ipHash      sql.NullString
+		license     sql.NullString
+		attribution sql.NullString
+		sourceURL   sql.NullString
+		deletedAt   sql.NullTime
+		deletedBy   sql.NullString
+		inFile      bool
+		updatedAt   sql.NullTime
+		encrypted   bool
+		sealed      bool
+		takenDownAt sql.NullTime
+		notice      sql.NullString
+		legal       bool
+	)
+	if err := row.Scan(&id, &content, &syntax, &createdAt, &expiresAt, &password, &size, &metadata, &title, &public, &noindex, &owner, &quarantined, &reason, &ipHash, &license, &attribution, &sourceURL, &deletedAt, &deletedBy, &inFile, &updatedAt, &encrypted, &sealed, &takenDownAt, &notice, &legal); err != nil {
+		return nil, err
+	}
 
 	paste := &storage.Paste{
-		ID:           id,
-		Content:      string(content),
-		Syntax:       syntax,
-		CreatedAt:    createdAt.UTC(),
-		PasswordHash: password.String,
-		Size:         size,
+		ID:               id,
+		Title:            title.String,
+		Content:          string(content),
+		Syntax:           syntax,
+		CreatedAt:        createdAt.UTC(),
+		Size:             size,
+		Public:           public,
+		NoIndex:          noindex,
+		Owner:            owner.String,
+		Quarantined:      quarantined,
+		QuarantineReason: reason.String,
+		IPHash:           ipHash.String,
+		License:          license.String,
+		Attribution:      attribution.String,
+		SourceURL:        sourceURL.String,
+		DeletedBy:        deletedBy.String,
+		InFile:           inFile,
+		Encrypted:        encrypted,
+		Sealed:           sealed,
+		TakedownNotice:   notice.String,
+		TakedownLegal:    legal,
 	}
 	if expiresAt.Valid {
 		paste.ExpiresAt = expiresAt.Time.UTC()
 	}
+	if deletedAt.Valid {
+		paste.DeletedAt = deletedAt.Time.UTC()
+	}
+	if updatedAt.Valid {
+		paste.UpdatedAt = updatedAt.Time.UTC()
+	}
+	if takenDownAt.Valid {
+		paste.TakenDownAt = takenDownAt.Time.UTC()
+	}
 	if password.Valid {
 		paste.PasswordHash = password.String
 	}
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &paste.Metadata); err != nil {
+			return nil, fmt.Errorf("decode metadata: %w", err)
+		}
+	}
 	return paste, nil
 }
 
@@ -153,6 +721,150 @@ func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int, error
 	return int(rows), nil
 }
 
+// SaveAPIKey inserts or replaces an API key.
+func (s *Store) SaveAPIKey(ctx context.Context, key *storage.APIKey) error {
+	if key == nil {
+		return errors.New("api key is nil")
+	}
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return fmt.Errorf("encode scopes: %w", err)
+	}
+	const q = `
+INSERT INTO api_keys (id, name, secret_hash, scopes, created_at, rate_limit, burst)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+    name=excluded.name,
+    secret_hash=excluded.secret_hash,
+    scopes=excluded.scopes,
+    created_at=excluded.created_at,
+    rate_limit=excluded.rate_limit,
+    burst=excluded.burst;
+`
+	if _, err := s.db.ExecContext(ctx, q, key.ID, key.Name, key.SecretHash, string(scopes), key.CreatedAt.UTC(), key.RateLimit, key.Burst); err != nil {
+		return fmt.Errorf("save api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey fetches an API key by id.
+func (s *Store) GetAPIKey(ctx context.Context, id string) (*storage.APIKey, error) {
+	const q = `SELECT id, name, secret_hash, scopes, created_at, rate_limit, burst FROM api_keys WHERE id = ?;`
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, q, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("query api key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns every API key ordered by id.
+func (s *Store) ListAPIKeys(ctx context.Context) ([]*storage.APIKey, error) {
+	const q = `SELECT id, name, secret_hash, scopes, created_at, rate_limit, burst FROM api_keys ORDER BY id;`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+	var out []*storage.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		out = append(out, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteAPIKey revokes an API key.
+func (s *Store) DeleteAPIKey(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = ?;`, id)
+	if err != nil {
+		return fmt.Errorf("delete api key: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*storage.APIKey, error) {
+	var (
+		key    storage.APIKey
+		scopes string
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.SecretHash, &scopes, &key.CreatedAt, &key.RateLimit, &key.Burst); err != nil {
+		return nil, err
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("decode scopes: %w", err)
+	}
+	return &key, nil
+}
+
+// SaveReport inserts or replaces an abuse report.
+func (s *Store) SaveReport(ctx context.Context, report *storage.Report) error {
+	if report == nil {
+		return errors.New("report is nil")
+	}
+	const q = `
+INSERT INTO reports (id, paste_id, reason, details, ip_hash, created_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+    paste_id=excluded.paste_id,
+    reason=excluded.reason,
+    details=excluded.details,
+    ip_hash=excluded.ip_hash,
+    created_at=excluded.created_at;
+`
+	if _, err := s.db.ExecContext(ctx, q, report.ID, report.PasteID, report.Reason, report.Details, report.IPHash, report.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("save report: %w", err)
+	}
+	return nil
+}
+
+// ListReports returns every report, oldest first.
+func (s *Store) ListReports(ctx context.Context) ([]*storage.Report, error) {
+	const q = `SELECT id, paste_id, reason, COALESCE(details, ''), COALESCE(ip_hash, ''), created_at FROM reports ORDER BY created_at, id;`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("list reports: %w", err)
+	}
+	defer rows.Close()
+	var out []*storage.Report
+	for rows.Next() {
+		var r storage.Report
+		if err := rows.Scan(&r.ID, &r.PasteID, &r.Reason, &r.Details, &r.IPHash, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan report: %w", err)
+		}
+		r.CreatedAt = r.CreatedAt.UTC()
+		out = append(out, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list reports: %w", err)
+	}
+	return out, nil
+}
+
+// DeleteReport removes a report.
+func (s *Store) DeleteReport(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM reports WHERE id = ?;`, id)
+	if err != nil {
+		return fmt.Errorf("delete report: %w", err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
 // Close closes the database connection.
 func (s *Store) Close() error {
 	if s == nil || s.db == nil {
@@ -168,6 +880,17 @@ func nullableTime(t time.Time) any {
 	return t.UTC()
 }
 
+func encodeMetadata(m map[string]string) (any, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("encode metadata: %w", err)
+	}
+	return string(data), nil
+}
+
 func nullString(s string) any {
 	if s == "" {
 		return nil
@@ -0,0 +1,72 @@
+package sqlitestore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"tiny-pastebin/internal/storage"
+	"tiny-pastebin/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.TestStore(t, func() storage.Store {
+		store, err := Open(filepath.Join(t.TempDir(), "conformance.sqlite"))
+		if err != nil {
+			t.Fatalf("open store: %v", err)
+		}
+		return store
+	})
+}
+
+func TestOpenTuning(t *testing.T) {
+	dir := t.TempDir()
+	pragma := func(s *Store, name string) string {
+		t.Helper()
+		var v string
+		if err := s.db.QueryRow("PRAGMA " + name).Scan(&v); err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return v
+	}
+
+	s, err := Open(filepath.Join(dir, "default.sqlite"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	if got := pragma(s, "journal_mode"); got != "wal" {
+		t.Fatalf("expected WAL by default, got %q", got)
+	}
+	if got := pragma(s, "synchronous"); got != "1" {
+		t.Fatalf("expected synchronous NORMAL by default, got %q", got)
+	}
+	if got := pragma(s, "busy_timeout"); got != "5000" {
+		t.Fatalf("expected 5s busy timeout, got %q", got)
+	}
+
+	opened, err := storage.Open("sqlite://" + filepath.Join(dir, "dsn.sqlite") + "?journal_mode=delete&synchronous=full&busy_timeout=250ms&max_open_conns=2")
+	if err != nil {
+		t.Fatalf("open dsn: %v", err)
+	}
+	defer opened.Close()
+	tuned := opened.(*Store)
+	if got := pragma(tuned, "journal_mode"); got != "delete" {
+		t.Fatalf("expected delete journal, got %q", got)
+	}
+	if got := pragma(tuned, "synchronous"); got != "2" {
+		t.Fatalf("expected synchronous FULL, got %q", got)
+	}
+	if got := pragma(tuned, "busy_timeout"); got != "250" {
+		t.Fatalf("expected 250ms busy timeout, got %q", got)
+	}
+	if got := tuned.db.Stats().MaxOpenConnections; got != 2 {
+		t.Fatalf("expected pool of 2, got %d", got)
+	}
+
+	for _, dsn := range []string{"?journal_mode=bogus", "?busy_timeout=5", "?max_open_conns=0"} {
+		if s, err := storage.Open("sqlite://" + filepath.Join(dir, "bad.sqlite") + dsn); err == nil {
+			s.Close()
+			t.Fatalf("expected %s rejected", dsn)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Horizons for the expiring-soon counts in Stats.
+const (
+	ExpiringSoon  = time.Hour
+	ExpiringToday = 24 * time.Hour
+)
+
+// Stats summarizes what a store holds. Like Totals, the counts include
+// expired pastes DeleteExpired has not removed yet; the expiring counts cover
+// pastes that have not expired but will within ExpiringSoon or ExpiringToday.
+type Stats struct {
+	Pastes             int64            `json:"pastes"`
+	Bytes              int64            `json:"bytes"`
+	BySyntax           map[string]int64 `json:"by_syntax"`
+	ExpiringWithinHour int64            `json:"expiring_within_hour"`
+	ExpiringWithinDay  int64            `json:"expiring_within_day"`
+}
+
+// Add counts p into st as of now.
+func (st *Stats) Add(p *Paste, now time.Time) {
+	st.Pastes++
+	st.Bytes += int64(len(p.Content))
+	if st.BySyntax == nil {
+		st.BySyntax = make(map[string]int64)
+	}
+	st.BySyntax[p.Syntax]++
+	st.AddExpiry(p.ExpiresAt, now)
+}
+
+// AddExpiry counts a paste expiring at expiresAt into the expiring-soon
+// counts, for backends that tally expiries separately from the rest.
+func (st *Stats) AddExpiry(expiresAt, now time.Time) {
+	if expiresAt.IsZero() || !expiresAt.After(now) {
+		return
+	}
+	left := expiresAt.Sub(now)
+	if left <= ExpiringSoon {
+		st.ExpiringWithinHour++
+	}
+	if left <= ExpiringToday {
+		st.ExpiringWithinDay++
+	}
+}
+
+// ScanStats computes Stats by iterating every paste in s, for backends with
+// no cheaper way to answer.
+func ScanStats(ctx context.Context, s Store, now time.Time) (Stats, error) {
+	st := Stats{BySyntax: make(map[string]int64)}
+	err := s.Iterate(ctx, func(p *Paste) error {
+		st.Add(p, now)
+		return nil
+	})
+	return st, err
+}
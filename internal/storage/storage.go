@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 )
 
@@ -11,13 +12,55 @@ var ErrNotFound = errors.New("paste not found")
 
 // Paste represents a stored paste entry.
 type Paste struct {
-	ID           string    `json:"id"`
-	Content      string    `json:"content"`
-	Syntax       string    `json:"syntax"`
-	CreatedAt    time.Time `json:"created_at"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	PasswordHash string    `json:"password_hash,omitempty"`
-	Size         int       `json:"size"`
+	ID           string            `json:"id"`
+	Title        string            `json:"title,omitempty"`
+	Content      string            `json:"content"`
+	Syntax       string            `json:"syntax"`
+	CreatedAt    time.Time         `json:"created_at"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+	PasswordHash string            `json:"password_hash,omitempty"`
+	Size         int               `json:"size"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Public       bool              `json:"public,omitempty"`
+	NoIndex      bool              `json:"noindex,omitempty"`
+	// Owner identifies the signed-in creator, e.g. "oidc:<subject>".
+	Owner string `json:"owner,omitempty"`
+	// Quarantined pastes are hidden from readers until reviewed.
+	Quarantined      bool   `json:"quarantined,omitempty"`
+	QuarantineReason string `json:"quarantine_reason,omitempty"`
+	// IPHash is a keyed hash of the creator's address, used to group pastes
+	// by origin without storing the address itself.
+	IPHash string `json:"ip_hash,omitempty"`
+	// License is an SPDX license identifier or expression, e.g. "MIT".
+	License     string `json:"license,omitempty"`
+	Attribution string `json:"attribution,omitempty"`
+	// SourceURL is where the content was copied from, for comparing the
+	// paste against its upstream later.
+	SourceURL string `json:"source_url,omitempty"`
+	// Encrypted pastes hold ciphertext made in the creator's browser with a
+	// key the server never sees; readers' browsers decrypt it.
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Sealed pastes hold content encrypted under a key derived from their
+	// password, so it cannot be read from the store without the password.
+	Sealed bool `json:"sealed,omitempty"`
+	// DeletedAt marks a soft-deleted paste, kept until the janitor purges it
+	// so an admin can still undelete it.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+	// TakenDownAt marks a paste an admin took down. Readers get a notice
+	// instead of the content, which is kept under legal hold, never
+	// expiring or deleted, until an admin purges it.
+	TakenDownAt    time.Time `json:"taken_down_at,omitzero"`
+	TakedownNotice string    `json:"takedown_notice,omitempty"`
+	// TakedownLegal answers 451 Unavailable For Legal Reasons rather than
+	// 410 Gone.
+	TakedownLegal bool `json:"takedown_legal,omitempty"`
+	// UpdatedAt is when the paste was last saved through a store stamped by
+	// StampUpdates. Incremental backups select pastes by it.
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	// InFile marks a record whose content is kept in a file beside the
+	// store. Stores opened through WithBlobs never hand such records out.
+	InFile bool `json:"in_file,omitempty"`
 }
 
 // HasExpiration reports whether the paste has an expiry set.
@@ -25,11 +68,129 @@ func (p Paste) HasExpiration() bool {
 	return !p.ExpiresAt.IsZero()
 }
 
+// Deleted reports whether the paste has been soft-deleted.
+func (p Paste) Deleted() bool {
+	return !p.DeletedAt.IsZero()
+}
+
+// TakenDown reports whether the paste has been taken down.
+func (p Paste) TakenDown() bool {
+	return !p.TakenDownAt.IsZero()
+}
+
+// ListOptions filters the pastes returned by Store.List.
+type ListOptions struct {
+	// Metadata restricts results to pastes carrying every key/value pair.
+	Metadata map[string]string
+	// Owner restricts results to pastes created by the given identity.
+	Owner string
+	// PublicOnly restricts results to publicly listed, unprotected pastes
+	// that are neither quarantined nor taken down.
+	PublicOnly bool
+	// ActiveAt, when set, excludes pastes that expired at or before it.
+	ActiveAt time.Time
+	// Syntax restricts results to a single syntax.
+	Syntax string
+	// MinSize and MaxSize bound the content size in bytes; zero disables a bound.
+	MinSize int
+	MaxSize int
+	// CreatedAfter and CreatedBefore bound the creation time; zero disables a bound.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// IPHash restricts results to pastes created from the same address.
+	IPHash string
+	// Quarantined restricts results to quarantined pastes.
+	Quarantined bool
+	// Deleted restricts results to soft-deleted pastes, which are otherwise
+	// left out.
+	Deleted bool
+	// Offset skips that many matching pastes before applying Limit.
+	Offset int
+	// Limit caps the number of results; zero means no limit.
+	Limit int
+}
+
+// Match reports whether p satisfies the filter, ignoring Offset and Limit.
+func (o ListOptions) Match(p *Paste) bool {
+	if o.Deleted != p.Deleted() {
+		return false
+	}
+	if o.Owner != "" && p.Owner != o.Owner {
+		return false
+	}
+	if o.Syntax != "" && p.Syntax != o.Syntax {
+		return false
+	}
+	if o.IPHash != "" && p.IPHash != o.IPHash {
+		return false
+	}
+	if o.Quarantined && !p.Quarantined {
+		return false
+	}
+	if (o.MinSize > 0 && p.Size < o.MinSize) || (o.MaxSize > 0 && p.Size > o.MaxSize) {
+		return false
+	}
+	if !o.CreatedAfter.IsZero() && !p.CreatedAt.After(o.CreatedAfter) {
+		return false
+	}
+	if !o.CreatedBefore.IsZero() && !p.CreatedAt.Before(o.CreatedBefore) {
+		return false
+	}
+	if o.PublicOnly && (!p.Public || p.PasswordHash != "" || p.Quarantined || p.TakenDown()) {
+		return false
+	}
+	if !o.ActiveAt.IsZero() && p.HasExpiration() && !p.ExpiresAt.After(o.ActiveAt) {
+		return false
+	}
+	for k, v := range o.Metadata {
+		if got, ok := p.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Page applies Offset and Limit to an already filtered and sorted slice.
+func (o ListOptions) Page(pastes []*Paste) []*Paste {
+	if o.Offset > 0 {
+		if o.Offset >= len(pastes) {
+			return nil
+		}
+		pastes = pastes[o.Offset:]
+	}
+	if o.Limit > 0 && len(pastes) > o.Limit {
+		pastes = pastes[:o.Limit]
+	}
+	return pastes
+}
+
+// SortNewestFirst orders pastes by creation time descending, breaking ties by ID.
+func SortNewestFirst(pastes []*Paste) {
+	sort.Slice(pastes, func(i, j int) bool {
+		if pastes[i].CreatedAt.Equal(pastes[j].CreatedAt) {
+			return pastes[i].ID < pastes[j].ID
+		}
+		return pastes[i].CreatedAt.After(pastes[j].CreatedAt)
+	})
+}
+
 // Store defines the storage backend contract.
 type Store interface {
 	Save(ctx context.Context, paste *Paste) error
 	Get(ctx context.Context, id string) (*Paste, error)
 	Delete(ctx context.Context, id string) error
 	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+	// List returns pastes matching opts, newest first.
+	List(ctx context.Context, opts ListOptions) ([]*Paste, error)
+	// Count returns the number of pastes matching opts, ignoring Offset and Limit.
+	Count(ctx context.Context, opts ListOptions) (int, error)
+	// Iterate calls fn with every stored paste, in no particular order,
+	// stopping at the first error fn returns. Expired pastes not yet removed
+	// by DeleteExpired may be included. fn must not call back into the store.
+	Iterate(ctx context.Context, fn func(*Paste) error) error
+	// Stats summarizes the stored pastes as of the current time.
+	Stats(ctx context.Context) (Stats, error)
+	// Ping checks that the store can serve reads, for readiness probes.
+	Ping(ctx context.Context) error
 	Close() error
 }
@@ -0,0 +1,689 @@
+// Package storagetest is a conformance suite for storage.Store
+// implementations. Backend tests call TestStore with a constructor for an
+// empty store, so every backend is held to the same contract.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"tiny-pastebin/internal/storage"
+)
+
+// TestStore runs the suite. newStore must return an empty store each time it
+// is called; the suite closes it. Timestamps are compared at millisecond
+// precision, the least any backend is expected to keep.
+func TestStore(t *testing.T, newStore func() storage.Store) {
+	t.Helper()
+	run := func(name string, fn func(t *testing.T, s storage.Store)) {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			t.Cleanup(func() {
+				if err := s.Close(); err != nil {
+					t.Errorf("close: %v", err)
+				}
+			})
+			fn(t, s)
+		})
+	}
+	run("CRUD", testCRUD)
+	run("Update", testUpdate)
+	run("SoftDelete", testSoftDelete)
+	run("Expiry", testExpiry)
+	run("List", testList)
+	run("Iterate", testIterate)
+	run("Unicode", testUnicode)
+	run("Concurrency", testConcurrency)
+	run("APIKeys", testAPIKeys)
+	run("Reports", testReports)
+	run("Audit", testAudit)
+	run("Totals", testTotals)
+	run("Content", testContent)
+	run("Changes", testChanges)
+	run("Expired", testExpired)
+	run("Batch", testBatch)
+	run("Stats", testStats)
+	run("Ping", testPing)
+}
+
+// now is truncated so stored timestamps compare equal after a round trip.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func mustSave(t *testing.T, s storage.Store, p *storage.Paste) {
+	t.Helper()
+	if err := s.Save(context.Background(), p); err != nil {
+		t.Fatalf("save %s: %v", p.ID, err)
+	}
+}
+
+func mustGet(t *testing.T, s storage.Store, id string) *storage.Paste {
+	t.Helper()
+	p, err := s.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get %s: %v", id, err)
+	}
+	return p
+}
+
+func expectMissing(t *testing.T, s storage.Store, id string) {
+	t.Helper()
+	if p, err := s.Get(context.Background(), id); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("get %s: expected storage.ErrNotFound, got %+v, %v", id, p, err)
+	}
+}
+
+func testCRUD(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	created := now()
+	want := &storage.Paste{
+		ID:             "crud",
+		Title:          "A title",
+		Content:        "package main\n",
+		Syntax:         "go",
+		CreatedAt:      created,
+		ExpiresAt:      created.Add(time.Hour),
+		PasswordHash:   "hash",
+		Size:           13,
+		Metadata:       map[string]string{"ticket": "T-1"},
+		Public:         true,
+		NoIndex:        true,
+		Owner:          "oidc:alice",
+		IPHash:         "iphash",
+		License:        "MIT OR Apache-2.0",
+		Attribution:    "Alice Example",
+		SourceURL:      "https://example.com/app.conf",
+		Encrypted:      true,
+		Sealed:         true,
+		TakenDownAt:    created.Add(time.Minute),
+		TakedownNotice: "Removed after a court order",
+		TakedownLegal:  true,
+	}
+	mustSave(t, s, want)
+	got := mustGet(t, s, "crud")
+	if !samePaste(got, want) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+	}
+
+	got.Content = "mutated"
+	if again := mustGet(t, s, "crud"); again.Content != want.Content {
+		t.Fatalf("mutating a returned paste changed the stored one")
+	}
+
+	expectMissing(t, s, "absent")
+	if err := s.Delete(ctx, "crud"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	expectMissing(t, s, "crud")
+	if err := s.Delete(ctx, "crud"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("second delete: expected storage.ErrNotFound, got %v", err)
+	}
+}
+
+func testUpdate(t *testing.T, s storage.Store) {
+	created := now()
+	p := &storage.Paste{ID: "upd", Content: "v1", Syntax: "plaintext", Size: 2, CreatedAt: created, ExpiresAt: created.Add(time.Hour)}
+	mustSave(t, s, p)
+	p.Content, p.Size, p.Quarantined, p.QuarantineReason = "v2", 2, true, "spam"
+	p.ExpiresAt = time.Time{}
+	mustSave(t, s, p)
+
+	got := mustGet(t, s, "upd")
+	if got.Content != "v2" || !got.Quarantined || got.QuarantineReason != "spam" || got.HasExpiration() {
+		t.Fatalf("expected the save to replace the paste, got %+v", got)
+	}
+	if n, err := s.Count(context.Background(), storage.ListOptions{}); err != nil || n != 1 {
+		t.Fatalf("expected one paste after an update, got %d (%v)", n, err)
+	}
+}
+
+func testSoftDelete(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	created := now()
+	mustSave(t, s, &storage.Paste{ID: "kept", Content: "a", Size: 1, CreatedAt: created})
+	gone := &storage.Paste{ID: "gone", Content: "b", Size: 1, CreatedAt: created, DeletedAt: created.Add(time.Minute), DeletedBy: "owner"}
+	mustSave(t, s, gone)
+
+	if got := mustGet(t, s, "gone"); !samePaste(got, gone) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, gone)
+	}
+	for _, tc := range []struct {
+		opts storage.ListOptions
+		want string
+	}{
+		{storage.ListOptions{}, "kept"},
+		{storage.ListOptions{Deleted: true}, "gone"},
+	} {
+		got, err := s.List(ctx, tc.opts)
+		if err != nil || len(got) != 1 || got[0].ID != tc.want {
+			t.Fatalf("list %+v: expected only %s, got %d pastes (%v)", tc.opts, tc.want, len(got), err)
+		}
+		if n, err := s.Count(ctx, tc.opts); err != nil || n != 1 {
+			t.Fatalf("count %+v: expected 1, got %d (%v)", tc.opts, n, err)
+		}
+	}
+
+	gone.DeletedAt, gone.DeletedBy = time.Time{}, ""
+	mustSave(t, s, gone)
+	if n, err := s.Count(ctx, storage.ListOptions{}); err != nil || n != 2 {
+		t.Fatalf("expected an undeleted paste to be listed again, got %d (%v)", n, err)
+	}
+}
+
+func testExpiry(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	at := now()
+	mustSave(t, s, &storage.Paste{ID: "lapsed", Content: "a", Size: 1, CreatedAt: at.Add(-time.Hour), ExpiresAt: at.Add(-time.Minute)})
+	mustSave(t, s, &storage.Paste{ID: "future", Content: "b", Size: 1, CreatedAt: at, ExpiresAt: at.Add(time.Hour)})
+	mustSave(t, s, &storage.Paste{ID: "forever", Content: "c", Size: 1, CreatedAt: at})
+	// Dropping an expiry must drop it from the expiry index too.
+	mustSave(t, s, &storage.Paste{ID: "extended", Content: "d", Size: 1, CreatedAt: at, ExpiresAt: at.Add(-time.Minute)})
+	mustSave(t, s, &storage.Paste{ID: "extended", Content: "d", Size: 1, CreatedAt: at})
+
+	// Backends with native TTLs may need a moment to lapse the paste.
+	time.Sleep(10 * time.Millisecond)
+	n, err := s.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("delete expired: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected one expired paste removed, got %d", n)
+	}
+	expectMissing(t, s, "lapsed")
+	for _, id := range []string{"future", "forever", "extended"} {
+		mustGet(t, s, id)
+	}
+	if n, err := s.DeleteExpired(ctx, time.Now()); err != nil || n != 0 {
+		t.Fatalf("expected nothing left to expire, got %d (%v)", n, err)
+	}
+}
+
+func testList(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	base := now().Add(-time.Hour)
+	for i := 0; i < 6; i++ {
+		p := &storage.Paste{
+			ID:        fmt.Sprintf("p%d", i),
+			Content:   fmt.Sprintf("%*s", 10*(i+1), "x"),
+			Syntax:    []string{"go", "python"}[i%2],
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			Owner:     []string{"oidc:a", "oidc:b", ""}[i%3],
+			Metadata:  map[string]string{"env": []string{"prod", "dev"}[i/3]},
+			Public:    i < 4,
+			IPHash:    "h" + fmt.Sprint(i%2),
+		}
+		p.Size = len(p.Content)
+		if i == 1 {
+			p.PasswordHash = "secret"
+		}
+		if i == 2 {
+			p.Quarantined = true
+		}
+		if i == 5 {
+			p.ExpiresAt = base.Add(90 * time.Minute)
+		}
+		mustSave(t, s, p)
+	}
+
+	cases := []struct {
+		name string
+		opts storage.ListOptions
+		want []string
+	}{
+		{"all newest first", storage.ListOptions{}, []string{"p5", "p4", "p3", "p2", "p1", "p0"}},
+		{"syntax", storage.ListOptions{Syntax: "python"}, []string{"p5", "p3", "p1"}},
+		{"owner", storage.ListOptions{Owner: "oidc:a"}, []string{"p3", "p0"}},
+		{"metadata", storage.ListOptions{Metadata: map[string]string{"env": "dev"}}, []string{"p5", "p4", "p3"}},
+		{"public only", storage.ListOptions{PublicOnly: true}, []string{"p3", "p0"}},
+		{"active", storage.ListOptions{ActiveAt: base.Add(2 * time.Hour)}, []string{"p4", "p3", "p2", "p1", "p0"}},
+		{"size", storage.ListOptions{MinSize: 20, MaxSize: 40}, []string{"p3", "p2", "p1"}},
+		{"created", storage.ListOptions{CreatedAfter: base.Add(time.Minute), CreatedBefore: base.Add(4 * time.Minute)}, []string{"p3", "p2"}},
+		{"ip hash", storage.ListOptions{IPHash: "h0"}, []string{"p4", "p2", "p0"}},
+		{"quarantined", storage.ListOptions{Quarantined: true}, []string{"p2"}},
+		{"page", storage.ListOptions{Offset: 1, Limit: 2}, []string{"p4", "p3"}},
+		{"page past end", storage.ListOptions{Offset: 10}, nil},
+	}
+	for _, tc := range cases {
+		got, err := s.List(ctx, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: list: %v", tc.name, err)
+		}
+		ids := make([]string, 0, len(got))
+		for _, p := range got {
+			ids = append(ids, p.ID)
+		}
+		if len(ids) != len(tc.want) || (len(ids) > 0 && !reflect.DeepEqual(ids, tc.want)) {
+			t.Errorf("%s: got %v, want %v", tc.name, ids, tc.want)
+		}
+
+		opts := tc.opts
+		opts.Offset, opts.Limit = 0, 0
+		all, _ := s.List(ctx, opts)
+		if n, err := s.Count(ctx, tc.opts); err != nil || n != len(all) {
+			t.Errorf("%s: count %d (%v), want %d ignoring paging", tc.name, n, err, len(all))
+		}
+	}
+}
+
+func testIterate(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	base := now()
+	want := map[string]*storage.Paste{}
+	for i := range 5 {
+		p := &storage.Paste{
+			ID:           fmt.Sprintf("it%d", i),
+			Content:      fmt.Sprintf("paste %d", i),
+			Syntax:       "plaintext",
+			CreatedAt:    base.Add(time.Duration(i) * time.Minute),
+			ExpiresAt:    base.Add(time.Hour),
+			PasswordHash: "hash",
+			Size:         7,
+			Metadata:     map[string]string{"n": fmt.Sprint(i)},
+		}
+		mustSave(t, s, p)
+		want[p.ID] = p
+	}
+
+	got := map[string]*storage.Paste{}
+	if err := s.Iterate(ctx, func(p *storage.Paste) error {
+		if _, dup := got[p.ID]; dup {
+			t.Errorf("iterate yielded %s twice", p.ID)
+		}
+		got[p.ID] = p
+		return nil
+	}); err != nil {
+		t.Fatalf("iterate: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("iterate: expected %d pastes, got %d", len(want), len(got))
+	}
+	for id, p := range want {
+		if g := got[id]; g == nil || !samePaste(g, p) {
+			t.Fatalf("iterate %s: expected %+v, got %+v", id, p, g)
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := s.Iterate(ctx, func(*storage.Paste) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("iterate: expected to stop at the first error, got %d calls, %v", calls, err)
+	}
+}
+
+func testUnicode(t *testing.T, s storage.Store) {
+	content := "héllo wörld ✓ 日本語 🚀 \x00 tab\tend\r\n"
+	title := "Ünïcödé 📋"
+	p := &storage.Paste{ID: "uni", Title: title, Content: content, Syntax: "plaintext", Size: len(content), CreatedAt: now(),
+		Metadata: map[string]string{"note": "naïve café"}}
+	mustSave(t, s, p)
+	got := mustGet(t, s, "uni")
+	if got.Content != content || got.Title != title || got.Size != len(content) || got.Metadata["note"] != "naïve café" {
+		t.Fatalf("unicode round trip mismatch: %q %q %d %v", got.Content, got.Title, got.Size, got.Metadata)
+	}
+}
+
+func testConcurrency(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	const workers, perWorker = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				p := &storage.Paste{ID: id, Content: id, Syntax: "plaintext", Size: len(id), CreatedAt: now()}
+				if err := s.Save(ctx, p); err != nil {
+					errs <- err
+					continue
+				}
+				got, err := s.Get(ctx, id)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if got.Content != id {
+					errs <- fmt.Errorf("%s read back %q", id, got.Content)
+				}
+				if i%2 == 1 {
+					if err := s.Delete(ctx, id); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n, err := s.Count(ctx, storage.ListOptions{}); err != nil || n != workers*perWorker/2 {
+		t.Fatalf("expected %d pastes after concurrent writes, got %d (%v)", workers*perWorker/2, n, err)
+	}
+}
+
+func testAPIKeys(t *testing.T, s storage.Store) {
+	keys, ok := storage.As[storage.APIKeyStore](s)
+	if !ok {
+		t.Skip("store does not implement storage.APIKeyStore")
+	}
+	ctx := context.Background()
+	created := now()
+	for _, id := range []string{"kb", "ka"} {
+		k := &storage.APIKey{ID: id, Name: "key " + id, SecretHash: "h-" + id, Scopes: []string{"paste:read", "paste:create"}, CreatedAt: created, RateLimit: 2.5, Burst: 4}
+		if err := keys.SaveAPIKey(ctx, k); err != nil {
+			t.Fatalf("save key: %v", err)
+		}
+	}
+	got, err := keys.GetAPIKey(ctx, "ka")
+	if err != nil || got.Name != "key ka" || got.SecretHash != "h-ka" || !got.HasScope("paste:create") || !got.CreatedAt.Equal(created) || got.RateLimit != 2.5 || got.Burst != 4 {
+		t.Fatalf("key round trip mismatch: %+v (%v)", got, err)
+	}
+	list, err := keys.ListAPIKeys(ctx)
+	if err != nil || len(list) != 2 || list[0].ID != "ka" || list[1].ID != "kb" {
+		t.Fatalf("expected keys ordered by id, got %v (%v)", list, err)
+	}
+	if err := keys.DeleteAPIKey(ctx, "ka"); err != nil {
+		t.Fatalf("delete key: %v", err)
+	}
+	if _, err := keys.GetAPIKey(ctx, "ka"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected deleted key missing, got %v", err)
+	}
+	if err := keys.DeleteAPIKey(ctx, "ka"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("second key delete: expected storage.ErrNotFound, got %v", err)
+	}
+}
+
+func testReports(t *testing.T, s storage.Store) {
+	reports, ok := storage.As[storage.ReportStore](s)
+	if !ok {
+		t.Skip("store does not implement storage.ReportStore")
+	}
+	ctx := context.Background()
+	created := now()
+	for i, id := range []string{"rb", "ra", "rc"} {
+		r := &storage.Report{ID: id, PasteID: "p-" + id, Reason: "spam", Details: "details " + id, IPHash: "h", CreatedAt: created.Add(time.Duration(i) * time.Second)}
+		if err := reports.SaveReport(ctx, r); err != nil {
+			t.Fatalf("save report: %v", err)
+		}
+	}
+	list, err := reports.ListReports(ctx)
+	if err != nil || len(list) != 3 || list[0].ID != "rb" || list[1].ID != "ra" || list[2].ID != "rc" {
+		t.Fatalf("expected reports oldest first, got %v (%v)", list, err)
+	}
+	if got := list[1]; got.PasteID != "p-ra" || got.Reason != "spam" || got.Details != "details ra" || got.IPHash != "h" || !got.CreatedAt.Equal(created.Add(time.Second)) {
+		t.Fatalf("report round trip mismatch: %+v", got)
+	}
+	if err := reports.DeleteReport(ctx, "ra"); err != nil {
+		t.Fatalf("delete report: %v", err)
+	}
+	if err := reports.DeleteReport(ctx, "ra"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("second report delete: expected storage.ErrNotFound, got %v", err)
+	}
+	if list, _ := reports.ListReports(ctx); len(list) != 2 {
+		t.Fatalf("expected 2 reports left, got %d", len(list))
+	}
+}
+
+func testAudit(t *testing.T, s storage.Store) {
+	audit, ok := storage.As[storage.AuditStore](s)
+	if !ok {
+		t.Skip("store does not implement storage.AuditStore")
+	}
+	ctx := context.Background()
+	base := now()
+	for i, action := range []string{"paste.created", "password.failed", "paste.created", "admin.action"} {
+		e := &storage.AuditEntry{ID: fmt.Sprintf("a%d", i), Time: base.Add(time.Duration(i) * time.Second), Action: action, PasteID: "p1", Actor: "owner", IPHash: "h", Detail: "detail", Country: "NL"}
+		if i == 3 {
+			e.PasteID = ""
+			e.Actor = "admin"
+		}
+		if err := audit.AppendAudit(ctx, e); err != nil {
+			t.Fatalf("append audit entry: %v", err)
+		}
+	}
+	list, err := audit.ListAudit(ctx, storage.AuditQuery{})
+	if err != nil || len(list) != 4 || list[0].ID != "a3" || list[3].ID != "a0" {
+		t.Fatalf("expected entries newest first, got %v (%v)", list, err)
+	}
+	if got := list[3]; got.Action != "paste.created" || got.PasteID != "p1" || got.Actor != "owner" || got.IPHash != "h" || got.Detail != "detail" || got.Country != "NL" || !got.Time.Equal(base) {
+		t.Fatalf("audit round trip mismatch: %+v", got)
+	}
+	if list, _ := audit.ListAudit(ctx, storage.AuditQuery{Action: "paste.created", Limit: 1}); len(list) != 1 || list[0].ID != "a2" {
+		t.Fatalf("expected the newest matching entry, got %v", list)
+	}
+	if list, _ := audit.ListAudit(ctx, storage.AuditQuery{PasteID: "p1", Since: base.Add(time.Second), Until: base.Add(3 * time.Second)}); len(list) != 2 || list[0].ID != "a2" || list[1].ID != "a1" {
+		t.Fatalf("expected the entries within the window, got %v", list)
+	}
+	n, err := audit.PruneAudit(ctx, base.Add(2*time.Second))
+	if err != nil || n != 2 {
+		t.Fatalf("prune: expected 2 removed, got %d (%v)", n, err)
+	}
+	if list, _ := audit.ListAudit(ctx, storage.AuditQuery{}); len(list) != 2 || list[1].ID != "a2" {
+		t.Fatalf("expected the newer entries kept, got %v", list)
+	}
+}
+
+func testTotals(t *testing.T, s storage.Store) {
+	ts, ok := storage.As[storage.TotalsStore](s)
+	if !ok {
+		t.Skip("store does not keep running totals")
+	}
+	ctx := context.Background()
+	expect := func(step string, pastes, bytes int64) {
+		t.Helper()
+		got, err := ts.Totals(ctx)
+		if err != nil {
+			t.Fatalf("%s: totals: %v", step, err)
+		}
+		if got.Pastes != pastes || got.Bytes != bytes {
+			t.Fatalf("%s: expected %d pastes / %d bytes, got %+v", step, pastes, bytes, got)
+		}
+	}
+	expect("empty", 0, 0)
+
+	base := now()
+	mustSave(t, s, &storage.Paste{ID: "ta", Content: "aaa", CreatedAt: base})
+	mustSave(t, s, &storage.Paste{ID: "tb", Content: "bb", CreatedAt: base})
+	mustSave(t, s, &storage.Paste{ID: "tc", Content: "c", CreatedAt: base, ExpiresAt: time.Now().Add(5 * time.Millisecond)})
+	expect("after saves", 3, 6)
+
+	mustSave(t, s, &storage.Paste{ID: "ta", Content: "aaaaa", CreatedAt: base})
+	expect("after overwrite", 3, 8)
+
+	if err := s.Delete(ctx, "tb"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	expect("after delete", 2, 6)
+	if err := s.Delete(ctx, "tb"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("second delete: %v", err)
+	}
+	expect("after missing delete", 2, 6)
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err := s.DeleteExpired(ctx, time.Now()); err != nil {
+		t.Fatalf("delete expired: %v", err)
+	}
+	expect("after sweep", 1, 5)
+}
+
+func testContent(t *testing.T, s storage.Store) {
+	cs, ok := storage.Streamer(s)
+	if !ok {
+		t.Skip("store does not stream content")
+	}
+	ctx := context.Background()
+	want := &storage.Paste{ID: "streamed", Title: "big", Content: "héllo\x00world", Syntax: "go", CreatedAt: now()}
+	mustSave(t, s, want)
+
+	meta, err := cs.GetMeta(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("get meta: %v", err)
+	}
+	if meta.Content != "" || meta.Size != len(want.Content) || meta.Title != want.Title || meta.Syntax != want.Syntax {
+		t.Fatalf("unexpected meta %+v", meta)
+	}
+	rc, err := cs.GetContent(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("get content: %v", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read content: %v", err)
+	}
+	if string(got) != want.Content {
+		t.Fatalf("expected content %q got %q", want.Content, got)
+	}
+
+	if _, err := cs.GetMeta(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("missing meta: %v", err)
+	}
+	if _, err := cs.GetContent(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("missing content: %v", err)
+	}
+}
+
+func testChanges(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	base := now()
+	mustSave(t, s, &storage.Paste{ID: "c-old", Content: "a", CreatedAt: base, UpdatedAt: base.Add(-time.Hour)})
+	mustSave(t, s, &storage.Paste{ID: "c-moved", Content: "b", CreatedAt: base, UpdatedAt: base.Add(-time.Hour)})
+	mustSave(t, s, &storage.Paste{ID: "c-new", Content: "c", CreatedAt: base, UpdatedAt: base.Add(time.Minute)})
+	mustSave(t, s, &storage.Paste{ID: "c-gone", Content: "d", CreatedAt: base, UpdatedAt: base.Add(time.Minute)})
+	mustSave(t, s, &storage.Paste{ID: "c-unstamped", Content: "e", CreatedAt: base})
+	mustSave(t, s, &storage.Paste{ID: "c-moved", Content: "b2", CreatedAt: base, UpdatedAt: base.Add(2 * time.Minute)})
+	if err := s.Delete(ctx, "c-gone"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	changed := map[string]string{}
+	err := storage.IterateChanged(ctx, s, base, func(p *storage.Paste) error {
+		changed[p.ID] = p.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("iterate changed: %v", err)
+	}
+	want := map[string]string{"c-new": "c", "c-moved": "b2"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("expected changes %v, got %v", want, changed)
+	}
+}
+
+func testExpired(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	base := now()
+	mustSave(t, s, &storage.Paste{ID: "x-past", Content: "gone", CreatedAt: base, ExpiresAt: base.Add(-time.Minute)})
+	mustSave(t, s, &storage.Paste{ID: "x-edge", Content: "edge", CreatedAt: base, ExpiresAt: base})
+	mustSave(t, s, &storage.Paste{ID: "x-future", Content: "later", CreatedAt: base, ExpiresAt: base.Add(time.Hour)})
+	mustSave(t, s, &storage.Paste{ID: "x-never", Content: "kept", CreatedAt: base})
+
+	expired := map[string]string{}
+	err := storage.IterateExpired(ctx, s, base, func(p *storage.Paste) error {
+		expired[p.ID] = p.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("iterate expired: %v", err)
+	}
+	want := map[string]string{"x-past": "gone", "x-edge": "edge"}
+	if !reflect.DeepEqual(expired, want) {
+		t.Fatalf("expected expired %v, got %v", want, expired)
+	}
+}
+
+func testBatch(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	base := now()
+	mustSave(t, s, &storage.Paste{ID: "b-old", Content: "before", CreatedAt: base})
+	batch := []*storage.Paste{
+		{ID: "b-one", Content: "first", CreatedAt: base},
+		{ID: "b-two", Content: "second", CreatedAt: base, ExpiresAt: base.Add(time.Hour)},
+		{ID: "b-old", Content: "after", CreatedAt: base},
+	}
+	if err := storage.SaveBatch(ctx, s, batch); err != nil {
+		t.Fatalf("save batch: %v", err)
+	}
+	for _, want := range batch {
+		got, err := s.Get(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("get %s: %v", want.ID, err)
+		}
+		if got.Content != want.Content || !got.ExpiresAt.Equal(want.ExpiresAt) {
+			t.Fatalf("expected %s saved as %q, got %+v", want.ID, want.Content, got)
+		}
+	}
+	if err := storage.SaveBatch(ctx, s, nil); err != nil {
+		t.Fatalf("save empty batch: %v", err)
+	}
+}
+
+func testStats(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	got, err := s.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if got.Pastes != 0 || got.Bytes != 0 || len(got.BySyntax) != 0 {
+		t.Fatalf("expected empty stats, got %+v", got)
+	}
+
+	base := now()
+	soon := time.Now().Add(30 * time.Minute)
+	mustSave(t, s, &storage.Paste{ID: "sa", Content: "package a", Syntax: "go", CreatedAt: base})
+	mustSave(t, s, &storage.Paste{ID: "sb", Content: "package b", Syntax: "go", CreatedAt: base})
+	mustSave(t, s, &storage.Paste{ID: "sc", Content: "hour", Syntax: "text", CreatedAt: base, ExpiresAt: soon})
+	mustSave(t, s, &storage.Paste{ID: "sd", Content: "day", Syntax: "text", CreatedAt: base, ExpiresAt: soon.Add(5 * time.Hour)})
+	mustSave(t, s, &storage.Paste{ID: "se", Content: "week", Syntax: "text", CreatedAt: base, ExpiresAt: soon.Add(72 * time.Hour)})
+
+	got, err = s.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	want := storage.Stats{
+		Pastes:             5,
+		Bytes:              int64(len("package a") + len("package b") + len("hour") + len("day") + len("week")),
+		BySyntax:           map[string]int64{"go": 2, "text": 3},
+		ExpiringWithinHour: 1,
+		ExpiringWithinDay:  2,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func testPing(t *testing.T, s storage.Store) {
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Ping(ctx); err == nil {
+		t.Fatalf("expected ping with a cancelled context to fail")
+	}
+}
+
+func samePaste(a, b *storage.Paste) bool {
+	ac, bc := *a, *b
+	if !ac.CreatedAt.Equal(bc.CreatedAt) || !ac.ExpiresAt.Equal(bc.ExpiresAt) || !ac.DeletedAt.Equal(bc.DeletedAt) || !ac.UpdatedAt.Equal(bc.UpdatedAt) || !ac.TakenDownAt.Equal(bc.TakenDownAt) {
+		return false
+	}
+	ac.CreatedAt, bc.CreatedAt, ac.ExpiresAt, bc.ExpiresAt = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	ac.DeletedAt, bc.DeletedAt, ac.UpdatedAt, bc.UpdatedAt = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	ac.TakenDownAt, bc.TakenDownAt = time.Time{}, time.Time{}
+	return reflect.DeepEqual(ac, bc)
+}
@@ -0,0 +1,31 @@
+package storage
+
+import "context"
+
+// Totals counts the pastes a store holds and the content bytes they take.
+type Totals struct {
+	Pastes int64 `json:"pastes"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// TotalsStore is implemented by stores that keep running totals as pastes are
+// saved, deleted and swept, so they can answer without scanning. Totals
+// include expired pastes DeleteExpired has not removed yet.
+type TotalsStore interface {
+	Totals(ctx context.Context) (Totals, error)
+}
+
+// CountTotals reports s's totals, from its running counters when it keeps
+// them and by iterating every paste otherwise.
+func CountTotals(ctx context.Context, s Store) (Totals, error) {
+	if ts, ok := As[TotalsStore](s); ok {
+		return ts.Totals(ctx)
+	}
+	var t Totals
+	err := s.Iterate(ctx, func(p *Paste) error {
+		t.Pastes++
+		t.Bytes += int64(len(p.Content))
+		return nil
+	})
+	return t, err
+}
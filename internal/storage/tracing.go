@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tiny-pastebin/internal/tracing"
+)
+
+// WithTracing decorates store so each call is recorded as a span under the
+// one in its context, named after the method and tagged with system, the
+// backend such as "bolt" or "sqlite". Wrap the backend itself, so the spans
+// time the database and not the layers above it. Calls whose context carries
+// no tracer are passed through untouched.
+func WithTracing(store Store, system string) Store {
+	return &tracedStore{Store: store, system: system}
+}
+
+type tracedStore struct {
+	Store
+	system string
+}
+
+func (t *tracedStore) Unwrap() Store { return t.Store }
+
+func (t *tracedStore) start(ctx context.Context, op string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, tracing.KindClient, "store."+op)
+	span.SetAttr("db.system", t.system)
+	return ctx, span
+}
+
+func (t *tracedStore) Save(ctx context.Context, paste *Paste) error {
+	ctx, span := t.start(ctx, "Save")
+	defer span.End()
+	span.SetAttr("paste.size", len(paste.Content))
+	err := t.Store.Save(ctx, paste)
+	span.Fail(err)
+	return err
+}
+
+func (t *tracedStore) SaveBatch(ctx context.Context, pastes []*Paste) error {
+	ctx, span := t.start(ctx, "SaveBatch")
+	defer span.End()
+	span.SetAttr("paste.count", len(pastes))
+	err := SaveBatch(ctx, t.Store, pastes)
+	span.Fail(err)
+	return err
+}
+
+func (t *tracedStore) Get(ctx context.Context, id string) (*Paste, error) {
+	ctx, span := t.start(ctx, "Get")
+	defer span.End()
+	p, err := t.Store.Get(ctx, id)
+	if !errors.Is(err, ErrNotFound) {
+		span.Fail(err)
+	}
+	return p, err
+}
+
+func (t *tracedStore) Delete(ctx context.Context, id string) error {
+	ctx, span := t.start(ctx, "Delete")
+	defer span.End()
+	err := t.Store.Delete(ctx, id)
+	if !errors.Is(err, ErrNotFound) {
+		span.Fail(err)
+	}
+	return err
+}
+
+func (t *tracedStore) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := t.start(ctx, "DeleteExpired")
+	defer span.End()
+	n, err := t.Store.DeleteExpired(ctx, before)
+	span.SetAttr("paste.count", n)
+	span.Fail(err)
+	return n, err
+}
+
+func (t *tracedStore) List(ctx context.Context, opts ListOptions) ([]*Paste, error) {
+	ctx, span := t.start(ctx, "List")
+	defer span.End()
+	pastes, err := t.Store.List(ctx, opts)
+	span.SetAttr("paste.count", len(pastes))
+	span.Fail(err)
+	return pastes, err
+}
+
+func (t *tracedStore) Count(ctx context.Context, opts ListOptions) (int, error) {
+	ctx, span := t.start(ctx, "Count")
+	defer span.End()
+	n, err := t.Store.Count(ctx, opts)
+	span.Fail(err)
+	return n, err
+}
+
+func (t *tracedStore) Iterate(ctx context.Context, fn func(*Paste) error) error {
+	ctx, span := t.start(ctx, "Iterate")
+	defer span.End()
+	err := t.Store.Iterate(ctx, fn)
+	span.Fail(err)
+	return err
+}
+
+func (t *tracedStore) Stats(ctx context.Context) (Stats, error) {
+	ctx, span := t.start(ctx, "Stats")
+	defer span.End()
+	st, err := t.Store.Stats(ctx)
+	span.Fail(err)
+	return st, err
+}
+
+func (t *tracedStore) Ping(ctx context.Context) error {
+	ctx, span := t.start(ctx, "Ping")
+	defer span.End()
+	err := t.Store.Ping(ctx)
+	span.Fail(err)
+	return err
+}
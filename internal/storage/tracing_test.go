@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"tiny-pastebin/internal/tracing"
+)
+
+// spanStore records the span each Get runs under.
+type spanStore struct {
+	mapStore
+	seen tracing.SpanContext
+}
+
+func (s *spanStore) Get(ctx context.Context, id string) (*Paste, error) {
+	s.seen = tracing.SpanFromContext(ctx).Context()
+	return s.mapStore.Get(ctx, id)
+}
+
+func TestWithTracing(t *testing.T) {
+	inner := &spanStore{mapStore: mapStore{pastes: map[string]Paste{}}}
+	store := WithTracing(inner, "test")
+
+	if _, err := store.Get(context.Background(), "missing"); err != ErrNotFound {
+		t.Fatalf("expected not found passed through, got %v", err)
+	}
+	if inner.seen.Valid() {
+		t.Fatal("expected no span without a tracer")
+	}
+
+	tr, err := tracing.New(tracing.Config{Endpoint: "http://127.0.0.1:1", SampleRatio: 1})
+	if err != nil {
+		t.Fatalf("tracer: %v", err)
+	}
+	defer tr.Shutdown(context.Background())
+	ctx, parent := tracing.Start(tracing.WithTracer(context.Background(), tr), tracing.KindServer, "GET /p/{id}")
+	_, _ = store.Get(ctx, "missing")
+	if !inner.seen.Valid() || inner.seen.TraceID != parent.Context().TraceID || inner.seen.SpanID == parent.Context().SpanID {
+		t.Fatalf("expected the backend called under a child span, got %+v", inner.seen)
+	}
+	if _, ok := As[*spanStore](store); !ok {
+		t.Fatal("expected the backend reachable through Unwrap")
+	}
+}
@@ -0,0 +1,356 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config configures a Tracer.
+type Config struct {
+	// Endpoint is the OTLP/HTTP collector, such as http://localhost:4318.
+	// /v1/traces is appended when it has no path.
+	Endpoint string
+	// Headers are sent with every export, for collector authentication.
+	Headers map[string]string
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+	// SampleRatio is the fraction of new traces recorded, between 0 and 1.
+	// Traces continued from a remote parent follow its decision.
+	SampleRatio float64
+	// Interval is how often finished spans are exported; zero means five
+	// seconds. Spans are also exported once a batch is full.
+	Interval time.Duration
+	// Client defaults to one with a 10 second timeout.
+	Client *http.Client
+	Logger *slog.Logger
+}
+
+const (
+	batchSize = 512
+	// queueSize bounds the spans waiting for export; more are dropped
+	// rather than slowing requests down when the collector is away.
+	queueSize = 4096
+)
+
+// Tracer exports the spans started under it. It is safe for concurrent use.
+type Tracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+	ratio    float64
+	client   *http.Client
+	logger   *slog.Logger
+
+	queue   chan spanData
+	flushCh chan chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// spanData is a finished span, as exported.
+type spanData struct {
+	sc     SpanContext
+	parent [8]byte
+	kind   Kind
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  []attribute
+	failed bool
+	errMsg string
+}
+
+// New returns a Tracer exporting to cfg.Endpoint in the background until
+// Shutdown.
+func New(cfg Config) (*Tracer, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("tracing: endpoint %q is not an http(s) URL", cfg.Endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing: sample ratio %v is not between 0 and 1", cfg.SampleRatio)
+	}
+	t := &Tracer{
+		endpoint: u.String(),
+		headers:  cfg.Headers,
+		service:  cfg.ServiceName,
+		ratio:    cfg.SampleRatio,
+		client:   cfg.Client,
+		logger:   cfg.Logger,
+		queue:    make(chan spanData, queueSize),
+		flushCh:  make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if t.service == "" {
+		t.service = "tinypaste"
+	}
+	if t.client == nil {
+		t.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	go t.run(interval)
+	return t, nil
+}
+
+func (t *Tracer) export(s *Span, end time.Time) {
+	s.mu.Lock()
+	d := spanData{
+		sc:     s.sc,
+		parent: s.parent,
+		kind:   s.kind,
+		name:   s.name,
+		start:  s.start,
+		end:    end,
+		attrs:  s.attrs,
+		failed: s.failed,
+		errMsg: s.errMsg,
+	}
+	s.mu.Unlock()
+	select {
+	case t.queue <- d:
+	default:
+		t.mu.Lock()
+		t.dropped++
+		t.mu.Unlock()
+	}
+}
+
+// Flush exports the spans ended so far and waits for it, or for ctx.
+func (t *Tracer) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case t.flushCh <- ack:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown exports the remaining spans and stops the exporter. Spans ended
+// afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.once.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracer) run(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var batch []spanData
+	send := func() {
+		// Whatever is queued goes along, in batches of batchSize.
+		for {
+			for len(batch) < batchSize {
+				select {
+				case d := <-t.queue:
+					batch = append(batch, d)
+					continue
+				default:
+				}
+				break
+			}
+			if len(batch) == 0 {
+				return
+			}
+			t.post(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case d := <-t.queue:
+			batch = append(batch, d)
+			if len(batch) >= batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-t.flushCh:
+			send()
+			close(ack)
+		case <-t.stop:
+			send()
+			return
+		}
+	}
+}
+
+// post sends batch to the collector. Failed batches are logged and
+// dropped; traces are not worth piling up memory for.
+func (t *Tracer) post(batch []spanData) {
+	t.mu.Lock()
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+	if dropped > 0 && t.logger != nil {
+		t.logger.Warn("trace export queue full", "dropped_spans", dropped)
+	}
+
+	body, err := json.Marshal(t.encode(batch))
+	if err != nil {
+		t.logError(err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		t.logError(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.logError(err)
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		t.logError(errors.New("collector answered " + resp.Status))
+	}
+}
+
+func (t *Tracer) logError(err error) {
+	if t.logger != nil {
+		t.logger.Warn("trace export failed", "error", err)
+	}
+}
+
+// The OTLP JSON encoding of ExportTraceServiceRequest. IDs are hex
+// strings and 64 bit integers decimal strings, as OTLP/JSON requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		String *string  `json:"stringValue,omitempty"`
+		Bool   *bool    `json:"boolValue,omitempty"`
+		Int    *string  `json:"intValue,omitempty"`
+		Double *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// statusError is the OTLP STATUS_CODE_ERROR.
+const statusError = 2
+
+func (t *Tracer) encode(batch []spanData) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, d := range batch {
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(d.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(d.sc.SpanID[:]),
+			Name:              d.name,
+			Kind:              d.kind,
+			StartTimeUnixNano: strconv.FormatInt(d.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(d.end.UnixNano(), 10),
+		}
+		if d.parent != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(d.parent[:])
+		}
+		for _, a := range d.attrs {
+			s.Attributes = append(s.Attributes, keyValue(a.key, a.value))
+		}
+		if d.failed {
+			s.Status = &otlpStatus{Code: statusError, Message: d.errMsg}
+		}
+		spans = append(spans, s)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{keyValue("service.name", t.service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "tiny-pastebin"}, Spans: spans}},
+	}}}
+}
+
+func keyValue(key string, v any) otlpKeyValue {
+	var val otlpValue
+	switch v := v.(type) {
+	case string:
+		val.String = &v
+	case bool:
+		val.Bool = &v
+	case int:
+		s := strconv.Itoa(v)
+		val.Int = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		val.Int = &s
+	case float64:
+		val.Double = &v
+	default:
+		s := fmt.Sprint(v)
+		val.String = &s
+	}
+	return otlpKeyValue{Key: key, Value: val}
+}
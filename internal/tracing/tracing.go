@@ -0,0 +1,264 @@
+// Package tracing records spans in the OpenTelemetry model, propagates them
+// in contexts and W3C traceparent headers, and exports them to a collector
+// over OTLP/HTTP with JSON encoding.
+//
+// Only what tinypaste needs is implemented: no metrics, no span events or
+// links, and a parent-based ratio sampler.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the OpenTelemetry span kind.
+type Kind int
+
+// Span kinds, numbered as in OTLP.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// Valid reports whether sc has non-zero IDs.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Span is an operation in progress. A nil *Span is valid and records
+// nothing, so callers need not check whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent [8]byte
+	kind   Kind
+	start  time.Time
+
+	mu     sync.Mutex
+	name   string
+	attrs  []attribute
+	errMsg string
+	failed bool
+	ended  bool
+}
+
+type attribute struct {
+	key   string
+	value any
+}
+
+// SetName replaces the name the span was started with, for names only
+// known once the operation has run, such as an HTTP route.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttr records an attribute. Values are strings, bools, integers or
+// floats; anything else is recorded as its string form.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attribute{key, value})
+	s.mu.Unlock()
+}
+
+// Fail marks the span as failed with err. A nil err is ignored, so it can
+// be called with the result of the traced operation.
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.failed = true
+	s.errMsg = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and hands it to the exporter. Later calls do
+// nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.mu.Unlock()
+	if s.sc.Sampled {
+		s.tracer.export(s, time.Now())
+	}
+}
+
+// Context returns the identity of the span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+type (
+	tracerKey struct{}
+	spanKey   struct{}
+	remoteKey struct{}
+)
+
+// WithTracer returns a context in which Start records spans with t.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// Start begins a span named name as a child of the span in ctx, or of the
+// remote parent Extract stored there. It returns a nil span when ctx
+// carries no tracer.
+func Start(ctx context.Context, kind Kind, name string) (context.Context, *Span) {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, kind: kind, name: name, start: time.Now()}
+	parent := SpanFromContext(ctx).Context()
+	if !parent.Valid() {
+		parent, _ = ctx.Value(remoteKey{}).(SpanContext)
+	}
+	if parent.Valid() {
+		s.sc.TraceID = parent.TraceID
+		s.sc.Sampled = parent.Sampled
+		s.parent = parent.SpanID
+	} else {
+		s.sc.TraceID = newTraceID()
+		s.sc.Sampled = t.sample(s.sc.TraceID)
+	}
+	s.sc.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SpanFromContext returns the span started in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// TraceID returns the hex ID of the trace in ctx, or "" when none is being
+// recorded, for correlating logs with traces.
+func TraceID(ctx context.Context) string {
+	sc := SpanFromContext(ctx).Context()
+	if !sc.Sampled {
+		return ""
+	}
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+const traceparentHeader = "traceparent"
+
+// Extract returns ctx carrying the remote parent in the traceparent header
+// of h, if there is a valid one.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, ok := parseTraceparent(h.Get(traceparentHeader))
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject sets the traceparent header of h to the span in ctx, so the
+// receiving service continues the trace.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanFromContext(ctx).Context()
+	if !sc.Valid() {
+		return
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	h.Set(traceparentHeader, "00-"+hex.EncodeToString(sc.TraceID[:])+"-"+hex.EncodeToString(sc.SpanID[:])+"-"+flags)
+}
+
+// parseTraceparent parses a version 00 traceparent header. Later versions
+// are read as 00, as the specification asks.
+func parseTraceparent(v string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if strings.ToLower(v) != v {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.Valid()
+}
+
+func newTraceID() [16]byte {
+	var id [16]byte
+	for id == [16]byte{} {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(err)
+		}
+	}
+	return id
+}
+
+func newSpanID() [8]byte {
+	var id [8]byte
+	for id == [8]byte{} {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(err)
+		}
+	}
+	return id
+}
+
+// sample decides whether a new trace is recorded. The decision is derived
+// from the trace ID, so it is consistent for a trace.
+func (t *Tracer) sample(id [16]byte) bool {
+	switch {
+	case t.ratio >= 1:
+		return true
+	case t.ratio <= 0:
+		return false
+	}
+	v := binary.BigEndian.Uint64(id[8:]) >> 1
+	return float64(v) < t.ratio*float64(1<<63)
+}
@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTraceparent(t *testing.T) {
+	h := http.Header{}
+	h.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tr := &Tracer{ratio: 0}
+	ctx := WithTracer(Extract(context.Background(), h), tr)
+	ctx, span := Start(ctx, KindServer, "GET /")
+	sc := span.Context()
+	if !sc.Sampled || TraceID(ctx) != "4bf92f3577b34da6a3ce929d0e0e4736" || span.parent != [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7} {
+		t.Fatalf("expected the remote trace continued, got %+v", sc)
+	}
+
+	out := http.Header{}
+	Inject(ctx, out)
+	back, ok := parseTraceparent(out.Get("traceparent"))
+	if !ok || back != sc {
+		t.Fatalf("expected an injected header to round trip, got %q", out.Get("traceparent"))
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		if _, ok := parseTraceparent(bad); ok {
+			t.Errorf("expected %q refused", bad)
+		}
+	}
+}
+
+func TestStartWithoutTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), KindInternal, "noop")
+	if span != nil || ctx != context.Background() {
+		t.Fatal("expected no span without a tracer")
+	}
+	// Nil spans accept every call.
+	span.SetAttr("k", "v")
+	span.Fail(errors.New("boom"))
+	span.SetName("x")
+	span.End()
+}
+
+func TestExport(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got otlpRequest
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected export %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got.ResourceSpans = append(got.ResourceSpans, req.ResourceSpans...)
+	}))
+	defer collector.Close()
+
+	tr, err := New(Config{Endpoint: collector.URL, Headers: map[string]string{"Authorization": "Bearer secret"}, SampleRatio: 1, Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx, root := Start(WithTracer(context.Background(), tr), KindServer, "GET /p/{id}")
+	root.SetAttr("http.response.status_code", 200)
+	_, child := Start(ctx, KindClient, "store.Get")
+	child.Fail(errors.New("not found"))
+	child.End()
+	root.End()
+	root.End()
+
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got.ResourceSpans) != 1 || got.ResourceSpans[0].Resource.Attributes[0].Value.String == nil || *got.ResourceSpans[0].Resource.Attributes[0].Value.String != "tinypaste" {
+		t.Fatalf("unexpected export %+v", got)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected both spans exported once, got %d", len(spans))
+	}
+	store, server := spans[0], spans[1]
+	if store.TraceID != server.TraceID || store.ParentSpanID != server.SpanID || server.ParentSpanID != "" {
+		t.Fatalf("expected the store span under the request span, got %+v and %+v", store, server)
+	}
+	if store.Kind != KindClient || store.Status == nil || store.Status.Code != statusError || store.Status.Message != "not found" {
+		t.Fatalf("unexpected store span %+v", store)
+	}
+	if len(server.Attributes) != 1 || server.Attributes[0].Value.Int == nil || *server.Attributes[0].Value.Int != "200" {
+		t.Fatalf("unexpected attributes %+v", server.Attributes)
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	if _, err := New(Config{Endpoint: "http://localhost:4318", SampleRatio: 2}); err == nil {
+		t.Fatal("expected a ratio above 1 refused")
+	}
+	if _, err := New(Config{Endpoint: "localhost:4318"}); err == nil {
+		t.Fatal("expected an endpoint without scheme refused")
+	}
+	tr := &Tracer{ratio: 0.5}
+	sampled := 0
+	for range 1000 {
+		if tr.sample(newTraceID()) {
+			sampled++
+		}
+	}
+	if sampled < 400 || sampled > 600 {
+		t.Fatalf("expected about half the traces sampled, got %d", sampled)
+	}
+	ctx := WithTracer(context.Background(), &Tracer{ratio: 0})
+	ctx, span := Start(ctx, KindServer, "GET /")
+	if span.Context().Sampled || TraceID(ctx) != "" {
+		t.Fatal("expected unsampled traces not recorded")
+	}
+	if _, child := Start(ctx, KindClient, "store.Get"); child.Context().TraceID != span.Context().TraceID || child.Context().Sampled {
+		t.Fatal("expected children to follow the parent's decision")
+	}
+}
@@ -0,0 +1,189 @@
+// Package client is a Go SDK for the tiny-pastebin JSON API. It follows the
+// OpenAPI document served at /api/v1/openapi.json; keep the two in sync.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNotFound matches errors for pastes that do not exist or have expired.
+var ErrNotFound = errors.New("paste not found")
+
+// Client talks to one tiny-pastebin instance.
+type Client struct {
+	// BaseURL is the instance root, e.g. https://paste.example.com.
+	BaseURL string
+	// Token is sent as a bearer token: an API key or the admin token. Optional.
+	Token string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the instance at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: baseURL}
+}
+
+// CreateRequest describes a new paste.
+type CreateRequest struct {
+	Title   string `json:"title,omitempty"`
+	Content string `json:"content"`
+	Syntax  string `json:"syntax,omitempty"`
+	// Expire is one of the expiry values the instance advertises, e.g. "1h".
+	Expire string `json:"expire,omitempty"`
+	// Password protects the paste; readers must pass it to Get.
+	Password string            `json:"password,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Public   bool              `json:"public,omitempty"`
+	NoIndex  bool              `json:"noindex,omitempty"`
+	// License is an SPDX identifier or expression, e.g. "MIT".
+	License     string `json:"license,omitempty"`
+	Attribution string `json:"attribution,omitempty"`
+	// SourceURL records where the content came from.
+	SourceURL string `json:"source_url,omitempty"`
+}
+
+// Paste is a paste as returned by the API. Content is empty for Create.
+type Paste struct {
+	ID          string            `json:"id"`
+	Title       string            `json:"title,omitempty"`
+	URL         string            `json:"url"`
+	Syntax      string            `json:"syntax"`
+	Size        int               `json:"size"`
+	CreatedAt   time.Time         `json:"created_at"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Protected   bool              `json:"protected"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	License     string            `json:"license,omitempty"`
+	Attribution string            `json:"attribution,omitempty"`
+	SourceURL   string            `json:"source_url,omitempty"`
+	Content     string            `json:"content,omitempty"`
+	// ClaimToken is set by Create for anonymous callers; pass it to Claim
+	// once signed in to take ownership of the paste.
+	ClaimToken string `json:"claim_token,omitempty"`
+}
+
+// Error is returned for non-success responses.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("tinypaste: %d %s", e.StatusCode, e.Message)
+}
+
+// Is lets errors.Is(err, ErrNotFound) match 404 responses.
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Create uploads a paste and returns its summary.
+func (c *Client) Create(ctx context.Context, req CreateRequest) (*Paste, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var out Paste
+	if err := c.do(ctx, http.MethodPost, "/pastes", bytes.NewReader(body), nil, http.StatusCreated, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Get fetches a paste with its content. password may be empty for
+// unprotected pastes.
+func (c *Client) Get(ctx context.Context, id, password string) (*Paste, error) {
+	header := http.Header{}
+	if password != "" {
+		header.Set("X-Paste-Password", password)
+	}
+	var out Paste
+	if err := c.do(ctx, http.MethodGet, "/pastes/"+url.PathEscape(id), nil, header, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Delete removes a paste owned by the client's API key, or any paste when
+// using the admin token.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/pastes/"+url.PathEscape(id), nil, nil, http.StatusNoContent, nil)
+}
+
+// Claim moves an anonymously created paste into the account behind the
+// client's credentials. Each token can be redeemed once.
+func (c *Client) Claim(ctx context.Context, id, claimToken string) (*Paste, error) {
+	body, err := json.Marshal(map[string]string{"claim_token": claimToken})
+	if err != nil {
+		return nil, err
+	}
+	var out Paste
+	if err := c.do(ctx, http.MethodPost, "/pastes/"+url.PathEscape(id)+"/claim", bytes.NewReader(body), nil, http.StatusOK, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Exists reports whether a paste can currently be read.
+func (c *Client) Exists(ctx context.Context, id string) (bool, error) {
+	err := c.do(ctx, http.MethodHead, "/pastes/"+url.PathEscape(id)+"/exists", nil, nil, http.StatusOK, nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, header http.Header, want int, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+"/api/v1"+path, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != want {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = http.StatusText(res.StatusCode)
+		}
+		return &Error{StatusCode: res.StatusCode, Message: apiErr.Error}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
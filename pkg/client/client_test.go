@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tiny-pastebin/internal/httpserver"
+	"tiny-pastebin/internal/id"
+	"tiny-pastebin/internal/storage/boltstore"
+	"tiny-pastebin/web"
+)
+
+func TestClientRoundTrip(t *testing.T) {
+	store, err := boltstore.Open(filepath.Join(t.TempDir(), "client.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	srv, err := httpserver.New(httpserver.Config{Store: store, IDGenerator: id.New(12), MaxBytes: 4096, AdminToken: "admin"})
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	ctx := context.Background()
+
+	c := New(ts.URL)
+	created, err := c.Create(ctx, CreateRequest{Content: "secret notes", Syntax: "plaintext", Password: "hunter2", Metadata: map[string]string{"ticket": "7"}})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !created.Protected || created.ID == "" {
+		t.Fatalf("unexpected created paste %+v", created)
+	}
+
+	var apiErr *Error
+	if _, err := c.Get(ctx, created.ID, ""); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected password required, got %v", err)
+	}
+	got, err := c.Get(ctx, created.ID, "hunter2")
+	if err != nil || got.Content != "secret notes" || got.Metadata["ticket"] != "7" {
+		t.Fatalf("get: %+v (%v)", got, err)
+	}
+	if ok, err := c.Exists(ctx, created.ID); err != nil || !ok {
+		t.Fatalf("exists: %v %v", ok, err)
+	}
+
+	// Anonymous pastes have no owner, so only the admin token can delete them.
+	if err := c.Delete(ctx, created.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected anonymous delete refused, got %v", err)
+	}
+	admin := &Client{BaseURL: ts.URL + "/", Token: "admin", HTTPClient: ts.Client()}
+	if err := admin.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("admin delete: %v", err)
+	}
+	if _, err := c.Get(ctx, created.ID, "hunter2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	if ok, err := c.Exists(ctx, created.ID); err != nil || ok {
+		t.Fatalf("expected missing paste, got %v %v", ok, err)
+	}
+}
+
+// TestTypesMatchOpenAPI keeps the SDK's field names in step with the schema.
+func TestTypesMatchOpenAPI(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+				AllOf      []struct {
+					Properties map[string]json.RawMessage `json:"properties"`
+				} `json:"allOf"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(web.OpenAPI, &doc); err != nil {
+		t.Fatalf("decode openapi: %v", err)
+	}
+	schemas := doc.Components.Schemas
+
+	properties := func(names ...string) map[string]bool {
+		out := make(map[string]bool)
+		for _, name := range names {
+			s := schemas[name]
+			for p := range s.Properties {
+				out[p] = true
+			}
+			for _, part := range s.AllOf {
+				for p := range part.Properties {
+					out[p] = true
+				}
+			}
+		}
+		return out
+	}
+	check := func(v any, props map[string]bool) {
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			if !props[name] {
+				t.Errorf("%s.%s (%q) is not in the OpenAPI schema", typ.Name(), typ.Field(i).Name, name)
+			}
+		}
+	}
+	check(CreateRequest{}, properties("CreatePasteRequest"))
+	check(Paste{}, properties("PasteSummary", "Paste", "CreatedPaste"))
+}
@@ -7,3 +7,8 @@ var Templates embed.FS
 
 //go:embed static/*
 var Static embed.FS
+
+// OpenAPI describes the JSON API served under /api/v1.
+//
+//go:embed openapi.json
+var OpenAPI []byte
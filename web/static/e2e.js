@@ -0,0 +1,66 @@
+// End-to-end encryption for pastes. Content is sealed with AES-GCM under a
+// random key that only travels in the URL fragment, which browsers never send
+// to the server; the server stores base64(iv || ciphertext).
+(function () {
+  'use strict';
+
+  const IV_BYTES = 12;
+
+  function toBase64(bytes) {
+    let bin = '';
+    for (const b of bytes) {
+      bin += String.fromCharCode(b);
+    }
+    return btoa(bin);
+  }
+
+  function fromBase64(text) {
+    const bin = atob(text);
+    const out = new Uint8Array(bin.length);
+    for (let i = 0; i < bin.length; i++) {
+      out[i] = bin.charCodeAt(i);
+    }
+    return out;
+  }
+
+  function toBase64URL(bytes) {
+    return toBase64(bytes).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+  }
+
+  function fromBase64URL(text) {
+    text = text.replace(/-/g, '+').replace(/_/g, '/');
+    while (text.length % 4) {
+      text += '=';
+    }
+    return fromBase64(text);
+  }
+
+  function importKey(raw, usage) {
+    return crypto.subtle.importKey('raw', raw, 'AES-GCM', false, [usage]);
+  }
+
+  window.e2e = {
+    // WebCrypto is only offered to secure (HTTPS or localhost) pages.
+    available: !!(window.crypto && crypto.subtle),
+
+    // encrypt returns the ciphertext to store and the key for the fragment.
+    async encrypt(text) {
+      const raw = crypto.getRandomValues(new Uint8Array(32));
+      const iv = crypto.getRandomValues(new Uint8Array(IV_BYTES));
+      const key = await importKey(raw, 'encrypt');
+      const sealed = new Uint8Array(await crypto.subtle.encrypt({ name: 'AES-GCM', iv: iv }, key, new TextEncoder().encode(text)));
+      const envelope = new Uint8Array(iv.length + sealed.length);
+      envelope.set(iv);
+      envelope.set(sealed, iv.length);
+      return { content: toBase64(envelope), key: toBase64URL(raw) };
+    },
+
+    // decrypt rejects when the key is wrong or the ciphertext was altered.
+    async decrypt(content, keyText) {
+      const envelope = fromBase64(content);
+      const key = await importKey(fromBase64URL(keyText), 'decrypt');
+      const plain = await crypto.subtle.decrypt({ name: 'AES-GCM', iv: envelope.slice(0, IV_BYTES) }, key, envelope.slice(IV_BYTES));
+      return new TextDecoder().decode(plain);
+    }
+  };
+})();